@@ -11,6 +11,7 @@ import (
 	"github.com/gobuffalo/buffalo/middleware/csrf"
 	"github.com/gobuffalo/buffalo/middleware/i18n"
 	"github.com/gobuffalo/packr"
+	"github.com/markbates/goth/gothic"
 )
 
 // ENV is used to help switch settings based on where the
@@ -41,6 +42,8 @@ func App() *buffalo.App {
 		app.Use(middleware.PopTransaction(models.DB))
 		app.Use(SetCurrentUser)
 		app.Use(SetCurrentHost)
+		app.Use(RequireTwoFactor)
+		app.Use(SetClarificationCounts)
 
 		// Wraps each request in a transaction.
 		//  c.Value("tx").(*pop.PopTransaction)
@@ -56,17 +59,42 @@ func App() *buffalo.App {
 		userAuth.GET("/register", UsersRegisterGet)
 		userAuth.POST("/register", UsersRegisterPost)
 		userAuth.GET("/login", UsersLoginGet)
-		userAuth.POST("/login", UsersLoginPost)
+		userAuth.POST("/login", RateLimitByIP(loginLimiter)(UsersLoginPost))
 		userAuth.GET("/logout", UsersLogout)
+		userAuth.GET("/verify/{token}", UsersVerify)
+		userAuth.GET("/forgot_password", UsersForgotPasswordGet)
+		userAuth.POST("/forgot_password", UsersForgotPasswordPost)
+		userAuth.GET("/reset_password/{token}", UsersResetPasswordGet)
+		userAuth.POST("/reset_password/{token}", UsersResetPasswordPost)
+		userAuth.GET("/two_factor", UsersTwoFactorGet)
+		userAuth.POST("/two_factor", RateLimitByIP(loginLimiter)(UsersTwoFactorPost))
+		userAuth.GET("/two_factor/setup", UsersTwoFactorSetupGet)
+		userAuth.POST("/two_factor/setup", UsersTwoFactorSetupPost)
+		userAuth.GET("/profile/{username}", UsersProfile)
+		userAuth.GET("/edit_profile", UserRequired(UsersEditProfileGet))
+		userAuth.POST("/edit_profile", UserRequired(UsersEditProfilePost))
+
+		auth := app.Group("/auth")
+		auth.GET("/{provider}", buffalo.WrapHandlerFunc(gothic.BeginAuthHandler))
+		auth.GET("/{provider}/callback", AuthCallback)
 
 		hostAuth := app.Group("/hosts")
 		hostAuth.GET("/", HostHomePage)
 		hostAuth.GET("/register", HostsRegisterGet)
 		hostAuth.POST("/register", HostsRegisterPost)
 		hostAuth.GET("/login", HostsLoginGet)
-		hostAuth.POST("/login", HostsLoginPost)
+		hostAuth.POST("/login", RateLimitByIP(loginLimiter)(HostsLoginPost))
 		hostAuth.GET("/logout", HostsLogout)
 		hostAuth.GET("/dashboard", HostsDashboard)
+		hostAuth.GET("/verify/{token}", HostsVerify)
+		hostAuth.GET("/forgot_password", HostsForgotPasswordGet)
+		hostAuth.POST("/forgot_password", HostsForgotPasswordPost)
+		hostAuth.GET("/reset_password/{token}", HostsResetPasswordGet)
+		hostAuth.POST("/reset_password/{token}", HostsResetPasswordPost)
+		hostAuth.GET("/two_factor", HostsTwoFactorGet)
+		hostAuth.POST("/two_factor", RateLimitByIP(loginLimiter)(HostsTwoFactorPost))
+		hostAuth.GET("/two_factor/setup", HostRequired(HostsTwoFactorSetupGet))
+		hostAuth.POST("/two_factor/setup", HostRequired(HostsTwoFactorSetupPost))
 
 		contestGroup := app.Group("/contests")
 		contestGroup.GET("/user_index", ContestsUserIndex)
@@ -77,22 +105,76 @@ func App() *buffalo.App {
 		contestGroup.GET("/edit/{cid}", HostRequired(ContestsEditGet))
 		contestGroup.POST("/edit/{cid}", HostRequired(ContestsEditPost))
 		contestGroup.GET("/delete/{cid}", HostRequired(ContestsDelete))
+		contestGroup.POST("/ratings/{cid}", HostRequired(ContestsApplyRatings))
+		contestGroup.POST("/virtual/{cid}", UserRequired(ContestsStartVirtual))
+		contestGroup.POST("/rejudge/{cid}", HostRequired(ContestsRejudge))
+		contestGroup.GET("/export/{cid}", HostRequired(ContestsExport))
+		contestGroup.GET("/problemset/{cid}", HostRequired(ContestsProblemSet))
+		contestGroup.POST("/problemset/{cid}", HostRequired(ContestsProblemSetBuild))
+		contestGroup.POST("/import", HostRequired(ContestsImport))
 
 		questionGroup := app.Group("/questions")
-		//questionGroup.GET("/index", QuestionsIndex)
+		questionGroup.GET("/index", QuestionsIndex)
 		questionGroup.GET("/create/{cid}", HostRequired(QuestionsCreateGet))
 		questionGroup.POST("/create/{cid}", HostRequired(QuestionsCreatePost))
+		questionGroup.POST("/import/{cid}", HostRequired(QuestionsImport))
 		questionGroup.GET("/detail/{qid}", QuestionsDetail)
 		questionGroup.GET("/edit/{qid}", HostRequired(QuestionsEditGet))
 		questionGroup.POST("/edit/{qid}", HostRequired(QuestionsEditPost))
 		questionGroup.GET("/delete/{qid}", HostRequired(QuestionsDelete))
+		questionGroup.POST("/rejudge/{qid}", HostRequired(QuestionsRejudge))
+		questionGroup.POST("/solutions/{qid}", HostRequired(QuestionsSolutionsCreate))
+		questionGroup.POST("/solutions/run/{qid}", HostRequired(QuestionsSolutionsRun))
+		questionGroup.POST("/solutions/delete/{rsid}", HostRequired(QuestionsSolutionsDelete))
+		questionGroup.POST("/answers/{qid}", HostRequired(QuestionsAnswers))
+		questionGroup.POST("/generators/{qid}", HostRequired(QuestionsGeneratorsCreate))
+		questionGroup.POST("/generators/delete/{gid}", HostRequired(QuestionsGeneratorsDelete))
+		questionGroup.POST("/generate/{qid}", HostRequired(QuestionsGenerate))
+		questionGroup.POST("/attachments/{qid}", HostRequired(QuestionsAttachmentsCreate))
+		questionGroup.POST("/attachments/delete/{aid}", HostRequired(QuestionsAttachmentsDelete))
+		questionGroup.GET("/files/{qid}/{name}", QuestionsFiles)
+		questionGroup.GET("/editorial/{qid}", QuestionsEditorial)
+		questionGroup.POST("/editorial/{qid}", HostRequired(QuestionsEditorialSave))
+		questionGroup.POST("/editorial/delete/{qid}", HostRequired(QuestionsEditorialDelete))
+		questionGroup.POST("/editorial/comments/{qid}", UserRequired(QuestionsEditorialCommentsCreate))
+		questionGroup.POST("/editorial/comments/delete/{ecid}", HostRequired(QuestionsEditorialCommentsDelete))
+
+		tagGroup := app.Group("/tags")
+		tagGroup.GET("/index", HostRequired(TagsIndex))
+		tagGroup.POST("/create", HostRequired(TagsCreate))
+		tagGroup.POST("/rename", HostRequired(TagsRename))
+		tagGroup.POST("/delete", HostRequired(TagsDelete))
+
+		clarificationGroup := app.Group("/clarifications")
+		clarificationGroup.GET("/index", ClarificationsIndex)
+		clarificationGroup.GET("/contest/{cid}", ClarificationsContest)
+		clarificationGroup.POST("/create/{cid}", UserRequired(ClarificationsCreate))
+		clarificationGroup.POST("/answer/{clid}", HostRequired(ClarificationsAnswer))
+		clarificationGroup.POST("/announce/{cid}", HostRequired(ClarificationsAnnounce))
 
 		submissionGroup := app.Group("/submissions")
 		submissionGroup.GET("/index", SubmissionsIndex)
 		submissionGroup.GET("/create/{cid}/{qid}", SubmissionsCreateGet)
-		submissionGroup.POST("/create/{cid}/{qid}", SubmissionsCreatePost)
+		submissionGroup.POST("/create/{cid}/{qid}", RateLimitByUser(submissionLimiter)(SubmissionsCreatePost))
+		submissionGroup.POST("/run/{qid}", UserRequired(RateLimitByUser(runLimiter)(SubmissionsRun)))
 		submissionGroup.GET("/detail/{sid}", SubmissionsDetail)
+		submissionGroup.POST("/rejudge/{sid}", HostRequired(SubmissionsRejudge))
+		submissionGroup.GET("/source/{sid}", SubmissionsSource)
+		submissionGroup.GET("/diff/{sid}", SubmissionsDiff)
 		app.GET("/leaderboard/display/{cid}", LeaderboardDisplay)
+		app.GET("/judge/workers", AdminRequired(JudgeWorkersIndex))
+
+		// The judge worker API authenticates with JUDGE_TOKEN instead of
+		// sessions, so it is exempt from CSRF protection.
+		judgeGroup := app.Group("/judge")
+		judgeGroup.Use(WorkerTokenRequired)
+		judgeGroup.Middleware.Skip(csrf.New, JudgeWorkersRegister, JudgeWorkersHeartbeat, JudgeWorkersPull, JudgeJobsProgress, JudgeJobsFinish)
+		judgeGroup.POST("/workers/register", JudgeWorkersRegister)
+		judgeGroup.POST("/workers/heartbeat/{wid}", JudgeWorkersHeartbeat)
+		judgeGroup.POST("/workers/pull/{wid}", JudgeWorkersPull)
+		judgeGroup.POST("/jobs/progress/{wid}/{jid}", JudgeJobsProgress)
+		judgeGroup.POST("/jobs/finish/{wid}/{jid}", JudgeJobsFinish)
+		judgeGroup.GET("/testdata/{qid}/{checksum}", JudgeTestData)
 		app.ServeFiles("/", assetsBox) // serve files from the public directory
 	}
 
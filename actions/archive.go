@@ -0,0 +1,90 @@
+package actions
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/cpjudge/cpjudge/judge"
+	"github.com/cpjudge/cpjudge/models"
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop"
+	"github.com/pkg/errors"
+)
+
+// maxArchiveSize is the largest contest archive that can be imported.
+const maxArchiveSize = 1 << 30
+
+// ContestsExport downloads a contest as an archive. Params "submissions"
+// and "standings" add the submissions and the standings.
+func ContestsExport(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	host := c.Value("current_host").(*models.Host)
+	contest := &models.Contest{}
+	if err := tx.Find(contest, c.Param("cid")); err != nil {
+		return c.Error(404, err)
+	}
+	if host.ID != contest.HostID {
+		c.Flash().Add("danger", "You are not authorized to view that page. Please login as host.")
+		return c.Redirect(302, "/contests/detail/%s", contest.ID)
+	}
+	opts := models.ExportOptions{
+		Submissions: c.Param("submissions") == "true",
+		Standings:   c.Param("standings") == "true",
+	}
+	b, err := models.ExportContest(tx, contest, opts)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return c.Render(200, r.Download(c, "contest_"+contest.ID.String()+".zip", bytes.NewReader(b)))
+}
+
+// ContestsImport recreates a contest from an archive under the current
+// host.
+func ContestsImport(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	host := c.Value("current_host").(*models.Host)
+	f, err := c.File("Archive")
+	if err != nil || !f.Valid() {
+		c.Flash().Add("danger", "Choose a contest archive to import.")
+		return c.Redirect(302, "/contests/create")
+	}
+	// Archives can be large, so the upload is kept in a temporary file that
+	// the import reads as needed.
+	tmp, err := ioutil.TempFile("", "cpjudge-archive")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer os.Remove(tmp.Name())
+	n, err := io.Copy(tmp, io.LimitReader(f, maxArchiveSize+1))
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if n > maxArchiveSize {
+		c.Flash().Add("danger", fmt.Sprintf("Contest archives can be at most %d MB.", maxArchiveSize>>20))
+		return c.Redirect(302, "/contests/create")
+	}
+	res, err := models.ImportContest(tx, tmp.Name(), host.ID)
+	if err != nil {
+		cause := errors.Cause(err)
+		_, invalid := cause.(*models.ArchiveError)
+		if invalid || cause == models.ErrNotArchive || cause == models.ErrArchiveVersion {
+			c.Flash().Add("danger", err.Error())
+			return c.Redirect(302, "/contests/create")
+		}
+		return errors.WithStack(err)
+	}
+	if err := judge.QueueContest(tx, res.Contest.ID); err != nil {
+		return errors.WithStack(err)
+	}
+	c.Flash().Add("success", fmt.Sprintf("Contest imported with %d questions and %d submissions. The submissions are judged again here.", res.Questions, res.Submissions))
+	if len(res.MissingUsers) > 0 {
+		c.Flash().Add("warning", fmt.Sprintf("Submissions of %d users without an account here were skipped.", len(res.MissingUsers)))
+	}
+	return c.Redirect(302, "/contests/detail/%s", res.Contest.ID)
+}
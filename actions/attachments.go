@@ -0,0 +1,87 @@
+package actions
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/cpjudge/cpjudge/models"
+	"github.com/cpjudge/cpjudge/storage"
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop"
+	"github.com/pkg/errors"
+)
+
+// QuestionsAttachmentsCreate attaches a file to the statement of a
+// question, replacing the attachment of the same name.
+func QuestionsAttachmentsCreate(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	question, err := findHostedQuestion(c, "qid")
+	if question == nil {
+		return err
+	}
+	f, err := c.File("AttachmentFile")
+	if err != nil || !f.Valid() {
+		c.Flash().Add("danger", "Choose a file to attach.")
+		return c.Redirect(302, "/questions/edit/%s", question.ID)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(f, models.MaxAttachmentSize+1))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	a, verrs, err := models.AddAttachment(tx, question.ID, f.Filename, data)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if verrs.HasAny() {
+		return renderQuestionEdit(c, question, verrs.Errors)
+	}
+	c.Flash().Add("success", fmt.Sprintf("%s was attached.", a.Filename))
+	return c.Redirect(302, "/questions/edit/%s", question.ID)
+}
+
+// QuestionsAttachmentsDelete removes an attachment.
+func QuestionsAttachmentsDelete(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	a := &models.Attachment{}
+	if err := tx.Find(a, c.Param("aid")); err != nil {
+		return c.Error(404, err)
+	}
+	question := &models.Question{}
+	if err := tx.Find(question, a.QuestionID); err != nil {
+		return c.Error(404, err)
+	}
+	ok, err := hostsQuestion(c, question)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		c.Flash().Add("danger", "You are not authorized to view that page. Please login as host.")
+		return c.Redirect(302, "/contests/detail/%s", question.ContestID)
+	}
+	if err := a.Delete(tx); err != nil {
+		return errors.WithStack(err)
+	}
+	c.Flash().Add("success", fmt.Sprintf("%s was deleted.", a.Filename))
+	return c.Redirect(302, "/questions/edit/%s", question.ID)
+}
+
+// QuestionsFiles sends an attachment of a question, as statements link to
+// them.
+func QuestionsFiles(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	question := &models.Question{}
+	if err := tx.Find(question, c.Param("qid")); err != nil {
+		return c.Error(404, err)
+	}
+	a, err := models.FindAttachment(tx, question.ID, c.Param("name"))
+	if err != nil {
+		return c.Error(404, err)
+	}
+	b, err := storage.ReadAll(storage.Default(), a.StoragePath)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return c.Render(200, r.Download(c, a.Filename, bytes.NewReader(b)))
+}
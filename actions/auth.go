@@ -0,0 +1,127 @@
+package actions
+
+import (
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/cpjudge/cpjudge/mailers"
+	"github.com/cpjudge/cpjudge/models"
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/envy"
+	"github.com/gobuffalo/pop"
+	"github.com/markbates/goth"
+	"github.com/markbates/goth/gothic"
+	"github.com/markbates/goth/providers/github"
+	"github.com/markbates/goth/providers/google"
+	"github.com/markbates/goth/providers/openidConnect"
+	"github.com/pkg/errors"
+)
+
+// authProviderNames maps enabled goth provider keys to the labels shown on
+// the login page.
+var authProviderNames = map[string]string{}
+
+func init() {
+	gothic.Store = App().SessionStore
+	setupAuthProviders()
+}
+
+// setupAuthProviders enables every external login provider whose
+// credentials are present in the environment.
+func setupAuthProviders() {
+	callback := func(provider string) string {
+		return fmt.Sprintf("%s/auth/%s/callback", mailers.AppURL, provider)
+	}
+	providers := []goth.Provider{}
+
+	if key := envy.Get("GITHUB_KEY", ""); key != "" {
+		providers = append(providers, github.New(key, envy.Get("GITHUB_SECRET", ""), callback("github"), "read:user", "user:email"))
+		authProviderNames["github"] = "GitHub"
+	}
+	if key := envy.Get("GOOGLE_KEY", ""); key != "" {
+		providers = append(providers, google.New(key, envy.Get("GOOGLE_SECRET", ""), callback("google"), "email", "profile"))
+		authProviderNames["google"] = "Google"
+	}
+	// OIDC_DISCOVERY_URL points at the provider's
+	// /.well-known/openid-configuration document.
+	if key := envy.Get("OIDC_CLIENT_ID", ""); key != "" {
+		p, err := openidConnect.New(key, envy.Get("OIDC_CLIENT_SECRET", ""), callback("openid-connect"), envy.Get("OIDC_DISCOVERY_URL", ""), "openid", "email", "profile")
+		if err != nil {
+			log.Printf("auth: openid connect provider disabled: %v", err)
+		} else {
+			providers = append(providers, p)
+			authProviderNames["openid-connect"] = envy.Get("OIDC_NAME", "Institution")
+		}
+	}
+	goth.UseProviders(providers...)
+}
+
+// AuthProvider is an enabled external login provider.
+type AuthProvider struct {
+	Key  string
+	Name string
+}
+
+// authProviders lists the enabled external login providers.
+func authProviders() []AuthProvider {
+	providers := []AuthProvider{}
+	for key, name := range authProviderNames {
+		providers = append(providers, AuthProvider{Key: key, Name: name})
+	}
+	sort.Slice(providers, func(i, j int) bool {
+		return providers[i].Name < providers[j].Name
+	})
+	return providers
+}
+
+// AuthCallback logs in the user returned by an external provider. A logged
+// in user gets the external account linked to their account instead.
+func AuthCallback(c buffalo.Context) error {
+	gu, err := gothic.CompleteUserAuth(c.Response(), c.Request())
+	if err != nil {
+		return c.Error(401, err)
+	}
+	eu := externalUser(gu)
+	tx := c.Value("tx").(*pop.Connection)
+
+	if current, ok := c.Value("current_user").(*models.User); ok {
+		if err := current.LinkIdentity(tx, eu); err != nil {
+			return errors.WithStack(err)
+		}
+		c.Flash().Add("success", fmt.Sprintf("Your %s account has been linked.", authProviderNames[eu.Provider]))
+		return c.Redirect(302, "/contests/user_index")
+	}
+
+	user, err := models.UserFromExternal(tx, eu)
+	if err != nil {
+		if errors.Cause(err) == models.ErrExternalEmailNotVerified {
+			c.Flash().Add("danger", err.Error())
+			return c.Redirect(302, "/users/login")
+		}
+		return errors.WithStack(err)
+	}
+	// An external login replaces the password, not the second factor.
+	return logInUser(c, user)
+}
+
+// externalUser converts a goth user. GitHub only returns verified addresses,
+// Google and OpenID Connect report verification in the raw claims.
+func externalUser(gu goth.User) models.ExternalUser {
+	verified := gu.Provider == "github"
+	for _, claim := range []string{"email_verified", "verified_email"} {
+		switch v := gu.RawData[claim].(type) {
+		case bool:
+			verified = v
+		case string:
+			verified = v == "true"
+		}
+	}
+	return models.ExternalUser{
+		Provider:       gu.Provider,
+		ProviderUserID: gu.UserID,
+		Email:          gu.Email,
+		EmailVerified:  verified,
+		Nickname:       gu.NickName,
+	}
+}
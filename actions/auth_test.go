@@ -0,0 +1,58 @@
+package actions
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/gobuffalo/envy"
+	"github.com/markbates/goth"
+)
+
+// mockOIDCProvider serves just enough of an OpenID Connect provider for
+// discovery and the authorization redirect.
+func mockOIDCProvider() *httptest.Server {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/.well-known/openid-configuration" {
+			http.NotFound(w, req)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                 srv.URL,
+			"authorization_endpoint": srv.URL + "/authorize",
+			"token_endpoint":         srv.URL + "/token",
+			"userinfo_endpoint":      srv.URL + "/userinfo",
+			"jwks_uri":               srv.URL + "/jwks",
+		})
+	}))
+	return srv
+}
+
+func (as *ActionSuite) Test_Auth_OpenIDConnect_Redirect() {
+	srv := mockOIDCProvider()
+	defer srv.Close()
+	envy.Set("OIDC_CLIENT_ID", "cpjudge")
+	envy.Set("OIDC_DISCOVERY_URL", srv.URL+"/.well-known/openid-configuration")
+	defer envy.Set("OIDC_CLIENT_ID", "")
+	setupAuthProviders()
+	defer goth.ClearProviders()
+
+	res := as.HTML("/auth/openid-connect").Get()
+	as.Equal(307, res.Code)
+	as.True(strings.HasPrefix(res.Location(), srv.URL+"/authorize"))
+}
+
+func (as *ActionSuite) Test_Auth_ExternalUser() {
+	eu := externalUser(goth.User{
+		Provider: "openid-connect",
+		UserID:   "42",
+		Email:    "ada@example.com",
+		RawData:  map[string]interface{}{"email_verified": false},
+	})
+	as.False(eu.EmailVerified)
+
+	eu = externalUser(goth.User{Provider: "github", UserID: "7", Email: "ada@example.com"})
+	as.True(eu.EmailVerified)
+}
@@ -0,0 +1,195 @@
+package actions
+
+import (
+	"time"
+
+	"github.com/cpjudge/cpjudge/models"
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop"
+	"github.com/gobuffalo/pop/nulls"
+	"github.com/gobuffalo/uuid"
+	"github.com/pkg/errors"
+)
+
+// ClarificationsIndex lists the clarifications of the current user across
+// contests and marks them as read. Hosts see their unanswered questions.
+func ClarificationsIndex(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	if host, ok := c.Value("current_host").(*models.Host); ok {
+		pending, err := models.PendingClarifications(tx, host.ID)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		c.Set("clarifications", pending)
+		return c.Render(200, r.HTML("clarifications/index.html"))
+	}
+	user, ok := c.Value("current_user").(*models.User)
+	if !ok {
+		c.Flash().Add("danger", "You must be logged in to view that page.")
+		return c.Redirect(302, "/users/login")
+	}
+	clarifications, err := models.UserClarifications(tx, user.ID)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if err := models.MarkClarificationsRead(tx, user.ID, time.Now()); err != nil {
+		return errors.WithStack(err)
+	}
+	c.Set("clarifications", clarifications)
+	c.Set("unread_clarifications", 0)
+	return c.Render(200, r.HTML("clarifications/index.html"))
+}
+
+// ClarificationsContest shows the clarifications and announcements of a
+// contest, with forms to ask, answer and announce.
+func ClarificationsContest(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	contest := &models.Contest{}
+	if err := tx.Find(contest, c.Param("cid")); err != nil {
+		return c.Error(404, err)
+	}
+	return renderContestClarifications(c, contest, 200)
+}
+
+func renderContestClarifications(c buffalo.Context, contest *models.Contest, status int) error {
+	tx := c.Value("tx").(*pop.Connection)
+	owner := false
+	if host, ok := c.Value("current_host").(*models.Host); ok {
+		owner = host.ID == contest.HostID
+	}
+	userID := uuid.Nil
+	if user, ok := c.Value("current_user").(*models.User); ok {
+		userID = user.ID
+	}
+	clarifications, err := models.ContestClarifications(tx, contest.ID, userID, owner)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	questions := models.Questions{}
+	if err := tx.Where("contest_id = ?", contest.ID).Order("created_at asc").All(&questions); err != nil {
+		return errors.WithStack(err)
+	}
+	c.Set("contest", contest)
+	c.Set("owner", owner)
+	c.Set("questions", questions)
+	c.Set("clarifications", clarifications)
+	if _, ok := c.Value("clarification").(*models.Clarification); !ok {
+		c.Set("clarification", &models.Clarification{})
+	}
+	return c.Render(status, r.HTML("clarifications/contest.html"))
+}
+
+// ClarificationsCreate lets a contestant ask about a contest or one of its
+// questions.
+func ClarificationsCreate(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	user := c.Value("current_user").(*models.User)
+	contest := &models.Contest{}
+	if err := tx.Find(contest, c.Param("cid")); err != nil {
+		return c.Error(404, err)
+	}
+	clarification := &models.Clarification{
+		ContestID: contest.ID,
+		UserID:    nulls.NewUUID(user.ID),
+		Body:      c.Request().FormValue("Body"),
+	}
+	if qid, err := uuid.FromString(c.Request().FormValue("QuestionID")); err == nil {
+		question := &models.Question{}
+		if err := tx.Where("id = ? and contest_id = ?", qid, contest.ID).First(question); err == nil {
+			clarification.QuestionID = nulls.NewUUID(question.ID)
+		}
+	}
+	verrs, err := tx.ValidateAndCreate(clarification)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if verrs.HasAny() {
+		c.Set("clarification", clarification)
+		c.Set("errors", verrs.Errors)
+		return renderContestClarifications(c, contest, 422)
+	}
+	c.Flash().Add("success", "Your question has been sent to the host.")
+	return c.Redirect(302, "/clarifications/contest/%s", contest.ID)
+}
+
+// ClarificationsAnswer lets the host of a contest answer a question,
+// privately or to everyone.
+func ClarificationsAnswer(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	host := c.Value("current_host").(*models.Host)
+	clarification := &models.Clarification{}
+	if err := tx.Find(clarification, c.Param("clid")); err != nil {
+		return c.Error(404, err)
+	}
+	contest := &models.Contest{}
+	if err := tx.Find(contest, clarification.ContestID); err != nil {
+		return c.Error(404, err)
+	}
+	if host.ID != contest.HostID {
+		c.Flash().Add("danger", "You are not authorized to view that page. Please login as host.")
+		return c.Redirect(302, "/contests/detail/%s", contest.ID)
+	}
+	public := c.Request().FormValue("Public") == "true"
+	verrs, err := clarification.Respond(tx, c.Request().FormValue("Answer"), public, time.Now())
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if verrs.HasAny() {
+		c.Set("errors", verrs.Errors)
+		return renderContestClarifications(c, contest, 422)
+	}
+	c.Flash().Add("success", "The clarification has been answered.")
+	return c.Redirect(302, "/clarifications/contest/%s", contest.ID)
+}
+
+// ClarificationsAnnounce publishes an announcement to every contestant.
+func ClarificationsAnnounce(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	host := c.Value("current_host").(*models.Host)
+	contest := &models.Contest{}
+	if err := tx.Find(contest, c.Param("cid")); err != nil {
+		return c.Error(404, err)
+	}
+	if host.ID != contest.HostID {
+		c.Flash().Add("danger", "You are not authorized to view that page. Please login as host.")
+		return c.Redirect(302, "/contests/detail/%s", contest.ID)
+	}
+	_, verrs, err := models.Announce(tx, contest.ID, c.Request().FormValue("Body"), time.Now())
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if verrs.HasAny() {
+		c.Set("errors", verrs.Errors)
+		return renderContestClarifications(c, contest, 422)
+	}
+	c.Flash().Add("success", "Your announcement has been published.")
+	return c.Redirect(302, "/clarifications/contest/%s", contest.ID)
+}
+
+// SetClarificationCounts makes the number of unread clarifications of a
+// user, or unanswered questions of a host, available to the layout.
+func SetClarificationCounts(next buffalo.Handler) buffalo.Handler {
+	return func(c buffalo.Context) error {
+		tx, ok := c.Value("tx").(*pop.Connection)
+		if !ok {
+			return next(c)
+		}
+		c.Set("unread_clarifications", 0)
+		c.Set("pending_clarifications", 0)
+		if user, ok := c.Value("current_user").(*models.User); ok {
+			n, err := models.UnreadClarifications(tx, user)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			c.Set("unread_clarifications", n)
+		}
+		if host, ok := c.Value("current_host").(*models.Host); ok {
+			n, err := models.CountPendingClarifications(tx, host.ID)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			c.Set("pending_clarifications", n)
+		}
+		return next(c)
+	}
+}
@@ -0,0 +1,30 @@
+package actions
+
+import (
+	"time"
+
+	"github.com/cpjudge/cpjudge/models"
+)
+
+func (as *ActionSuite) Test_Clarifications_Contest() {
+	contest := &models.Contest{Title: "Weekly", Description: "Weekly contest"}
+	as.NoError(as.DB.Create(contest))
+	_, _, err := models.Announce(as.DB, contest.ID, "Problem B has been fixed.", time.Now())
+	as.NoError(err)
+
+	res := as.HTML("/clarifications/contest/%s", contest.ID).Get()
+	as.Equal(200, res.Code)
+	as.Contains(res.Body.String(), "Problem B has been fixed.")
+}
+
+func (as *ActionSuite) Test_Clarifications_Index_RequiresLogin() {
+	res := as.HTML("/clarifications/index").Get()
+	as.Equal(302, res.Code)
+	as.Equal("/users/login", res.Location())
+}
+
+func (as *ActionSuite) Test_Clarifications_Answer_RequiresHost() {
+	res := as.HTML("/clarifications/answer/00000000-0000-0000-0000-000000000000").Post(nil)
+	as.Equal(302, res.Code)
+	as.Equal("/", res.Location())
+}
@@ -2,7 +2,8 @@ package actions
 
 import (
 	"fmt"
-	"os"
+	"strings"
+	"time"
 
 	"github.com/cpjudge/cpjudge/models"
 	"github.com/gobuffalo/buffalo"
@@ -18,6 +19,7 @@ func ContestsUserIndex(c buffalo.Context) error {
 	// Paginate results. Params "page" and "per_page" control pagination.
 	// Default values are "page=1" and "per_page=20".
 	q := tx.Order("created_at desc").PaginateFromParams(c.Params())
+	q = filterContests(c, q, true)
 	// Retrieve all Contests from the DB
 	if err := q.All(contests); err != nil {
 		return errors.WithStack(err)
@@ -26,7 +28,7 @@ func ContestsUserIndex(c buffalo.Context) error {
 	c.Set("contests", contests)
 	// Add the paginator to the context so it can be used in the template.
 	c.Set("pagination", q.Paginator)
-	return c.Render(200, r.HTML("contests/index.html"))
+	return renderContestsIndex(c, "/contests/user_index", true)
 }
 
 // ContestsIndex default implementation.
@@ -39,6 +41,7 @@ func ContestsHostIndex(c buffalo.Context) error {
 	// Paginate results. Params "page" and "per_page" control pagination.
 	// Default values are "page=1" and "per_page=20".
 	q := tx.PaginateFromParams(c.Params())
+	q = filterContests(c, q, false)
 	// Retrieve all Contests from the DB
 	if err := q.BelongsTo(host).All(contests); err != nil {
 		return errors.WithStack(err)
@@ -47,6 +50,45 @@ func ContestsHostIndex(c buffalo.Context) error {
 	c.Set("contests", contests)
 	// Add the paginator to the context so it can be used in the template.
 	c.Set("pagination", q.Paginator)
+	return renderContestsIndex(c, "/contests/host_index", false)
+}
+
+// filterContests narrows a contest listing to the "q" search and to
+// contests with a question tagged "tag". Unless ended is false, only tags of
+// ended contests count, so listings do not give away running problems.
+func filterContests(c buffalo.Context, q *pop.Query, ended bool) *pop.Query {
+	if search := strings.TrimSpace(c.Param("q")); search != "" {
+		q = models.SearchContests(q, search)
+	}
+	if tag := strings.TrimSpace(c.Param("tag")); tag != "" {
+		sub := "EXISTS (SELECT 1 FROM questions WHERE questions.contest_id = contests.id AND CONCAT(',', questions.tags, ',') LIKE ?)"
+		q = q.Where(sub, "%,"+models.NormalizeTag(tag)+",%")
+		if ended {
+			q = q.Where("contests.end_time is not null and contests.end_time <= ?", time.Now())
+		}
+	}
+	return q
+}
+
+// renderContestsIndex renders a contest listing whose filter form submits
+// to action. Unless ended is false, it offers only tags of ended contests,
+// like filterContests.
+func renderContestsIndex(c buffalo.Context, action string, ended bool) error {
+	tx := c.Value("tx").(*pop.Connection)
+	var tags []string
+	var err error
+	if ended {
+		tags, err = models.ArchivedTagNames(tx, time.Now())
+	} else {
+		tags, err = models.TagNames(tx)
+	}
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	c.Set("tags", tags)
+	c.Set("search", c.Param("q"))
+	c.Set("tag", c.Param("tag"))
+	c.Set("filter_action", action)
 	return c.Render(200, r.HTML("contests/index.html"))
 }
 
@@ -64,6 +106,8 @@ func ContestsCreatePost(c buffalo.Context) error {
 		return errors.WithStack(err)
 	}
 	contest.HostID = host.ID
+	contest.Rated = c.Request().FormValue("Rated") == "true"
+	contest.PublicSolutions = c.Request().FormValue("PublicSolutions") == "true"
 	// Get the DB connection from the context
 	tx := c.Value("tx").(*pop.Connection)
 	// Validate the data from the html form
@@ -113,9 +157,71 @@ func ContestsDetail(c buffalo.Context) error {
 	}
 	c.Set("questions", questions)
 	c.Set("qPagination", qPage.Paginator)
+
+	now := time.Now()
+	c.Set("replayable", contest.Ended(now) && contest.Duration() > 0)
+	running := false
+	if user, ok := c.Value("current_user").(*models.User); ok {
+		vp, err := models.FindVirtualParticipation(tx, user.ID, contest.ID)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if vp != nil {
+			running = vp.Running(now)
+			c.Set("virtual", vp)
+			c.Set("virtual_running", running)
+		}
+	}
+	if h, ok := c.Value("current_host").(*models.Host); ok && h.ID == contest.HostID {
+		problemSet, err := models.LatestContestTask(tx, contest.ID, models.TaskProblemSet)
+		if err != nil {
+			return err
+		}
+		if problemSet != nil {
+			c.Set("problem_set", problemSet)
+		}
+	}
+	// Editorials would spoil a replay.
+	if !running {
+		if err := questions.LoadEditorials(tx, *contest, now); err != nil {
+			return errors.WithStack(err)
+		}
+	}
 	return c.Render(200, r.HTML("contests/detail"))
 }
 
+// replaying reports whether the current user is replaying the contest at
+// t, which hides its editorials and shared solutions from them.
+func replaying(c buffalo.Context, contest *models.Contest, t time.Time) (bool, error) {
+	user, ok := c.Value("current_user").(*models.User)
+	if !ok {
+		return false, nil
+	}
+	return models.Replaying(c.Value("tx").(*pop.Connection), user.ID, contest.ID, t)
+}
+
+// ContestsStartVirtual starts a virtual participation of the current user
+// in a past contest.
+func ContestsStartVirtual(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	user := c.Value("current_user").(*models.User)
+	contest := &models.Contest{}
+	if err := tx.Find(contest, c.Param("cid")); err != nil {
+		return c.Error(404, err)
+	}
+	vp, err := models.StartVirtualParticipation(tx, user, contest, time.Now())
+	if err != nil {
+		switch errors.Cause(err) {
+		case models.ErrContestNotReplayable, models.ErrContestNotEnded, models.ErrAlreadyParticipated:
+			c.Flash().Add("danger", err.Error())
+			return c.Redirect(302, "/contests/detail/%s", contest.ID)
+		}
+		return errors.WithStack(err)
+	}
+	c.Flash().Add("success", fmt.Sprintf("Your virtual contest has started. It ends at %s UTC.", vp.EndsAt.UTC().Format("2006-01-02 15:04")))
+	return c.Redirect(302, "/contests/detail/%s", contest.ID)
+}
+
 // ContestsEditGet displays a form to edit the contest.
 func ContestsEditGet(c buffalo.Context) error {
 	tx := c.Value("tx").(*pop.Connection)
@@ -137,6 +243,8 @@ func ContestsEditPost(c buffalo.Context) error {
 	if err := c.Bind(contest); err != nil {
 		return errors.WithStack(err)
 	}
+	contest.Rated = c.Request().FormValue("Rated") == "true"
+	contest.PublicSolutions = c.Request().FormValue("PublicSolutions") == "true"
 	verrs, err := tx.ValidateAndUpdate(contest)
 	if err != nil {
 		return errors.WithStack(err)
@@ -166,10 +274,25 @@ func ContestsDelete(c buffalo.Context) error {
 	} else {
 		for i := 0; i < len(questions); i++ {
 			question := questions[i]
-			err := os.RemoveAll("../testcases/testcase_" + question.ID.String())
+			err := question.DeleteTestData(tx)
 			if err != nil {
 				return errors.WithStack(err)
 			}
+			if err := question.DeleteValidator(); err != nil {
+				return errors.WithStack(err)
+			}
+			if err := question.DeleteReferenceSolutions(tx); err != nil {
+				return errors.WithStack(err)
+			}
+			if err := question.DeleteGenerators(tx); err != nil {
+				return errors.WithStack(err)
+			}
+			if err := question.DeleteAttachments(tx); err != nil {
+				return errors.WithStack(err)
+			}
+			if err := question.DeleteEditorial(tx); err != nil {
+				return errors.WithStack(err)
+			}
 			err = tx.Destroy(&question)
 			if err != nil {
 				return errors.WithStack(err)
@@ -177,9 +300,37 @@ func ContestsDelete(c buffalo.Context) error {
 		}
 	}
 
+	if err := contest.DeleteTasks(tx); err != nil {
+		return errors.WithStack(err)
+	}
 	if err := tx.Destroy(contest); err != nil {
 		return errors.WithStack(err)
 	}
 	c.Flash().Add("success", "Contest was successfully deleted.")
 	return c.Redirect(302, "/contests/host_index")
 }
+
+// ContestsApplyRatings computes the rating changes of an ended rated contest.
+func ContestsApplyRatings(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	host := c.Value("current_host").(*models.Host)
+	contest := &models.Contest{}
+	if err := tx.Find(contest, c.Param("cid")); err != nil {
+		return c.Error(404, err)
+	}
+	if host.ID != contest.HostID {
+		c.Flash().Add("danger", "You are not authorized to view that page. Please login as host.")
+		return c.Redirect(302, "/contests/detail/%s", contest.ID)
+	}
+	changes, err := models.ApplyContestRatings(tx, contest)
+	if err != nil {
+		switch errors.Cause(err) {
+		case models.ErrContestNotRated, models.ErrContestNotEnded, models.ErrRatingsApplied:
+			c.Flash().Add("danger", err.Error())
+			return c.Redirect(302, "/contests/detail/%s", contest.ID)
+		}
+		return errors.WithStack(err)
+	}
+	c.Flash().Add("success", fmt.Sprintf("Ratings updated for %d participants.", len(changes)))
+	return c.Redirect(302, "/leaderboard/display/%s", contest.ID)
+}
@@ -15,3 +15,37 @@ func (as *ActionSuite) Test_Contests_Delete() {
 func (as *ActionSuite) Test_Contests_Detail() {
 	as.Fail("Not Implemented!")
 }
+
+func (as *ActionSuite) Test_Contests_ApplyRatings_RequiresHost() {
+	res := as.HTML("/contests/ratings/00000000-0000-0000-0000-000000000000").Post(nil)
+	as.Equal(302, res.Code)
+	as.Equal("/", res.Location())
+}
+
+func (as *ActionSuite) Test_Contests_StartVirtual_RequiresLogin() {
+	res := as.HTML("/contests/virtual/00000000-0000-0000-0000-000000000000").Post(nil)
+	as.Equal(302, res.Code)
+	as.Equal("/users/login", res.Location())
+}
+
+func (as *ActionSuite) Test_Contests_Export_RequiresHost() {
+	res := as.HTML("/contests/export/00000000-0000-0000-0000-000000000000").Get()
+	as.Equal(302, res.Code)
+	as.Equal("/", res.Location())
+}
+
+func (as *ActionSuite) Test_Contests_ProblemSet_RequiresHost() {
+	res := as.HTML("/contests/problemset/00000000-0000-0000-0000-000000000000").Get()
+	as.Equal(302, res.Code)
+	as.Equal("/", res.Location())
+
+	res = as.HTML("/contests/problemset/00000000-0000-0000-0000-000000000000").Post(nil)
+	as.Equal(302, res.Code)
+	as.Equal("/", res.Location())
+}
+
+func (as *ActionSuite) Test_Contests_Import_RequiresHost() {
+	res := as.HTML("/contests/import").Post(nil)
+	as.Equal(302, res.Code)
+	as.Equal("/", res.Location())
+}
@@ -0,0 +1,179 @@
+package actions
+
+import (
+	"time"
+
+	"github.com/cpjudge/cpjudge/models"
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop"
+	"github.com/pkg/errors"
+)
+
+// QuestionsEditorial shows the editorial of a question with its comments,
+// and the accepted solutions when the contest shares them. The host of the
+// contest can preview the editorial before it is published.
+func QuestionsEditorial(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	question := &models.Question{}
+	if err := tx.Find(question, c.Param("qid")); err != nil {
+		return c.Error(404, err)
+	}
+	return renderEditorial(c, question, 200)
+}
+
+func renderEditorial(c buffalo.Context, question *models.Question, status int) error {
+	tx := c.Value("tx").(*pop.Connection)
+	contest := &models.Contest{}
+	if err := tx.Find(contest, question.ContestID); err != nil {
+		return c.Error(404, err)
+	}
+	owner := false
+	if host, ok := c.Value("current_host").(*models.Host); ok {
+		owner = host.ID == contest.HostID
+	}
+	now := time.Now()
+	editorial, err := models.QuestionEditorial(tx, question.ID)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	hidden, err := replaying(c, contest, now)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	published := !hidden && editorial != nil && editorial.Published(*contest, now)
+	solutions := []models.SharedSolution{}
+	if !hidden {
+		if solutions, err = models.SharedSolutions(tx, *contest, question.ID, now); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	if !published && !owner && len(solutions) == 0 {
+		c.Flash().Add("warning", "The editorial of this question is not published yet.")
+		return c.Redirect(302, "/submissions/create/%s/%s", contest.ID, question.ID)
+	}
+	if editorial != nil && (published || owner) {
+		comments, err := models.ListEditorialComments(tx, editorial.ID)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		c.Set("editorial", editorial)
+		c.Set("comments", comments)
+		c.Set("publish_time", editorial.PublishedAt(*contest))
+	}
+	c.Set("question", question)
+	c.Set("contest", contest)
+	c.Set("owner", owner)
+	c.Set("published", published)
+	c.Set("solutions", solutions)
+	if _, ok := c.Value("comment").(*models.EditorialComment); !ok {
+		c.Set("comment", &models.EditorialComment{})
+	}
+	return c.Render(status, r.HTML("questions/editorial.html"))
+}
+
+// QuestionsEditorialSave writes the editorial of a question and when it is
+// published.
+func QuestionsEditorialSave(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	question, err := findHostedQuestion(c, "qid")
+	if question == nil {
+		return err
+	}
+	form := &models.Editorial{}
+	if err := c.Bind(form); err != nil {
+		return errors.WithStack(err)
+	}
+	_, verrs, err := models.SaveEditorial(tx, question.ID, form.Content, form.PublishAt)
+	if err != nil {
+		return err
+	}
+	if verrs.HasAny() {
+		return renderQuestionEdit(c, question, verrs.Errors)
+	}
+	c.Flash().Add("success", "Editorial was saved.")
+	return c.Redirect(302, "/questions/edit/%s", question.ID)
+}
+
+// QuestionsEditorialDelete removes the editorial of a question and its
+// comments.
+func QuestionsEditorialDelete(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	question, err := findHostedQuestion(c, "qid")
+	if question == nil {
+		return err
+	}
+	if err := question.DeleteEditorial(tx); err != nil {
+		return err
+	}
+	c.Flash().Add("success", "Editorial was deleted.")
+	return c.Redirect(302, "/questions/edit/%s", question.ID)
+}
+
+// QuestionsEditorialCommentsCreate comments on a published editorial.
+func QuestionsEditorialCommentsCreate(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	user := c.Value("current_user").(*models.User)
+	question := &models.Question{}
+	if err := tx.Find(question, c.Param("qid")); err != nil {
+		return c.Error(404, err)
+	}
+	contest := &models.Contest{}
+	if err := tx.Find(contest, question.ContestID); err != nil {
+		return c.Error(404, err)
+	}
+	editorial, err := models.QuestionEditorial(tx, question.ID)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	now := time.Now()
+	hidden, err := replaying(c, contest, now)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if hidden || editorial == nil || !editorial.Published(*contest, now) {
+		return c.Error(404, errors.New("editorial not found"))
+	}
+	comment := &models.EditorialComment{EditorialID: editorial.ID, UserID: user.ID, Body: c.Request().FormValue("Body")}
+	verrs, err := tx.ValidateAndCreate(comment)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if verrs.HasAny() {
+		c.Set("comment", comment)
+		c.Set("errors", verrs.Errors)
+		return renderEditorial(c, question, 422)
+	}
+	c.Flash().Add("success", "Your comment was posted.")
+	return c.Redirect(302, "/questions/editorial/%s", question.ID)
+}
+
+// QuestionsEditorialCommentsDelete removes a comment on an editorial of
+// the host's contest.
+func QuestionsEditorialCommentsDelete(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	comment := &models.EditorialComment{}
+	if err := tx.Find(comment, c.Param("ecid")); err != nil {
+		return c.Error(404, err)
+	}
+	editorial := &models.Editorial{}
+	if err := tx.Find(editorial, comment.EditorialID); err != nil {
+		return c.Error(404, err)
+	}
+	question := &models.Question{}
+	if err := tx.Find(question, editorial.QuestionID); err != nil {
+		return c.Error(404, err)
+	}
+	ok, err := hostsQuestion(c, question)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		c.Flash().Add("danger", "You are not authorized to view that page. Please login as host.")
+		return c.Redirect(302, "/contests/detail/%s", question.ContestID)
+	}
+	if err := tx.Destroy(comment); err != nil {
+		return errors.WithStack(err)
+	}
+	c.Flash().Add("success", "Comment was deleted.")
+	return c.Redirect(302, "/questions/editorial/%s", question.ID)
+}
@@ -0,0 +1,105 @@
+package actions
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/cpjudge/cpjudge/models"
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop"
+	"github.com/gobuffalo/pop/nulls"
+	"github.com/pkg/errors"
+)
+
+// QuestionsGeneratorsCreate adds a generator to a question, replacing the
+// generator of the same name.
+func QuestionsGeneratorsCreate(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	question, err := findHostedQuestion(c, "qid")
+	if question == nil {
+		return err
+	}
+	f, err := c.File("GeneratorFile")
+	if err != nil || !f.Valid() {
+		c.Flash().Add("danger", "Choose a generator to upload.")
+		return c.Redirect(302, "/questions/edit/%s", question.ID)
+	}
+	source, err := ioutil.ReadAll(io.LimitReader(f, maxProgramSize+1))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if len(source) > maxProgramSize {
+		c.Flash().Add("danger", fmt.Sprintf("Generators can be at most %d KB.", maxProgramSize>>10))
+		return c.Redirect(302, "/questions/edit/%s", question.ID)
+	}
+	g, verrs, err := models.AddGenerator(tx, question.ID, f.Filename, source)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if verrs.HasAny() {
+		return renderQuestionEdit(c, question, verrs.Errors)
+	}
+	c.Flash().Add("success", fmt.Sprintf("Generator %s was added.", g.Name))
+	return c.Redirect(302, "/questions/edit/%s", question.ID)
+}
+
+// QuestionsGeneratorsDelete removes a generator.
+func QuestionsGeneratorsDelete(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	g := &models.Generator{}
+	if err := tx.Find(g, c.Param("gid")); err != nil {
+		return c.Error(404, err)
+	}
+	question := &models.Question{}
+	if err := tx.Find(question, g.QuestionID); err != nil {
+		return c.Error(404, err)
+	}
+	ok, err := hostsQuestion(c, question)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		c.Flash().Add("danger", "You are not authorized to view that page. Please login as host.")
+		return c.Redirect(302, "/contests/detail/%s", question.ContestID)
+	}
+	if err := g.Delete(tx); err != nil {
+		return errors.WithStack(err)
+	}
+	c.Flash().Add("success", fmt.Sprintf("Generator %s was deleted.", g.Name))
+	return c.Redirect(302, "/questions/edit/%s", question.ID)
+}
+
+// QuestionsGenerate saves the generator script of a question and queues a
+// task building its test data: the generators make the inputs, which are
+// validated, and the main solution makes the answers.
+func QuestionsGenerate(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	question, err := findHostedQuestion(c, "qid")
+	if question == nil {
+		return err
+	}
+	script := strings.Replace(c.Request().FormValue("GeneratorScript"), "\r\n", "\n", -1)
+	if err := question.StoreGeneratorScript(tx, script); err != nil {
+		return errors.WithStack(err)
+	}
+	back := fmt.Sprintf("/questions/edit/%s", question.ID)
+	calls, err := models.ParseGeneratorScript(script)
+	if err != nil {
+		c.Flash().Add("danger", err.Error())
+		return c.Redirect(302, back)
+	}
+	if len(calls) == 0 {
+		c.Flash().Add("danger", "The generator script makes no tests.")
+		return c.Redirect(302, back)
+	}
+	// Running the generators may take long, so the tests are built in the
+	// background.
+	task := &models.Task{Kind: models.TaskGenerate, QuestionID: nulls.NewUUID(question.ID)}
+	if err := models.EnqueueTask(tx, task); err != nil {
+		return errors.WithStack(err)
+	}
+	c.Flash().Add("success", fmt.Sprintf("The %d tests are generated in the background. The outcome is shown on the edit page.", len(calls)))
+	return c.Redirect(302, back)
+}
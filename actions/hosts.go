@@ -1,6 +1,9 @@
 package actions
 
 import (
+	"fmt"
+
+	"github.com/cpjudge/cpjudge/mailers"
 	"github.com/cpjudge/cpjudge/models"
 	"github.com/gobuffalo/buffalo"
 	"github.com/gobuffalo/pop"
@@ -46,7 +49,10 @@ func HostsRegisterPost(c buffalo.Context) error {
 		return c.Render(422, r.HTML("hosts/register.html"))
 	}
 	// If there are no errors set a success message
-	c.Flash().Add("success", "Account created successfully. Proceed to Host Login")
+	if err := mailers.SendVerificationEmail(host.Hostname, host.Email, "/hosts/verify", host.VerificationToken); err != nil {
+		return errors.WithStack(err)
+	}
+	c.Flash().Add("success", "Account created successfully. Check your email to verify your address, then proceed to Host Login.")
 	// and redirect to the home page
 	return c.Redirect(302, "/")
 }
@@ -64,7 +70,39 @@ func HostsLoginPost(c buffalo.Context) error {
 		return errors.WithStack(err)
 	}
 	tx := c.Value("tx").(*pop.Connection)
-	err := host.Authorize(tx)
+	ip := clientIP(c.Request())
+	wait, err := models.LoginWait(models.DB, "host", host.Email, ip)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if wait > 0 {
+		c.Set("host", host)
+		verrs := validate.NewErrors()
+		verrs.Add("Login", fmt.Sprintf("Too many failed login attempts. Please try again in %s.", roundWait(wait)))
+		c.Set("errors", verrs.Errors)
+		return c.Render(429, r.HTML("hosts/login"))
+	}
+	email := host.Email
+	err = host.Authorize(tx)
+	// Attempts are recorded outside the request transaction so that failed
+	// logins are kept even though the request is rolled back.
+	success := err == nil || errors.Cause(err) == models.ErrNotVerified
+	if err := models.RecordLoginAttempt(models.DB, "host", email, ip, success); err != nil {
+		return errors.WithStack(err)
+	}
+	if errors.Cause(err) == models.ErrNotVerified {
+		if err := host.RefreshVerificationToken(tx); err != nil {
+			return errors.WithStack(err)
+		}
+		if err := mailers.SendVerificationEmail(host.Hostname, host.Email, "/hosts/verify", host.VerificationToken); err != nil {
+			return errors.WithStack(err)
+		}
+		c.Set("host", host)
+		verrs := validate.NewErrors()
+		verrs.Add("Login", "Your email address is not verified yet. We have sent you a new verification link.")
+		c.Set("errors", verrs.Errors)
+		return c.Render(422, r.HTML("hosts/login"))
+	}
 	if err != nil {
 		c.Set("host", host)
 		verrs := validate.NewErrors()
@@ -72,6 +110,14 @@ func HostsLoginPost(c buffalo.Context) error {
 		c.Set("errors", verrs.Errors)
 		return c.Render(422, r.HTML("hosts/login"))
 	}
+	tf, err := models.FindTwoFactor(tx, host.ID)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if tf.Enabled {
+		c.Session().Set("pending_host_id", host.ID.String())
+		return c.Redirect(302, "/hosts/two_factor")
+	}
 	c.Session().Set("current_host_id", host.ID)
 	c.Flash().Add("success", "Welcome back!")
 	return c.Redirect(302, "/contests/host_index")
@@ -82,6 +128,88 @@ func HostsDashboard(c buffalo.Context) error {
 	return c.Render(200, r.HTML("hosts/dashboard.html"))
 }
 
+// HostsVerify confirms the email address of a host from the link mailed
+// at registration.
+func HostsVerify(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	if _, err := models.VerifyHost(tx, c.Param("token")); err != nil {
+		if errors.Cause(err) == models.ErrInvalidToken {
+			c.Flash().Add("danger", err.Error())
+			return c.Redirect(302, "/hosts/login")
+		}
+		return errors.WithStack(err)
+	}
+	c.Flash().Add("success", "Your email address has been verified. Proceed to Host Login.")
+	return c.Redirect(302, "/hosts/login")
+}
+
+// HostsForgotPasswordGet displays a form asking for the account email.
+func HostsForgotPasswordGet(c buffalo.Context) error {
+	return c.Render(200, r.HTML("hosts/forgot_password.html"))
+}
+
+// HostsForgotPasswordPost mails a password reset link. The response is the
+// same whether or not the email belongs to an account.
+func HostsForgotPasswordPost(c buffalo.Context) error {
+	host := &models.Host{}
+	if err := c.Bind(host); err != nil {
+		return errors.WithStack(err)
+	}
+	tx := c.Value("tx").(*pop.Connection)
+	token, err := host.StartPasswordReset(tx)
+	switch errors.Cause(err) {
+	case nil:
+		if err := mailers.SendPasswordResetEmail(host.Hostname, host.Email, "/hosts/reset_password", token); err != nil {
+			return errors.WithStack(err)
+		}
+	case models.ErrNoAccount:
+	default:
+		return errors.WithStack(err)
+	}
+	c.Flash().Add("success", "If an account exists for that email, a password reset link has been sent to it.")
+	return c.Redirect(302, "/hosts/login")
+}
+
+// HostsResetPasswordGet displays a form to choose a new password.
+func HostsResetPasswordGet(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	if _, err := models.FindHostByResetToken(tx, c.Param("token")); err != nil {
+		if errors.Cause(err) == models.ErrInvalidToken {
+			c.Flash().Add("danger", err.Error())
+			return c.Redirect(302, "/hosts/forgot_password")
+		}
+		return errors.WithStack(err)
+	}
+	c.Set("token", c.Param("token"))
+	return c.Render(200, r.HTML("hosts/reset_password.html"))
+}
+
+// HostsResetPasswordPost sets a new password for the owner of the token.
+func HostsResetPasswordPost(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	host, err := models.FindHostByResetToken(tx, c.Param("token"))
+	if err != nil {
+		if errors.Cause(err) == models.ErrInvalidToken {
+			c.Flash().Add("danger", err.Error())
+			return c.Redirect(302, "/hosts/forgot_password")
+		}
+		return errors.WithStack(err)
+	}
+	host.Password = c.Request().FormValue("Password")
+	host.PasswordConfirm = c.Request().FormValue("PasswordConfirm")
+	verrs, err := host.ResetPassword(tx)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if verrs.HasAny() {
+		c.Set("token", c.Param("token"))
+		c.Set("errors", verrs.Errors)
+		return c.Render(422, r.HTML("hosts/reset_password.html"))
+	}
+	c.Flash().Add("success", "Your password has been changed. Proceed to Host Login.")
+	return c.Redirect(302, "/hosts/login")
+}
+
 // HostsLogout clears the session and logs out the host.
 func HostsLogout(c buffalo.Context) error {
 	c.Session().Clear()
@@ -27,3 +27,14 @@ func (as *ActionSuite) Test_HostsResource_Update() {
 func (as *ActionSuite) Test_HostsResource_Destroy() {
 	as.Fail("Not Implemented!")
 }
+
+func (as *ActionSuite) Test_Hosts_ForgotPassword() {
+	res := as.HTML("/hosts/forgot_password").Get()
+	as.Equal(200, res.Code)
+}
+
+func (as *ActionSuite) Test_Hosts_Verify_InvalidToken() {
+	res := as.HTML("/hosts/verify/bogus").Get()
+	as.Equal(302, res.Code)
+	as.Equal("/hosts/login", res.Location())
+}
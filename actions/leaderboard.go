@@ -1,7 +1,7 @@
 package actions
 
 import (
-	"sort"
+	"time"
 
 	"github.com/cpjudge/cpjudge/models"
 	"github.com/gobuffalo/buffalo"
@@ -9,13 +9,6 @@ import (
 	"github.com/pkg/errors"
 )
 
-type SubmissionCount struct {
-	// User     uuid.UUID `json:"user"`
-	Username string `json:"username"`
-	Correct  int    `json:"correct" default:"0"`
-	Wrong    int    `json:"wrong" default:"0"`
-}
-
 // LeaderboardDisplay default implementation.
 func LeaderboardDisplay(c buffalo.Context) error {
 	tx := c.Value("tx").(*pop.Connection)
@@ -24,43 +17,35 @@ func LeaderboardDisplay(c buffalo.Context) error {
 		return c.Error(404, err)
 	}
 
-	submissions := models.Submissions{}
-	if err := tx.BelongsTo(contest).All(&submissions); err != nil {
+	leaderboard, err := models.ContestStandings(tx, contest.ID)
+	if err != nil {
 		return errors.WithStack(err)
 	}
-
-	userMap := make(map[string]*SubmissionCount)
-	var Leaderboard []SubmissionCount
-
-	for _, submission := range submissions {
-		user := &models.User{}
-		if err := tx.Find(user, submission.UserID); err != nil {
-			return errors.WithStack(err)
+	// ?virtual=<id> ghost-ranks a virtual participant against the original
+	// contestants at the same elapsed time.
+	if vid := c.Param("virtual"); vid != "" {
+		vp := &models.VirtualParticipation{}
+		if err := tx.Where("id = ? and contest_id = ?", vid, contest.ID).First(vp); err != nil {
+			return c.Error(404, err)
 		}
-
-		if _, ok := userMap[user.Username]; !ok {
-			userMap[user.Username] = new(SubmissionCount)
-		}
-		if submission.Status == "Correct Answer" {
-			userMap[user.Username].Correct++
-		} else if submission.Status == "Runtime Error" ||
-			submission.Status == "Wrong answer" ||
-			submission.Status == "Time Limit Exceeded" {
-			userMap[user.Username].Wrong++
+		now := time.Now()
+		leaderboard, err = models.VirtualStandings(tx, vp, contest, now)
+		if err != nil {
+			return errors.WithStack(err)
 		}
+		c.Set("virtual", vp)
+		c.Set("elapsed", models.Submission{Elapsed: int(vp.Elapsed(now).Seconds())}.ElapsedClock())
 	}
-
-	for key, value := range userMap {
-		Leaderboard = append(Leaderboard, SubmissionCount{key, value.Correct, value.Wrong})
+	changes := models.RatingChanges{}
+	if err := tx.Where("contest_id = ?", contest.ID).All(&changes); err != nil {
+		return errors.WithStack(err)
 	}
-	sort.Slice(Leaderboard[:], func(i, j int) bool {
-		return (Leaderboard[i].Correct > Leaderboard[j].Correct ||
-			(Leaderboard[i].Correct == Leaderboard[j].Correct &&
-				Leaderboard[i].Wrong < Leaderboard[j].Wrong))
-	})
+	leaderboard = leaderboard.WithRatingChanges(changes)
 	// Make submissions available inside the html template
-	c.Set("leaderboard", Leaderboard)
+	c.Set("leaderboard", leaderboard)
 	c.Set("contest_name", contest.Title)
+	c.Set("contest", contest)
+	c.Set("rated", len(changes) > 0)
 	// return c.Render(200, r.HTML("submissions/index.html"))
 	return c.Render(200, r.HTML("leaderboard/display.html"))
 }
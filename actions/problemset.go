@@ -0,0 +1,73 @@
+package actions
+
+import (
+	"bytes"
+
+	"github.com/cpjudge/cpjudge/models"
+	"github.com/cpjudge/cpjudge/storage"
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop"
+	"github.com/gobuffalo/pop/nulls"
+	"github.com/pkg/errors"
+)
+
+// findHostedContest finds the contest in the URL. When the current host did
+// not make it, it flashes an error and returns a nil contest, having
+// redirected to the contest.
+func findHostedContest(c buffalo.Context) (*models.Contest, error) {
+	tx := c.Value("tx").(*pop.Connection)
+	host := c.Value("current_host").(*models.Host)
+	contest := &models.Contest{}
+	if err := tx.Find(contest, c.Param("cid")); err != nil {
+		return nil, c.Error(404, err)
+	}
+	if host.ID != contest.HostID {
+		c.Flash().Add("danger", "You are not authorized to view that page. Please login as host.")
+		return nil, c.Redirect(302, "/contests/detail/%s", contest.ID)
+	}
+	return contest, nil
+}
+
+// ContestsProblemSetBuild queues building the statements of a contest as a
+// PDF, to print for onsite contests. pdflatex can run for minutes, so the
+// build happens in the background and the contest page links the result.
+func ContestsProblemSetBuild(c buffalo.Context) error {
+	contest, err := findHostedContest(c)
+	if contest == nil {
+		return err
+	}
+	tx := c.Value("tx").(*pop.Connection)
+	latest, err := models.LatestContestTask(tx, contest.ID, models.TaskProblemSet)
+	if err != nil {
+		return err
+	}
+	if latest != nil && !latest.Done() {
+		c.Flash().Add("warning", "The problem set is already being built.")
+		return c.Redirect(302, "/contests/detail/%s", contest.ID)
+	}
+	task := &models.Task{Kind: models.TaskProblemSet, ContestID: nulls.NewUUID(contest.ID)}
+	if err := models.EnqueueTask(tx, task); err != nil {
+		return err
+	}
+	c.Flash().Add("success", "The problem set is being built in the background. Its link appears on this page when it is ready.")
+	return c.Redirect(302, "/contests/detail/%s", contest.ID)
+}
+
+// ContestsProblemSet sends the latest problem set built for a contest.
+func ContestsProblemSet(c buffalo.Context) error {
+	contest, err := findHostedContest(c)
+	if contest == nil {
+		return err
+	}
+	tx := c.Value("tx").(*pop.Connection)
+	task := &models.Task{}
+	err = tx.Where("contest_id = ? AND kind = ? AND output <> ''", contest.ID, models.TaskProblemSet).Order("created_at desc").First(task)
+	if err != nil {
+		return c.Error(404, err)
+	}
+	b, err := storage.ReadAll(storage.Default(), task.Output)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return c.Render(200, r.Download(c, "problemset_"+contest.ID.String()+".pdf", bytes.NewReader(b)))
+}
@@ -2,17 +2,71 @@ package actions
 
 import (
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/cpjudge/cpjudge/importer"
+	"github.com/cpjudge/cpjudge/judge"
 	"github.com/cpjudge/cpjudge/models"
+	"github.com/cpjudge/cpjudge/tasks"
 	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/buffalo/binding"
 	"github.com/gobuffalo/pop"
 	"github.com/gobuffalo/uuid"
 	"github.com/pkg/errors"
 )
 
-// QuestionsIndex default implementation.
+// QuestionsIndex lists the problem archive: the questions of every contest
+// that has ended. Params "q", "tag", "min_difficulty" and "max_difficulty"
+// filter the list.
 func QuestionsIndex(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+
+	q := tx.PaginateFromParams(c.Params()).
+		Join("contests", "contests.id = questions.contest_id").
+		Where("contests.end_time is not null and contests.end_time <= ?", time.Now())
+	if search := strings.TrimSpace(c.Param("q")); search != "" {
+		q = models.SearchQuestions(q, search)
+	}
+	if tag := strings.TrimSpace(c.Param("tag")); tag != "" {
+		q = models.TaggedWith(q, tag)
+	}
+	if min, err := strconv.Atoi(c.Param("min_difficulty")); err == nil {
+		q = q.Where("questions.difficulty >= ?", min)
+	}
+	if max, err := strconv.Atoi(c.Param("max_difficulty")); err == nil {
+		q = q.Where("questions.difficulty <= ?", max)
+	}
+
+	questions := models.Questions{}
+	if err := q.Order("contests.end_time desc, questions.created_at asc").All(&questions); err != nil {
+		return errors.WithStack(err)
+	}
+	userID := uuid.Nil
+	if user, ok := c.Value("current_user").(*models.User); ok {
+		userID = user.ID
+	}
+	if err := questions.LoadSolvedCounts(tx, userID); err != nil {
+		return errors.WithStack(err)
+	}
+
+	tags, err := models.ArchivedTagNames(tx, time.Now())
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	c.Set("questions", questions)
+	c.Set("pagination", q.Paginator)
+	c.Set("tags", tags)
+	c.Set("search", c.Param("q"))
+	c.Set("tag", c.Param("tag"))
+	c.Set("min_difficulty", c.Param("min_difficulty"))
+	c.Set("max_difficulty", c.Param("max_difficulty"))
 	return c.Render(200, r.HTML("questions/index.html"))
 }
 
@@ -30,12 +84,17 @@ func QuestionsCreateGet(c buffalo.Context) error {
 		return c.Redirect(302, "/contests/detail/%s", contest.ID)
 	}
 	c.Set("contest", contest)
+	tags, err := models.TagNames(tx)
+	if err != nil {
+		return errors.WithStack(err)
+	}
 	c.Set("question", &models.Question{})
+	c.Set("checkers", models.Checkers)
+	c.Set("tags", tags)
 	return c.Render(200, r.HTML("questions/create"))
 }
 
 func QuestionsCreatePost(c buffalo.Context) error {
-	fmt.Printf("\n\nQuestion is being created\n\n")
 	question := &models.Question{}
 	//host := c.Value("current_host").(*models.User)
 	if err := c.Bind(question); err != nil {
@@ -71,26 +130,128 @@ func QuestionsCreatePost(c buffalo.Context) error {
 		return c.Redirect(302, "/contests/detail/%s", c.Param("cid"))
 	}
 	c.Flash().Add("success", "Question added successfully.")
+	return c.Redirect(302, "/contests/detail/%s", c.Param("cid"))
+}
+
+// maxPackageSize is the largest problem package that can be imported.
+const maxPackageSize = 512 << 20
+
+// QuestionsImport creates a question from a Polygon or Kattis problem
+// package. Features of the package that could not be imported are shown as
+// warnings.
+func QuestionsImport(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	host := c.Value("current_host").(*models.Host)
+	contest := &models.Contest{}
+	if err := tx.Find(contest, c.Param("cid")); err != nil {
+		return c.Error(404, err)
+	}
+	if host.ID != contest.HostID {
+		c.Flash().Add("danger", "You are not authorized to view that page. Please login as host.")
+		return c.Redirect(302, "/contests/detail/%s", contest.ID)
+	}
+	f, err := c.File("Package")
+	if err != nil || !f.Valid() {
+		c.Flash().Add("danger", "Choose a problem package to import.")
+		return c.Redirect(302, "/questions/create/%s", contest.ID)
+	}
+	b, err := ioutil.ReadAll(io.LimitReader(f, maxPackageSize+1))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if len(b) > maxPackageSize {
+		c.Flash().Add("danger", fmt.Sprintf("Problem packages can be at most %d MB.", maxPackageSize>>20))
+		return c.Redirect(302, "/questions/create/%s", contest.ID)
+	}
+	problem, err := importer.Read(b)
+	if err != nil {
+		c.Flash().Add("danger", errors.Cause(err).Error())
+		return c.Redirect(302, "/questions/create/%s", contest.ID)
+	}
+
+	data, err := problem.TestData()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if err := checkImportedValidator(problem); err != nil {
+		return errors.WithStack(err)
+	}
 
-	//tx = c.Value("tx").(*pop.Connection)
-	query := tx.Where("testcases_path = ''")
-	questions := []models.Question{}
-	err = query.All(&questions)
-	if err != nil {
-		fmt.Print("\n\nAll filled!!\n\n")
-		fmt.Printf("%v\n", err)
-	} else {
-		for i := 0; i < len(questions); i++ {
-			fmt.Println("\n\nFound!!!\n\n")
-			question := questions[i]
-			question.TestCasesPath = "../testcases/testcase_" + question.ID.String()
-			tx.ValidateAndSave(&question)
-			fmt.Print("Success!\n")
-			//fmt.Printf("%v\n", user)
+	question := problem.Question()
+	question.ContestID = contest.ID
+	verrs, err := tx.ValidateAndCreate(question)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if verrs.HasAny() {
+		c.Flash().Add("danger", "The imported question is not valid: "+verrs.Error())
+		return c.Redirect(302, "/questions/create/%s", contest.ID)
+	}
+	if problem.Validator != nil {
+		if err := question.StoreValidator(tx, problem.Validator.Name, problem.Validator.Source); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	for _, sol := range problem.Solutions {
+		solution := &models.ReferenceSolution{
+			QuestionID: question.ID,
+			Filename:   sol.Name,
+			Expected:   sol.Expected,
+			Main:       sol.Main,
+		}
+		verrs, err := models.AddReferenceSolution(tx, solution, sol.Source)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if verrs.HasAny() {
+			problem.Warnings = append(problem.Warnings, fmt.Sprintf("The solution %s was not imported: %s", sol.Name, verrs.Error()))
 		}
 	}
+	for _, a := range problem.Attachments {
+		_, verrs, err := models.AddAttachment(tx, question.ID, a.Name, a.Data)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if verrs.HasAny() {
+			problem.Warnings = append(problem.Warnings, fmt.Sprintf("The picture %s was not imported: %s", a.Name, verrs.Error()))
+		}
+	}
+	// The tests are stored once the validator accepted them, and the
+	// reference solutions are judged on them.
+	if err := tasks.EnqueueTestData(tx, question, data); err != nil {
+		return errors.WithStack(err)
+	}
 
-	return c.Redirect(302, "/contests/detail/%s", c.Param("cid"))
+	c.Flash().Add("success", fmt.Sprintf("Question imported from the %s package with %d tests. The tests are checked in the background.", strings.Title(problem.Format), len(problem.Tests)))
+	for _, w := range problem.Warnings {
+		c.Flash().Add("warning", w)
+	}
+	return c.Redirect(302, "/questions/edit/%s", question.ID)
+}
+
+// checkImportedValidator drops the validator of an imported problem, with
+// a warning, when it does not compile here, for example because it needs
+// testlib.h.
+func checkImportedValidator(problem *importer.Problem) error {
+	if problem.Validator == nil {
+		return nil
+	}
+	dir, err := ioutil.TempDir("", "cpjudge-import")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer os.RemoveAll(dir)
+	validator := filepath.Join(dir, "validator"+models.ProgramExtension(problem.Validator.Name))
+	if err := ioutil.WriteFile(validator, problem.Validator.Source, 0644); err != nil {
+		return errors.WithStack(err)
+	}
+	err = judge.CheckCompiles(validator)
+	if _, ok := err.(*judge.CompileError); ok {
+		problem.Warnings = append(problem.Warnings, fmt.Sprintf("The validator %s does not compile here and was not imported.", problem.Validator.Name))
+		problem.Validator = nil
+		return nil
+	}
+	return err
 }
 
 // QuestionsEditGet displays a form to edit the question.
@@ -101,9 +262,67 @@ func QuestionsEditGet(c buffalo.Context) error {
 		return c.Error(404, err)
 	}
 	c.Set("question", question)
+	if err := setTestCaseNames(c, question); err != nil {
+		return err
+	}
 	return c.Render(200, r.HTML("questions/edit.html"))
 }
 
+// setTestCaseNames lists the uploaded test cases so hosts can pick samples,
+// the versions of the test data, the checkers, the reference solutions, the
+// generators, the attachments, the known tags, the editorial and the latest
+// background tasks.
+func setTestCaseNames(c buffalo.Context, question *models.Question) error {
+	names, err := question.TestCaseNames()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	c.Set("test_cases", names)
+	c.Set("checkers", models.Checkers)
+	tx := c.Value("tx").(*pop.Connection)
+	versions, err := models.QuestionTestDataVersions(tx, question.ID)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	c.Set("test_data_versions", versions)
+	solutions, err := models.QuestionReferenceSolutions(tx, question.ID)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	c.Set("solutions", solutions)
+	c.Set("expected_verdicts", models.ExpectedVerdicts)
+	generators, err := models.QuestionGenerators(tx, question.ID)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	c.Set("generators", generators)
+	attachments, err := models.QuestionAttachments(tx, question.ID)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	c.Set("attachments", attachments)
+	tags, err := models.TagNames(tx)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	c.Set("tags", tags)
+	editorial, err := models.QuestionEditorial(tx, question.ID)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	c.Set("has_editorial", editorial != nil)
+	if editorial == nil {
+		editorial = &models.Editorial{}
+	}
+	c.Set("editorial", editorial)
+	recent, err := models.QuestionTasks(tx, question.ID, 5)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	c.Set("tasks", recent)
+	return nil
+}
+
 // QuestionsEditQuestion updates a question.
 func QuestionsEditPost(c buffalo.Context) error {
 	tx := c.Value("tx").(*pop.Connection)
@@ -114,14 +333,33 @@ func QuestionsEditPost(c buffalo.Context) error {
 	if err := c.Bind(question); err != nil {
 		return errors.WithStack(err)
 	}
+	// The sample checkboxes are only shown once test cases are uploaded.
+	if c.Request().FormValue("SamplesShown") == "true" {
+		question.Samples = strings.Join(c.Request().Form["SampleNames"], ",")
+	}
+	rejected, err := checkValidatorUpload(c, question)
+	if rejected || err != nil {
+		return err
+	}
+	var archive []byte
+	if question.TestCasesZipFile.Valid() {
+		if archive, err = readUpload(question.TestCasesZipFile); err != nil {
+			return err
+		}
+		// The upload is stored once the task checking it is done.
+		question.TestCasesZipFile = binding.File{}
+	}
 	verrs, err := tx.ValidateAndSave(question)
 	if err != nil {
 		return errors.WithStack(err)
 	}
 	if verrs.HasAny() {
-		c.Set("question", question)
-		c.Set("errors", verrs.Errors)
-		return c.Render(422, r.HTML("questions/edit.html"))
+		return renderQuestionEdit(c, question, verrs.Errors)
+	}
+	if archive != nil {
+		if err := tasks.EnqueueTestData(tx, question, archive); err != nil {
+			return errors.WithStack(err)
+		}
 	}
 
 	// query := tx.Where("testcases_path = ''")
@@ -142,9 +380,61 @@ func QuestionsEditPost(c buffalo.Context) error {
 	// }
 
 	c.Flash().Add("success", "Question was updated successfully.")
+	if archive != nil {
+		c.Flash().Add("success", "The test cases are checked in the background. The outcome is shown on the edit page.")
+	}
 	return c.Redirect(302, "/questions/detail/%s", question.ID)
 }
 
+// renderQuestionEdit shows the edit form again with errors.
+func renderQuestionEdit(c buffalo.Context, question *models.Question, errs map[string][]string) error {
+	c.Set("question", question)
+	c.Set("errors", errs)
+	if err := setTestCaseNames(c, question); err != nil {
+		return err
+	}
+	return c.Render(422, r.HTML("questions/edit.html"))
+}
+
+// checkValidatorUpload checks an uploaded validator compiles. When it does
+// not, it renders the edit form with the compiler output and returns true.
+func checkValidatorUpload(c buffalo.Context, question *models.Question) (bool, error) {
+	if !question.ValidatorFile.Valid() || models.ProgramExtension(question.ValidatorFile.Filename) == "" {
+		return false, nil
+	}
+	dir, err := ioutil.TempDir("", "cpjudge-upload")
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	defer os.RemoveAll(dir)
+	b, err := readUpload(question.ValidatorFile)
+	if err != nil {
+		return false, err
+	}
+	validator := filepath.Join(dir, "validator"+models.ProgramExtension(question.ValidatorFile.Filename))
+	if err := ioutil.WriteFile(validator, b, 0644); err != nil {
+		return false, errors.WithStack(err)
+	}
+	if err := judge.CheckCompiles(validator); err != nil {
+		if _, ok := err.(*judge.CompileError); ok {
+			return true, renderQuestionEdit(c, question, map[string][]string{"validator": {err.Error()}})
+		}
+		return false, err
+	}
+	return false, nil
+}
+
+// readUpload reads an uploaded file and rewinds it, so it can be read again
+// when the model is saved.
+func readUpload(f binding.File) ([]byte, error) {
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	_, err = f.Seek(0, io.SeekStart)
+	return b, errors.WithStack(err)
+}
+
 // QuestionsDelete default implementation.
 func QuestionsDelete(c buffalo.Context) error {
 	tx := c.Value("tx").(*pop.Connection)
@@ -153,8 +443,25 @@ func QuestionsDelete(c buffalo.Context) error {
 		return c.Error(404, err)
 	}
 	cid := question.ContestID
-	err := os.RemoveAll("../testcases/testcase_" + question.ID.String())
-	if err != nil {
+	if err := question.DeleteTestData(tx); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := question.DeleteValidator(); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := question.DeleteReferenceSolutions(tx); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := question.DeleteGenerators(tx); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := question.DeleteAttachments(tx); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := question.DeleteEditorial(tx); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := question.DeleteTasks(tx); err != nil {
 		return errors.WithStack(err)
 	}
 
@@ -177,7 +484,13 @@ func QuestionsDetail(c buffalo.Context) error {
 		return c.Error(404, err)
 	}
 
+	samples, err := question.LoadSamples()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
 	c.Set("question", question)
 	c.Set("contest", contest)
+	c.Set("samples", samples)
 	return c.Render(200, r.HTML("questions/detail.html"))
 }
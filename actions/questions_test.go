@@ -1,7 +1,34 @@
 package actions
 
+import (
+	"fmt"
+	"time"
+
+	"github.com/cpjudge/cpjudge/models"
+	"github.com/gobuffalo/pop/nulls"
+)
+
 func (as *ActionSuite) Test_Questions_Index() {
-	as.Fail("Not Implemented!")
+	past := &models.Contest{
+		Title:       "Old Round",
+		Description: "An old round",
+		StartTime:   nulls.NewTime(time.Now().Add(-48 * time.Hour)),
+		EndTime:     nulls.NewTime(time.Now().Add(-24 * time.Hour)),
+	}
+	as.NoError(as.DB.Create(past))
+	running := &models.Contest{Title: "Live Round", Description: "A live round"}
+	as.NoError(as.DB.Create(running))
+	as.NoError(as.DB.Create(&models.Question{Title: "Archived Sum", Description: "Add", ContestID: past.ID, Tags: "math"}))
+	as.NoError(as.DB.Create(&models.Question{Title: "Live Sum", Description: "Add", ContestID: running.ID}))
+
+	res := as.HTML("/questions/index").Get()
+	as.Equal(200, res.Code)
+	as.Contains(res.Body.String(), "Archived Sum")
+	as.NotContains(res.Body.String(), "Live Sum")
+
+	res = as.HTML("/questions/index?tag=graphs").Get()
+	as.Equal(200, res.Code)
+	as.NotContains(res.Body.String(), "Archived Sum")
 }
 
 func (as *ActionSuite) Test_Questions_Create() {
@@ -19,3 +46,94 @@ func (as *ActionSuite) Test_Questions_Delete() {
 func (as *ActionSuite) Test_Questions_Detail() {
 	as.Fail("Not Implemented!")
 }
+
+func (as *ActionSuite) Test_Questions_Rejudge_RequiresHost() {
+	res := as.HTML("/questions/rejudge/00000000-0000-0000-0000-000000000000").Post(nil)
+	as.Equal(302, res.Code)
+	as.Equal("/", res.Location())
+}
+
+func (as *ActionSuite) Test_Questions_Import_RequiresHost() {
+	res := as.HTML("/questions/import/00000000-0000-0000-0000-000000000000").Post(nil)
+	as.Equal(302, res.Code)
+	as.Equal("/", res.Location())
+}
+
+func (as *ActionSuite) Test_Questions_Solutions_RequiresHost() {
+	for _, path := range []string{
+		"/questions/solutions/00000000-0000-0000-0000-000000000000",
+		"/questions/solutions/run/00000000-0000-0000-0000-000000000000",
+		"/questions/solutions/delete/00000000-0000-0000-0000-000000000000",
+		"/questions/answers/00000000-0000-0000-0000-000000000000",
+	} {
+		res := as.HTML(path).Post(nil)
+		as.Equal(302, res.Code)
+		as.Equal("/", res.Location())
+	}
+}
+
+func (as *ActionSuite) Test_Questions_Generators_RequiresHost() {
+	for _, path := range []string{
+		"/questions/generators/00000000-0000-0000-0000-000000000000",
+		"/questions/generators/delete/00000000-0000-0000-0000-000000000000",
+		"/questions/generate/00000000-0000-0000-0000-000000000000",
+	} {
+		res := as.HTML(path).Post(nil)
+		as.Equal(302, res.Code)
+		as.Equal("/", res.Location())
+	}
+}
+
+func (as *ActionSuite) Test_Questions_Attachments_RequiresHost() {
+	for _, path := range []string{
+		"/questions/attachments/00000000-0000-0000-0000-000000000000",
+		"/questions/attachments/delete/00000000-0000-0000-0000-000000000000",
+	} {
+		res := as.HTML(path).Post(nil)
+		as.Equal(302, res.Code)
+		as.Equal("/", res.Location())
+	}
+}
+
+func (as *ActionSuite) Test_Questions_Files_NotFound() {
+	res := as.HTML("/questions/files/00000000-0000-0000-0000-000000000000/graph.png").Get()
+	as.Equal(404, res.Code)
+}
+
+func (as *ActionSuite) Test_Questions_Editorial_RequiresHost() {
+	res := as.HTML("/questions/editorial/00000000-0000-0000-0000-000000000000").Post(map[string]string{"Content": "Add them."})
+	as.Equal(302, res.Code)
+	as.Equal("/", res.Location())
+}
+
+func (as *ActionSuite) Test_Questions_Editorial_Comments_RequiresLogin() {
+	res := as.HTML("/questions/editorial/comments/00000000-0000-0000-0000-000000000000").Post(map[string]string{"Body": "Thanks!"})
+	as.Equal(302, res.Code)
+	as.Equal("/users/login", res.Location())
+}
+
+func (as *ActionSuite) Test_Questions_Editorial_Published() {
+	running := &models.Contest{Title: "Live Round", Description: "A live round", EndTime: nulls.NewTime(time.Now().Add(time.Hour))}
+	as.NoError(as.DB.Create(running))
+	live := &models.Question{Title: "Live Sum", Description: "Add", ContestID: running.ID}
+	as.NoError(as.DB.Create(live))
+	_, verrs, err := models.SaveEditorial(as.DB, live.ID, "Live secret.", nulls.Time{})
+	as.NoError(err)
+	as.False(verrs.HasAny())
+
+	res := as.HTML("/questions/editorial/%s", live.ID).Get()
+	as.Equal(302, res.Code)
+	as.Equal(fmt.Sprintf("/submissions/create/%s/%s", running.ID, live.ID), res.Location())
+
+	past := &models.Contest{Title: "Old Round", Description: "An old round", EndTime: nulls.NewTime(time.Now().Add(-time.Hour))}
+	as.NoError(as.DB.Create(past))
+	old := &models.Question{Title: "Old Sum", Description: "Add", ContestID: past.ID}
+	as.NoError(as.DB.Create(old))
+	_, verrs, err = models.SaveEditorial(as.DB, old.ID, "Add them up.", nulls.Time{})
+	as.NoError(err)
+	as.False(verrs.HasAny())
+
+	res = as.HTML("/questions/editorial/%s", old.ID).Get()
+	as.Equal(200, res.Code)
+	as.Contains(res.Body.String(), "Add them up.")
+}
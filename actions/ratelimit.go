@@ -0,0 +1,135 @@
+package actions
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cpjudge/cpjudge/models"
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/envy"
+)
+
+// RateLimiter allows at most Limit events per key in any Window. It keeps
+// its state in memory, so every app process limits on its own. Keys whose
+// events have all expired are dropped once per Window.
+type RateLimiter struct {
+	Limit  int
+	Window time.Duration
+
+	mu     sync.Mutex
+	events map[string][]time.Time
+	pruned time.Time
+}
+
+// NewRateLimiter returns a limiter allowing limit events per window.
+func NewRateLimiter(limit int, window time.Duration) *RateLimiter {
+	return &RateLimiter{Limit: limit, Window: window, events: map[string][]time.Time{}}
+}
+
+// Allow records an event for key if it is within the limit. Otherwise it
+// returns false and how long until the next event would be allowed.
+func (rl *RateLimiter) Allow(key string) (bool, time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	now := time.Now()
+	if now.Sub(rl.pruned) >= rl.Window {
+		rl.prune(now)
+	}
+	recent := rl.events[key][:0]
+	for _, t := range rl.events[key] {
+		if now.Sub(t) < rl.Window {
+			recent = append(recent, t)
+		}
+	}
+	if len(recent) >= rl.Limit {
+		rl.events[key] = recent
+		return false, recent[0].Add(rl.Window).Sub(now)
+	}
+	rl.events[key] = append(recent, now)
+	return true, 0
+}
+
+// prune drops the keys whose last event is older than the window. The
+// events of a key are in order, so only the last one needs checking.
+func (rl *RateLimiter) prune(now time.Time) {
+	for key, events := range rl.events {
+		if len(events) == 0 || now.Sub(events[len(events)-1]) >= rl.Window {
+			delete(rl.events, key)
+		}
+	}
+	rl.pruned = now
+}
+
+// loginLimiter caps login requests per address before any password is
+// checked. Failed logins are additionally throttled per account and address
+// by models.LoginWait.
+var loginLimiter = NewRateLimiter(20, time.Minute)
+
+// submissionLimiter caps how often a single user can submit to the judge.
+var submissionLimiter = NewRateLimiter(6, time.Minute)
+
+// runLimiter caps how often a single user can run code on custom input.
+var runLimiter = NewRateLimiter(10, time.Minute)
+
+// RateLimitByIP rejects requests from addresses over the limiter's limit.
+func RateLimitByIP(rl *RateLimiter) buffalo.MiddlewareFunc {
+	return func(next buffalo.Handler) buffalo.Handler {
+		return func(c buffalo.Context) error {
+			if ok, wait := rl.Allow(clientIP(c.Request())); !ok {
+				return tooManyRequests(c, wait)
+			}
+			return next(c)
+		}
+	}
+}
+
+// RateLimitByUser rejects requests from the logged in user once they are
+// over the limiter's limit.
+func RateLimitByUser(rl *RateLimiter) buffalo.MiddlewareFunc {
+	return func(next buffalo.Handler) buffalo.Handler {
+		return func(c buffalo.Context) error {
+			if user, ok := c.Value("current_user").(*models.User); ok {
+				if ok, wait := rl.Allow(user.ID.String()); !ok {
+					return tooManyRequests(c, wait)
+				}
+			}
+			return next(c)
+		}
+	}
+}
+
+func tooManyRequests(c buffalo.Context, wait time.Duration) error {
+	c.Response().Header().Set("Retry-After", fmt.Sprintf("%d", int(wait.Seconds())+1))
+	c.Flash().Add("danger", fmt.Sprintf("Too many requests. Please try again in %s.", roundWait(wait)))
+	referer := c.Request().Referer()
+	if referer == "" {
+		referer = "/"
+	}
+	return c.Redirect(303, referer)
+}
+
+func roundWait(wait time.Duration) time.Duration {
+	if wait < time.Second {
+		return time.Second
+	}
+	return wait.Round(time.Second)
+}
+
+// clientIP returns the address of the client. X-Forwarded-For is only
+// trusted when TRUST_PROXY_HEADERS is set, i.e. the app runs behind a proxy.
+func clientIP(req *http.Request) string {
+	if envy.Get("TRUST_PROXY_HEADERS", "") == "true" {
+		if fwd := req.Header.Get("X-Forwarded-For"); fwd != "" {
+			return strings.TrimSpace(strings.Split(fwd, ",")[0])
+		}
+	}
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
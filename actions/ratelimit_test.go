@@ -0,0 +1,42 @@
+package actions
+
+import (
+	"time"
+)
+
+func (as *ActionSuite) Test_RateLimiter() {
+	rl := NewRateLimiter(2, time.Minute)
+	ok, _ := rl.Allow("a")
+	as.True(ok)
+	ok, _ = rl.Allow("a")
+	as.True(ok)
+	ok, wait := rl.Allow("a")
+	as.False(ok)
+	as.True(wait > 0 && wait <= time.Minute)
+
+	ok, _ = rl.Allow("b")
+	as.True(ok)
+}
+
+func (as *ActionSuite) Test_RateLimiter_Prunes() {
+	rl := NewRateLimiter(1, 50*time.Millisecond)
+	for _, key := range []string{"a", "b", "c"} {
+		ok, _ := rl.Allow(key)
+		as.True(ok)
+	}
+	as.Equal(3, len(rl.events))
+
+	time.Sleep(60 * time.Millisecond)
+	ok, _ := rl.Allow("d")
+	as.True(ok)
+	as.Equal(1, len(rl.events))
+}
+
+func (as *ActionSuite) Test_Users_Login_Throttled() {
+	for i := 0; i < 5; i++ {
+		as.HTML("/users/login").Post(map[string]string{"Email": "nobody@example.com", "Password": "wrong"})
+	}
+	res := as.HTML("/users/login").Post(map[string]string{"Email": "nobody@example.com", "Password": "wrong"})
+	as.Equal(429, res.Code)
+	as.Contains(res.Body.String(), "Too many failed login attempts")
+}
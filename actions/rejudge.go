@@ -0,0 +1,75 @@
+package actions
+
+import (
+	"fmt"
+
+	"github.com/cpjudge/cpjudge/judge"
+	"github.com/cpjudge/cpjudge/models"
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop"
+	"github.com/gobuffalo/uuid"
+	"github.com/pkg/errors"
+)
+
+// SubmissionsRejudge judges a single submission again.
+func SubmissionsRejudge(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	submission := &models.Submission{}
+	if err := tx.Find(submission, c.Param("sid")); err != nil {
+		return c.Error(404, err)
+	}
+	filter := models.RejudgeFilter{SubmissionID: submission.ID}
+	return rejudge(c, submission.ContestID, filter, fmt.Sprintf("/submissions/detail/%s", submission.ID))
+}
+
+// QuestionsRejudge judges the submissions of a question again, optionally
+// only those with the verdicts given in Statuses.
+func QuestionsRejudge(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	question := &models.Question{}
+	if err := tx.Find(question, c.Param("qid")); err != nil {
+		return c.Error(404, err)
+	}
+	filter := models.RejudgeFilter{QuestionID: question.ID, Statuses: rejudgeStatuses(c)}
+	return rejudge(c, question.ContestID, filter, fmt.Sprintf("/questions/detail/%s", question.ID))
+}
+
+// ContestsRejudge judges the submissions of a contest again, optionally
+// only those with the verdicts given in Statuses.
+func ContestsRejudge(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	contest := &models.Contest{}
+	if err := tx.Find(contest, c.Param("cid")); err != nil {
+		return c.Error(404, err)
+	}
+	filter := models.RejudgeFilter{ContestID: contest.ID, Statuses: rejudgeStatuses(c)}
+	return rejudge(c, contest.ID, filter, fmt.Sprintf("/contests/detail/%s", contest.ID))
+}
+
+func rejudgeStatuses(c buffalo.Context) []string {
+	if err := c.Request().ParseForm(); err != nil {
+		return nil
+	}
+	return c.Request().Form["Statuses"]
+}
+
+// rejudge checks that the current host owns the contest and rejudges the
+// selected submissions.
+func rejudge(c buffalo.Context, contestID uuid.UUID, filter models.RejudgeFilter, back string) error {
+	tx := c.Value("tx").(*pop.Connection)
+	host := c.Value("current_host").(*models.Host)
+	contest := &models.Contest{}
+	if err := tx.Find(contest, contestID); err != nil {
+		return c.Error(404, err)
+	}
+	if host.ID != contest.HostID {
+		c.Flash().Add("danger", "You are not authorized to view that page. Please login as host.")
+		return c.Redirect(302, "/contests/detail/%s", contest.ID)
+	}
+	n, err := judge.Rejudge(tx, filter, fmt.Sprintf("Rejudged by %s", host.Hostname))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	c.Flash().Add("success", fmt.Sprintf("Queued %d submissions for rejudging. They are judged in the background, and applied ratings are recalculated once they are done.", n))
+	return c.Redirect(302, back)
+}
@@ -3,8 +3,11 @@ package actions
 import (
 	"html/template"
 
+	"github.com/cpjudge/cpjudge/models"
+	"github.com/cpjudge/cpjudge/statement"
 	"github.com/gobuffalo/buffalo/render"
 	"github.com/gobuffalo/packr"
+	"github.com/gobuffalo/uuid"
 )
 
 var r *render.Engine
@@ -22,6 +25,17 @@ func init() {
 			"csrf": func() template.HTML {
 				return template.HTML("<input name=\"authenticity_token\" value=\"<%= authenticity_token %>\" type=\"hidden\">")
 			},
+			"authProviders": authProviders,
+			"shortChecksum": models.ShortChecksum,
+			"statement":     statementHelper,
 		},
 	})
 }
+
+// statementHelper renders a section of a question's statement, linking the
+// files it names to the attachments of the question.
+func statementHelper(md string, questionID uuid.UUID) template.HTML {
+	return statement.HTML(md, func(name string) string {
+		return "/questions/files/" + questionID.String() + "/" + name
+	})
+}
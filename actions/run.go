@@ -0,0 +1,60 @@
+package actions
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cpjudge/cpjudge/judge"
+	"github.com/cpjudge/cpjudge/models"
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop"
+	"github.com/pkg/errors"
+)
+
+// maxRunInput caps the custom input of a run.
+const maxRunInput = 1 << 20
+
+// SubmissionsRun compiles the code from the editor and runs it once on
+// custom input with the limits of the question. Nothing is stored and the
+// leaderboard is not affected.
+func SubmissionsRun(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	question := &models.Question{}
+	if err := tx.Find(question, c.Param("qid")); err != nil {
+		return c.Error(404, err)
+	}
+	source := c.Request().FormValue("SourceCode")
+	if strings.TrimSpace(source) == "" {
+		return c.Render(422, r.JSON(map[string]string{"error": "Write some code in the editor to run it."}))
+	}
+	stdin := c.Request().FormValue("Stdin")
+	if len(stdin) > maxRunInput {
+		return c.Render(422, r.JSON(map[string]string{"error": "The input is too large."}))
+	}
+
+	dir, err := ioutil.TempDir("", "cpjudge-run")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer os.RemoveAll(dir)
+	language := models.FindLanguage(c.Request().FormValue("Language"))
+	path := filepath.Join(dir, "main."+language.Extension)
+	if err := ioutil.WriteFile(path, []byte(source), 0644); err != nil {
+		return errors.WithStack(err)
+	}
+
+	res := judge.Execute(path, strings.NewReader(stdin), judge.QuestionLimits(question))
+	status := res.Status
+	if status == "" {
+		status = "OK"
+	}
+	return c.Render(200, r.JSON(map[string]interface{}{
+		"status":    status,
+		"stdout":    res.Stdout,
+		"stderr":    res.Stderr,
+		"time_ms":   res.Time.Nanoseconds() / 1e6,
+		"memory_kb": res.MemoryKB,
+	}))
+}
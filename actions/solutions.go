@@ -0,0 +1,161 @@
+package actions
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/cpjudge/cpjudge/judge"
+	"github.com/cpjudge/cpjudge/models"
+	"github.com/cpjudge/cpjudge/tasks"
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop"
+	"github.com/gobuffalo/pop/nulls"
+	"github.com/pkg/errors"
+)
+
+// maxProgramSize is the largest reference solution or generator that can be
+// uploaded.
+const maxProgramSize = 1 << 20
+
+// hostsQuestion reports whether the current host made the contest of a
+// question.
+func hostsQuestion(c buffalo.Context, question *models.Question) (bool, error) {
+	tx := c.Value("tx").(*pop.Connection)
+	host := c.Value("current_host").(*models.Host)
+	contest := &models.Contest{}
+	if err := tx.Find(contest, question.ContestID); err != nil {
+		return false, errors.WithStack(err)
+	}
+	return host.ID == contest.HostID, nil
+}
+
+// findHostedQuestion finds the question in param. When the current host did
+// not make its contest, it flashes an error and returns a nil question; the
+// caller redirects to the contest.
+func findHostedQuestion(c buffalo.Context, param string) (*models.Question, error) {
+	tx := c.Value("tx").(*pop.Connection)
+	question := &models.Question{}
+	if err := tx.Find(question, c.Param(param)); err != nil {
+		return nil, c.Error(404, err)
+	}
+	ok, err := hostsQuestion(c, question)
+	if err != nil || ok {
+		return question, err
+	}
+	c.Flash().Add("danger", "You are not authorized to view that page. Please login as host.")
+	return nil, c.Redirect(302, "/contests/detail/%s", question.ContestID)
+}
+
+// QuestionsSolutionsCreate adds a reference solution to a question and
+// judges it on the current test data.
+func QuestionsSolutionsCreate(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	question, err := findHostedQuestion(c, "qid")
+	if question == nil {
+		return err
+	}
+	f, err := c.File("SolutionFile")
+	if err != nil || !f.Valid() {
+		c.Flash().Add("danger", "Choose a reference solution to upload.")
+		return c.Redirect(302, "/questions/edit/%s", question.ID)
+	}
+	source, err := ioutil.ReadAll(io.LimitReader(f, maxProgramSize+1))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if len(source) > maxProgramSize {
+		c.Flash().Add("danger", fmt.Sprintf("Reference solutions can be at most %d KB.", maxProgramSize>>10))
+		return c.Redirect(302, "/questions/edit/%s", question.ID)
+	}
+	solution := &models.ReferenceSolution{
+		QuestionID: question.ID,
+		Filename:   f.Filename,
+		Expected:   c.Request().FormValue("Expected"),
+		Main:       c.Request().FormValue("Main") == "true",
+	}
+	verrs, err := models.AddReferenceSolution(tx, solution, source)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if verrs.HasAny() {
+		return renderQuestionEdit(c, question, verrs.Errors)
+	}
+	if err := judge.QueueReferenceSolutions(tx, question, models.ReferenceSolutions{*solution}); err != nil {
+		return errors.WithStack(err)
+	}
+	c.Flash().Add("success", fmt.Sprintf("Reference solution %s was added. It is judged in the background.", solution.Filename))
+	return c.Redirect(302, "/questions/edit/%s", question.ID)
+}
+
+// QuestionsSolutionsDelete removes a reference solution.
+func QuestionsSolutionsDelete(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	solution := &models.ReferenceSolution{}
+	if err := tx.Find(solution, c.Param("rsid")); err != nil {
+		return c.Error(404, err)
+	}
+	question := &models.Question{}
+	if err := tx.Find(question, solution.QuestionID); err != nil {
+		return c.Error(404, err)
+	}
+	ok, err := hostsQuestion(c, question)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		c.Flash().Add("danger", "You are not authorized to view that page. Please login as host.")
+		return c.Redirect(302, "/contests/detail/%s", question.ContestID)
+	}
+	if err := solution.Delete(tx); err != nil {
+		return errors.WithStack(err)
+	}
+	c.Flash().Add("success", fmt.Sprintf("Reference solution %s was deleted.", solution.Filename))
+	return c.Redirect(302, "/questions/edit/%s", question.ID)
+}
+
+// QuestionsSolutionsRun judges every reference solution of a question again.
+func QuestionsSolutionsRun(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	question, err := findHostedQuestion(c, "qid")
+	if question == nil {
+		return err
+	}
+	solutions, err := models.QuestionReferenceSolutions(tx, question.ID)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if err := judge.QueueReferenceSolutions(tx, question, solutions); err != nil {
+		return errors.WithStack(err)
+	}
+	c.Flash().Add("success", fmt.Sprintf("%d reference solutions are judged in the background.", len(solutions)))
+	return c.Redirect(302, "/questions/edit/%s", question.ID)
+}
+
+// QuestionsAnswers replaces the answers of the current test data with the
+// outputs of the main solution, as a new version.
+func QuestionsAnswers(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	question, err := findHostedQuestion(c, "qid")
+	if question == nil {
+		return err
+	}
+	if question.TestCasesPath == "" {
+		c.Flash().Add("danger", "Upload the inputs of the test cases first.")
+		return c.Redirect(302, "/questions/edit/%s", question.ID)
+	}
+	main, err := models.MainSolution(tx, question.ID)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if main == nil {
+		c.Flash().Add("danger", tasks.ErrNoMainSolution.Error())
+		return c.Redirect(302, "/questions/edit/%s", question.ID)
+	}
+	task := &models.Task{Kind: models.TaskAnswers, QuestionID: nulls.NewUUID(question.ID)}
+	if err := models.EnqueueTask(tx, task); err != nil {
+		return errors.WithStack(err)
+	}
+	c.Flash().Add("success", "Answers are generated with the main solution in the background.")
+	return c.Redirect(302, "/questions/edit/%s", question.ID)
+}
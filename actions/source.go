@@ -0,0 +1,127 @@
+package actions
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/cpjudge/cpjudge/models"
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop"
+	"github.com/gobuffalo/uuid"
+	"github.com/pkg/errors"
+)
+
+// sourceAccess decides which sources of submissions to a contest the
+// current user or host may read: their own, all of them for admins and the
+// host of the contest and, once a contest sharing its solutions has ended,
+// accepted ones for anyone not replaying it.
+type sourceAccess struct {
+	contest   models.Contest
+	user      *models.User
+	host      *models.Host
+	replaying bool
+	now       time.Time
+}
+
+// newSourceAccess loads what deciding access to the sources of submissions
+// to a contest needs, once for any number of submissions.
+func newSourceAccess(c buffalo.Context, contestID uuid.UUID) (*sourceAccess, error) {
+	tx := c.Value("tx").(*pop.Connection)
+	a := &sourceAccess{now: time.Now()}
+	if err := tx.Find(&a.contest, contestID); err != nil {
+		if errors.Cause(err) != sql.ErrNoRows {
+			return nil, errors.WithStack(err)
+		}
+	}
+	a.user, _ = c.Value("current_user").(*models.User)
+	a.host, _ = c.Value("current_host").(*models.Host)
+	var err error
+	if a.replaying, err = replaying(c, &a.contest, a.now); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// allows reports whether the source of the submission may be read.
+func (a *sourceAccess) allows(submission *models.Submission) bool {
+	if !a.replaying && a.contest.SharesSource(*submission, a.now) {
+		return true
+	}
+	if a.user != nil {
+		return a.user.ID == submission.UserID || a.user.Admin
+	}
+	if a.host != nil {
+		return a.host.ID == a.contest.HostID
+	}
+	return false
+}
+
+// findViewableSubmission loads the submission named by param and checks
+// that its source may be viewed.
+func findViewableSubmission(c buffalo.Context, param string) (*models.Submission, error) {
+	tx := c.Value("tx").(*pop.Connection)
+	submission := &models.Submission{}
+	if err := tx.Find(submission, c.Param(param)); err != nil {
+		return nil, c.Error(404, err)
+	}
+	access, err := newSourceAccess(c, submission.ContestID)
+	if err != nil {
+		return nil, err
+	}
+	if !access.allows(submission) {
+		return nil, c.Error(404, errors.New("submission not found"))
+	}
+	return submission, nil
+}
+
+// SubmissionsSource downloads the source of a submission.
+func SubmissionsSource(c buffalo.Context) error {
+	submission, err := findViewableSubmission(c, "sid")
+	if err != nil {
+		return err
+	}
+	source, err := submission.Source()
+	if err != nil {
+		return c.Error(404, err)
+	}
+	return c.Render(200, r.Download(c, submission.SourceFilename(), strings.NewReader(source)))
+}
+
+// SubmissionsDiff shows a line diff between two submissions of the same
+// user for the same question, given by the "with" parameter. The older one
+// is the base.
+func SubmissionsDiff(c buffalo.Context) error {
+	a, err := findViewableSubmission(c, "sid")
+	if err != nil {
+		return err
+	}
+	b, err := findViewableSubmission(c, "with")
+	if err != nil {
+		return err
+	}
+	if a.UserID != b.UserID || a.QuestionID != b.QuestionID {
+		c.Flash().Add("danger", "Only submissions of the same user for the same question can be compared.")
+		return c.Redirect(302, "/submissions/detail/%s", a.ID)
+	}
+	if b.CreatedAt.Before(a.CreatedAt) {
+		a, b = b, a
+	}
+	oldSource, err := a.Source()
+	if err != nil {
+		return c.Error(404, err)
+	}
+	newSource, err := b.Source()
+	if err != nil {
+		return c.Error(404, err)
+	}
+	diff, err := models.Diff(oldSource, newSource)
+	if err == models.ErrDiffTooLarge {
+		c.Flash().Add("warning", "The submissions differ in too many lines to compare.")
+		return c.Redirect(302, "/submissions/detail/%s", b.ID)
+	}
+	c.Set("base", a)
+	c.Set("head", b)
+	c.Set("diff", diff)
+	return c.Render(200, r.HTML("submissions/diff.html"))
+}
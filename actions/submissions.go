@@ -1,19 +1,19 @@
 package actions
 
 import (
-	"bytes"
 	"fmt"
-	"io/ioutil"
-	"log"
 	"os"
 	"os/exec"
 	"strings"
 	"time"
 
+	"github.com/cpjudge/cpjudge/judge"
 	"github.com/cpjudge/cpjudge/models"
 	"github.com/gobuffalo/buffalo"
 	"github.com/gobuffalo/pop"
+	"github.com/gobuffalo/pop/nulls"
 	"github.com/gobuffalo/uuid"
+	"github.com/gobuffalo/validate"
 	"github.com/pkg/errors"
 )
 
@@ -68,15 +68,40 @@ func SubmissionsCreateGet(c buffalo.Context) error {
 		return c.Error(404, err)
 	}
 
+	samples, err := question.LoadSamples()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
 	c.Set("question", question)
 	c.Set("submission", submission)
 	c.Set("contest", contest)
+	c.Set("samples", samples)
+	c.Set("languages", models.Languages)
+	now := time.Now()
+	c.Set("practice", contest.Ended(now))
+	running := false
+	if user, ok := c.Value("current_user").(*models.User); ok {
+		vp, err := models.FindVirtualParticipation(tx, user.ID, contest.ID)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if vp != nil && vp.Running(now) {
+			running = true
+			c.Set("virtual", vp)
+		}
+	}
+	editorial, err := models.QuestionEditorial(tx, question.ID)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	c.Set("has_editorial", !running && editorial != nil && editorial.Published(*contest, now))
+	c.Set("shares_solutions", !running && contest.SharesSolutions(now))
 	return c.Render(200, r.HTML("submissions/create"))
 }
 
 func SubmissionsCreatePost(c buffalo.Context) error {
 	// Allocate an empty Submission
-	fmt.Println("\n\nInside submission create\n\n")
 	submission := &models.Submission{}
 	user := c.Value("current_user").(*models.User)
 	// Bind submission to the html form elements
@@ -96,14 +121,64 @@ func SubmissionsCreatePost(c buffalo.Context) error {
 		return errors.WithStack(err)
 	}
 
+	question := &models.Question{}
+	if err := tx.Find(question, questionID); err != nil {
+		return c.Error(404, err)
+	}
+	if question.ContestID != contestID {
+		return c.Error(404, errors.New("question not found"))
+	}
+	contest := &models.Contest{}
+	if err := tx.Find(contest, contestID); err != nil {
+		return c.Error(404, err)
+	}
+	now := time.Now()
+	if !contest.Started(now) {
+		c.Flash().Add("danger", "The contest has not started yet.")
+		return c.Redirect(302, "/contests/detail/%s", contest.ID)
+	}
+	// Submissions after the end of the contest are practice submissions,
+	// unless they belong to a running virtual participation.
+	submission.Practice = contest.Ended(now)
+	if submission.Practice {
+		vp, err := models.FindVirtualParticipation(tx, user.ID, contest.ID)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if vp != nil && vp.Running(now) {
+			submission.VirtualParticipationID = nulls.NewUUID(vp.ID)
+			submission.Elapsed = int(vp.Elapsed(now).Seconds())
+		}
+	} else if contest.StartTime.Valid {
+		submission.Elapsed = int(now.Sub(contest.StartTime.Time).Seconds())
+	}
+
 	submission.QuestionID = questionID
-	submission.Status = "Pending"
+	submission.Status = models.StatusPending
+	submission.Language = models.FindLanguage(submission.Language).Name
 	submission.ContestID = contestID
-	verrs, err := tx.ValidateAndCreate(submission)
-	if err != nil {
-		return errors.WithStack(err)
+	// The ID is chosen here so the storage key is known when the
+	// submission is created.
+	submission.ID = uuid.Must(uuid.NewV4())
+	submission.SubmissionPath = submission.StorageKey()
+	verrs := validate.NewErrors()
+	if !submission.HasSource() {
+		verrs.Add("submission_file", "Upload a file or write your code in the editor.")
+	} else {
+		verrs, err = tx.ValidateAndCreate(submission)
+		if err != nil {
+			return errors.WithStack(err)
+		}
 	}
 	if verrs.HasAny() {
+		samples, err := question.LoadSamples()
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		c.Set("question", question)
+		c.Set("contest", contest)
+		c.Set("samples", samples)
+		c.Set("languages", models.Languages)
 		c.Set("submission", submission)
 		c.Set("errors", verrs.Errors)
 		return c.Render(422, r.HTML("submissions/create"))
@@ -111,38 +186,8 @@ func SubmissionsCreatePost(c buffalo.Context) error {
 	// If there are no errors set a success message
 	c.Flash().Add("success", "Your code has been submitted. It is being evaluated now. Please wait.")
 
-	query := tx.Where("submission_path = ''")
-	submissions := []models.Submission{}
-	err = query.All(&submissions)
-	if err != nil {
-		fmt.Print("\n\nAll filled!!\n\n")
-		fmt.Printf("%v\n", err)
-	} else {
-		for i := 0; i < len(submissions); i++ {
-			fmt.Println("\n\nFound!!!\n\n")
-			submission := submissions[i]
-			submission.SubmissionPath = "../submissions/submission_" + submission.ID.String() + ".c"
-			tx.ValidateAndSave(&submission)
-			fmt.Print("Success!\n")
-			//fmt.Printf("%v\n", user)
-		}
-	}
-
-	query = tx.Where("status = 'Pending'")
-	submissions = []models.Submission{}
-	err = query.All(&submissions)
-	if err != nil {
-		fmt.Print("\n\nAll filled!!\n\n")
-		fmt.Printf("%v\n", err)
-	} else {
-		for i := 0; i < len(submissions); i++ {
-			fmt.Println("\n\nFound!!!\n\n")
-			submission := submissions[i]
-			submission.Status = EvaluateSubmission(c, submission.SubmissionPath, questionID)
-			tx.ValidateAndSave(&submission)
-			fmt.Print("Success!\n")
-			//fmt.Printf("%v\n", user)
-		}
+	if err := judge.Dispatch(tx, submission); err != nil {
+		return errors.WithStack(err)
 	}
 
 	// and redirect to the index page
@@ -157,109 +202,47 @@ func SubmissionsDetail(c buffalo.Context) error {
 		return c.Error(404, err)
 	}
 	c.Set("submission", submission)
-	return c.Render(200, r.HTML("submissions/detail.html"))
-}
-
-func EvaluateSubmission(c buffalo.Context, submissionPath string, questionId uuid.UUID) string {
-	//query := tx.Where("question_id = (?)", questionId.String())
-
-	// Get the DB connection from the context
-	tx := c.Value("tx").(*pop.Connection)
-	question := &models.Question{}
-	err := tx.Find(question, questionId)
+	verdicts, err := models.SubmissionVerdicts(tx, submission.ID)
 	if err != nil {
-		fmt.Print("\n\nNo question with this id found!!\n\n")
-		return "Some error occured"
-	} else {
-		testCasesPath := question.TestCasesPath
-
-		// Compile code
-		compile := exec.Command("gcc", submissionPath)
-		err := compile.Run()
+		return errors.WithStack(err)
+	}
+	c.Set("verdicts", verdicts)
+	if submission.Status == models.StatusPending {
+		job, err := models.SubmissionJob(tx, submission.ID)
 		if err != nil {
-			return "Compilation error"
+			return errors.WithStack(err)
 		}
-
-		// Test cases inputs
-		inputTestCaseFiles, err := ioutil.ReadDir(testCasesPath + "/inputs/")
-		if err != nil {
-			fmt.Println("\n\nInput test cases could not be read\n\n")
-			return "Some error occurred"
+		if job != nil {
+			c.Set("job", job)
 		}
+	}
+	access, err := newSourceAccess(c, submission.ContestID)
+	if err != nil {
+		return err
+	}
+	if access.host != nil {
+		c.Set("can_rejudge", access.host.ID == access.contest.HostID)
+	}
 
-		// Test cases answers
-		answerTestCaseFiles, err := ioutil.ReadDir(testCasesPath + "/answers/")
-		if err != nil {
-			fmt.Println("\n\nAnswers of test cases could not be read\n\n")
-			return "Some error occurred"
+	if access.allows(submission) {
+		if source, err := submission.Source(); err == nil {
+			c.Set("source", source)
+			c.Set("language", models.FindLanguage(submission.Language))
 		}
-
-		// Number of input test cases
-		numInputTestCaseFiles := len(inputTestCaseFiles)
-
-		// Number of test cases answers
-		numAnswersTestCaseFiles := len(answerTestCaseFiles)
-
-		if numInputTestCaseFiles != numAnswersTestCaseFiles {
-			fmt.Printf("Number of input files and answer files in test case folder are not equal.")
-			return "Some error occurred"
+		all := models.Submissions{}
+		err := tx.Where("user_id = ? and question_id = ? and id != ?", submission.UserID, submission.QuestionID, submission.ID).
+			Order("created_at desc").All(&all)
+		if err != nil {
+			return errors.WithStack(err)
 		}
-
-		for i := 0; i < numInputTestCaseFiles; i++ {
-
-			input, err := os.Open(testCasesPath + "/inputs/" + inputTestCaseFiles[i].Name())
-			if err != nil {
-				fmt.Println("\n\nCould not read input test case file\n\n")
-				return "Some error occurred"
-			}
-
-			cmd := exec.Command("./a.out")
-			cmd.Stdin = input
-			var out bytes.Buffer
-			cmd.Stdout = &out
-
-			if err := cmd.Start(); err != nil {
-				log.Println(err)
-			}
-
-			done := make(chan error, 1)
-			go func() {
-				done <- cmd.Wait()
-			}()
-			select {
-			case <-time.After(3 * time.Second): //TODO : Take time limit from question
-				if err := cmd.Process.Kill(); err != nil {
-					log.Println("failed to kill process: ", err)
-					return "System Error"
-				}
-				log.Println("process killed as timeout reached")
-				return "Time Limit Exceeded"
-			case err := <-done:
-				if err != nil {
-					log.Println("process finished with error = %v", err)
-					return "Runtime Error"
-				}
-				log.Print("process finished successfully")
-
-				outputString := out.String()
-				outputString = strings.Trim(outputString, "\n")
-				// fmt.Printf("Output: %q\n", outputString)
-
-				dat, err := ioutil.ReadFile(testCasesPath + "/answers/" + answerTestCaseFiles[i].Name())
-				if err != nil {
-					fmt.Println("\n\nCould not read answer test case file")
-					return "System Error"
-				}
-
-				answerString := string(dat)
-				answerString = strings.Trim(answerString, "\n")
-				//fmt.Printf("%q\n", answerString)
-
-				if strings.Compare(outputString, answerString) != 0 {
-					return "Wrong answer"
-				}
+		// Shared solutions can only be compared with other shared ones.
+		others := models.Submissions{}
+		for i := range all {
+			if access.allows(&all[i]) {
+				others = append(others, all[i])
 			}
 		}
+		c.Set("others", others)
 	}
-	return "Correct Answer"
+	return c.Render(200, r.HTML("submissions/detail.html"))
 }
@@ -1,5 +1,16 @@
 package actions
 
+import (
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/cpjudge/cpjudge/models"
+	"github.com/cpjudge/cpjudge/storage"
+	"github.com/gobuffalo/pop/nulls"
+	"github.com/gobuffalo/uuid"
+)
+
 func (as *ActionSuite) Test_Submissions_Index() {
 	as.Fail("Not Implemented!")
 }
@@ -7,3 +18,172 @@ func (as *ActionSuite) Test_Submissions_Index() {
 func (as *ActionSuite) Test_Submissions_Detail() {
 	as.Fail("Not Implemented!")
 }
+
+func (as *ActionSuite) Test_Submissions_Source_Hidden() {
+	user := &models.User{Username: "ada", Email: "ada@example.com", Password: "secret", PasswordConfirm: "secret"}
+	verrs, err := user.Create(as.DB)
+	as.NoError(err)
+	as.False(verrs.HasAny())
+	s := &models.Submission{UserID: user.ID, Status: models.StatusCorrect, Language: "C"}
+	as.NoError(as.DB.Create(s))
+
+	// Guests can neither download the source nor diff it.
+	res := as.HTML("/submissions/source/%s", s.ID).Get()
+	as.Equal(404, res.Code)
+	res = as.HTML("/submissions/diff/%s?with=%s", s.ID, s.ID).Get()
+	as.Equal(404, res.Code)
+}
+
+func (as *ActionSuite) Test_Submissions_Create_RequiresSource() {
+	user := &models.User{Username: "ada", Email: "ada@example.com", Password: "secret", PasswordConfirm: "secret"}
+	verrs, err := user.Create(as.DB)
+	as.NoError(err)
+	as.False(verrs.HasAny())
+	contest := &models.Contest{Title: "Weekly", Description: "Weekly contest"}
+	as.NoError(as.DB.Create(contest))
+	question := &models.Question{Title: "Sum", Description: "Add two numbers", ContestID: contest.ID}
+	as.NoError(as.DB.Create(question))
+
+	as.Session.Set("current_user_id", user.ID)
+	res := as.HTML("/submissions/create/%s/%s", contest.ID, question.ID).Post(map[string]string{
+		"Language":   "C",
+		"SourceCode": "   ",
+	})
+	as.Equal(422, res.Code)
+	as.Contains(res.Body.String(), "Upload a file or write your code in the editor.")
+}
+
+func (as *ActionSuite) Test_Submissions_Run() {
+	res := as.HTML("/submissions/run/00000000-0000-0000-0000-000000000000").Post(nil)
+	as.Equal(302, res.Code)
+	as.Equal("/users/login", res.Location())
+
+	user := &models.User{Username: "ada", Email: "ada@example.com", Password: "secret", PasswordConfirm: "secret"}
+	verrs, err := user.Create(as.DB)
+	as.NoError(err)
+	as.False(verrs.HasAny())
+	contest := &models.Contest{Title: "Weekly", Description: "Weekly contest"}
+	as.NoError(as.DB.Create(contest))
+	question := &models.Question{Title: "Sum", Description: "Add two numbers", ContestID: contest.ID}
+	as.NoError(as.DB.Create(question))
+
+	as.Session.Set("current_user_id", user.ID)
+	res = as.HTML("/submissions/run/%s", question.ID).Post(map[string]string{"Language": "C", "SourceCode": ""})
+	as.Equal(422, res.Code)
+	as.Contains(res.Body.String(), "Write some code")
+
+	count, err := as.DB.Count(&models.Submission{})
+	as.NoError(err)
+	as.Equal(0, count)
+}
+
+func (as *ActionSuite) Test_Submissions_Source_Shared() {
+	dir, err := ioutil.TempDir("", "storage")
+	as.NoError(err)
+	defer os.RemoveAll(dir)
+	storage.Set(storage.NewLocal(dir))
+	defer storage.Set(nil)
+
+	user := &models.User{Username: "ada", Email: "ada@example.com", Password: "secret", PasswordConfirm: "secret"}
+	verrs, err := user.Create(as.DB)
+	as.NoError(err)
+	as.False(verrs.HasAny())
+	contest := &models.Contest{
+		Title:           "Old Round",
+		Description:     "An old round",
+		EndTime:         nulls.NewTime(time.Now().Add(-time.Hour)),
+		PublicSolutions: true,
+	}
+	as.NoError(as.DB.Create(contest))
+	accepted := &models.Submission{UserID: user.ID, ContestID: contest.ID, Status: models.StatusCorrect, Language: "C", SourceCode: "int main(){}"}
+	accepted.ID = uuid.Must(uuid.NewV4())
+	accepted.SubmissionPath = accepted.StorageKey()
+	as.NoError(as.DB.Create(accepted))
+	wrong := &models.Submission{UserID: user.ID, ContestID: contest.ID, Status: models.StatusWrong, Language: "C"}
+	as.NoError(as.DB.Create(wrong))
+
+	// Guests can read accepted solutions of the ended contest only.
+	res := as.HTML("/submissions/source/%s", accepted.ID).Get()
+	as.Equal(200, res.Code)
+	as.Equal("int main(){}", res.Body.String())
+	res = as.HTML("/submissions/source/%s", wrong.ID).Get()
+	as.Equal(404, res.Code)
+}
+
+func (as *ActionSuite) Test_Submissions_Source_HiddenDuringReplay() {
+	dir, err := ioutil.TempDir("", "storage")
+	as.NoError(err)
+	defer os.RemoveAll(dir)
+	storage.Set(storage.NewLocal(dir))
+	defer storage.Set(nil)
+
+	ada := &models.User{Username: "ada", Email: "ada@example.com", Password: "secret", PasswordConfirm: "secret"}
+	verrs, err := ada.Create(as.DB)
+	as.NoError(err)
+	as.False(verrs.HasAny())
+	bob := &models.User{Username: "bob", Email: "bob@example.com", Password: "secret", PasswordConfirm: "secret"}
+	verrs, err = bob.Create(as.DB)
+	as.NoError(err)
+	as.False(verrs.HasAny())
+	end := time.Now().Add(-time.Hour)
+	contest := &models.Contest{
+		Title:           "Old Round",
+		Description:     "An old round",
+		StartTime:       nulls.NewTime(end.Add(-2 * time.Hour)),
+		EndTime:         nulls.NewTime(end),
+		PublicSolutions: true,
+	}
+	as.NoError(as.DB.Create(contest))
+	question := &models.Question{Title: "Sum", Description: "Add two numbers", ContestID: contest.ID}
+	as.NoError(as.DB.Create(question))
+	_, _, err = models.SaveEditorial(as.DB, question.ID, "Add them.", nulls.Time{})
+	as.NoError(err)
+	accepted := &models.Submission{UserID: ada.ID, ContestID: contest.ID, QuestionID: question.ID, Status: models.StatusCorrect, Language: "C", SourceCode: "int main(){}"}
+	accepted.ID = uuid.Must(uuid.NewV4())
+	accepted.SubmissionPath = accepted.StorageKey()
+	as.NoError(as.DB.Create(accepted))
+	_, err = models.StartVirtualParticipation(as.DB, bob, contest, time.Now())
+	as.NoError(err)
+
+	// Bob is replaying the contest, so its solutions and editorial would
+	// spoil it.
+	as.Session.Set("current_user_id", bob.ID)
+	res := as.HTML("/submissions/source/%s", accepted.ID).Get()
+	as.Equal(404, res.Code)
+	res = as.HTML("/questions/editorial/%s", question.ID).Get()
+	as.Equal(302, res.Code)
+	res = as.HTML("/questions/editorial/comments/%s", question.ID).Post(map[string]string{"Body": "Thanks!"})
+	as.Equal(404, res.Code)
+
+	// Guests still see them.
+	as.Session.Delete("current_user_id")
+	res = as.HTML("/submissions/source/%s", accepted.ID).Get()
+	as.Equal(200, res.Code)
+	res = as.HTML("/questions/editorial/%s", question.ID).Get()
+	as.Equal(200, res.Code)
+}
+
+func (as *ActionSuite) Test_Submissions_Create_QuestionOfOtherContest() {
+	user := &models.User{Username: "ada", Email: "ada@example.com", Password: "secret", PasswordConfirm: "secret"}
+	verrs, err := user.Create(as.DB)
+	as.NoError(err)
+	as.False(verrs.HasAny())
+	open := &models.Contest{Title: "Weekly", Description: "Weekly contest"}
+	as.NoError(as.DB.Create(open))
+	future := &models.Contest{Title: "Final", Description: "Not started", StartTime: nulls.NewTime(time.Now().Add(time.Hour))}
+	as.NoError(as.DB.Create(future))
+	secret := &models.Question{Title: "Secret", Description: "Not public yet", ContestID: future.ID}
+	as.NoError(as.DB.Create(secret))
+
+	// Submitting through an open contest does not reach a question of a
+	// contest that has not started.
+	as.Session.Set("current_user_id", user.ID)
+	res := as.HTML("/submissions/create/%s/%s", open.ID, secret.ID).Post(map[string]string{
+		"Language":   "C",
+		"SourceCode": "int main(){}",
+	})
+	as.Equal(404, res.Code)
+	count, err := as.DB.Count(&models.Submission{})
+	as.NoError(err)
+	as.Equal(0, count)
+}
@@ -0,0 +1,70 @@
+package actions
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cpjudge/cpjudge/models"
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop"
+	"github.com/pkg/errors"
+)
+
+// TagsIndex lists the tags questions can have, with how many questions have
+// each, so hosts can add, rename and remove them.
+func TagsIndex(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	host := c.Value("current_host").(*models.Host)
+	uses, err := models.TagUses(tx, host.ID)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	c.Set("uses", uses)
+	return c.Render(200, r.HTML("tags/index.html"))
+}
+
+// TagsCreate adds a tag to the vocabulary.
+func TagsCreate(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	tag, verrs, err := models.CreateTag(tx, c.Request().FormValue("Name"))
+	if err != nil {
+		return err
+	}
+	if verrs.HasAny() {
+		c.Flash().Add("danger", strings.Join(verrs.Get("tags"), " "))
+		return c.Redirect(302, "/tags/index")
+	}
+	c.Flash().Add("success", fmt.Sprintf("Tag %q was added.", tag.Name))
+	return c.Redirect(302, "/tags/index")
+}
+
+// TagsRename renames a tag on the questions of the host's contests.
+func TagsRename(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	host := c.Value("current_host").(*models.Host)
+	old := models.NormalizeTag(c.Request().FormValue("Tag"))
+	name := models.NormalizeTag(c.Request().FormValue("Name"))
+	if verrs := models.ValidateTagName(name); verrs.HasAny() {
+		c.Flash().Add("danger", strings.Join(verrs.Get("tags"), " "))
+		return c.Redirect(302, "/tags/index")
+	}
+	n, err := models.RetagQuestions(tx, host.ID, old, name)
+	if err != nil {
+		return err
+	}
+	c.Flash().Add("success", fmt.Sprintf("Tag %q was renamed to %q on %d of your questions.", old, name, n))
+	return c.Redirect(302, "/tags/index")
+}
+
+// TagsDelete removes a tag from the questions of the host's contests.
+func TagsDelete(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	host := c.Value("current_host").(*models.Host)
+	old := models.NormalizeTag(c.Request().FormValue("Tag"))
+	n, err := models.RetagQuestions(tx, host.ID, old, "")
+	if err != nil {
+		return err
+	}
+	c.Flash().Add("success", fmt.Sprintf("Tag %q was removed from %d of your questions.", old, n))
+	return c.Redirect(302, "/tags/index")
+}
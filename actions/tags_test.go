@@ -0,0 +1,19 @@
+package actions
+
+func (as *ActionSuite) Test_Tags_Index_RequiresHost() {
+	res := as.HTML("/tags/index").Get()
+	as.Equal(302, res.Code)
+	as.Equal("/", res.Location())
+}
+
+func (as *ActionSuite) Test_Tags_Rename_RequiresHost() {
+	res := as.HTML("/tags/rename").Post(map[string]string{"Tag": "dp", "Name": "dynamic programming"})
+	as.Equal(302, res.Code)
+	as.Equal("/", res.Location())
+}
+
+func (as *ActionSuite) Test_Tags_Delete_RequiresHost() {
+	res := as.HTML("/tags/delete").Post(map[string]string{"Tag": "dp"})
+	as.Equal(302, res.Code)
+	as.Equal("/", res.Location())
+}
@@ -0,0 +1,244 @@
+package actions
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image/png"
+	"strings"
+
+	"github.com/cpjudge/cpjudge/models"
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop"
+	"github.com/gobuffalo/uuid"
+	"github.com/pkg/errors"
+)
+
+// HostsTwoFactorSetupGet shows the QR code to enroll a host in two-factor
+// authentication.
+func HostsTwoFactorSetupGet(c buffalo.Context) error {
+	host := c.Value("current_host").(*models.Host)
+	return twoFactorSetupGet(c, "hosts", host.Email)
+}
+
+// HostsTwoFactorSetupPost enables two-factor authentication for a host.
+func HostsTwoFactorSetupPost(c buffalo.Context) error {
+	host := c.Value("current_host").(*models.Host)
+	return twoFactorSetupPost(c, "hosts", host.ID)
+}
+
+// HostsTwoFactorGet asks a host who passed the password check for a code.
+func HostsTwoFactorGet(c buffalo.Context) error {
+	if c.Session().Get("pending_host_id") == nil {
+		return c.Redirect(302, "/hosts/login")
+	}
+	c.Set("kind", "hosts")
+	return c.Render(200, r.HTML("two_factor/verify.html"))
+}
+
+// HostsTwoFactorPost completes a host login with a TOTP or recovery code.
+func HostsTwoFactorPost(c buffalo.Context) error {
+	hid, err := uuid.FromString(fmt.Sprint(c.Session().Get("pending_host_id")))
+	if err != nil {
+		return c.Redirect(302, "/hosts/login")
+	}
+	tx := c.Value("tx").(*pop.Connection)
+	host := &models.Host{}
+	if err := tx.Find(host, hid); err != nil {
+		return c.Redirect(302, "/hosts/login")
+	}
+	ok, err := twoFactorVerify(c, "hosts", "host", hid, host.Email)
+	if !ok {
+		return err
+	}
+	c.Session().Delete("pending_host_id")
+	c.Session().Set("current_host_id", hid)
+	c.Flash().Add("success", "Welcome back!")
+	return c.Redirect(302, "/contests/host_index")
+}
+
+// UsersTwoFactorSetupGet shows the QR code to enroll a user in two-factor
+// authentication.
+func UsersTwoFactorSetupGet(c buffalo.Context) error {
+	user, ok := c.Value("current_user").(*models.User)
+	if !ok {
+		return c.Redirect(302, "/users/login")
+	}
+	return twoFactorSetupGet(c, "users", user.Email)
+}
+
+// UsersTwoFactorSetupPost enables two-factor authentication for a user.
+func UsersTwoFactorSetupPost(c buffalo.Context) error {
+	user, ok := c.Value("current_user").(*models.User)
+	if !ok {
+		return c.Redirect(302, "/users/login")
+	}
+	return twoFactorSetupPost(c, "users", user.ID)
+}
+
+// UsersTwoFactorGet asks a user who passed the password check for a code.
+func UsersTwoFactorGet(c buffalo.Context) error {
+	if c.Session().Get("pending_user_id") == nil {
+		return c.Redirect(302, "/users/login")
+	}
+	c.Set("kind", "users")
+	return c.Render(200, r.HTML("two_factor/verify.html"))
+}
+
+// UsersTwoFactorPost completes a user login with a TOTP or recovery code.
+func UsersTwoFactorPost(c buffalo.Context) error {
+	uid, err := uuid.FromString(fmt.Sprint(c.Session().Get("pending_user_id")))
+	if err != nil {
+		return c.Redirect(302, "/users/login")
+	}
+	tx := c.Value("tx").(*pop.Connection)
+	user := &models.User{}
+	if err := tx.Find(user, uid); err != nil {
+		return c.Redirect(302, "/users/login")
+	}
+	ok, err := twoFactorVerify(c, "users", "user", uid, user.Email)
+	if !ok {
+		return err
+	}
+	c.Session().Delete("pending_user_id")
+	c.Session().Set("current_user_id", uid)
+	c.Flash().Add("success", "Welcome back!")
+	return c.Redirect(302, "/contests/user_index")
+}
+
+// twoFactorSetupGet generates a secret, keeps it in the session until it is
+// confirmed and renders it as a QR code.
+func twoFactorSetupGet(c buffalo.Context, kind, accountName string) error {
+	key, err := models.NewTOTPKey(accountName)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	img, err := key.Image(200, 200)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return errors.WithStack(err)
+	}
+	c.Session().Set("totp_setup_secret", key.Secret())
+	c.Set("kind", kind)
+	c.Set("secret", key.Secret())
+	c.Set("qr", "data:image/png;base64,"+base64.StdEncoding.EncodeToString(buf.Bytes()))
+	return c.Render(200, r.HTML("two_factor/setup.html"))
+}
+
+// twoFactorSetupPost confirms the secret from the session with a code and
+// shows the recovery codes once.
+func twoFactorSetupPost(c buffalo.Context, kind string, ownerID uuid.UUID) error {
+	secret, ok := c.Session().Get("totp_setup_secret").(string)
+	if !ok {
+		return c.Redirect(302, "/%s/two_factor/setup", kind)
+	}
+	tx := c.Value("tx").(*pop.Connection)
+	tf, err := models.FindTwoFactor(tx, ownerID)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	codes, err := tf.Enable(tx, secret, c.Request().FormValue("Code"))
+	if err != nil {
+		if errors.Cause(err) == models.ErrInvalidCode {
+			c.Flash().Add("danger", err.Error())
+			return c.Redirect(302, "/%s/two_factor/setup", kind)
+		}
+		return errors.WithStack(err)
+	}
+	c.Session().Delete("totp_setup_secret")
+	c.Set("codes", codes)
+	c.Flash().Add("success", "Two-factor authentication is now enabled.")
+	return c.Render(200, r.HTML("two_factor/recovery_codes.html"))
+}
+
+// twoFactorVerify checks the code posted by an account that passed the
+// password check. Failed codes are throttled per account like passwords,
+// under their own account type so that logging in again with the password
+// does not reset them. Once the account is throttled the pending login is
+// dropped and the password has to be entered again. When the code is not
+// accepted it returns false, having rendered or redirected.
+func twoFactorVerify(c buffalo.Context, kind, accountType string, ownerID uuid.UUID, email string) (bool, error) {
+	attemptType := accountType + "_two_factor"
+	ip := clientIP(c.Request())
+	wait, err := models.LoginWait(models.DB, attemptType, email, ip)
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	c.Set("kind", kind)
+	if wait > 0 {
+		c.Set("errors", map[string][]string{"Code": {fmt.Sprintf("Too many invalid authentication codes. Please try again in %s.", roundWait(wait))}})
+		return false, c.Render(429, r.HTML("two_factor/verify.html"))
+	}
+	ok, err := twoFactorCheck(c, ownerID)
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	// Like password logins, attempts are recorded outside the request
+	// transaction so that failures are kept when it is rolled back.
+	if err := models.RecordLoginAttempt(models.DB, attemptType, email, ip, ok); err != nil {
+		return false, errors.WithStack(err)
+	}
+	if ok {
+		return true, nil
+	}
+	wait, err = models.LoginWait(models.DB, attemptType, email, ip)
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	if wait > 0 {
+		c.Session().Delete("pending_" + accountType + "_id")
+		c.Flash().Add("danger", "Too many invalid authentication codes. Please log in again.")
+		return false, c.Redirect(302, "/%s/login", kind)
+	}
+	c.Set("errors", map[string][]string{"Code": {"Invalid authentication code."}})
+	return false, c.Render(422, r.HTML("two_factor/verify.html"))
+}
+
+func twoFactorCheck(c buffalo.Context, ownerID uuid.UUID) (bool, error) {
+	tx := c.Value("tx").(*pop.Connection)
+	tf, err := models.FindTwoFactor(tx, ownerID)
+	if err != nil {
+		return false, err
+	}
+	return tf.Check(tx, c.Request().FormValue("Code"))
+}
+
+// twoFactorRequired reports whether an account must log in with a second
+// factor: hosts always, users only when they are admins.
+func twoFactorRequired(c buffalo.Context) (string, uuid.UUID, bool) {
+	if host, ok := c.Value("current_host").(*models.Host); ok {
+		return "hosts", host.ID, true
+	}
+	if user, ok := c.Value("current_user").(*models.User); ok && user.Admin {
+		return "users", user.ID, true
+	}
+	return "", uuid.Nil, false
+}
+
+// RequireTwoFactor sends hosts and admins who have not enrolled in
+// two-factor authentication to the setup page.
+func RequireTwoFactor(next buffalo.Handler) buffalo.Handler {
+	return func(c buffalo.Context) error {
+		kind, ownerID, required := twoFactorRequired(c)
+		if !required {
+			return next(c)
+		}
+		path := c.Request().URL.Path
+		if strings.HasPrefix(path, "/"+kind+"/two_factor") || path == "/"+kind+"/logout" {
+			return next(c)
+		}
+		tx := c.Value("tx").(*pop.Connection)
+		tf, err := models.FindTwoFactor(tx, ownerID)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if tf.Enabled {
+			return next(c)
+		}
+		c.Flash().Add("warning", "Please set up two-factor authentication to continue.")
+		return c.Redirect(302, "/%s/two_factor/setup", kind)
+	}
+}
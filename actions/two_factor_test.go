@@ -0,0 +1,7 @@
+package actions
+
+func (as *ActionSuite) Test_Hosts_TwoFactor_WithoutPendingLogin() {
+	res := as.HTML("/hosts/two_factor").Get()
+	as.Equal(302, res.Code)
+	as.Equal("/hosts/login", res.Location())
+}
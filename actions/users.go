@@ -1,6 +1,9 @@
 package actions
 
 import (
+	"fmt"
+
+	"github.com/cpjudge/cpjudge/mailers"
 	"github.com/cpjudge/cpjudge/models"
 	"github.com/gobuffalo/buffalo"
 	"github.com/gobuffalo/pop"
@@ -41,7 +44,10 @@ func UsersRegisterPost(c buffalo.Context) error {
 		return c.Render(422, r.HTML("users/register.html"))
 	}
 	// If there are no errors set a success message
-	c.Flash().Add("success", "Account created successfully. Proceed to Login.")
+	if err := mailers.SendVerificationEmail(user.Username, user.Email, "/users/verify", user.VerificationToken); err != nil {
+		return errors.WithStack(err)
+	}
+	c.Flash().Add("success", "Account created successfully. Check your email to verify your address, then proceed to Login.")
 	// and redirect to the home page
 	return c.Redirect(302, "/")
 }
@@ -59,7 +65,39 @@ func UsersLoginPost(c buffalo.Context) error {
 		return errors.WithStack(err)
 	}
 	tx := c.Value("tx").(*pop.Connection)
-	err := user.Authorize(tx)
+	ip := clientIP(c.Request())
+	wait, err := models.LoginWait(models.DB, "user", user.Email, ip)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if wait > 0 {
+		c.Set("user", user)
+		verrs := validate.NewErrors()
+		verrs.Add("Login", fmt.Sprintf("Too many failed login attempts. Please try again in %s.", roundWait(wait)))
+		c.Set("errors", verrs.Errors)
+		return c.Render(429, r.HTML("users/login"))
+	}
+	email := user.Email
+	err = user.Authorize(tx)
+	// Attempts are recorded outside the request transaction so that failed
+	// logins are kept even though the request is rolled back.
+	success := err == nil || errors.Cause(err) == models.ErrNotVerified
+	if err := models.RecordLoginAttempt(models.DB, "user", email, ip, success); err != nil {
+		return errors.WithStack(err)
+	}
+	if errors.Cause(err) == models.ErrNotVerified {
+		if err := user.RefreshVerificationToken(tx); err != nil {
+			return errors.WithStack(err)
+		}
+		if err := mailers.SendVerificationEmail(user.Username, user.Email, "/users/verify", user.VerificationToken); err != nil {
+			return errors.WithStack(err)
+		}
+		c.Set("user", user)
+		verrs := validate.NewErrors()
+		verrs.Add("Login", "Your email address is not verified yet. We have sent you a new verification link.")
+		c.Set("errors", verrs.Errors)
+		return c.Render(422, r.HTML("users/login"))
+	}
 	if err != nil {
 		c.Set("user", user)
 		verrs := validate.NewErrors()
@@ -67,11 +105,174 @@ func UsersLoginPost(c buffalo.Context) error {
 		c.Set("errors", verrs.Errors)
 		return c.Render(422, r.HTML("users/login"))
 	}
+	return logInUser(c, user)
+}
+
+// logInUser starts the session of a user whose first factor was checked,
+// or asks for their TOTP code when two-factor authentication is enabled.
+func logInUser(c buffalo.Context, user *models.User) error {
+	tx := c.Value("tx").(*pop.Connection)
+	tf, err := models.FindTwoFactor(tx, user.ID)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if tf.Enabled {
+		c.Session().Set("pending_user_id", user.ID.String())
+		return c.Redirect(302, "/users/two_factor")
+	}
 	c.Session().Set("current_user_id", user.ID)
 	c.Flash().Add("success", "Welcome back!")
 	return c.Redirect(302, "/contests/user_index")
 }
 
+// UsersVerify confirms the email address of a user from the link mailed
+// at registration.
+func UsersVerify(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	if _, err := models.VerifyUser(tx, c.Param("token")); err != nil {
+		if errors.Cause(err) == models.ErrInvalidToken {
+			c.Flash().Add("danger", err.Error())
+			return c.Redirect(302, "/users/login")
+		}
+		return errors.WithStack(err)
+	}
+	c.Flash().Add("success", "Your email address has been verified. Proceed to Login.")
+	return c.Redirect(302, "/users/login")
+}
+
+// UsersForgotPasswordGet displays a form asking for the account email.
+func UsersForgotPasswordGet(c buffalo.Context) error {
+	return c.Render(200, r.HTML("users/forgot_password.html"))
+}
+
+// UsersForgotPasswordPost mails a password reset link. The response is the
+// same whether or not the email belongs to an account.
+func UsersForgotPasswordPost(c buffalo.Context) error {
+	user := &models.User{}
+	if err := c.Bind(user); err != nil {
+		return errors.WithStack(err)
+	}
+	tx := c.Value("tx").(*pop.Connection)
+	token, err := user.StartPasswordReset(tx)
+	switch errors.Cause(err) {
+	case nil:
+		if err := mailers.SendPasswordResetEmail(user.Username, user.Email, "/users/reset_password", token); err != nil {
+			return errors.WithStack(err)
+		}
+	case models.ErrNoAccount:
+	default:
+		return errors.WithStack(err)
+	}
+	c.Flash().Add("success", "If an account exists for that email, a password reset link has been sent to it.")
+	return c.Redirect(302, "/users/login")
+}
+
+// UsersResetPasswordGet displays a form to choose a new password.
+func UsersResetPasswordGet(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	if _, err := models.FindUserByResetToken(tx, c.Param("token")); err != nil {
+		if errors.Cause(err) == models.ErrInvalidToken {
+			c.Flash().Add("danger", err.Error())
+			return c.Redirect(302, "/users/forgot_password")
+		}
+		return errors.WithStack(err)
+	}
+	c.Set("token", c.Param("token"))
+	return c.Render(200, r.HTML("users/reset_password.html"))
+}
+
+// UsersResetPasswordPost sets a new password for the owner of the token.
+func UsersResetPasswordPost(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	user, err := models.FindUserByResetToken(tx, c.Param("token"))
+	if err != nil {
+		if errors.Cause(err) == models.ErrInvalidToken {
+			c.Flash().Add("danger", err.Error())
+			return c.Redirect(302, "/users/forgot_password")
+		}
+		return errors.WithStack(err)
+	}
+	user.Password = c.Request().FormValue("Password")
+	user.PasswordConfirm = c.Request().FormValue("PasswordConfirm")
+	verrs, err := user.ResetPassword(tx)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if verrs.HasAny() {
+		c.Set("token", c.Param("token"))
+		c.Set("errors", verrs.Errors)
+		return c.Render(422, r.HTML("users/reset_password.html"))
+	}
+	c.Flash().Add("success", "Your password has been changed. Proceed to Login.")
+	return c.Redirect(302, "/users/login")
+}
+
+// UsersProfile displays the public profile of a user.
+func UsersProfile(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	user := &models.User{}
+	if err := tx.Where("username = ?", c.Param("username")).First(user); err != nil {
+		return c.Error(404, err)
+	}
+	profile, err := models.UserProfile(tx, *user)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	c.Set("profile", profile)
+	return c.Render(200, r.HTML("users/profile.html"))
+}
+
+// UsersEditProfileGet displays a form to edit the current user's profile.
+func UsersEditProfileGet(c buffalo.Context) error {
+	c.Set("user", c.Value("current_user"))
+	return c.Render(200, r.HTML("users/edit_profile.html"))
+}
+
+// UsersEditProfilePost updates the current user's profile fields.
+func UsersEditProfilePost(c buffalo.Context) error {
+	user := c.Value("current_user").(*models.User)
+	// Only copy the editable fields, binding the form to the user would
+	// let it overwrite anything, including Admin.
+	user.DisplayName = c.Request().FormValue("DisplayName")
+	user.Institution = c.Request().FormValue("Institution")
+	user.Country = c.Request().FormValue("Country")
+	tx := c.Value("tx").(*pop.Connection)
+	verrs, err := user.UpdateProfile(tx)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if verrs.HasAny() {
+		c.Set("user", user)
+		c.Set("errors", verrs.Errors)
+		return c.Render(422, r.HTML("users/edit_profile.html"))
+	}
+	c.Flash().Add("success", "Profile updated successfully.")
+	return c.Redirect(302, "/users/profile/%s", user.Username)
+}
+
+// UserRequired requires a user to be logged in before accessing a route.
+func UserRequired(next buffalo.Handler) buffalo.Handler {
+	return func(c buffalo.Context) error {
+		_, ok := c.Value("current_user").(*models.User)
+		if ok {
+			return next(c)
+		}
+		c.Flash().Add("danger", "You must be logged in to view that page.")
+		return c.Redirect(302, "/users/login")
+	}
+}
+
+// AdminRequired only lets admins through.
+func AdminRequired(next buffalo.Handler) buffalo.Handler {
+	return func(c buffalo.Context) error {
+		if user, ok := c.Value("current_user").(*models.User); ok && user.Admin {
+			return next(c)
+		}
+		c.Flash().Add("danger", "You are not authorized to view that page.")
+		return c.Redirect(302, "/")
+	}
+}
+
 // UsersLogout clears the session and logs out the user.
 func UsersLogout(c buffalo.Context) error {
 	c.Session().Clear()
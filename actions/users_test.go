@@ -27,3 +27,29 @@ func (as *ActionSuite) Test_UsersResource_Update() {
 func (as *ActionSuite) Test_UsersResource_Destroy() {
 	as.Fail("Not Implemented!")
 }
+
+func (as *ActionSuite) Test_Users_ForgotPassword() {
+	res := as.HTML("/users/forgot_password").Get()
+	as.Equal(200, res.Code)
+
+	res = as.HTML("/users/forgot_password").Post(map[string]string{"Email": "nobody@example.com"})
+	as.Equal(302, res.Code)
+	as.Equal("/users/login", res.Location())
+}
+
+func (as *ActionSuite) Test_Users_ResetPassword_InvalidToken() {
+	res := as.HTML("/users/reset_password/bogus").Get()
+	as.Equal(302, res.Code)
+	as.Equal("/users/forgot_password", res.Location())
+}
+
+func (as *ActionSuite) Test_Users_Profile_NotFound() {
+	res := as.HTML("/users/profile/nobody").Get()
+	as.Equal(404, res.Code)
+}
+
+func (as *ActionSuite) Test_Users_EditProfile_RequiresLogin() {
+	res := as.HTML("/users/edit_profile").Get()
+	as.Equal(302, res.Code)
+	as.Equal("/users/login", res.Location())
+}
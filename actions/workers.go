@@ -0,0 +1,263 @@
+package actions
+
+import (
+	"archive/zip"
+	"crypto/subtle"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/cpjudge/cpjudge/judge"
+	"github.com/cpjudge/cpjudge/models"
+	"github.com/cpjudge/cpjudge/storage"
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/envy"
+	"github.com/gobuffalo/pop"
+	"github.com/gobuffalo/uuid"
+	"github.com/pkg/errors"
+)
+
+// WorkerTokenRequired lets through requests carrying the JUDGE_TOKEN shared
+// with the judge workers. The worker API is disabled when it is not set.
+func WorkerTokenRequired(next buffalo.Handler) buffalo.Handler {
+	return func(c buffalo.Context) error {
+		token := envy.Get("JUDGE_TOKEN", "")
+		given := strings.TrimPrefix(c.Request().Header.Get("Authorization"), "Bearer ")
+		if token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(given)) != 1 {
+			return c.Render(403, r.JSON(map[string]string{"error": "Invalid judge token."}))
+		}
+		return next(c)
+	}
+}
+
+func findWorker(c buffalo.Context) (*models.Worker, error) {
+	tx := c.Value("tx").(*pop.Connection)
+	w := &models.Worker{}
+	if err := tx.Find(w, c.Param("wid")); err != nil {
+		return nil, c.Error(404, err)
+	}
+	return w, nil
+}
+
+// JudgeWorkersRegister records a new worker and returns its ID.
+func JudgeWorkersRegister(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	info := judge.WorkerInfo{}
+	if err := c.Bind(&info); err != nil {
+		return errors.WithStack(err)
+	}
+	w, err := models.RegisterWorker(tx, info.Name, time.Now())
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return c.Render(200, r.JSON(judge.WorkerInfo{ID: w.ID.String(), Name: w.Name}))
+}
+
+// JudgeWorkersHeartbeat keeps a worker and the job it runs alive.
+func JudgeWorkersHeartbeat(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	w, err := findWorker(c)
+	if err != nil {
+		return err
+	}
+	if err := w.Heartbeat(tx, time.Now()); err != nil {
+		return errors.WithStack(err)
+	}
+	return c.Render(204, nil)
+}
+
+// JudgeWorkersPull hands the next job to a worker, or responds with 204
+// when there is none.
+func JudgeWorkersPull(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	w, err := findWorker(c)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if err := w.Heartbeat(tx, now); err != nil {
+		return errors.WithStack(err)
+	}
+	job, err := models.ClaimJudgeJob(tx, w, now)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if job == nil {
+		return c.Render(204, nil)
+	}
+
+	if job.ReferenceSolutionID.Valid {
+		return pullReferenceJob(c, w, job)
+	}
+	submission := &models.Submission{}
+	if err := tx.Find(submission, job.SubmissionID.UUID); err != nil {
+		return errors.WithStack(err)
+	}
+	question, err := jobQuestion(tx, job, submission.QuestionID)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	source, err := submission.Source()
+	if err != nil {
+		log.Printf("judge: source of submission %s: %v", submission.ID, err)
+		if err := judge.Complete(tx, job, w, judge.StatusSystemError); err != nil {
+			return errors.WithStack(err)
+		}
+		return c.Render(204, nil)
+	}
+	return c.Render(200, r.JSON(judge.NewJob(job, submission, question, source)))
+}
+
+// pullReferenceJob hands a claimed job of a reference solution to a worker.
+func pullReferenceJob(c buffalo.Context, w *models.Worker, job *models.JudgeJob) error {
+	tx := c.Value("tx").(*pop.Connection)
+	solution := &models.ReferenceSolution{}
+	if err := tx.Find(solution, job.ReferenceSolutionID.UUID); err != nil {
+		return errors.WithStack(err)
+	}
+	question, err := jobQuestion(tx, job, solution.QuestionID)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	source, err := solution.Source()
+	if err != nil {
+		log.Printf("judge: source of reference solution %s: %v", solution.ID, err)
+		if err := judge.Complete(tx, job, w, judge.StatusSystemError); err != nil {
+			return errors.WithStack(err)
+		}
+		return c.Render(204, nil)
+	}
+	return c.Render(200, r.JSON(judge.NewReferenceJob(job, solution, question, source)))
+}
+
+// jobQuestion loads the question a job is judged on and records the
+// version of its test data on the job. Later uploads do not affect the
+// version the worker is given.
+func jobQuestion(tx *pop.Connection, job *models.JudgeJob, questionID uuid.UUID) (*models.Question, error) {
+	question := &models.Question{}
+	if err := tx.Find(question, questionID); err != nil {
+		return nil, err
+	}
+	if question.TestCasesSum == "" {
+		if err := question.UpdateChecksum(tx); err != nil {
+			return nil, err
+		}
+	}
+	job.TestDataVersion = question.TestCasesSum
+	return question, tx.Update(job)
+}
+
+// findWorkerJob returns the job in the URL if it still belongs to the worker.
+func findWorkerJob(c buffalo.Context) (*models.Worker, *models.JudgeJob, error) {
+	tx := c.Value("tx").(*pop.Connection)
+	w, err := findWorker(c)
+	if err != nil {
+		return nil, nil, err
+	}
+	job, err := models.FindWorkerJob(tx, w, c.Param("jid"))
+	if err == models.ErrJobNotAssigned {
+		return nil, nil, c.Render(409, r.JSON(map[string]string{"error": err.Error()}))
+	}
+	if err != nil {
+		return nil, nil, c.Error(404, err)
+	}
+	return w, job, nil
+}
+
+// JudgeJobsProgress records which test a worker is running.
+func JudgeJobsProgress(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	w, job, err := findWorkerJob(c)
+	if job == nil {
+		return err
+	}
+	progress := judge.JobProgress{}
+	if err := c.Bind(&progress); err != nil {
+		return errors.WithStack(err)
+	}
+	now := time.Now()
+	if err := job.Progress(tx, progress.Test, progress.Total, now); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := w.Heartbeat(tx, now); err != nil {
+		return errors.WithStack(err)
+	}
+	return c.Render(204, nil)
+}
+
+// JudgeJobsFinish stores the verdict of a job.
+func JudgeJobsFinish(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	w, job, err := findWorkerJob(c)
+	if job == nil {
+		return err
+	}
+	result := judge.JobResult{}
+	if err := c.Bind(&result); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := judge.Complete(tx, job, w, result.Status); err != nil {
+		return errors.WithStack(err)
+	}
+	return c.Render(204, nil)
+}
+
+// JudgeTestData sends a version of the test cases of a question, by
+// checksum, as a zip.
+func JudgeTestData(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	question := &models.Question{}
+	if err := tx.Find(question, c.Param("qid")); err != nil {
+		return c.Error(404, err)
+	}
+	p, err := question.TestDataPath(tx, c.Param("checksum"))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if p == "" {
+		return c.Error(404, errors.New("unknown test data version"))
+	}
+	store := storage.Default()
+	prefix := p + "/"
+	keys, err := store.List(prefix)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	res := c.Response()
+	res.Header().Set("Content-Type", "application/zip")
+	res.WriteHeader(200)
+	zw := zip.NewWriter(res)
+	for _, key := range keys {
+		f, err := zw.Create(strings.TrimPrefix(key, prefix))
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		b, err := storage.ReadAll(store, key)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if _, err := f.Write(b); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return errors.WithStack(zw.Close())
+}
+
+// JudgeWorkersIndex shows admins the registered workers and the queue.
+func JudgeWorkersIndex(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	workers, err := models.LoadWorkers(tx)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	queued, err := models.CountQueuedJobs(tx)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	c.Set("workers", workers)
+	c.Set("queued", queued)
+	c.Set("now", time.Now())
+	c.Set("use_workers", judge.UseWorkers())
+	return c.Render(200, r.HTML("judge/workers.html"))
+}
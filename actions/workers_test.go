@@ -0,0 +1,93 @@
+package actions
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/cpjudge/cpjudge/judge"
+	"github.com/cpjudge/cpjudge/models"
+	"github.com/gobuffalo/envy"
+)
+
+func (as *ActionSuite) Test_Judge_RequiresToken() {
+	envy.Set("JUDGE_TOKEN", "")
+	res := as.JSON("/judge/workers/register").Post(judge.WorkerInfo{Name: "w"})
+	as.Equal(403, res.Code)
+
+	envy.Set("JUDGE_TOKEN", "secret")
+	defer envy.Set("JUDGE_TOKEN", "")
+	req := as.JSON("/judge/workers/register")
+	req.Headers["Authorization"] = "Bearer wrong"
+	as.Equal(403, req.Post(judge.WorkerInfo{Name: "w"}).Code)
+}
+
+func (as *ActionSuite) Test_Judge_PullAndFinish() {
+	envy.Set("JUDGE_TOKEN", "secret")
+	defer envy.Set("JUDGE_TOKEN", "")
+	call := func(path string, body interface{}) (int, []byte) {
+		req := as.JSON(path)
+		req.Headers["Authorization"] = "Bearer secret"
+		res := req.Post(body)
+		return res.Code, res.Body.Bytes()
+	}
+
+	code, body := call("/judge/workers/register", judge.WorkerInfo{Name: "box"})
+	as.Equal(200, code)
+	info := judge.WorkerInfo{}
+	as.NoError(json.Unmarshal(body, &info))
+
+	code, _ = call("/judge/workers/pull/"+info.ID, nil)
+	as.Equal(204, code)
+
+	user := &models.User{Username: "ada", Email: "ada@example.com", Password: "secret", PasswordConfirm: "secret"}
+	verrs, err := user.Create(as.DB)
+	as.NoError(err)
+	as.False(verrs.HasAny())
+	contest := &models.Contest{Title: "Weekly", Description: "Weekly contest"}
+	as.NoError(as.DB.Create(contest))
+	question := &models.Question{Title: "Sum", Description: "Add two numbers", ContestID: contest.ID, TestCasesSum: "abc"}
+	as.NoError(as.DB.Create(question))
+	s := &models.Submission{UserID: user.ID, ContestID: contest.ID, QuestionID: question.ID, Status: models.StatusPending, Language: "C", SourceCode: "int main(){return 0;}"}
+	as.NoError(as.DB.Create(s))
+	s.SubmissionPath = s.StorageKey()
+	as.NoError(as.DB.Update(s))
+	_, err = models.EnqueueJudgeJob(as.DB, s.ID)
+	as.NoError(err)
+
+	code, body = call("/judge/workers/pull/"+info.ID, nil)
+	as.Equal(200, code)
+	job := judge.Job{}
+	as.NoError(json.Unmarshal(body, &job))
+	as.Equal(s.ID.String(), job.SubmissionID)
+	as.Equal("int main(){return 0;}", job.Source)
+
+	code, _ = call("/judge/jobs/progress/"+info.ID+"/"+job.ID, judge.JobProgress{Test: 1, Total: 3})
+	as.Equal(204, code)
+	code, _ = call("/judge/jobs/finish/"+info.ID+"/"+job.ID, judge.JobResult{Status: models.StatusCorrect})
+	as.Equal(204, code)
+	as.NoError(as.DB.Reload(s))
+	as.Equal(models.StatusCorrect, s.Status)
+
+	// A finished job cannot be reported on again.
+	code, _ = call("/judge/jobs/finish/"+info.ID+"/"+job.ID, judge.JobResult{Status: models.StatusWrong})
+	as.Equal(409, code)
+}
+
+func (as *ActionSuite) Test_Judge_WorkersPage_RequiresAdmin() {
+	res := as.HTML("/judge/workers").Get()
+	as.Equal(302, res.Code)
+
+	admin := &models.User{Username: "root", Email: "root@example.com", Password: "secret", PasswordConfirm: "secret"}
+	verrs, err := admin.Create(as.DB)
+	as.NoError(err)
+	as.False(verrs.HasAny())
+	admin.Admin = true
+	as.NoError(as.DB.Update(admin))
+	as.NoError(as.DB.Create(&models.TwoFactor{OwnerID: admin.ID, Secret: "JBSWY3DPEHPK3PXP", Enabled: true}))
+	as.Session.Set("current_user_id", admin.ID)
+	_, err = models.RegisterWorker(as.DB, "box", time.Now())
+	as.NoError(err)
+	res = as.HTML("/judge/workers").Get()
+	as.Equal(200, res.Code)
+	as.Contains(res.Body.String(), "box")
+}
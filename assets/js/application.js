@@ -2,9 +2,99 @@
 require("expose-loader?$!expose-loader?jQuery!jquery");
 require("popper.js/dist/popper.min.js");
 require("bootstrap/dist/js/bootstrap.min.js");
+const hljs = require("highlight.js");
+const renderMathInElement = require("katex/dist/contrib/auto-render.js");
 
 $(() => {
+  $("pre.source code").each((i, block) => hljs.highlightBlock(block));
 
+  // Typeset the math of statements, which the server wraps in \( \) and
+  // \[ \] so markdown leaves it alone.
+  $(".statement").each((i, el) => renderMathInElement(el, {
+    delimiters: [
+      {left: "\\[", right: "\\]", display: true},
+      {left: "\\(", right: "\\)", display: false},
+    ],
+    throwOnError: false,
+  }));
+
+  // Add a known tag to the question's tags.
+  $("button.add-tag").on("click", (e) => {
+    const input = $("#tags");
+    const tags = input.val().split(",").map((t) => t.trim()).filter((t) => t !== "");
+    const tag = $(e.currentTarget).data("tag");
+    if (tags.indexOf(tag) < 0) {
+      tags.push(tag);
+    }
+    input.val(tags.join(", "));
+  });
+
+  // Keep a draft of the editor per question.
+  $("form.submission-form").each((i, form) => {
+    const key = "draft:" + $(form).data("question");
+    const editor = $(form).find("textarea.code-editor");
+    if (!editor.val() && localStorage.getItem(key)) {
+      editor.val(localStorage.getItem(key));
+    }
+    editor.on("input", () => localStorage.setItem(key, editor.val()));
+    $(form).on("submit", () => {
+      if (editor.val().trim() !== "") {
+        localStorage.removeItem(key);
+      }
+    });
+  });
+
+  // Run the editor's code on custom input without submitting it.
+  $("button.run-code").on("click", (e) => {
+    const button = $(e.currentTarget);
+    const form = button.closest("form");
+    const result = form.find(".run-result");
+    button.prop("disabled", true);
+    $.ajax({
+      url: button.data("url"),
+      method: "POST",
+      data: new FormData(form[0]),
+      processData: false,
+      contentType: false,
+      dataType: "json",
+    }).done((res) => {
+      result.find(".run-status").text(res.status);
+      result.find(".run-usage").text(res.time_ms + " ms, " + res.memory_kb + " KB");
+      result.find(".run-stdout").text(res.stdout);
+      result.find(".run-stderr").text(res.stderr);
+    }).fail((xhr) => {
+      const res = xhr.responseJSON || { error: "The code could not be run. Please try again later." };
+      result.find(".run-status").text(res.error);
+      result.find(".run-usage, .run-stdout, .run-stderr").text("");
+    }).always(() => {
+      result.removeClass("d-none");
+      button.prop("disabled", false);
+    });
+  });
+
+  // Copy sample tests to the clipboard.
+  $("button.copy-sample").on("click", (e) => {
+    const button = $(e.currentTarget);
+    const text = button.parent().next("pre").text();
+    const area = $("<textarea>").val(text).appendTo("body").select();
+    document.execCommand("copy");
+    area.remove();
+    button.text("Copied");
+    setTimeout(() => button.text("Copy"), 1500);
+  });
+
+  // Insert spaces instead of moving the focus on Tab.
+  $("textarea.code-editor").on("keydown", (e) => {
+    if (e.key !== "Tab") {
+      return;
+    }
+    e.preventDefault();
+    const el = e.target;
+    const start = el.selectionStart;
+    el.value = el.value.substring(0, start) + "    " + el.value.substring(el.selectionEnd);
+    el.selectionStart = el.selectionEnd = start + 4;
+    $(el).trigger("input");
+  });
 });
 
 // bootstrap 3
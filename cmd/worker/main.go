@@ -0,0 +1,43 @@
+// Command worker judges submissions for a cpjudge server. Run as many as
+// needed, on any machine that can reach the server and has gcc and g++:
+//
+//	JUDGE_TOKEN=secret worker -server https://judge.example.com
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/cpjudge/cpjudge/judge"
+)
+
+func main() {
+	hostname, _ := os.Hostname()
+	w := &judge.Worker{}
+	flag.StringVar(&w.Server, "server", "http://127.0.0.1:3000", "URL of the cpjudge server")
+	flag.StringVar(&w.Name, "name", hostname, "name shown on the worker status page")
+	flag.StringVar(&w.CacheDir, "cache", filepath.Join(os.TempDir(), "cpjudge-testdata"), "directory to cache test data in")
+	flag.DurationVar(&w.PollInterval, "poll", 2*time.Second, "how often to ask for work when idle")
+	flag.DurationVar(&w.HeartbeatInterval, "heartbeat", 10*time.Second, "how often to tell the server the worker is alive")
+	flag.Parse()
+	w.Token = os.Getenv("JUDGE_TOKEN")
+	if w.Token == "" {
+		log.Fatal("JUDGE_TOKEN must be set")
+	}
+
+	stop := make(chan struct{})
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		close(stop)
+	}()
+	if err := w.Run(stop); err != nil {
+		log.Fatal(err)
+	}
+}
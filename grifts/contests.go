@@ -0,0 +1,75 @@
+package grifts
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/cpjudge/cpjudge/judge"
+	"github.com/cpjudge/cpjudge/models"
+	"github.com/gobuffalo/pop"
+	"github.com/markbates/grift/grift"
+	"github.com/pkg/errors"
+)
+
+var _ = grift.Namespace("contests", func() {
+
+	grift.Desc("export", "Exports a contest to an archive: buffalo task contests:export <contest id> <file> [submissions] [standings]")
+	grift.Add("export", func(c *grift.Context) error {
+		if len(c.Args) < 2 {
+			return errors.New("usage: buffalo task contests:export <contest id> <file> [submissions] [standings]")
+		}
+		opts := models.ExportOptions{}
+		for _, arg := range c.Args[2:] {
+			switch arg {
+			case "submissions":
+				opts.Submissions = true
+			case "standings":
+				opts.Standings = true
+			default:
+				return errors.Errorf("unknown option %q", arg)
+			}
+		}
+		return models.DB.Transaction(func(tx *pop.Connection) error {
+			contest := &models.Contest{}
+			if err := tx.Find(contest, c.Args[0]); err != nil {
+				return errors.WithStack(err)
+			}
+			b, err := models.ExportContest(tx, contest, opts)
+			if err != nil {
+				return err
+			}
+			if err := ioutil.WriteFile(c.Args[1], b, 0644); err != nil {
+				return errors.WithStack(err)
+			}
+			fmt.Printf("Exported %s to %s\n", contest.Title, c.Args[1])
+			return nil
+		})
+	})
+
+	grift.Desc("import", "Imports a contest archive for a host: buffalo task contests:import <file> <host email>")
+	grift.Add("import", func(c *grift.Context) error {
+		if len(c.Args) != 2 {
+			return errors.New("usage: buffalo task contests:import <file> <host email>")
+		}
+		return models.DB.Transaction(func(tx *pop.Connection) error {
+			host := &models.Host{}
+			if err := tx.Where("email = ?", strings.ToLower(c.Args[1])).First(host); err != nil {
+				return errors.Wrapf(err, "host %s", c.Args[1])
+			}
+			res, err := models.ImportContest(tx, c.Args[0], host.ID)
+			if err != nil {
+				return err
+			}
+			if err := judge.QueueContest(tx, res.Contest.ID); err != nil {
+				return err
+			}
+			fmt.Printf("Imported %s (%s) with %d questions and %d submissions, queued to be judged\n", res.Contest.Title, res.Contest.ID, res.Questions, res.Submissions)
+			if len(res.MissingUsers) > 0 {
+				fmt.Printf("Skipped the submissions of users without an account: %s\n", strings.Join(res.MissingUsers, ", "))
+			}
+			return nil
+		})
+	})
+
+})
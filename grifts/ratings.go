@@ -0,0 +1,45 @@
+package grifts
+
+import (
+	"fmt"
+
+	"github.com/cpjudge/cpjudge/models"
+	"github.com/gobuffalo/pop"
+	"github.com/markbates/grift/grift"
+	"github.com/pkg/errors"
+)
+
+var _ = grift.Namespace("ratings", func() {
+
+	grift.Desc("apply", "Applies the ratings of an ended rated contest: buffalo task ratings:apply <contest id>")
+	grift.Add("apply", func(c *grift.Context) error {
+		if len(c.Args) != 1 {
+			return errors.New("usage: buffalo task ratings:apply <contest id>")
+		}
+		return models.DB.Transaction(func(tx *pop.Connection) error {
+			contest := &models.Contest{}
+			if err := tx.Find(contest, c.Args[0]); err != nil {
+				return errors.WithStack(err)
+			}
+			changes, err := models.ApplyContestRatings(tx, contest)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Updated ratings of %d participants of %s\n", len(changes), contest.Title)
+			return nil
+		})
+	})
+
+	grift.Desc("recalculate", "Recomputes all ratings from the results of every ended rated contest")
+	grift.Add("recalculate", func(c *grift.Context) error {
+		return models.DB.Transaction(func(tx *pop.Connection) error {
+			n, err := models.RecalculateRatings(tx)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Recalculated ratings from %d contests\n", n)
+			return nil
+		})
+	})
+
+})
@@ -0,0 +1,25 @@
+package grifts
+
+import (
+	"fmt"
+
+	"github.com/cpjudge/cpjudge/models"
+	"github.com/gobuffalo/pop"
+	"github.com/markbates/grift/grift"
+)
+
+var _ = grift.Namespace("tags", func() {
+
+	grift.Desc("normalize", "Normalizes the tags of questions and the tag vocabulary saved before tags were normalized")
+	grift.Add("normalize", func(c *grift.Context) error {
+		return models.DB.Transaction(func(tx *pop.Connection) error {
+			n, err := models.NormalizeStoredTags(tx)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Normalized the tags of %d questions\n", n)
+			return nil
+		})
+	})
+
+})
@@ -0,0 +1,326 @@
+// Package importer reads problem packages prepared in Codeforces Polygon or
+// in the Kattis problem package format, so they can be added as questions.
+package importer
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cpjudge/cpjudge/models"
+	"github.com/pkg/errors"
+)
+
+// Package formats.
+const (
+	FormatPolygon = "polygon"
+	FormatKattis  = "kattis"
+)
+
+// ErrUnknownFormat is returned for archives that are neither Polygon nor
+// Kattis packages.
+var ErrUnknownFormat = errors.New("The archive is not a Polygon or Kattis problem package.")
+
+// MaxFileSize is the largest file read from a package.
+const MaxFileSize = 256 << 20
+
+// Test is a test case of an imported problem.
+type Test struct {
+	Name   string
+	Input  []byte
+	Answer []byte
+	Sample bool
+}
+
+// Program is the source of a program shipped with a package.
+type Program struct {
+	Name   string
+	Source []byte
+}
+
+// Solution is a reference solution shipped with a package, with the
+// verdict it is expected to get: one of models.ExpectCorrect,
+// models.ExpectTimeLimit and models.ExpectWrong.
+type Solution struct {
+	Program
+	Expected string
+	Main     bool
+}
+
+// Attachment is a picture shown in the statement.
+type Attachment struct {
+	Name string
+	Data []byte
+}
+
+// Problem is what was read from a package. The statement is in markdown
+// sections: the Description is the legend, followed by Input, Output and
+// Notes. Warnings list the features of the package that could not be
+// imported.
+type Problem struct {
+	Format      string
+	Title       string
+	Description string
+	Input       string
+	Output      string
+	Notes       string
+	Attachments []Attachment
+	// TimeLimit is in milliseconds and MemoryLimit in megabytes. They are
+	// zero when the package does not set them.
+	TimeLimit   int
+	MemoryLimit int
+	Checker     string
+	Validator   *Program
+	Solutions   []Solution
+	Tests       []Test
+	Warnings    []string
+}
+
+func (p *Problem) warn(format string, args ...interface{}) {
+	p.Warnings = append(p.Warnings, fmt.Sprintf(format, args...))
+}
+
+// Read imports the problem in a zip archive of a Polygon or Kattis package.
+// The package may be wrapped in a directory.
+func Read(archive []byte) (*Problem, error) {
+	files, err := unzip(archive)
+	if err != nil {
+		return nil, err
+	}
+	var p *Problem
+	switch {
+	case files.has("problem.xml"):
+		p, err = readPolygon(files)
+	case files.has("problem.yaml"):
+		p, err = readKattis(files)
+	default:
+		return nil, ErrUnknownFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(p.Tests) == 0 {
+		return nil, errors.New("The package has no tests.")
+	}
+	p.nameTests()
+	p.clampLimits()
+	return p, nil
+}
+
+// nameTests names the tests 01.txt, 02.txt and so on, so they sort in the
+// order of the package.
+func (p *Problem) nameTests() {
+	width := len(strconv.Itoa(len(p.Tests)))
+	if width < 2 {
+		width = 2
+	}
+	for i := range p.Tests {
+		p.Tests[i].Name = fmt.Sprintf("%0*d.txt", width, i+1)
+	}
+}
+
+func (p *Problem) clampLimits() {
+	if p.TimeLimit > models.MaxTimeLimit {
+		p.warn("The time limit of %d ms was lowered to %d ms.", p.TimeLimit, models.MaxTimeLimit)
+		p.TimeLimit = models.MaxTimeLimit
+	}
+	if p.MemoryLimit > models.MaxMemoryLimit {
+		p.warn("The memory limit of %d MB was lowered to %d MB.", p.MemoryLimit, models.MaxMemoryLimit)
+		p.MemoryLimit = models.MaxMemoryLimit
+	}
+}
+
+// SampleNames returns the names of the sample tests.
+func (p *Problem) SampleNames() []string {
+	names := []string{}
+	for _, t := range p.Tests {
+		if t.Sample {
+			names = append(names, t.Name)
+		}
+	}
+	return names
+}
+
+// TestData returns the tests as a zip archive in the layout of test data
+// uploads: inputs/ and answers/ holding files of the same name.
+func (p *Problem) TestData() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	for _, t := range p.Tests {
+		for dir, body := range map[string][]byte{"inputs/": t.Input, "answers/": t.Answer} {
+			f, err := zw.Create(dir + t.Name)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			if _, err := f.Write(body); err != nil {
+				return nil, errors.WithStack(err)
+			}
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Question returns a question for the problem. The tests are stored
+// separately, with Question.StoreTestData.
+func (p *Problem) Question() *models.Question {
+	return &models.Question{
+		Title:        p.Title,
+		Description:  p.Description,
+		InputFormat:  p.Input,
+		OutputFormat: p.Output,
+		Notes:        p.Notes,
+		TimeLimit:    p.TimeLimit,
+		MemoryLimit:  p.MemoryLimit,
+		Checker:      p.Checker,
+		Samples:      strings.Join(p.SampleNames(), ","),
+	}
+}
+
+// packageFiles maps the slash separated paths in a package to their
+// contents.
+type packageFiles map[string][]byte
+
+func (f packageFiles) has(name string) bool {
+	_, ok := f[name]
+	return ok
+}
+
+// dir returns the names of the files under prefix, sorted.
+func (f packageFiles) dir(prefix string) []string {
+	names := []string{}
+	for name := range f {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// unzip reads the files of an archive. When the package is wrapped in a
+// directory, paths are made relative to it.
+func unzip(archive []byte) (packageFiles, error) {
+	zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		return nil, errors.New("The package is not a valid zip archive.")
+	}
+	root, found := "", false
+	for _, f := range zr.File {
+		base := path.Base(f.Name)
+		if base != "problem.xml" && base != "problem.yaml" {
+			continue
+		}
+		if dir := strings.TrimSuffix(f.Name, base); !found || len(dir) < len(root) {
+			root, found = dir, true
+		}
+	}
+	files := packageFiles{}
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() || !strings.HasPrefix(f.Name, root) {
+			continue
+		}
+		if f.UncompressedSize64 > MaxFileSize {
+			return nil, errors.Errorf("%s is larger than %d MB.", f.Name, MaxFileSize>>20)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		b, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		files[strings.TrimPrefix(f.Name, root)] = b
+	}
+	return files, nil
+}
+
+// sourceExtensions are the languages programs shipped with packages can be
+// written in.
+var sourceExtensions = map[string]bool{".c": true, ".cc": true, ".cpp": true}
+
+// addSolution adds a reference solution, unless it is not written in C or
+// C++. It reports whether it was added.
+func (p *Problem) addSolution(files packageFiles, name, expected string, main bool) bool {
+	source, ok := files[name]
+	if !ok || !sourceExtensions[strings.ToLower(path.Ext(name))] {
+		return false
+	}
+	p.Solutions = append(p.Solutions, Solution{
+		Program:  Program{Name: path.Base(name), Source: source},
+		Expected: expected,
+		Main:     main,
+	})
+	return true
+}
+
+var (
+	sectionHeading = regexp.MustCompile(`(?i)^#{1,3}\s*(input|output|notes?)\s*#*$`)
+	markdownImage  = regexp.MustCompile(`!\[([^\]]*)\]\(([^)\s]+)\)`)
+)
+
+// imageExtensions are tried in order for pictures named without one, as
+// LaTeX allows.
+var imageExtensions = []string{".png", ".jpg", ".jpeg", ".pdf"}
+
+// splitSections splits a markdown statement into its legend and the input,
+// output and notes sections, which start with headings of those names.
+func splitSections(md string) (legend, input, output, notes string) {
+	sections := map[string][]string{}
+	current := ""
+	for _, line := range strings.Split(md, "\n") {
+		if m := sectionHeading.FindStringSubmatch(strings.TrimSpace(line)); m != nil {
+			current = strings.TrimSuffix(strings.ToLower(m[1]), "s")
+			continue
+		}
+		sections[current] = append(sections[current], line)
+	}
+	section := func(name string) string {
+		return strings.TrimSpace(strings.Join(sections[name], "\n"))
+	}
+	return section(""), section("input"), section("output"), section("note")
+}
+
+// attachImages attaches the pictures a statement section shows, which are
+// under dir in the package, and refers to them by their attachment name.
+func (p *Problem) attachImages(files packageFiles, dir, md string) string {
+	return markdownImage.ReplaceAllStringFunc(md, func(image string) string {
+		m := markdownImage.FindStringSubmatch(image)
+		name := m[2]
+		if strings.Contains(name, ":") {
+			return image
+		}
+		data, ok := files[path.Join(dir, name)]
+		if !ok && path.Ext(name) == "" {
+			for _, ext := range imageExtensions {
+				if data, ok = files[path.Join(dir, name+ext)]; ok {
+					name += ext
+					break
+				}
+			}
+		}
+		if !ok {
+			p.warn("The picture %s is missing from the package.", name)
+			return m[1]
+		}
+		base := path.Base(name)
+		attached := false
+		for _, a := range p.Attachments {
+			attached = attached || a.Name == base
+		}
+		if !attached {
+			p.Attachments = append(p.Attachments, Attachment{Name: base, Data: data})
+		}
+		return "![" + m[1] + "](" + base + ")"
+	})
+}
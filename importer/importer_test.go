@@ -0,0 +1,313 @@
+package importer
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cpjudge/cpjudge/models"
+)
+
+func zipFiles(t *testing.T, files map[string]string) []byte {
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	for name, body := range files {
+		f, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func hasWarning(p *Problem, substr string) bool {
+	for _, w := range p.Warnings {
+		if strings.Contains(w, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+const polygonXML = `<?xml version="1.0" encoding="utf-8" standalone="no"?>
+<problem revision="4" short-name="a-plus-b">
+    <names>
+        <name language="russian" value="A + B (ru)"/>
+        <name language="english" value="A + B"/>
+    </names>
+    <judging input-file="" output-file="">
+        <testset name="tests">
+            <time-limit>2000</time-limit>
+            <memory-limit>268435456</memory-limit>
+            <test-count>3</test-count>
+            <input-path-pattern>tests/%02d</input-path-pattern>
+            <answer-path-pattern>tests/%02d.a</answer-path-pattern>
+            <tests>
+                <test method="manual" sample="true"/>
+                <test cmd="gen 1" method="generated"/>
+                <test cmd="gen 2" method="generated"/>
+            </tests>
+        </testset>
+    </judging>
+    <assets>
+        <checker name="std::rcmp6.cpp" type="testlib">
+            <source path="files/check.cpp" type="cpp.g++17"/>
+        </checker>
+        <validators>
+            <validator>
+                <source path="files/val.cpp" type="cpp.g++17"/>
+            </validator>
+        </validators>
+        <solutions>
+            <solution tag="main">
+                <source path="solutions/sol.cpp" type="cpp.g++17"/>
+            </solution>
+            <solution tag="time-limit-exceeded">
+                <source path="solutions/slow.c" type="c.gcc"/>
+            </solution>
+            <solution tag="wrong-answer">
+                <source path="solutions/Wrong.java" type="java11"/>
+            </solution>
+            <solution tag="rejected">
+                <source path="solutions/bad.cpp" type="cpp.g++17"/>
+            </solution>
+        </solutions>
+    </assets>
+</problem>`
+
+func polygonPackage() map[string]string {
+	return map[string]string{
+		"a-plus-b/problem.xml":                           polygonXML,
+		"a-plus-b/statement-sections/english/legend.tex": "Compute $a + b$.\n% a comment\nIt is \\textbf{easy}.\n\n\\includegraphics{sum}",
+		"a-plus-b/statement-sections/english/sum.png":    "png",
+		"a-plus-b/statement-sections/english/input.tex":  "Two integers $a$ and $b$ ($1 \\le a, b \\le 10^9$).",
+		"a-plus-b/statement-sections/english/output.tex": "Print $a + b$.",
+		"a-plus-b/statement-sections/english/notes.tex":  "",
+		"a-plus-b/tests/01":                              "1 2\n",
+		"a-plus-b/tests/01.a":                            "3\n",
+		"a-plus-b/tests/02":                              "5 5\n",
+		"a-plus-b/tests/02.a":                            "10\n",
+		"a-plus-b/tests/03":                              "7 8\n",
+		"a-plus-b/tests/03.a":                            "15\n",
+		"a-plus-b/files/check.cpp":                       "// checker",
+		"a-plus-b/files/val.cpp":                         "// validator",
+		"a-plus-b/solutions/sol.cpp":                     "// main",
+		"a-plus-b/solutions/slow.c":                      "// slow",
+		"a-plus-b/solutions/Wrong.java":                  "// wrong",
+		"a-plus-b/solutions/bad.cpp":                     "// bad",
+	}
+}
+
+func TestReadPolygon(t *testing.T) {
+	p, err := Read(zipFiles(t, polygonPackage()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Format != FormatPolygon || p.Title != "A + B" {
+		t.Errorf("got format %q, title %q", p.Format, p.Title)
+	}
+	want := "Compute $a + b$.\n\nIt is **easy**.\n\n![](sum.png)"
+	if p.Description != want {
+		t.Errorf("description:\n%s\nwant:\n%s", p.Description, want)
+	}
+	if p.Input != "Two integers $a$ and $b$ ($1 \\le a, b \\le 10^9$)." || p.Output != "Print $a + b$." || p.Notes != "" {
+		t.Errorf("sections %q, %q, %q", p.Input, p.Output, p.Notes)
+	}
+	if len(p.Attachments) != 1 || p.Attachments[0].Name != "sum.png" || string(p.Attachments[0].Data) != "png" {
+		t.Errorf("attachments %+v", p.Attachments)
+	}
+	if p.TimeLimit != 2000 || p.MemoryLimit != 256 {
+		t.Errorf("limits %d ms, %d MB", p.TimeLimit, p.MemoryLimit)
+	}
+	if p.Checker != models.FloatChecker(1e-6) {
+		t.Errorf("checker %q", p.Checker)
+	}
+	if p.Validator == nil || p.Validator.Name != "val.cpp" || string(p.Validator.Source) != "// validator" {
+		t.Errorf("validator %+v", p.Validator)
+	}
+	wantSolutions := []Solution{
+		{Program: Program{Name: "sol.cpp", Source: []byte("// main")}, Expected: models.ExpectCorrect, Main: true},
+		{Program: Program{Name: "slow.c", Source: []byte("// slow")}, Expected: models.ExpectTimeLimit},
+	}
+	if !reflect.DeepEqual(p.Solutions, wantSolutions) {
+		t.Errorf("solutions %+v", p.Solutions)
+	}
+	if !hasWarning(p, "2 solutions were not imported") {
+		t.Errorf("warnings %q", p.Warnings)
+	}
+	if got := p.SampleNames(); !reflect.DeepEqual(got, []string{"01.txt"}) {
+		t.Errorf("samples %q", got)
+	}
+	q := p.Question()
+	if q.Samples != "01.txt" || q.TimeLimit != 2000 || q.Checker != p.Checker {
+		t.Errorf("question %+v", q)
+	}
+
+	b, err := p.TestData()
+	if err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{}
+	for _, f := range zr.File {
+		rc, _ := f.Open()
+		body, _ := ioutil.ReadAll(rc)
+		rc.Close()
+		files[f.Name] = string(body)
+	}
+	if len(files) != 6 || files["inputs/03.txt"] != "7 8\n" || files["answers/03.txt"] != "15\n" {
+		t.Errorf("test data %q", files)
+	}
+}
+
+func TestReadPolygonUnsupported(t *testing.T) {
+	files := polygonPackage()
+	files["a-plus-b/problem.xml"] = strings.NewReplacer(
+		`input-file=""`, `input-file="input.txt"`,
+		`<test cmd="gen 2" method="generated"/>`, `<test cmd="gen 2" method="generated" group="1" points="10"/>`,
+		"<time-limit>2000", "<time-limit>60000",
+	).Replace(polygonXML)
+	p, err := Read(zipFiles(t, files))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, w := range []string{"files", "groups", "time limit"} {
+		if !hasWarning(p, w) {
+			t.Errorf("no warning about %q in %q", w, p.Warnings)
+		}
+	}
+	if p.TimeLimit != models.MaxTimeLimit {
+		t.Errorf("time limit %d", p.TimeLimit)
+	}
+
+	files["a-plus-b/problem.xml"] = strings.Replace(polygonXML, "std::rcmp6.cpp", "check.cpp", 1)
+	if _, err := Read(zipFiles(t, files)); err == nil || !strings.Contains(err.Error(), "check.cpp") {
+		t.Errorf("custom checker: got error %v", err)
+	}
+	files["a-plus-b/problem.xml"] = polygonXML
+
+	delete(files, "a-plus-b/tests/02.a")
+	if _, err := Read(zipFiles(t, files)); err == nil {
+		t.Error("missing answer was accepted")
+	}
+	files["a-plus-b/problem.xml"] = strings.Replace(polygonXML, "</assets>", "<interactor/></assets>", 1)
+	if _, err := Read(zipFiles(t, files)); err == nil {
+		t.Error("interactive problem was accepted")
+	}
+}
+
+func TestReadKattis(t *testing.T) {
+	p, err := Read(zipFiles(t, map[string]string{
+		"problem.yaml":                     "name: Hello\nlimits:\n  memory: 512\nvalidator_flags: case_sensitive float_tolerance 1e-6\n",
+		".timelimit":                       "1.5\n",
+		"problem_statement/problem.en.tex": "\\problemname{Hello}\nSay hello~$n$ times.\n\\begin{itemize}\n\\item once\n\\item twice\n\\end{itemize}\n\\section*{Input}\nAn integer $n$.\n",
+		"data/sample/1.in":                 "1\n",
+		"data/sample/1.ans":                "hello\n",
+		"data/secret/group1/a.in":          "2\n",
+		"data/secret/group1/a.ans":         "hello hello\n",
+		"data/secret/b.in":                 "3\n",
+		"data/secret/b.ans":                "hello hello hello\n",
+		"input_validators/validate.cc":     "// validator",
+		"submissions/accepted/a.cpp":       "// a",
+		"submissions/accepted/b.c":         "// b",
+		"submissions/accepted/multi/x.cpp": "// x",
+		"submissions/wrong_answer/w.c":     "// w",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Format != FormatKattis || p.Title != "Hello" || p.TimeLimit != 1500 || p.MemoryLimit != 512 {
+		t.Errorf("got %+v", p)
+	}
+	want := "Say hello $n$ times.\n\n- once\n- twice"
+	if p.Description != want || p.Input != "An integer $n$." {
+		t.Errorf("description:\n%s\nwant:\n%s\ninput: %q", p.Description, want, p.Input)
+	}
+	if p.Checker != models.FloatChecker(1e-6) {
+		t.Errorf("checker %q", p.Checker)
+	}
+	if p.Validator == nil || p.Validator.Name != "validate.cc" {
+		t.Errorf("validator %+v", p.Validator)
+	}
+	if len(p.Solutions) != 3 || !p.Solutions[0].Main || p.Solutions[0].Name != "a.cpp" || p.Solutions[1].Main ||
+		p.Solutions[2].Name != "w.c" || p.Solutions[2].Expected != models.ExpectWrong {
+		t.Errorf("solutions %+v", p.Solutions)
+	}
+	if len(p.Tests) != 3 || !p.Tests[0].Sample || string(p.Tests[2].Input) != "2\n" || p.Tests[1].Sample {
+		t.Errorf("tests %+v", p.Tests)
+	}
+	if hasWarning(p, "case-sensitively") {
+		t.Errorf("unexpected warnings %q", p.Warnings)
+	}
+}
+
+func TestReadKattisMarkdownAndDefaults(t *testing.T) {
+	p, err := Read(zipFiles(t, map[string]string{
+		"hello/problem.yaml":                    "name:\n  de: Hallo\n  en: Hello\n",
+		"hello/problem_statement/problem.en.md": "# Hello\n\nSay hello.\n",
+		"hello/data/secret/1.in":                "1\n",
+		"hello/data/secret/1.ans":               "hello\n",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Title != "Hello" || p.Description != "# Hello\n\nSay hello." || p.TimeLimit != 0 {
+		t.Errorf("got %+v", p)
+	}
+	if !hasWarning(p, "time limit") || p.Checker != models.CheckerTokens {
+		t.Errorf("warnings %q, checker %q", p.Warnings, p.Checker)
+	}
+
+	_, err = Read(zipFiles(t, map[string]string{"problem.yaml": "name: X\nvalidation: custom\n", "data/secret/1.in": "", "data/secret/1.ans": ""}))
+	if err == nil {
+		t.Error("custom output validator was accepted")
+	}
+	_, err = Read(zipFiles(t, map[string]string{"problem.yaml": "name: X\nvalidation: custom interactive\n", "data/secret/1.in": "", "data/secret/1.ans": ""}))
+	if err == nil {
+		t.Error("interactive problem was accepted")
+	}
+}
+
+func TestReadUnknown(t *testing.T) {
+	if _, err := Read(zipFiles(t, map[string]string{"inputs/1.txt": "1"})); err != ErrUnknownFormat {
+		t.Errorf("got %v, want ErrUnknownFormat", err)
+	}
+	if _, err := Read([]byte("not a zip")); err == nil {
+		t.Error("invalid zip was accepted")
+	}
+}
+
+func TestLatexToMarkdown(t *testing.T) {
+	for _, tt := range []struct{ tex, want string }{
+		{`\emph{a} and \texttt{b}`, "*a* and `b`"},
+		{`50\% off -- now`, "50% off \u2013 now"},
+		{`keep $a_1 -- b$ and $$\sum_{i} x$$`, `keep $a_1 -- b$ and $$\sum_{i} x$$`},
+		{"\\begin{enumerate}\n\\item one $x$\n\\begin{itemize}\\item nested\\end{itemize}\n\\item two\n\\end{enumerate}\nafter", "1. one $x$\n   - nested\n1. two\n\nafter"},
+		{`\textbf{bold \emph{both}}`, "**bold *both***"},
+		{`line\\next`, "line  \nnext"},
+		{`See \includegraphics[width=3cm]{pic.png}.`, "See ![](pic.png)."},
+	} {
+		if got := latexToMarkdown(tt.tex); got != tt.want {
+			t.Errorf("latexToMarkdown(%q) = %q, want %q", tt.tex, got, tt.want)
+		}
+	}
+}
+
+func TestSplitSections(t *testing.T) {
+	legend, input, output, notes := splitSections("Story.\n\n## Input\n\nOne line.\n\n### Output\nAnother.\n\n## Note\n\nEasy.")
+	if legend != "Story." || input != "One line." || output != "Another." || notes != "Easy." {
+		t.Errorf("got %q, %q, %q, %q", legend, input, output, notes)
+	}
+}
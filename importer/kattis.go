@@ -0,0 +1,183 @@
+package importer
+
+import (
+	"math"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/cpjudge/cpjudge/models"
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// kattisProblem is the part of a Kattis problem.yaml that is imported.
+type kattisProblem struct {
+	Name   interface{} `yaml:"name"`
+	Type   string      `yaml:"type"`
+	Limits struct {
+		Memory    int     `yaml:"memory"`
+		TimeLimit float64 `yaml:"time_limit"`
+	} `yaml:"limits"`
+	Validation     string `yaml:"validation"`
+	ValidatorFlags string `yaml:"validator_flags"`
+}
+
+// readKattis imports a Kattis problem package.
+func readKattis(files packageFiles) (*Problem, error) {
+	kp := kattisProblem{}
+	if err := yaml.Unmarshal(files["problem.yaml"], &kp); err != nil {
+		return nil, errors.Errorf("problem.yaml could not be read: %v", err)
+	}
+	validation := strings.Fields(kp.Validation)
+	for _, v := range validation {
+		if v == "interactive" {
+			return nil, errors.New("Interactive problems are not supported.")
+		}
+	}
+	if len(validation) > 0 && validation[0] == "custom" {
+		return nil, errors.New("Custom output validators are not supported.")
+	}
+	p := &Problem{Format: FormatKattis, MemoryLimit: kp.Limits.Memory}
+
+	p.Title = kattisName(kp.Name)
+	md, dir := kattisStatement(p, files)
+	p.Description, p.Input, p.Output, p.Notes = splitSections(p.attachImages(files, dir, md))
+
+	seconds := kp.Limits.TimeLimit
+	if b, ok := files[".timelimit"]; ok {
+		if s, err := strconv.ParseFloat(strings.TrimSpace(string(b)), 64); err == nil {
+			seconds = s
+		}
+	}
+	if seconds > 0 {
+		p.TimeLimit = int(math.Ceil(seconds * 1000))
+	} else {
+		p.warn("The package does not set a time limit; the default of %d ms applies.", models.DefaultTimeLimit)
+	}
+
+	p.Checker = kattisChecker(p, kp.ValidatorFlags)
+	if kp.Type == "scoring" || (len(validation) > 1 && validation[1] == "score") {
+		p.warn("Scoring is not supported; every test must pass.")
+	}
+
+	for _, dir := range []string{"data/sample/", "data/secret/"} {
+		for _, name := range files.dir(dir) {
+			if !strings.HasSuffix(name, ".in") {
+				continue
+			}
+			answer := strings.TrimSuffix(name, ".in") + ".ans"
+			if !files.has(answer) {
+				return nil, errors.Errorf("%s has no answer.", name)
+			}
+			p.Tests = append(p.Tests, Test{Input: files[name], Answer: files[answer], Sample: dir == "data/sample/"})
+		}
+	}
+
+	validators := []string{}
+	for _, dir := range []string{"input_validators/", "input_format_validators/"} {
+		for _, name := range files.dir(dir) {
+			if sourceExtensions[strings.ToLower(path.Ext(name))] {
+				validators = append(validators, name)
+			}
+		}
+	}
+	if len(validators) > 0 {
+		if len(validators) > 1 {
+			p.warn("Only the input validator %s is imported.", path.Base(validators[0]))
+		}
+		p.Validator = program(p, files, validators[0])
+	} else if len(files.dir("input_validators/"))+len(files.dir("input_format_validators/")) > 0 {
+		p.warn("Input validators that are not a single C or C++ file are not supported.")
+	}
+
+	skipped := 0
+	for _, d := range []struct{ dir, expected string }{
+		{"submissions/accepted/", models.ExpectCorrect},
+		{"submissions/time_limit_exceeded/", models.ExpectTimeLimit},
+		{"submissions/wrong_answer/", models.ExpectWrong},
+	} {
+		for _, name := range files.dir(d.dir) {
+			// The first accepted submission is the main solution.
+			main := d.expected == models.ExpectCorrect && len(p.Solutions) == 0
+			if strings.Contains(strings.TrimPrefix(name, d.dir), "/") || !p.addSolution(files, name, d.expected, main) {
+				skipped++
+			}
+		}
+	}
+	if skipped > 0 {
+		p.warn("%d files under submissions/ were not imported: only C and C++ submissions made of a single file are.", skipped)
+	}
+	return p, nil
+}
+
+// kattisName returns the English name of a problem, which is either a
+// string or a map from language codes.
+func kattisName(name interface{}) string {
+	switch n := name.(type) {
+	case string:
+		return n
+	case map[interface{}]interface{}:
+		if en, ok := n["en"].(string); ok {
+			return en
+		}
+		for _, v := range n {
+			if s, ok := v.(string); ok {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+// kattisChecker maps the flags of the default output validator to a
+// checker.
+func kattisChecker(p *Problem, flags string) string {
+	checker := models.CheckerTokens
+	caseSensitive := false
+	fields := strings.Fields(flags)
+	for i := 0; i < len(fields); i++ {
+		switch fields[i] {
+		case "case_sensitive":
+			caseSensitive = true
+		case "space_change_sensitive":
+			checker = models.CheckerExact
+		case "float_tolerance", "float_absolute_tolerance", "float_relative_tolerance":
+			if i+1 < len(fields) {
+				i++
+				if tolerance, err := strconv.ParseFloat(fields[i], 64); err == nil && tolerance > 0 && tolerance < 1 {
+					checker = models.FloatChecker(tolerance)
+					continue
+				}
+			}
+			p.warn("The float tolerance in %q could not be read.", flags)
+		default:
+			p.warn("The validator flag %q is not supported.", fields[i])
+		}
+	}
+	if !caseSensitive {
+		p.warn("Outputs are compared case-sensitively, unlike with the Kattis default validator.")
+	}
+	return checker
+}
+
+// kattisStatement returns the English statement, preferring markdown, and
+// the directory it is in.
+func kattisStatement(p *Problem, files packageFiles) (string, string) {
+	for _, dir := range []string{"statement/", "problem_statement/"} {
+		for _, name := range []string{"problem.en.md", "problem.md"} {
+			if b, ok := files[dir+name]; ok {
+				return strings.TrimSpace(string(b)), dir
+			}
+		}
+	}
+	for _, dir := range []string{"statement/", "problem_statement/"} {
+		for _, name := range []string{"problem.en.tex", "problem.tex"} {
+			if b, ok := files[dir+name]; ok {
+				return latexToMarkdown(string(b)), dir
+			}
+		}
+	}
+	p.warn("The package has no English statement; add the statement by hand.")
+	return "", ""
+}
@@ -0,0 +1,206 @@
+package importer
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/cpjudge/cpjudge/statement"
+)
+
+// latexToMarkdown converts the LaTeX found in problem statements to
+// markdown. Math between $ signs is kept as it is. Commands it does not
+// know are left in place for the host to fix.
+func latexToMarkdown(tex string) string {
+	tex = stripComments(tex)
+	tex = replaceCommand(tex, "includegraphics", func(name string) string {
+		return "![](" + strings.TrimSpace(name) + ")"
+	})
+	tex = replaceCommand(tex, "problemname", func(string) string { return "" })
+	for _, c := range []struct {
+		name, prefix, suffix string
+	}{
+		{"section*", "\n\n## ", "\n\n"},
+		{"section", "\n\n## ", "\n\n"},
+		{"subsection*", "\n\n### ", "\n\n"},
+		{"subsection", "\n\n### ", "\n\n"},
+		{"textbf", "**", "**"},
+		{"emph", "*", "*"},
+		{"textit", "*", "*"},
+		{"texttt", "`", "`"},
+		{"url", "<", ">"},
+	} {
+		prefix, suffix := c.prefix, c.suffix
+		tex = replaceCommand(tex, c.name, func(arg string) string { return prefix + arg + suffix })
+	}
+
+	// Math is set aside so the text conversions leave it alone.
+	parts := statement.SplitTeXMath(tex)
+	text := []string{}
+	for i, part := range parts {
+		if i%2 == 0 {
+			text = append(text, part)
+		} else {
+			text = append(text, mathPlaceholder(i/2))
+		}
+	}
+	md := convertText(strings.Join(text, ""))
+	for i := 1; i < len(parts); i += 2 {
+		md = strings.Replace(md, mathPlaceholder(i/2), parts[i], 1)
+	}
+	md = blankLines.ReplaceAllString(md, "\n\n")
+	return strings.TrimSpace(md)
+}
+
+func mathPlaceholder(n int) string {
+	return "\x00" + strconv.Itoa(n) + "\x00"
+}
+
+var (
+	blankLines = regexp.MustCompile(`\n[ \t]*(\n[ \t]*)+`)
+	listToken  = regexp.MustCompile(`\\(begin|end)\{(itemize|enumerate|center|flushleft|flushright)\}|\\item\b`)
+)
+
+// textReplacer handles special characters.
+var textReplacer = strings.NewReplacer(
+	`\\`, "  \n",
+	`\%`, "%",
+	`\&`, "&",
+	`\_`, `\_`,
+	`\#`, "#",
+	`\{`, "{",
+	`\}`, "}",
+	`\$`, `\$`,
+	`\ldots`, "...",
+	`\dots`, "...",
+	"``", `"`,
+	"''", `"`,
+	"---", "\u2014",
+	"--", "\u2013",
+	"~", " ",
+)
+
+// convertText converts lists and special characters.
+func convertText(text string) string {
+	out := ""
+	lists := []string{}
+	last := 0
+	for _, m := range listToken.FindAllStringSubmatchIndex(text, -1) {
+		out += text[last:m[0]]
+		last = m[1]
+		if m[2] < 0 {
+			// \item: the item starts a new line, indented by the lists
+			// around it.
+			marker := "- "
+			if n := len(lists); n > 0 && lists[n-1] == "enumerate" {
+				marker = "1. "
+			}
+			indent := ""
+			if len(lists) > 1 {
+				indent = strings.Repeat("   ", len(lists)-1)
+			}
+			out = strings.TrimRight(out, " \t\n") + "\n" + indent + marker
+			for last < len(text) && strings.ContainsRune(" \t\n", rune(text[last])) {
+				last++
+			}
+			continue
+		}
+		begin, env := text[m[2]:m[3]] == "begin", text[m[4]:m[5]]
+		switch {
+		case env != "itemize" && env != "enumerate":
+			out += "\n\n"
+		case begin:
+			if len(lists) == 0 {
+				// A blank line the first item must not trim away.
+				out = strings.TrimRight(out, " \t\n") + "\n" + paragraphBreak
+			}
+			lists = append(lists, env)
+		default:
+			if len(lists) > 0 {
+				lists = lists[:len(lists)-1]
+			}
+			out = strings.TrimRight(out, " \t\n") + "\n"
+			if len(lists) == 0 {
+				out += "\n"
+			}
+		}
+	}
+	out = strings.Replace(out+text[last:], paragraphBreak, "\n", -1)
+	return textReplacer.Replace(out)
+}
+
+const paragraphBreak = "\x01"
+
+// replaceCommand replaces each \name{arg} with f(arg), matching nested
+// braces.
+func replaceCommand(text, name string, f func(arg string) string) string {
+	cmd := `\` + name
+	b := strings.Builder{}
+	for {
+		i := strings.Index(text, cmd)
+		if i < 0 {
+			break
+		}
+		rest := text[i+len(cmd):]
+		// Skip longer commands sharing the prefix, such as \sectionmark.
+		if rest != "" && (isLetter(rest[0]) || (rest[0] == '*' && !strings.HasSuffix(name, "*"))) {
+			b.WriteString(text[:i+len(cmd)])
+			text = rest
+			continue
+		}
+		// Optional arguments, as in \includegraphics[width=5cm]{a.png}.
+		args := strings.TrimLeft(rest, " ")
+		if strings.HasPrefix(args, "[") {
+			if end := strings.Index(args, "]"); end >= 0 {
+				args = args[end+1:]
+			}
+		}
+		if !strings.HasPrefix(args, "{") {
+			b.WriteString(text[:i+len(cmd)])
+			text = rest
+			continue
+		}
+		depth, end := 0, -1
+		for j := 0; j < len(args) && end < 0; j++ {
+			switch args[j] {
+			case '\\':
+				j++
+			case '{':
+				depth++
+			case '}':
+				depth--
+				if depth == 0 {
+					end = j
+				}
+			}
+		}
+		if end < 0 {
+			break
+		}
+		b.WriteString(text[:i])
+		b.WriteString(f(args[1:end]))
+		text = args[end+1:]
+	}
+	b.WriteString(text)
+	return b.String()
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// stripComments removes % comments, keeping escaped \% signs.
+func stripComments(tex string) string {
+	lines := strings.Split(tex, "\n")
+	for i, line := range lines {
+		for j := 0; j < len(line); j++ {
+			if line[j] == '\\' {
+				j++
+			} else if line[j] == '%' {
+				lines[i] = line[:j]
+				break
+			}
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,193 @@
+package importer
+
+import (
+	"encoding/xml"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/cpjudge/cpjudge/models"
+	"github.com/pkg/errors"
+)
+
+// polygonProblem is the part of a Polygon problem.xml that is imported.
+type polygonProblem struct {
+	ShortName string `xml:"short-name,attr"`
+	Names     []struct {
+		Language string `xml:"language,attr"`
+		Value    string `xml:"value,attr"`
+	} `xml:"names>name"`
+	Judging struct {
+		InputFile  string `xml:"input-file,attr"`
+		OutputFile string `xml:"output-file,attr"`
+		Testsets   []struct {
+			Name        string `xml:"name,attr"`
+			TimeLimit   int    `xml:"time-limit"`
+			MemoryLimit int64  `xml:"memory-limit"`
+			InputPath   string `xml:"input-path-pattern"`
+			AnswerPath  string `xml:"answer-path-pattern"`
+			Tests       []struct {
+				Sample bool   `xml:"sample,attr"`
+				Group  string `xml:"group,attr"`
+				Points string `xml:"points,attr"`
+			} `xml:"tests>test"`
+		} `xml:"testset"`
+	} `xml:"judging"`
+	Checker *struct {
+		Name   string `xml:"name,attr"`
+		Source struct {
+			Path string `xml:"path,attr"`
+		} `xml:"source"`
+	} `xml:"assets>checker"`
+	Validators []struct {
+		Source struct {
+			Path string `xml:"path,attr"`
+		} `xml:"source"`
+	} `xml:"assets>validators>validator"`
+	Solutions []struct {
+		Tag    string `xml:"tag,attr"`
+		Source struct {
+			Path string `xml:"path,attr"`
+		} `xml:"source"`
+	} `xml:"assets>solutions>solution"`
+	Interactor *struct{} `xml:"assets>interactor"`
+}
+
+// polygonCheckers are the testlib standard checkers with a built-in
+// equivalent.
+var polygonCheckers = map[string]string{
+	"std::wcmp.cpp":  models.CheckerTokens,
+	"std::ncmp.cpp":  models.CheckerTokens,
+	"std::lcmp.cpp":  models.CheckerLines,
+	"std::fcmp.cpp":  models.CheckerExact,
+	"std::rcmp4.cpp": models.FloatChecker(1e-4),
+	"std::rcmp6.cpp": models.FloatChecker(1e-6),
+	"std::rcmp9.cpp": models.FloatChecker(1e-9),
+}
+
+// polygonSolutionTags map the tags of Polygon solutions to expected
+// verdicts.
+var polygonSolutionTags = map[string]string{
+	"main":                models.ExpectCorrect,
+	"accepted":            models.ExpectCorrect,
+	"time-limit-exceeded": models.ExpectTimeLimit,
+	"wrong-answer":        models.ExpectWrong,
+}
+
+// readPolygon imports a full Polygon package, which includes the generated
+// tests and their answers.
+func readPolygon(files packageFiles) (*Problem, error) {
+	pp := polygonProblem{}
+	if err := xml.Unmarshal(files["problem.xml"], &pp); err != nil {
+		return nil, errors.Errorf("problem.xml could not be read: %v", err)
+	}
+	if pp.Interactor != nil {
+		return nil, errors.New("Interactive problems are not supported.")
+	}
+	p := &Problem{Format: FormatPolygon, Checker: models.CheckerTokens}
+
+	language := "english"
+	for i, n := range pp.Names {
+		if i == 0 || n.Language == "english" {
+			p.Title, language = n.Value, n.Language
+		}
+	}
+	if p.Title == "" {
+		p.Title = pp.ShortName
+	}
+	polygonStatement(p, files, language)
+
+	if pp.Judging.InputFile != "" || pp.Judging.OutputFile != "" {
+		p.warn("Reading from and writing to files is not supported; programs use standard input and output.")
+	}
+	found := false
+	for _, ts := range pp.Judging.Testsets {
+		if ts.Name != "tests" {
+			if len(ts.Tests) > 0 {
+				p.warn("The testset %q was skipped; only \"tests\" is imported.", ts.Name)
+			}
+			continue
+		}
+		found = true
+		p.TimeLimit = ts.TimeLimit
+		p.MemoryLimit = int(ts.MemoryLimit >> 20)
+		grouped := false
+		for i, t := range ts.Tests {
+			grouped = grouped || t.Group != "" || t.Points != ""
+			input, answer := polygonPath(ts.InputPath, i+1), polygonPath(ts.AnswerPath, i+1)
+			if !files.has(input) {
+				return nil, errors.Errorf("Test %d is missing; download the full package, which includes generated tests.", i+1)
+			}
+			if !files.has(answer) {
+				return nil, errors.Errorf("The answer of test %d is missing; download the full package.", i+1)
+			}
+			p.Tests = append(p.Tests, Test{Input: files[input], Answer: files[answer], Sample: t.Sample})
+		}
+		if grouped {
+			p.warn("Test groups and points are not supported; every test must pass.")
+		}
+	}
+	if !found {
+		return nil, errors.New("problem.xml has no \"tests\" testset.")
+	}
+
+	if pp.Checker != nil {
+		if checker, ok := polygonCheckers[pp.Checker.Name]; ok {
+			p.Checker = checker
+		} else {
+			return nil, errors.Errorf("The custom checker %s is not supported.", path.Base(pp.Checker.Source.Path))
+		}
+	}
+	for i, v := range pp.Validators {
+		if i > 0 {
+			p.warn("Only the first validator is imported.")
+			break
+		}
+		p.Validator = program(p, files, v.Source.Path)
+	}
+	skipped := 0
+	for _, sol := range pp.Solutions {
+		expected, ok := polygonSolutionTags[sol.Tag]
+		if !ok || !p.addSolution(files, sol.Source.Path, expected, sol.Tag == "main") {
+			skipped++
+		}
+	}
+	if skipped > 0 {
+		p.warn("%d solutions were not imported: only C and C++ solutions expected to be accepted, to exceed the time limit or to give wrong answers are.", skipped)
+	}
+	return p, nil
+}
+
+// polygonStatement converts the statement sections in the given language to
+// markdown, attaching the pictures they show.
+func polygonStatement(p *Problem, files packageFiles, language string) {
+	dir := "statement-sections/" + language + "/"
+	section := func(name string) string {
+		return p.attachImages(files, dir, latexToMarkdown(string(files[dir+name+".tex"])))
+	}
+	p.Description = section("legend")
+	if p.Description == "" {
+		p.warn("The package has no %s statement sections; add the statement by hand.", language)
+	}
+	p.Input, p.Output, p.Notes = section("input"), section("output"), section("notes")
+}
+
+// polygonPath fills in a path pattern such as tests/%02d.
+func polygonPath(pattern string, n int) string {
+	return fmt.Sprintf(pattern, n)
+}
+
+// program reads the source of a program shipped with a package, if it is in
+// a supported language.
+func program(p *Problem, files packageFiles, name string) *Program {
+	source, ok := files[name]
+	if !ok {
+		p.warn("%s is missing from the package.", name)
+		return nil
+	}
+	if !sourceExtensions[strings.ToLower(path.Ext(name))] {
+		p.warn("%s is not written in C or C++ and was not imported.", path.Base(name))
+		return nil
+	}
+	return &Program{Name: path.Base(name), Source: source}
+}
@@ -0,0 +1,73 @@
+package judge
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/cpjudge/cpjudge/models"
+)
+
+// Check reports whether output matches answer under the checker. Unknown
+// checkers fall back to the exact comparison.
+func Check(checker, output, answer string) bool {
+	kind, tolerance, err := models.ParseChecker(checker)
+	if err != nil || kind == models.CheckerExact {
+		return strings.Trim(output, "\n") == strings.Trim(answer, "\n")
+	}
+	if kind == models.CheckerLines {
+		got, want := lines(output), lines(answer)
+		if len(got) != len(want) {
+			return false
+		}
+		for i := range got {
+			if !sameTokens(kind, got[i], want[i], tolerance) {
+				return false
+			}
+		}
+		return true
+	}
+	return sameTokens(kind, output, answer, tolerance)
+}
+
+// sameTokens reports whether output and answer have the same tokens, real
+// numbers compared within tolerance for float checkers.
+func sameTokens(kind, output, answer string, tolerance float64) bool {
+	got, want := strings.Fields(output), strings.Fields(answer)
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] == want[i] {
+			continue
+		}
+		if kind != models.CheckerFloat || !closeEnough(got[i], want[i], tolerance) {
+			return false
+		}
+	}
+	return true
+}
+
+// lines splits s into lines, dropping trailing blank lines.
+func lines(s string) []string {
+	l := strings.Split(s, "\n")
+	for len(l) > 0 && strings.TrimSpace(l[len(l)-1]) == "" {
+		l = l[:len(l)-1]
+	}
+	return l
+}
+
+// closeEnough reports whether two real numbers differ by at most tolerance,
+// absolutely or relative to the expected value.
+func closeEnough(got, want string, tolerance float64) bool {
+	g, err := strconv.ParseFloat(got, 64)
+	if err != nil || math.IsNaN(g) || math.IsInf(g, 0) {
+		return false
+	}
+	w, err := strconv.ParseFloat(want, 64)
+	if err != nil {
+		return false
+	}
+	diff := math.Abs(g - w)
+	return diff <= tolerance || diff <= tolerance*math.Abs(w)
+}
@@ -0,0 +1,105 @@
+package judge
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cpjudge/cpjudge/models"
+	"github.com/pkg/errors"
+)
+
+// GeneratorLimits apply to each run of a generator.
+var GeneratorLimits = Limits{Time: 30 * time.Second, MemoryMB: models.MaxMemoryLimit}
+
+// GenerateInputs builds test inputs by running generators as a generator
+// script calls them. sources maps generator names to the paths of their
+// sources. Generators get no input, so the same script always builds the
+// same inputs. It returns a test data archive of the inputs, or the tests
+// whose generator failed.
+func GenerateInputs(sources map[string]string, calls []models.GeneratorCall) ([]byte, []InvalidInput, error) {
+	dir, err := ioutil.TempDir("", "cpjudge-generate")
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	defer os.RemoveAll(dir)
+
+	binaries := map[string]string{}
+	for _, call := range calls {
+		if _, ok := binaries[call.Generator]; ok {
+			continue
+		}
+		source, ok := sources[call.Generator]
+		if !ok {
+			return nil, nil, &ScriptError{Line: call.Line, Message: fmt.Sprintf("There is no generator named %q.", call.Generator)}
+		}
+		binary := filepath.Join(dir, "generator_"+call.Generator)
+		if err := compileProgram(source, binary); err != nil {
+			if cerr, ok := err.(*CompileError); ok {
+				cerr.Output = call.Generator + ":\n" + cerr.Output
+			}
+			return nil, nil, err
+		}
+		binaries[call.Generator] = binary
+	}
+
+	inputs := filepath.Join(dir, "inputs")
+	if err := os.MkdirAll(inputs, 0755); err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	failed := []InvalidInput{}
+	for _, call := range calls {
+		f, err := os.Create(filepath.Join(inputs, call.Test))
+		if err != nil {
+			return nil, nil, errors.WithStack(err)
+		}
+		w := &cappedWriter{w: f, max: MaxTestFileSize}
+		res := runBinaryTo(dir, binaries[call.Generator], call.Args, strings.NewReader(""), w, GeneratorLimits)
+		f.Close()
+		msg := ""
+		switch {
+		case res.Status == StatusSystemError:
+			return nil, nil, errors.Errorf("The generator %s could not be run.", call.Generator)
+		case res.Status != "":
+			msg = "The generator got " + res.Status + "."
+			if stderr := strings.TrimSpace(res.Stderr); stderr != "" {
+				msg += "\n" + stderr
+			}
+		case w.exceeded:
+			msg = fmt.Sprintf("The input is larger than %d MB.", MaxTestFileSize>>20)
+		}
+		if msg != "" {
+			failed = append(failed, InvalidInput{Name: call.Test, Message: fmt.Sprintf("Line %d, %s: %s", call.Line, call, msg)})
+		}
+	}
+	if len(failed) > 0 {
+		return nil, failed, nil
+	}
+
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	for _, call := range calls {
+		if err := zipFile(zw, filepath.Join(inputs, call.Test), "inputs/"+call.Test); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	return buf.Bytes(), nil, nil
+}
+
+// ScriptError is a mistake in a generator script.
+type ScriptError struct {
+	Line    int
+	Message string
+}
+
+func (e *ScriptError) Error() string {
+	return fmt.Sprintf("Line %d: %s", e.Line, e.Message)
+}
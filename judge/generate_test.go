@@ -0,0 +1,85 @@
+package judge
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/cpjudge/cpjudge/models"
+)
+
+// repeater prints its first argument as many times as its second says, and
+// fails when there is no second argument.
+const repeater = `#include <stdio.h>
+#include <stdlib.h>
+int main(int argc, char **argv) {
+	if (argc < 3) {
+		fprintf(stderr, "usage: repeat word count\n");
+		return 1;
+	}
+	for (int i = atoi(argv[2]); i > 0; i--) {
+		printf("%s\n", argv[1]);
+	}
+	return 0;
+}
+`
+
+func TestGenerateInputs(t *testing.T) {
+	if _, err := exec.LookPath("gcc"); err != nil {
+		t.Skip("gcc is not installed")
+	}
+	dir, err := ioutil.TempDir("", "judge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	source := filepath.Join(dir, "repeat.c")
+	if err := ioutil.WriteFile(source, []byte(repeater), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sources := map[string]string{"repeat": source}
+
+	calls := []models.GeneratorCall{
+		{Line: 1, Test: "01.txt", Generator: "repeat", Args: []string{"a", "2"}},
+		{Line: 3, Test: "02.txt", Generator: "repeat", Args: []string{"b", "1"}},
+	}
+	archive, failed, err := GenerateInputs(sources, calls)
+	if err != nil || len(failed) > 0 {
+		t.Fatal(err, failed)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{}
+	for _, f := range zr.File {
+		rc, _ := f.Open()
+		b, _ := ioutil.ReadAll(rc)
+		rc.Close()
+		files[f.Name] = string(b)
+	}
+	want := map[string]string{"inputs/01.txt": "a\na\n", "inputs/02.txt": "b\n"}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("got %q, want %q", files, want)
+	}
+
+	calls[1].Args = []string{"b"}
+	_, failed, err = GenerateInputs(sources, calls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantFailed := []InvalidInput{{Name: "02.txt", Message: "Line 3, repeat b: The generator got Runtime Error.\nusage: repeat word count"}}
+	if !reflect.DeepEqual(failed, wantFailed) {
+		t.Errorf("got %+v, want %+v", failed, wantFailed)
+	}
+
+	calls[1].Generator = "random"
+	if _, _, err := GenerateInputs(sources, calls); err == nil || err.Error() != `Line 3: There is no generator named "random".` {
+		t.Errorf("unknown generator: %v", err)
+	}
+}
@@ -0,0 +1,55 @@
+package judge
+
+import (
+	"github.com/cpjudge/cpjudge/models"
+	"github.com/gobuffalo/pop"
+)
+
+// NewJob describes a claimed job for the worker.
+func NewJob(job *models.JudgeJob, s *models.Submission, q *models.Question, source string) Job {
+	return Job{
+		ID:           job.ID.String(),
+		SubmissionID: s.ID.String(),
+		QuestionID:   q.ID.String(),
+		SourceName:   s.SourceFilename(),
+		Source:       source,
+		Checksum:     job.TestDataVersion,
+		Samples:      q.SampleNames(),
+		TimeLimit:    q.EffectiveTimeLimit(),
+		MemoryLimit:  q.EffectiveMemoryLimit(),
+		Checker:      q.Checker,
+	}
+}
+
+// NewReferenceJob describes a claimed job of a reference solution for the
+// worker.
+func NewReferenceJob(job *models.JudgeJob, s *models.ReferenceSolution, q *models.Question, source string) Job {
+	return Job{
+		ID:                  job.ID.String(),
+		ReferenceSolutionID: s.ID.String(),
+		QuestionID:          q.ID.String(),
+		SourceName:          s.Filename,
+		Source:              source,
+		Checksum:            job.TestDataVersion,
+		Samples:             q.SampleNames(),
+		TimeLimit:           q.EffectiveTimeLimit(),
+		MemoryLimit:         q.EffectiveMemoryLimit(),
+		Checker:             q.Checker,
+	}
+}
+
+// Complete stores the verdict a worker reported. Once the last job of a
+// contest is done, ratings are recalculated if a rejudge changed verdicts
+// of the contest after its ratings were applied.
+func Complete(tx *pop.Connection, job *models.JudgeJob, w *models.Worker, status string) error {
+	s, err := job.Finish(tx, w, status)
+	if err != nil || s == nil {
+		return err
+	}
+	pending, err := models.ContestJobsPending(tx, s.ContestID)
+	if err != nil || pending {
+		return err
+	}
+	_, err = RecalculateOutdatedRatings(tx, s.ContestID)
+	return err
+}
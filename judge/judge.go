@@ -0,0 +1,311 @@
+// Package judge compiles submissions and runs them against the test cases
+// of their question.
+package judge
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/cpjudge/cpjudge/models"
+	"github.com/cpjudge/cpjudge/storage"
+	"github.com/gobuffalo/envy"
+	"github.com/gobuffalo/pop"
+	"github.com/gobuffalo/uuid"
+	"github.com/pkg/errors"
+)
+
+// StatusSystemError is the verdict when the judge itself fails, for example
+// because the test data is missing.
+const StatusSystemError = models.StatusSystemError
+
+// MaxOutput is how much of a program's stdout and stderr is kept for
+// display. Outputs are judged in full, up to MaxTestFileSize.
+const MaxOutput = 64 << 10
+
+// Limits are the resources a program may use on a single input.
+type Limits struct {
+	Time     time.Duration
+	MemoryMB int
+}
+
+// DefaultLimits apply to questions without limits of their own.
+var DefaultLimits = Limits{Time: models.DefaultTimeLimit * time.Millisecond, MemoryMB: models.DefaultMemoryLimit}
+
+// QuestionLimits returns the limits configured on a question.
+func QuestionLimits(q *models.Question) Limits {
+	return Limits{
+		Time:     time.Duration(q.EffectiveTimeLimit()) * time.Millisecond,
+		MemoryMB: q.EffectiveMemoryLimit(),
+	}
+}
+
+// Result is the outcome of running a program once.
+type Result struct {
+	// Status is empty when the program exited normally within its limits.
+	Status   string        `json:"status"`
+	Stdout   string        `json:"stdout"`
+	Stderr   string        `json:"stderr"`
+	Time     time.Duration `json:"time"`
+	MemoryKB int64         `json:"memory_kb"`
+}
+
+// Evaluate judges the source stored under submissionKey against the
+// current test data of a question. It returns the verdict and the checksum
+// of the test data used. Both are copied from storage into a temporary
+// directory first.
+func Evaluate(tx *pop.Connection, submissionKey string, questionID uuid.UUID) (string, string) {
+	question := &models.Question{}
+	if err := tx.Find(question, questionID); err != nil {
+		log.Printf("judge: question %s not found: %v", questionID, err)
+		return StatusSystemError, ""
+	}
+	if question.TestCasesSum == "" {
+		if err := question.UpdateChecksum(tx); err != nil {
+			log.Printf("judge: checksum of %s: %v", question.TestCasesPath, err)
+			return StatusSystemError, ""
+		}
+	}
+	version := question.TestCasesSum
+	dir, err := ioutil.TempDir("", "cpjudge-data")
+	if err != nil {
+		log.Printf("judge: %v", err)
+		return StatusSystemError, version
+	}
+	defer os.RemoveAll(dir)
+	store := storage.Default()
+	source := filepath.Join(dir, path.Base(submissionKey))
+	if err := storage.DownloadFile(store, submissionKey, source); err != nil {
+		log.Printf("judge: source %s could not be fetched: %v", submissionKey, err)
+		return StatusSystemError, version
+	}
+	testCases := filepath.Join(dir, "testcases")
+	if err := storage.Download(store, question.TestCasesPath+"/", testCases); err != nil {
+		log.Printf("judge: test cases %s could not be fetched: %v", question.TestCasesPath, err)
+		return StatusSystemError, version
+	}
+	return RunWithProgress(source, testCases, question.SampleNames(), QuestionLimits(question), question.Checker, nil), version
+}
+
+// Run compiles the source at submissionPath and runs it on every input in
+// testCasesPath/inputs, comparing the output with the answer of the same
+// name in testCasesPath/answers. The samples are run first, and failing one
+// of them gives the verdict on a sample, such as StatusWrongOnSample.
+// Outputs must match answers exactly.
+func Run(submissionPath, testCasesPath string, samples []string, limits Limits) string {
+	return RunWithProgress(submissionPath, testCasesPath, samples, limits, models.CheckerExact, nil)
+}
+
+// RunWithProgress is Run, comparing outputs with the given checker and
+// calling progress with the number of the test about to run and the number
+// of tests. progress may be nil.
+func RunWithProgress(submissionPath, testCasesPath string, samples []string, limits Limits, checker string, progress func(test, total int)) string {
+	dir, err := ioutil.TempDir("", "cpjudge")
+	if err != nil {
+		log.Printf("judge: %v", err)
+		return StatusSystemError
+	}
+	defer os.RemoveAll(dir)
+	binary := filepath.Join(dir, "a.out")
+
+	switch res := compile(submissionPath, binary); res.Status {
+	case "":
+	case StatusSystemError:
+		return StatusSystemError
+	default:
+		return models.StatusCompilationError
+	}
+
+	inputs, err := ioutil.ReadDir(filepath.Join(testCasesPath, "inputs"))
+	if err != nil {
+		log.Printf("judge: input test cases could not be read: %v", err)
+		return StatusSystemError
+	}
+	answers, err := ioutil.ReadDir(filepath.Join(testCasesPath, "answers"))
+	if err != nil {
+		log.Printf("judge: answers of test cases could not be read: %v", err)
+		return StatusSystemError
+	}
+	if len(inputs) != len(answers) {
+		log.Printf("judge: %s has %d inputs and %d answers", testCasesPath, len(inputs), len(answers))
+		return StatusSystemError
+	}
+
+	testCases := orderTestCases(inputs, samples)
+	for i, tc := range testCases {
+		if progress != nil {
+			progress(i+1, len(testCases))
+		}
+		input, err := os.Open(filepath.Join(testCasesPath, "inputs", tc.name))
+		if err != nil {
+			log.Printf("judge: could not read input test case file: %v", err)
+			return StatusSystemError
+		}
+		stdout := &bytes.Buffer{}
+		w := &cappedWriter{w: stdout, max: MaxTestFileSize}
+		res := runBinaryTo(dir, binary, nil, input, w, limits)
+		input.Close()
+		if res.Status == "" && w.exceeded {
+			// No answer is that large.
+			res.Status = models.StatusWrong
+		}
+		if res.Status != "" {
+			if tc.sample && res.Status != StatusSystemError {
+				return models.SampleStatus(res.Status)
+			}
+			return res.Status
+		}
+
+		answer, err := ioutil.ReadFile(filepath.Join(testCasesPath, "answers", tc.name))
+		if err != nil {
+			log.Printf("judge: could not read answer test case file: %v", err)
+			return StatusSystemError
+		}
+		if !Check(checker, stdout.String(), string(answer)) {
+			if tc.sample {
+				return models.StatusWrongOnSample
+			}
+			return models.StatusWrong
+		}
+	}
+	return models.StatusCorrect
+}
+
+type testCase struct {
+	name   string
+	sample bool
+}
+
+// orderTestCases puts the samples first, in the order given, followed by
+// the other inputs.
+func orderTestCases(inputs []os.FileInfo, samples []string) []testCase {
+	exists := map[string]bool{}
+	for _, f := range inputs {
+		exists[f.Name()] = true
+	}
+	ordered := []testCase{}
+	isSample := map[string]bool{}
+	for _, name := range samples {
+		if exists[name] && !isSample[name] {
+			isSample[name] = true
+			ordered = append(ordered, testCase{name: name, sample: true})
+		}
+	}
+	for _, f := range inputs {
+		if !isSample[f.Name()] {
+			ordered = append(ordered, testCase{name: f.Name()})
+		}
+	}
+	return ordered
+}
+
+// Execute compiles the source at submissionPath and runs it once on input,
+// for trying code on custom input. Compiler errors are returned in Stderr.
+func Execute(submissionPath string, input io.Reader, limits Limits) Result {
+	dir, err := ioutil.TempDir("", "cpjudge")
+	if err != nil {
+		log.Printf("judge: %v", err)
+		return Result{Status: StatusSystemError}
+	}
+	defer os.RemoveAll(dir)
+	binary := filepath.Join(dir, "a.out")
+
+	switch res := compile(submissionPath, binary); res.Status {
+	case "":
+	case StatusSystemError:
+		return res
+	default:
+		return Result{Status: models.StatusCompilationError, Stderr: compilerOutput(res)}
+	}
+	return runBinary(dir, binary, input, limits)
+}
+
+// compile compiles src into binary in the sandbox, picking the compiler
+// from the file extension. The compiler output is in Result.Stderr.
+func compile(src, binary string) Result {
+	argv := []string{"gcc", "-O2", "-o", binary, src, "-lm"}
+	if ext := filepath.Ext(src); ext == ".cpp" || ext == ".cc" {
+		argv = []string{"g++", "-O2", "-o", binary, src}
+	}
+	cmd := sandboxed(filepath.Dir(binary), []string{filepath.Dir(src)}, argv, CompileLimits)
+	return runCommand(cmd, nil, ioutil.Discard, CompileLimits)
+}
+
+// compilerOutput explains why a compilation failed.
+func compilerOutput(res Result) string {
+	if res.Status == models.StatusTimeLimit {
+		return fmt.Sprintf("The compiler ran for more than %s.", CompileLimits.Time)
+	}
+	return res.Stderr
+}
+
+// runBinary runs binary in the sandbox, in dir, with the given input under
+// the limits.
+func runBinary(dir, binary string, input io.Reader, limits Limits) Result {
+	stdout := &limitedBuffer{max: MaxOutput}
+	res := runBinaryTo(dir, binary, nil, input, stdout, limits)
+	res.Stdout = stdout.String()
+	return res
+}
+
+// runBinaryTo is runBinary passing args to the program and writing its
+// stdout to w. Result.Stdout is left empty.
+func runBinaryTo(dir, binary string, args []string, input io.Reader, w io.Writer, limits Limits) Result {
+	cmd := sandboxed(dir, nil, append([]string{binary}, args...), limits)
+	return runCommand(cmd, input, w, limits)
+}
+
+// limitedBuffer keeps the first max bytes written to it and drops the rest.
+type limitedBuffer struct {
+	bytes.Buffer
+	max int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.max - b.Len(); room > 0 {
+		if len(p) > room {
+			b.Buffer.Write(p[:room])
+		} else {
+			b.Buffer.Write(p)
+		}
+	}
+	return len(p), nil
+}
+
+// UseWorkers reports whether submissions are judged by worker processes
+// (JUDGE_MODE=workers) rather than by the web server.
+func UseWorkers() bool {
+	return envy.Get("JUDGE_MODE", "local") == "workers"
+}
+
+// Dispatch judges a new submission: it queues it for the workers, or
+// judges the pending submissions right away.
+func Dispatch(tx *pop.Connection, s *models.Submission) error {
+	if UseWorkers() {
+		_, err := models.EnqueueJudgeJob(tx, s.ID)
+		return err
+	}
+	return JudgePending(tx)
+}
+
+// JudgePending evaluates every pending submission.
+func JudgePending(tx *pop.Connection) error {
+	submissions := models.Submissions{}
+	if err := tx.Where("status = ?", models.StatusPending).All(&submissions); err != nil {
+		return errors.WithStack(err)
+	}
+	for i := range submissions {
+		s := &submissions[i]
+		s.Status, s.TestDataVersion = Evaluate(tx, s.SubmissionPath, s.QuestionID)
+		if err := tx.Update(s); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,220 @@
+package judge
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cpjudge/cpjudge/models"
+)
+
+func TestMain(m *testing.M) {
+	if _, err := exec.LookPath(NSJail); Sandbox != "none" && err != nil {
+		// Tests compile and run their own programs only.
+		Sandbox = "none"
+	}
+	os.Exit(m.Run())
+}
+
+// writeProblem lays out a sum problem with one test case and returns the
+// test case directory.
+func writeProblem(t *testing.T, dir string) string {
+	tc := filepath.Join(dir, "testcases")
+	for _, sub := range []string{"inputs", "answers"} {
+		if err := os.MkdirAll(filepath.Join(tc, sub), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(tc, "inputs", "1.txt"), []byte("1 2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(tc, "answers", "1.txt"), []byte("3\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return tc
+}
+
+func writeSource(t *testing.T, dir, src string) string {
+	path := filepath.Join(dir, "main.c")
+	if err := ioutil.WriteFile(path, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func Test_Run(t *testing.T) {
+	if _, err := exec.LookPath("gcc"); err != nil {
+		t.Skip("gcc is not installed")
+	}
+	dir, err := ioutil.TempDir("", "judge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	tc := writeProblem(t, dir)
+
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"#include <stdio.h>\nint main(){int a,b;scanf(\"%d %d\",&a,&b);printf(\"%d\\n\",a+b);return 0;}", models.StatusCorrect},
+		{"#include <stdio.h>\nint main(){printf(\"4\\n\");return 0;}", models.StatusWrong},
+		{"int main(){return 1;}", models.StatusRuntimeError},
+		{"int main(){for(;;);}", models.StatusTimeLimit},
+		{"int main(){", models.StatusCompilationError},
+	}
+	limits := Limits{Time: time.Second, MemoryMB: 64}
+	for _, tt := range tests {
+		if got := Run(writeSource(t, dir, tt.src), tc, nil, limits); got != tt.want {
+			t.Errorf("Run(%q) = %q, want %q", tt.src, got, tt.want)
+		}
+	}
+}
+
+func Test_Run_Samples(t *testing.T) {
+	if _, err := exec.LookPath("gcc"); err != nil {
+		t.Skip("gcc is not installed")
+	}
+	dir, err := ioutil.TempDir("", "judge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	tc := writeProblem(t, dir)
+	if err := ioutil.WriteFile(filepath.Join(tc, "inputs", "2.txt"), []byte("5 5\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(tc, "answers", "2.txt"), []byte("10\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// Prints 3 whatever the input: right on 1.txt, wrong on 2.txt.
+	src := writeSource(t, dir, "#include <stdio.h>\nint main(){printf(\"3\\n\");return 0;}")
+	if got := Run(src, tc, nil, DefaultLimits); got != models.StatusWrong {
+		t.Errorf("Run() = %q, want %q", got, models.StatusWrong)
+	}
+	if got := Run(src, tc, []string{"2.txt"}, DefaultLimits); got != models.StatusWrongOnSample {
+		t.Errorf("Run() = %q, want %q", got, models.StatusWrongOnSample)
+	}
+
+	// Crashes on 2.txt only.
+	src = writeSource(t, dir, "#include <stdio.h>\nint main(){int a,b;scanf(\"%d %d\",&a,&b);printf(\"%d\\n\",a+b);return a==5;}")
+	if got := Run(src, tc, []string{"2.txt"}, DefaultLimits); got != models.SampleStatus(models.StatusRuntimeError) {
+		t.Errorf("Run() = %q, want %q", got, models.SampleStatus(models.StatusRuntimeError))
+	}
+}
+
+func Test_Run_LargeOutput(t *testing.T) {
+	if _, err := exec.LookPath("gcc"); err != nil {
+		t.Skip("gcc is not installed")
+	}
+	dir, err := ioutil.TempDir("", "judge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	tc := writeProblem(t, dir)
+	// The answer of 1.txt is 100000 lines of "3", well over MaxOutput.
+	if err := ioutil.WriteFile(filepath.Join(tc, "answers", "1.txt"), []byte(strings.Repeat("3\n", 100000)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		last string
+		want string
+	}{
+		{"3", models.StatusCorrect},
+		{"4", models.StatusWrong},
+	} {
+		src := writeSource(t, dir, "#include <stdio.h>\nint main(){int i;for(i=1;i<100000;i++)printf(\"3\\n\");printf(\""+c.last+"\\n\");return 0;}")
+		if got := Run(src, tc, nil, DefaultLimits); got != c.want {
+			t.Errorf("Run() with last line %s = %q, want %q", c.last, got, c.want)
+		}
+	}
+}
+
+func Test_Run_MissingTestCases(t *testing.T) {
+	if _, err := exec.LookPath("gcc"); err != nil {
+		t.Skip("gcc is not installed")
+	}
+	dir, err := ioutil.TempDir("", "judge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	src := writeSource(t, dir, "int main(){return 0;}")
+	if got := Run(src, filepath.Join(dir, "missing"), nil, DefaultLimits); got != StatusSystemError {
+		t.Errorf("Run() = %q, want %q", got, StatusSystemError)
+	}
+}
+
+func Test_Run_KillsChildren(t *testing.T) {
+	if _, err := exec.LookPath("gcc"); err != nil {
+		t.Skip("gcc is not installed")
+	}
+	dir, err := ioutil.TempDir("", "judge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	tc := writeProblem(t, dir)
+
+	// The child keeps stdout open, so the run only ends once it is killed.
+	src := writeSource(t, dir, "#include <unistd.h>\nint main(){fork();for(;;);}")
+	start := time.Now()
+	if got := Run(src, tc, nil, Limits{Time: time.Second, MemoryMB: 64}); got != models.StatusTimeLimit {
+		t.Errorf("Run() = %q, want %q", got, models.StatusTimeLimit)
+	}
+	if d := time.Since(start); d > 10*time.Second {
+		t.Errorf("Run() took %s", d)
+	}
+}
+
+func Test_Execute(t *testing.T) {
+	if _, err := exec.LookPath("gcc"); err != nil {
+		t.Skip("gcc is not installed")
+	}
+	dir, err := ioutil.TempDir("", "judge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	src := writeSource(t, dir, "#include <stdio.h>\nint main(){int a,b;scanf(\"%d %d\",&a,&b);printf(\"%d\\n\",a+b);fprintf(stderr,\"done\");return 0;}")
+	res := Execute(src, strings.NewReader("40 2\n"), DefaultLimits)
+	if res.Status != "" || res.Stdout != "42\n" || res.Stderr != "done" {
+		t.Errorf("Execute() = %+v", res)
+	}
+
+	res = Execute(writeSource(t, dir, "int main(){"), strings.NewReader(""), DefaultLimits)
+	if res.Status != models.StatusCompilationError || res.Stderr == "" {
+		t.Errorf("Execute() = %+v, want a compilation error with the compiler output", res)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	for _, tt := range []struct {
+		checker, output, answer string
+		want                    bool
+	}{
+		{models.CheckerExact, "1 2\n\n", "1 2", true},
+		{models.CheckerExact, "1  2", "1 2", false},
+		{models.CheckerTokens, "1  2\n3 ", "1 2 3\n", true},
+		{models.CheckerTokens, "1 2", "1 2 3", false},
+		{models.CheckerLines, "1  2 \r\n3\n\n", "1 2\n3", true},
+		{models.CheckerLines, "1\n2 3", "1 2\n3", false},
+		{models.CheckerLines, "1 2\n\n3", "1 2\n3", false},
+		{models.FloatChecker(1e-6), "0.3333333", "0.333333333", true},
+		{models.FloatChecker(1e-6), "1000000.5", "1000000", true},
+		{models.FloatChecker(1e-6), "0.334", "0.333333333", false},
+		{models.FloatChecker(1e-6), "nan", "1", false},
+		{models.FloatChecker(1e-6), "yes 1.0000001", "yes 1", true},
+		{"unknown", "1 2\n", "1 2", true},
+	} {
+		if got := Check(tt.checker, tt.output, tt.answer); got != tt.want {
+			t.Errorf("Check(%q, %q, %q) = %v, want %v", tt.checker, tt.output, tt.answer, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package judge
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cpjudge/cpjudge/models"
+	"github.com/cpjudge/cpjudge/storage"
+	"github.com/gobuffalo/pop"
+	"github.com/gobuffalo/pop/nulls"
+	"github.com/pkg/errors"
+)
+
+// MaxTestFileSize is the largest input a generator, or answer a main
+// solution, may print.
+const MaxTestFileSize = 64 << 20
+
+// MissingAnswers reports whether a test data archive holds inputs but no
+// answers, which are then generated with the main solution.
+func MissingAnswers(archive []byte) bool {
+	zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		return false
+	}
+	inputs := false
+	for _, f := range zr.File {
+		name := strings.TrimPrefix(f.Name, "testcases/")
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		if strings.HasPrefix(name, "answers/") {
+			return false
+		}
+		inputs = inputs || strings.HasPrefix(name, "inputs/")
+	}
+	return inputs
+}
+
+// GenerateAnswers runs the solution at solutionPath on every input of a
+// test data archive and returns an archive of the inputs with the outputs
+// as answers. When the solution fails on some inputs, they are returned
+// instead, with the verdict.
+func GenerateAnswers(solutionPath string, archive []byte, limits Limits) ([]byte, []InvalidInput, error) {
+	dir, err := ioutil.TempDir("", "cpjudge-answers")
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := storage.Unzip(archive, storage.NewLocal(filepath.Join(dir, "testcases")), "", "testcases/"); err != nil {
+		return nil, nil, err
+	}
+	binary := filepath.Join(dir, "solution")
+	if err := compileProgram(solutionPath, binary); err != nil {
+		return nil, nil, err
+	}
+	inputs, err := ioutil.ReadDir(filepath.Join(dir, "testcases", "inputs"))
+	if err != nil {
+		return nil, nil, errors.New("The test data has no inputs folder.")
+	}
+	answers := filepath.Join(dir, "testcases", "answers")
+	if err := os.MkdirAll(answers, 0755); err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+
+	failed := []InvalidInput{}
+	for _, f := range inputs {
+		failure, err := generateAnswer(dir, binary, f.Name(), limits)
+		if err != nil {
+			return nil, nil, err
+		}
+		if failure != "" {
+			failed = append(failed, InvalidInput{Name: f.Name(), Message: failure})
+		}
+	}
+	if len(failed) > 0 {
+		return nil, failed, nil
+	}
+
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	for _, sub := range []string{"inputs", "answers"} {
+		for _, f := range inputs {
+			if err := zipFile(zw, filepath.Join(dir, "testcases", sub, f.Name()), sub+"/"+f.Name()); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	return buf.Bytes(), nil, nil
+}
+
+// generateAnswer writes the output of binary on an input to the answer of
+// the same name. It returns why the solution failed, or "".
+func generateAnswer(dir, binary, name string, limits Limits) (string, error) {
+	input, err := os.Open(filepath.Join(dir, "testcases", "inputs", name))
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	defer input.Close()
+	answer, err := os.Create(filepath.Join(dir, "testcases", "answers", name))
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	defer answer.Close()
+	w := &cappedWriter{w: answer, max: MaxTestFileSize}
+	res := runBinaryTo(dir, binary, nil, input, w, limits)
+	switch {
+	case res.Status == StatusSystemError:
+		return "", errors.Errorf("The main solution could not be run on %s.", name)
+	case res.Status != "":
+		return "The main solution got " + res.Status + ".", nil
+	case w.exceeded:
+		return fmt.Sprintf("The answer is larger than %d MB.", MaxTestFileSize>>20), nil
+	}
+	return "", nil
+}
+
+func zipFile(zw *zip.Writer, path, name string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer src.Close()
+	dst, err := zw.Create(name)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	_, err = io.Copy(dst, src)
+	return errors.WithStack(err)
+}
+
+// cappedWriter writes the first max bytes to w and drops the rest,
+// remembering that it did.
+type cappedWriter struct {
+	w        io.Writer
+	n, max   int
+	exceeded bool
+}
+
+func (c *cappedWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	if room := c.max - c.n; n > room {
+		c.exceeded = true
+		p = p[:room]
+	}
+	if len(p) > 0 {
+		if _, err := c.w.Write(p); err != nil {
+			return 0, err
+		}
+		c.n += len(p)
+	}
+	return n, nil
+}
+
+// QueueReferenceSolutions has the solutions judged on the current test
+// data of the question: by the workers, or by a background task of the web
+// server. They are pending until then.
+func QueueReferenceSolutions(tx *pop.Connection, question *models.Question, solutions models.ReferenceSolutions) error {
+	if question.TestCasesPath == "" || len(solutions) == 0 {
+		return nil
+	}
+	if question.TestCasesSum == "" {
+		if err := question.UpdateChecksum(tx); err != nil {
+			return err
+		}
+	}
+	for i := range solutions {
+		s := &solutions[i]
+		s.Status = models.StatusPending
+		s.TestDataSum = question.TestCasesSum
+		if err := tx.Update(s); err != nil {
+			return errors.WithStack(err)
+		}
+		if UseWorkers() {
+			if _, err := models.EnqueueReferenceJob(tx, s.ID); err != nil {
+				return err
+			}
+		}
+	}
+	if UseWorkers() {
+		return nil
+	}
+	return models.EnqueueTask(tx, &models.Task{Kind: models.TaskReferenceSolutions, QuestionID: nulls.NewUUID(question.ID)})
+}
+
+// QueueAllReferenceSolutions has every reference solution of the question
+// judged after its test data changed.
+func QueueAllReferenceSolutions(tx *pop.Connection, question *models.Question) error {
+	solutions, err := models.QuestionReferenceSolutions(tx, question.ID)
+	if err != nil {
+		return err
+	}
+	return QueueReferenceSolutions(tx, question, solutions)
+}
+
+// CheckReferenceSolutions judges the solutions against the current test
+// data of the question and records their verdicts.
+func CheckReferenceSolutions(tx *pop.Connection, question *models.Question, solutions models.ReferenceSolutions) error {
+	if question.TestCasesPath == "" || len(solutions) == 0 {
+		return nil
+	}
+	if question.TestCasesSum == "" {
+		if err := question.UpdateChecksum(tx); err != nil {
+			return err
+		}
+	}
+	dir, err := ioutil.TempDir("", "cpjudge-reference")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer os.RemoveAll(dir)
+	testCases := filepath.Join(dir, "testcases")
+	if err := storage.Download(storage.Default(), question.TestCasesPath+"/", testCases); err != nil {
+		return err
+	}
+
+	for i := range solutions {
+		s := &solutions[i]
+		source, err := s.Download(dir)
+		if err != nil {
+			return err
+		}
+		s.Status = RunWithProgress(source, testCases, question.SampleNames(), QuestionLimits(question), question.Checker, nil)
+		s.TestDataSum = question.TestCasesSum
+		if err := tx.Update(s); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
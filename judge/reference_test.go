@@ -0,0 +1,104 @@
+package judge
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// doubler prints twice the integer it reads, and loops forever on zero.
+const doubler = `#include <stdio.h>
+int main() {
+	int n;
+	scanf("%d", &n);
+	while (n == 0) {}
+	printf("%d\n", 2 * n);
+	return 0;
+}
+`
+
+func inputsArchive(t *testing.T, inputs map[string]string) []byte {
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	for name, body := range inputs {
+		f, err := zw.Create("testcases/inputs/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(body))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestMissingAnswers(t *testing.T) {
+	if !MissingAnswers(inputsArchive(t, map[string]string{"1.txt": "1\n"})) {
+		t.Error("inputs only: answers are not missing")
+	}
+	if MissingAnswers(testDataArchive(t, map[string]string{"1.txt": "1\n"})) {
+		t.Error("full test data: answers are missing")
+	}
+	if MissingAnswers([]byte("not a zip")) {
+		t.Error("invalid archive: answers are missing")
+	}
+}
+
+func TestGenerateAnswers(t *testing.T) {
+	if _, err := exec.LookPath("gcc"); err != nil {
+		t.Skip("gcc is not installed")
+	}
+	dir, err := ioutil.TempDir("", "judge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	solution := writeSource(t, dir, doubler)
+	limits := Limits{Time: time.Second, MemoryMB: 256}
+
+	archive, failed, err := GenerateAnswers(solution, inputsArchive(t, map[string]string{
+		"1.txt": "3\n",
+		"2.txt": "21\n",
+	}), limits)
+	if err != nil || len(failed) > 0 {
+		t.Fatal(err, failed)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{}
+	for _, f := range zr.File {
+		rc, _ := f.Open()
+		b, _ := ioutil.ReadAll(rc)
+		rc.Close()
+		files[f.Name] = string(b)
+	}
+	want := map[string]string{
+		"inputs/1.txt":  "3\n",
+		"inputs/2.txt":  "21\n",
+		"answers/1.txt": "6\n",
+		"answers/2.txt": "42\n",
+	}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("got %v, want %v", files, want)
+	}
+
+	_, failed, err = GenerateAnswers(solution, inputsArchive(t, map[string]string{
+		"1.txt": "3\n",
+		"2.txt": "0\n",
+	}), Limits{Time: 200 * time.Millisecond, MemoryMB: 256})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantFailed := []InvalidInput{{Name: "2.txt", Message: "The main solution got Time Limit Exceeded."}}
+	if !reflect.DeepEqual(failed, wantFailed) {
+		t.Errorf("got %+v, want %+v", failed, wantFailed)
+	}
+}
@@ -0,0 +1,84 @@
+package judge
+
+import (
+	"github.com/cpjudge/cpjudge/models"
+	"github.com/gobuffalo/pop"
+	"github.com/gobuffalo/pop/nulls"
+	"github.com/gobuffalo/uuid"
+	"github.com/pkg/errors"
+)
+
+// Rejudge marks the submissions selected by filter pending again, keeping
+// their previous verdicts in the history, and queues them: for the workers,
+// or for background tasks of the web server, one per contest. Standings are
+// computed from verdicts so they follow automatically; ratings of contests
+// that were already applied are recalculated once the submissions are
+// judged. It returns how many submissions were queued.
+func Rejudge(tx *pop.Connection, filter models.RejudgeFilter, reason string) (int, error) {
+	submissions, err := filter.Submissions(tx)
+	if err != nil {
+		return 0, err
+	}
+	contests := map[uuid.UUID]bool{}
+	for i := range submissions {
+		s := &submissions[i]
+		if err := s.ResetForRejudge(tx, reason); err != nil {
+			return 0, err
+		}
+		if UseWorkers() {
+			if _, err := models.EnqueueJudgeJob(tx, s.ID); err != nil {
+				return 0, err
+			}
+		}
+		contests[s.ContestID] = true
+	}
+	if !UseWorkers() {
+		for cid := range contests {
+			if err := queueContestTask(tx, cid); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return len(submissions), nil
+}
+
+// QueueContest has the pending submissions of a contest judged, such as
+// those of an imported contest: by the workers, or by a background task of
+// the web server.
+func QueueContest(tx *pop.Connection, contestID uuid.UUID) error {
+	if !UseWorkers() {
+		return queueContestTask(tx, contestID)
+	}
+	submissions := models.Submissions{}
+	if err := tx.Where("contest_id = ? and status = ?", contestID, models.StatusPending).All(&submissions); err != nil {
+		return errors.WithStack(err)
+	}
+	for _, s := range submissions {
+		if _, err := models.EnqueueJudgeJob(tx, s.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// queueContestTask queues a task judging the pending submissions of the
+// contest, unless one is queued already: it will judge them all.
+func queueContestTask(tx *pop.Connection, contestID uuid.UUID) error {
+	queued, err := tx.Where("kind = ? AND contest_id = ? AND status = ?", models.TaskSubmissions, contestID, models.JobQueued).Exists(&models.Task{})
+	if err != nil || queued {
+		return errors.WithStack(err)
+	}
+	return models.EnqueueTask(tx, &models.Task{Kind: models.TaskSubmissions, ContestID: nulls.NewUUID(contestID)})
+}
+
+// RecalculateOutdatedRatings recalculates ratings when a rejudge changed
+// verdicts of the contest after its ratings were applied. It reports whether
+// it did.
+func RecalculateOutdatedRatings(tx *pop.Connection, contestID uuid.UUID) (bool, error) {
+	outdated, err := models.RatingsOutdated(tx, contestID)
+	if err != nil || !outdated {
+		return false, err
+	}
+	_, err = models.RecalculateRatings(tx)
+	return err == nil, err
+}
@@ -0,0 +1,129 @@
+package judge
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/cpjudge/cpjudge/models"
+	"github.com/gobuffalo/envy"
+)
+
+// Sandbox is how programs are isolated from the judge. "nsjail", the
+// default, runs each program in nsjail with its own mount, PID, network,
+// IPC and user namespaces, seeing only the system directories and its work
+// directory. "none" runs programs directly, which is only safe on a
+// development machine judging its own code.
+var Sandbox = envy.Get("JUDGE_SANDBOX", "nsjail")
+
+// NSJail is the nsjail binary, and NSJailArgs are options added to every
+// nsjail command, for example cgroup limits.
+var (
+	NSJail     = envy.Get("NSJAIL", "nsjail")
+	NSJailArgs = strings.Fields(envy.Get("NSJAIL_ARGS", ""))
+)
+
+// CompileLimits apply to compilers.
+var CompileLimits = Limits{Time: 30 * time.Second, MemoryMB: 2048}
+
+// systemDirs are made visible, read-only, to sandboxed programs so that
+// compilers and dynamically linked binaries work.
+var systemDirs = []string{"/bin", "/usr", "/lib", "/lib64", "/lib32", "/etc/alternatives", "/etc/ld.so.cache"}
+
+// sandboxed returns the command running argv in dir under the limits. dir
+// is the only writable directory the program sees; readOnly directories
+// are visible too.
+func sandboxed(dir string, readOnly []string, argv []string, limits Limits) *exec.Cmd {
+	var cmd *exec.Cmd
+	if Sandbox == "none" {
+		// The address space is capped with ulimit, so running out of memory
+		// shows up as a runtime error.
+		sh := append([]string{"-c", fmt.Sprintf(`ulimit -v %d && exec "$0" "$@"`, limits.MemoryMB*1024)}, argv...)
+		cmd = exec.Command("sh", sh...)
+	} else {
+		// The wall time limit of nsjail only backs up the one enforced by
+		// runCommand.
+		seconds := int(limits.Time/time.Second) + 2
+		args := []string{
+			"--mode", "o", "--quiet",
+			"--time_limit", strconv.Itoa(seconds),
+			"--rlimit_cpu", strconv.Itoa(seconds),
+			"--rlimit_as", strconv.Itoa(limits.MemoryMB),
+			"--rlimit_fsize", strconv.Itoa(MaxTestFileSize>>20 + 1),
+			"--rlimit_stack", strconv.Itoa(limits.MemoryMB),
+			"--env", "PATH=/usr/bin:/bin",
+			"--tmpfsmount", "/tmp",
+			"--bindmount", "/dev/null",
+		}
+		for _, d := range systemDirs {
+			if _, err := os.Stat(d); err == nil {
+				args = append(args, "--bindmount_ro", d)
+			}
+		}
+		for _, d := range readOnly {
+			if d != dir {
+				args = append(args, "--bindmount_ro", d)
+			}
+		}
+		args = append(args, "--bindmount", dir, "--cwd", dir)
+		args = append(args, NSJailArgs...)
+		args = append(append(args, "--"), argv...)
+		cmd = exec.Command(NSJail, args...)
+	}
+	cmd.Dir = dir
+	// Everything the program starts is in its process group, which is killed
+	// as a whole when it runs out of time.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	return cmd
+}
+
+// runCommand runs cmd with the given input, writing its stdout to w, and
+// kills its process group once it runs longer than the time limit. The
+// status of the result is empty when it exited successfully within the
+// limits. Result.Stdout is left empty.
+func runCommand(cmd *exec.Cmd, input io.Reader, w io.Writer, limits Limits) Result {
+	cmd.Stdin = input
+	stderr := &limitedBuffer{max: MaxOutput}
+	cmd.Stdout = w
+	cmd.Stderr = stderr
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		log.Printf("judge: %v", err)
+		return Result{Status: StatusSystemError}
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+	res := Result{}
+	select {
+	case <-time.After(limits.Time):
+		// nsjail gives the jailed program a parent-death signal, so killing
+		// the group tears down the whole sandbox.
+		if err := syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL); err != nil {
+			log.Printf("judge: failed to kill process group: %v", err)
+			return Result{Status: StatusSystemError}
+		}
+		<-done
+		res.Status = models.StatusTimeLimit
+	case err := <-done:
+		if err != nil {
+			res.Status = models.StatusRuntimeError
+		}
+	}
+	res.Time = time.Since(start)
+	res.Stderr = stderr.String()
+	if usage, ok := cmd.ProcessState.SysUsage().(*syscall.Rusage); ok {
+		// Maxrss is in kilobytes on Linux, and covers the waited-for
+		// descendants of the process.
+		res.MemoryKB = int64(usage.Maxrss)
+	}
+	return res
+}
@@ -0,0 +1,104 @@
+package judge
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cpjudge/cpjudge/models"
+	"github.com/cpjudge/cpjudge/storage"
+	"github.com/pkg/errors"
+)
+
+// ValidatorLimits apply to each run of a validator.
+var ValidatorLimits = Limits{Time: 10 * time.Second, MemoryMB: models.MaxMemoryLimit}
+
+// InvalidInput is an input a validator rejected, with the validator's
+// message.
+type InvalidInput struct {
+	Name    string `json:"name"`
+	Message string `json:"message"`
+}
+
+// CompileError is returned when a program uploaded by a host does not
+// compile. It carries the compiler output.
+type CompileError struct {
+	Output string
+}
+
+func (e *CompileError) Error() string {
+	return "The program does not compile:\n" + e.Output
+}
+
+// ValidateTestData runs the validator at validatorPath on every input of a
+// test data archive. Validators read an input on stdin and exit with a
+// non-zero status, explaining why on stderr, when it is invalid, as testlib
+// validators do. It returns the inputs that were rejected.
+func ValidateTestData(validatorPath string, archive []byte) ([]InvalidInput, error) {
+	dir, err := ioutil.TempDir("", "cpjudge-validate")
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := storage.Unzip(archive, storage.NewLocal(filepath.Join(dir, "testcases")), "", "testcases/"); err != nil {
+		return nil, err
+	}
+	binary := filepath.Join(dir, "validator")
+	if err := compileProgram(validatorPath, binary); err != nil {
+		return nil, err
+	}
+
+	inputs, err := ioutil.ReadDir(filepath.Join(dir, "testcases", "inputs"))
+	if err != nil {
+		return nil, errors.New("The test data has no inputs folder.")
+	}
+	invalid := []InvalidInput{}
+	for _, f := range inputs {
+		input, err := os.Open(filepath.Join(dir, "testcases", "inputs", f.Name()))
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		res := runBinary(dir, binary, input, ValidatorLimits)
+		input.Close()
+		switch res.Status {
+		case "":
+		case StatusSystemError:
+			return nil, errors.Errorf("The validator could not be run on %s.", f.Name())
+		case models.StatusTimeLimit:
+			invalid = append(invalid, InvalidInput{Name: f.Name(), Message: "The validator exceeded the time limit."})
+		default:
+			msg := strings.TrimSpace(res.Stderr)
+			if msg == "" {
+				msg = "Rejected by the validator."
+			}
+			invalid = append(invalid, InvalidInput{Name: f.Name(), Message: msg})
+		}
+	}
+	return invalid, nil
+}
+
+// CheckCompiles reports a *CompileError when the source at path does not
+// compile.
+func CheckCompiles(path string) error {
+	dir, err := ioutil.TempDir("", "cpjudge-compile")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer os.RemoveAll(dir)
+	return compileProgram(path, filepath.Join(dir, "a.out"))
+}
+
+// compileProgram compiles a program uploaded by a host, returning a
+// *CompileError with the compiler output when it fails.
+func compileProgram(src, binary string) error {
+	switch res := compile(src, binary); res.Status {
+	case "":
+		return nil
+	case StatusSystemError:
+		return errors.New("The compiler could not be run.")
+	default:
+		return &CompileError{Output: compilerOutput(res)}
+	}
+}
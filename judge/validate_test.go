@@ -0,0 +1,85 @@
+package judge
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// rangeValidator accepts a single integer from 1 to 10 on its own line.
+const rangeValidator = `#include <stdio.h>
+int main() {
+	int n; char c;
+	if (scanf("%d%c", &n, &c) != 2 || c != '\n' || getchar() != EOF) {
+		fprintf(stderr, "expected one integer per line\n");
+		return 1;
+	}
+	if (n < 1 || n > 10) {
+		fprintf(stderr, "n = %d is out of range\n", n);
+		return 1;
+	}
+	return 0;
+}
+`
+
+func testDataArchive(t *testing.T, inputs map[string]string) []byte {
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	for name, body := range inputs {
+		for _, dir := range []string{"testcases/inputs/", "testcases/answers/"} {
+			f, err := zw.Create(dir + name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f.Write([]byte(body))
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestValidateTestData(t *testing.T) {
+	if _, err := exec.LookPath("gcc"); err != nil {
+		t.Skip("gcc is not installed")
+	}
+	dir, err := ioutil.TempDir("", "judge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	validator := writeSource(t, dir, rangeValidator)
+
+	invalid, err := ValidateTestData(validator, testDataArchive(t, map[string]string{
+		"1.txt": "5\n",
+		"2.txt": "50\n",
+		"3.txt": "7 \n",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []InvalidInput{
+		{Name: "2.txt", Message: "n = 50 is out of range"},
+		{Name: "3.txt", Message: "expected one integer per line"},
+	}
+	if !reflect.DeepEqual(invalid, want) {
+		t.Errorf("got %+v, want %+v", invalid, want)
+	}
+
+	broken := filepath.Join(dir, "broken.c")
+	if err := ioutil.WriteFile(broken, []byte("int main() {"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := CheckCompiles(broken).(*CompileError); !ok {
+		t.Error("broken validator compiled")
+	}
+	if err := CheckCompiles(validator); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,260 @@
+package judge
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cpjudge/cpjudge/storage"
+	"github.com/pkg/errors"
+)
+
+// ErrJobReassigned is returned to a worker reporting on a job that was
+// given to another worker or queued again.
+var ErrJobReassigned = errors.New("judge: job was reassigned")
+
+// Job is a submission or a reference solution handed to a worker.
+type Job struct {
+	ID                  string   `json:"id"`
+	SubmissionID        string   `json:"submission_id,omitempty"`
+	ReferenceSolutionID string   `json:"reference_solution_id,omitempty"`
+	QuestionID          string   `json:"question_id"`
+	SourceName          string   `json:"source_name"`
+	Source              string   `json:"source"`
+	Checksum            string   `json:"checksum"`
+	Samples             []string `json:"samples"`
+	TimeLimit           int      `json:"time_limit"`
+	MemoryLimit         int      `json:"memory_limit"`
+	Checker             string   `json:"checker"`
+}
+
+// WorkerInfo is sent by a worker registering with the server.
+type WorkerInfo struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// JobProgress is sent by a worker before running each test.
+type JobProgress struct {
+	Test  int `json:"test"`
+	Total int `json:"total"`
+}
+
+// JobResult is sent by a worker when a job is done.
+type JobResult struct {
+	Status string `json:"status"`
+}
+
+// Worker judges submissions for a server. It registers, then pulls jobs
+// one at a time, reporting progress and sending heartbeats while it works.
+// Test data is cached in CacheDir by checksum.
+type Worker struct {
+	Server   string
+	Token    string
+	Name     string
+	CacheDir string
+	Client   *http.Client
+	// PollInterval is how long to wait when there is no work.
+	PollInterval time.Duration
+	// HeartbeatInterval should be well below models.WorkerTimeout.
+	HeartbeatInterval time.Duration
+
+	id string
+}
+
+// Run registers the worker and judges jobs until stop is closed.
+func (w *Worker) Run(stop <-chan struct{}) error {
+	if err := w.Register(); err != nil {
+		return err
+	}
+	log.Printf("judge: registered as worker %s", w.id)
+	go w.heartbeat(stop)
+	for {
+		select {
+		case <-stop:
+			return nil
+		default:
+		}
+		worked, err := w.Work()
+		if err != nil {
+			log.Printf("judge: %v", err)
+		}
+		if err != nil || !worked {
+			select {
+			case <-stop:
+				return nil
+			case <-time.After(w.PollInterval):
+			}
+		}
+	}
+}
+
+func (w *Worker) heartbeat(stop <-chan struct{}) {
+	t := time.NewTicker(w.HeartbeatInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+			if _, err := w.post("/judge/workers/heartbeat/"+w.id, nil, nil); err != nil {
+				log.Printf("judge: heartbeat failed: %v", err)
+			}
+		}
+	}
+}
+
+// Register announces the worker to the server.
+func (w *Worker) Register() error {
+	info := WorkerInfo{}
+	if _, err := w.post("/judge/workers/register", WorkerInfo{Name: w.Name}, &info); err != nil {
+		return err
+	}
+	w.id = info.ID
+	return nil
+}
+
+// Work pulls a job and judges it. It reports whether there was a job.
+func (w *Worker) Work() (bool, error) {
+	job := Job{}
+	code, err := w.post("/judge/workers/pull/"+w.id, nil, &job)
+	if err != nil || code == http.StatusNoContent {
+		return false, err
+	}
+	status := w.evaluate(job)
+	_, err = w.post(fmt.Sprintf("/judge/jobs/finish/%s/%s", w.id, job.ID), JobResult{Status: status}, nil)
+	return true, err
+}
+
+func (w *Worker) evaluate(job Job) string {
+	testCases, err := w.testData(job.QuestionID, job.Checksum)
+	if err != nil {
+		log.Printf("judge: test data of question %s: %v", job.QuestionID, err)
+		return StatusSystemError
+	}
+	dir, err := ioutil.TempDir("", "cpjudge-job")
+	if err != nil {
+		log.Printf("judge: %v", err)
+		return StatusSystemError
+	}
+	defer os.RemoveAll(dir)
+	source := filepath.Join(dir, path.Base("/"+job.SourceName))
+	if err := ioutil.WriteFile(source, []byte(job.Source), 0644); err != nil {
+		log.Printf("judge: %v", err)
+		return StatusSystemError
+	}
+	limits := Limits{Time: time.Duration(job.TimeLimit) * time.Millisecond, MemoryMB: job.MemoryLimit}
+	return RunWithProgress(source, testCases, job.Samples, limits, job.Checker, func(test, total int) {
+		_, err := w.post(fmt.Sprintf("/judge/jobs/progress/%s/%s", w.id, job.ID), JobProgress{Test: test, Total: total}, nil)
+		if err != nil {
+			log.Printf("judge: progress of job %s: %v", job.ID, err)
+		}
+	})
+}
+
+// testData returns the directory holding the test cases with the given
+// checksum, downloading them into the cache first if needed.
+func (w *Worker) testData(questionID, checksum string) (string, error) {
+	if b, err := hex.DecodeString(checksum); err != nil || len(b) != 32 {
+		return "", errors.Errorf("invalid checksum %q", checksum)
+	}
+	dir := filepath.Join(w.CacheDir, checksum)
+	if _, err := os.Stat(dir); err == nil {
+		return dir, nil
+	}
+
+	req, err := w.request("GET", "/judge/testdata/"+questionID+"/"+checksum, nil)
+	if err != nil {
+		return "", err
+	}
+	res, err := w.client().Do(req)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", errors.Errorf("test data: %s", res.Status)
+	}
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	if err := os.MkdirAll(w.CacheDir, 0755); err != nil {
+		return "", errors.WithStack(err)
+	}
+	tmp, err := ioutil.TempDir(w.CacheDir, ".download")
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	defer os.RemoveAll(tmp)
+	if err := storage.Unzip(b, storage.NewLocal(tmp), "", ""); err != nil {
+		return "", err
+	}
+	if sum, err := storage.Checksum(storage.NewLocal(tmp), ""); err != nil || sum != checksum {
+		return "", errors.Errorf("test data checksum mismatch: got %s, want %s (%v)", sum, checksum, err)
+	}
+	// Another worker sharing the cache may have stored it meanwhile.
+	if err := os.Rename(tmp, dir); err != nil {
+		if _, statErr := os.Stat(dir); statErr != nil {
+			return "", errors.WithStack(err)
+		}
+	}
+	return dir, nil
+}
+
+func (w *Worker) client() *http.Client {
+	if w.Client != nil {
+		return w.Client
+	}
+	return http.DefaultClient
+}
+
+func (w *Worker) request(method, p string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequest(method, strings.TrimRight(w.Server, "/")+p, body)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+w.Token)
+	return req, nil
+}
+
+// post sends in as JSON and decodes a 200 response into out. It returns the
+// status code.
+func (w *Worker) post(p string, in, out interface{}) (int, error) {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	req, err := w.request("POST", p, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	res, err := w.client().Do(req)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	defer res.Body.Close()
+	switch {
+	case res.StatusCode == http.StatusConflict:
+		return res.StatusCode, ErrJobReassigned
+	case res.StatusCode == http.StatusOK && out != nil:
+		return res.StatusCode, errors.WithStack(json.NewDecoder(res.Body).Decode(out))
+	case res.StatusCode/100 != 2:
+		msg, _ := ioutil.ReadAll(io.LimitReader(res.Body, 1024))
+		return res.StatusCode, errors.Errorf("%s: %s: %s", p, res.Status, msg)
+	}
+	return res.StatusCode, nil
+}
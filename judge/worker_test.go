@@ -0,0 +1,132 @@
+package judge
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cpjudge/cpjudge/models"
+	"github.com/cpjudge/cpjudge/storage"
+)
+
+// fakeServer hands out one job and records what the worker reports.
+type fakeServer struct {
+	sync.Mutex
+	job       *Job
+	checksum  string
+	testdata  []byte
+	downloads int
+	progress  []JobProgress
+	status    string
+}
+
+func (f *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+	if r.Header.Get("Authorization") != "Bearer secret" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	switch {
+	case r.URL.Path == "/judge/workers/register":
+		json.NewEncoder(w).Encode(WorkerInfo{ID: "w1"})
+	case r.URL.Path == "/judge/workers/pull/w1":
+		if f.job == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		json.NewEncoder(w).Encode(f.job)
+		f.job = nil
+	case r.URL.Path == "/judge/testdata/q1/"+f.checksum:
+		f.downloads++
+		w.Write(f.testdata)
+	case r.URL.Path == "/judge/jobs/progress/w1/j1":
+		p := JobProgress{}
+		json.NewDecoder(r.Body).Decode(&p)
+		f.progress = append(f.progress, p)
+	case r.URL.Path == "/judge/jobs/finish/w1/j1":
+		res := JobResult{}
+		json.NewDecoder(r.Body).Decode(&res)
+		f.status = res.Status
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestWorker(t *testing.T) {
+	dir, err := ioutil.TempDir("", "worker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	tc := writeProblem(t, dir)
+	checksum, err := storage.Checksum(storage.NewLocal(tc), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive := filepath.Join(dir, "testdata.zip")
+	f, err := os.Create(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for _, name := range []string{"inputs/1.txt", "answers/1.txt"} {
+		b, _ := ioutil.ReadFile(filepath.Join(tc, name))
+		zf, _ := zw.Create(name)
+		zf.Write(b)
+	}
+	zw.Close()
+	f.Close()
+	testdata, _ := ioutil.ReadFile(archive)
+
+	job := Job{
+		ID:          "j1",
+		QuestionID:  "q1",
+		SourceName:  "submission_1.c",
+		Source:      "#include <stdio.h>\nint main(){int a,b;scanf(\"%d %d\",&a,&b);printf(\"%d\\n\",a+b);return 0;}\n",
+		Checksum:    checksum,
+		TimeLimit:   2000,
+		MemoryLimit: 256,
+	}
+	fake := &fakeServer{job: &job, checksum: checksum, testdata: testdata}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	w := &Worker{Server: server.URL, Token: "secret", Name: "test", CacheDir: filepath.Join(dir, "cache"), PollInterval: time.Millisecond}
+	if err := w.Register(); err != nil {
+		t.Fatal(err)
+	}
+	if worked, err := w.Work(); err != nil || !worked {
+		t.Fatalf("Work() = %v, %v", worked, err)
+	}
+	if fake.status != models.StatusCorrect {
+		t.Fatalf("status = %q, want %q", fake.status, models.StatusCorrect)
+	}
+	if len(fake.progress) != 1 || fake.progress[0] != (JobProgress{Test: 1, Total: 1}) {
+		t.Fatalf("progress = %v", fake.progress)
+	}
+	if _, err := os.Stat(filepath.Join(w.CacheDir, checksum, "inputs", "1.txt")); err != nil {
+		t.Fatalf("test data not cached: %v", err)
+	}
+
+	// The second job reuses the cached test data.
+	job.Source = strings.Replace(job.Source, "a+b", "a-b", 1)
+	fake.job = &job
+	if _, err := w.Work(); err != nil {
+		t.Fatal(err)
+	}
+	if fake.status != models.StatusWrong || fake.downloads != 1 {
+		t.Fatalf("status = %q after %d downloads", fake.status, fake.downloads)
+	}
+	if worked, err := w.Work(); err != nil || worked {
+		t.Fatalf("Work() with no jobs = %v, %v", worked, err)
+	}
+}
@@ -0,0 +1,41 @@
+package mailers
+
+import (
+	"github.com/gobuffalo/buffalo/mail"
+	"github.com/gobuffalo/buffalo/render"
+	"github.com/pkg/errors"
+)
+
+// SendVerificationEmail mails a link that confirms the owner of email.
+// path is the verification route for the account type, e.g. "/users/verify".
+func SendVerificationEmail(name, email, path, token string) error {
+	m := mail.NewMessage()
+	m.Subject = "Verify your CP Judge email address"
+	m.From = From
+	m.To = []string{email}
+	err := m.AddBody(r.HTML("verify_email.html"), render.Data{
+		"name": name,
+		"link": AppURL + path + "/" + token,
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return Sender().Send(m)
+}
+
+// SendPasswordResetEmail mails a time-limited link to reset a password.
+// path is the reset route for the account type, e.g. "/users/reset_password".
+func SendPasswordResetEmail(name, email, path, token string) error {
+	m := mail.NewMessage()
+	m.Subject = "Reset your CP Judge password"
+	m.From = From
+	m.To = []string{email}
+	err := m.AddBody(r.HTML("password_reset.html"), render.Data{
+		"name": name,
+		"link": AppURL + path + "/" + token,
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return Sender().Send(m)
+}
@@ -0,0 +1,58 @@
+package mailers
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gobuffalo/buffalo/mail"
+	"github.com/pkg/errors"
+)
+
+// FileSender is a mail.Sender used in development and tests. Every message
+// is logged, kept in memory and written to a file in Dir.
+type FileSender struct {
+	Dir string
+
+	mu       sync.Mutex
+	messages []mail.Message
+}
+
+// NewFileSender returns a FileSender writing to dir, creating it if needed.
+func NewFileSender(dir string) (*FileSender, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &FileSender{Dir: dir}, nil
+}
+
+// Send writes the message to disk instead of delivering it.
+func (s *FileSender) Send(m mail.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = append(s.messages, m)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\n", m.From)
+	fmt.Fprintf(&b, "To: %s\n", strings.Join(m.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\n", m.Subject)
+	for _, body := range m.Bodies {
+		fmt.Fprintf(&b, "\n--- %s ---\n%s\n", body.ContentType, body.Content)
+	}
+
+	name := fmt.Sprintf("%d_%s.eml", time.Now().UnixNano(), strings.Join(m.To, "_"))
+	log.Printf("mailers: %q to %v written to %s", m.Subject, m.To, filepath.Join(s.Dir, name))
+	return errors.WithStack(ioutil.WriteFile(filepath.Join(s.Dir, name), []byte(b.String()), 0644))
+}
+
+// Messages returns every message sent so far.
+func (s *FileSender) Messages() []mail.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]mail.Message{}, s.messages...)
+}
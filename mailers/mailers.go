@@ -0,0 +1,101 @@
+package mailers
+
+import (
+	"log"
+	"path/filepath"
+	"sync"
+
+	"github.com/gobuffalo/buffalo/mail"
+	"github.com/gobuffalo/buffalo/render"
+	"github.com/gobuffalo/envy"
+	"github.com/gobuffalo/packr"
+	"github.com/pkg/errors"
+)
+
+var (
+	mu   sync.Mutex
+	smtp mail.Sender
+)
+var r *render.Engine
+
+// From is the address outgoing mail is sent from.
+var From = envy.Get("MAIL_FROM", "no-reply@cpjudge.local")
+
+// AppURL is the externally visible base URL used to build links in emails.
+var AppURL = envy.Get("APP_URL", "http://127.0.0.1:3000")
+
+func init() {
+	r = render.New(render.Options{
+		HTMLLayout:   "layout.html",
+		TemplatesBox: packr.NewBox("../templates/mail"),
+		Helpers:      render.Helpers{},
+	})
+}
+
+// Init sets up the sender from the environment, and logs where messages
+// are written when they are not delivered. Servers call it when they
+// start, so that a relative MAIL_DIR is resolved once, against the
+// directory they were started in.
+func Init() error {
+	s, err := fromEnv()
+	if err != nil {
+		return err
+	}
+	if f, ok := s.(*FileSender); ok {
+		log.Printf("mailers: writing messages to %s", f.Dir)
+	}
+	SetSender(s)
+	return nil
+}
+
+func fromEnv() (mail.Sender, error) {
+	env := envy.Get("GO_ENV", "development")
+	// MAILER selects how mail is delivered. Production defaults to SMTP,
+	// everything else writes messages to MAIL_DIR so nothing leaves the box.
+	fallback := "file"
+	if env == "production" {
+		fallback = "smtp"
+	}
+	if envy.Get("MAILER", fallback) == "smtp" {
+		port := envy.Get("SMTP_PORT", "1025")
+		host := envy.Get("SMTP_HOST", "localhost")
+		user := envy.Get("SMTP_USER", "")
+		password := envy.Get("SMTP_PASSWORD", "")
+		s, err := mail.NewSMTPSender(host, port, user, password)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return s, nil
+	}
+	dir, err := filepath.Abs(envy.Get("MAIL_DIR", "../mail"))
+	if err != nil {
+		return nil, errors.Wrap(err, "mailers: resolving MAIL_DIR")
+	}
+	s, err := NewFileSender(dir)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Sender returns the sender used to deliver mail, set up from the
+// environment when Init was not called.
+func Sender() mail.Sender {
+	mu.Lock()
+	defer mu.Unlock()
+	if smtp == nil {
+		s, err := fromEnv()
+		if err != nil {
+			log.Fatal(err)
+		}
+		smtp = s
+	}
+	return smtp
+}
+
+// SetSender replaces the sender used to deliver mail, e.g. in tests.
+func SetSender(s mail.Sender) {
+	mu.Lock()
+	defer mu.Unlock()
+	smtp = s
+}
@@ -0,0 +1,79 @@
+package mailers
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gobuffalo/envy"
+)
+
+func Test_SendPasswordResetEmail(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	sender, err := NewFileSender(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := Sender()
+	SetSender(sender)
+	defer SetSender(old)
+
+	if err := SendPasswordResetEmail("ada", "ada@example.com", "/users/reset_password", "abc123"); err != nil {
+		t.Fatal(err)
+	}
+
+	messages := sender.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(messages))
+	}
+	if !strings.Contains(messages[0].Bodies[0].Content, AppURL+"/users/reset_password/abc123") {
+		t.Fatalf("reset link missing from body: %s", messages[0].Bodies[0].Content)
+	}
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 1 {
+		t.Fatalf("expected 1 file in %s, got %d", dir, len(files))
+	}
+}
+
+func TestInit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	old := Sender()
+	defer SetSender(old)
+	oldMailer, oldDir := envy.Get("MAILER", "file"), envy.Get("MAIL_DIR", "../mail")
+	defer envy.Set("MAILER", oldMailer)
+	defer envy.Set("MAIL_DIR", oldDir)
+	envy.Set("MAILER", "file")
+	envy.Set("MAIL_DIR", "outbox")
+
+	if err := Init(); err != nil {
+		t.Fatal(err)
+	}
+	// The directory no longer depends on where later code runs.
+	want, err := filepath.Abs("outbox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := Sender().(*FileSender).Dir; got != want {
+		t.Errorf("Dir = %q, want %q", got, want)
+	}
+}
@@ -4,6 +4,10 @@ import (
 	"log"
 
 	"github.com/cpjudge/cpjudge/actions"
+	"github.com/cpjudge/cpjudge/mailers"
+	"github.com/cpjudge/cpjudge/models"
+	"github.com/cpjudge/cpjudge/storage"
+	"github.com/cpjudge/cpjudge/tasks"
 )
 
 // main is the starting point to your Buffalo application.
@@ -13,7 +17,15 @@ import (
 // call `app.Serve()`, unless you don't want to start your
 // application that is. :)
 func main() {
+	if err := storage.Init(); err != nil {
+		log.Fatal(err)
+	}
+	if err := mailers.Init(); err != nil {
+		log.Fatal(err)
+	}
 	app := actions.App()
+	// Work such as checking uploaded test data runs in the background.
+	go tasks.Run(models.DB, nil)
 	if err := app.Serve(); err != nil {
 		log.Fatal(err)
 	}
@@ -0,0 +1,654 @@
+package models
+
+import (
+	"archive/zip"
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/cpjudge/cpjudge/storage"
+	"github.com/gobuffalo/pop"
+	"github.com/gobuffalo/pop/nulls"
+	"github.com/gobuffalo/uuid"
+	"github.com/pkg/errors"
+)
+
+// A contest archive is a zip holding contest.json, the test data of every
+// question under testdata/<checksum>/, validators under validators/,
+// reference solutions under solutions/, generators under generators/,
+// statement attachments under attachments/ and, when submissions are
+// exported, their sources under submissions/.
+// ArchiveVersion is raised whenever the format changes; older archives can
+// still be imported.
+const (
+	ArchiveFormat  = "cpjudge-contest"
+	ArchiveVersion = 6
+)
+
+var (
+	ErrNotArchive     = errors.New("The file is not a contest archive.")
+	ErrArchiveVersion = errors.New("The archive was made by a newer version of CP-Judge.")
+)
+
+// ArchiveError is a mistake in an imported archive, such as a missing file
+// or test data that does not match its checksum. Its message can be shown
+// to the host.
+type ArchiveError struct {
+	Message string
+}
+
+func (e *ArchiveError) Error() string {
+	return e.Message
+}
+
+func archiveErrorf(format string, args ...interface{}) error {
+	return &ArchiveError{Message: fmt.Sprintf(format, args...)}
+}
+
+// ExportOptions choose what is exported besides the contest and its
+// questions.
+type ExportOptions struct {
+	Submissions bool
+	Standings   bool
+}
+
+// contestArchive is the contest.json of an archive. IDs are those of the
+// exporting instance; they only link records within the archive.
+type contestArchive struct {
+	Format      string               `json:"format"`
+	Version     int                  `json:"version"`
+	ExportedAt  time.Time            `json:"exported_at"`
+	Contest     archivedContest      `json:"contest"`
+	Questions   []archivedQuestion   `json:"questions"`
+	Submissions []archivedSubmission `json:"submissions,omitempty"`
+	// Standings are a snapshot for the record. Importing recomputes them
+	// from the submissions.
+	Standings Standings `json:"standings,omitempty"`
+}
+
+type archivedContest struct {
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	StartTime   nulls.Time `json:"start_time"`
+	EndTime     nulls.Time `json:"end_time"`
+	Rated       bool       `json:"rated"`
+	// PublicSolutions is since version 6.
+	PublicSolutions bool `json:"public_solutions,omitempty"`
+}
+
+type archivedQuestion struct {
+	ID          uuid.UUID `json:"id"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	Difficulty  int       `json:"difficulty"`
+	Tags        string    `json:"tags"`
+	TimeLimit   int       `json:"time_limit"`
+	MemoryLimit int       `json:"memory_limit"`
+	Samples     string    `json:"samples"`
+	Checker     string    `json:"checker"`
+	// TestData is the checksum of the question's test data, or empty when
+	// it has none.
+	TestData string `json:"test_data"`
+	// Validator is the path of the validator source in the archive, since
+	// version 2.
+	Validator string `json:"validator,omitempty"`
+	// Solutions are the reference solutions, since version 3.
+	Solutions []archivedSolution `json:"solutions,omitempty"`
+	// Generators and GeneratorScript are since version 4.
+	Generators      []archivedGenerator `json:"generators,omitempty"`
+	GeneratorScript string              `json:"generator_script,omitempty"`
+	// The statement sections and Attachments are since version 5.
+	InputFormat  string               `json:"input_format,omitempty"`
+	OutputFormat string               `json:"output_format,omitempty"`
+	Notes        string               `json:"notes,omitempty"`
+	Attachments  []archivedAttachment `json:"attachments,omitempty"`
+	// Editorial is since version 6. Its comments are not exported.
+	Editorial *archivedEditorial `json:"editorial,omitempty"`
+}
+
+type archivedEditorial struct {
+	Content   string     `json:"content"`
+	PublishAt nulls.Time `json:"publish_at"`
+}
+
+type archivedAttachment struct {
+	Filename string `json:"filename"`
+	// File is the path of the file in the archive.
+	File string `json:"file"`
+}
+
+type archivedGenerator struct {
+	Filename string `json:"filename"`
+	// Source is the path of the source in the archive.
+	Source string `json:"source"`
+}
+
+type archivedSolution struct {
+	Filename string `json:"filename"`
+	Expected string `json:"expected"`
+	Main     bool   `json:"main"`
+	// Source is the path of the source in the archive.
+	Source string `json:"source"`
+}
+
+type archivedSubmission struct {
+	ID         uuid.UUID `json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	QuestionID uuid.UUID `json:"question_id"`
+	Username   string    `json:"username"`
+	Language   string    `json:"language"`
+	Status     string    `json:"status"`
+	Practice   bool      `json:"practice"`
+	Elapsed    int       `json:"elapsed"`
+	// Source is the path of the source in the archive.
+	Source string `json:"source"`
+}
+
+// ExportContest writes a contest to an archive. Submissions made during
+// virtual participations are not exported.
+func ExportContest(tx *pop.Connection, contest *Contest, opts ExportOptions) ([]byte, error) {
+	a := contestArchive{
+		Format:     ArchiveFormat,
+		Version:    ArchiveVersion,
+		ExportedAt: time.Now().UTC(),
+		Contest: archivedContest{
+			Title:           contest.Title,
+			Description:     contest.Description,
+			StartTime:       contest.StartTime,
+			EndTime:         contest.EndTime,
+			Rated:           contest.Rated,
+			PublicSolutions: contest.PublicSolutions,
+		},
+	}
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	store := storage.Default()
+
+	questions := Questions{}
+	if err := tx.Where("contest_id = ?", contest.ID).Order("created_at asc").All(&questions); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	written := map[string]bool{}
+	for i := range questions {
+		q := &questions[i]
+		if q.TestCasesPath != "" && q.TestCasesSum == "" {
+			if err := q.UpdateChecksum(tx); err != nil {
+				return nil, err
+			}
+		}
+		if q.TestCasesSum != "" && !written[q.TestCasesSum] {
+			written[q.TestCasesSum] = true
+			keys, err := store.List(q.TestCasesPath + "/")
+			if err != nil {
+				return nil, err
+			}
+			for _, key := range keys {
+				name := "testdata/" + q.TestCasesSum + "/" + strings.TrimPrefix(key, q.TestCasesPath+"/")
+				if err := archiveFile(zw, store, key, name); err != nil {
+					return nil, err
+				}
+			}
+		}
+		validator := ""
+		if q.HasValidator() {
+			validator = "validators/" + path.Base(q.ValidatorPath)
+			if err := archiveFile(zw, store, q.ValidatorPath, validator); err != nil {
+				return nil, err
+			}
+		}
+		solutions, err := QuestionReferenceSolutions(tx, q.ID)
+		if err != nil {
+			return nil, err
+		}
+		archivedSolutions := []archivedSolution{}
+		for _, rs := range solutions {
+			name := "solutions/" + path.Base(rs.SourcePath)
+			if err := archiveFile(zw, store, rs.SourcePath, name); err != nil {
+				return nil, err
+			}
+			archivedSolutions = append(archivedSolutions, archivedSolution{Filename: rs.Filename, Expected: rs.Expected, Main: rs.Main, Source: name})
+		}
+		generators, err := QuestionGenerators(tx, q.ID)
+		if err != nil {
+			return nil, err
+		}
+		archivedGenerators := []archivedGenerator{}
+		for _, g := range generators {
+			name := "generators/" + path.Base(g.SourcePath)
+			if err := archiveFile(zw, store, g.SourcePath, name); err != nil {
+				return nil, err
+			}
+			archivedGenerators = append(archivedGenerators, archivedGenerator{Filename: g.Filename, Source: name})
+		}
+		attachments, err := QuestionAttachments(tx, q.ID)
+		if err != nil {
+			return nil, err
+		}
+		archivedAttachments := []archivedAttachment{}
+		for _, at := range attachments {
+			name := "attachments/" + path.Base(at.StoragePath)
+			if err := archiveFile(zw, store, at.StoragePath, name); err != nil {
+				return nil, err
+			}
+			archivedAttachments = append(archivedAttachments, archivedAttachment{Filename: at.Filename, File: name})
+		}
+		var editorial *archivedEditorial
+		e, err := QuestionEditorial(tx, q.ID)
+		if err != nil {
+			return nil, err
+		}
+		if e != nil {
+			editorial = &archivedEditorial{Content: e.Content, PublishAt: e.PublishAt}
+		}
+		a.Questions = append(a.Questions, archivedQuestion{
+			ID:              q.ID,
+			Title:           q.Title,
+			Description:     q.Description,
+			Difficulty:      q.Difficulty,
+			Tags:            q.Tags,
+			TimeLimit:       q.TimeLimit,
+			MemoryLimit:     q.MemoryLimit,
+			Samples:         q.Samples,
+			Checker:         q.Checker,
+			TestData:        q.TestCasesSum,
+			Validator:       validator,
+			Solutions:       archivedSolutions,
+			Generators:      archivedGenerators,
+			GeneratorScript: q.GeneratorScript,
+			InputFormat:     q.InputFormat,
+			OutputFormat:    q.OutputFormat,
+			Notes:           q.Notes,
+			Attachments:     archivedAttachments,
+			Editorial:       editorial,
+		})
+	}
+
+	if opts.Submissions {
+		submissions := Submissions{}
+		err := tx.Where("contest_id = ? and virtual_participation_id is null", contest.ID).Order("created_at asc").All(&submissions)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		usernames := map[uuid.UUID]string{}
+		for _, s := range submissions {
+			if s.SubmissionPath == "" {
+				continue
+			}
+			if _, ok := usernames[s.UserID]; !ok {
+				user := &User{}
+				if err := tx.Find(user, s.UserID); err != nil {
+					return nil, errors.WithStack(err)
+				}
+				usernames[s.UserID] = user.Username
+			}
+			name := "submissions/" + path.Base(s.SubmissionPath)
+			if err := archiveFile(zw, store, s.SubmissionPath, name); err != nil {
+				return nil, err
+			}
+			a.Submissions = append(a.Submissions, archivedSubmission{
+				ID:         s.ID,
+				CreatedAt:  s.CreatedAt,
+				QuestionID: s.QuestionID,
+				Username:   usernames[s.UserID],
+				Language:   s.Language,
+				Status:     s.Status,
+				Practice:   s.Practice,
+				Elapsed:    s.Elapsed,
+				Source:     name,
+			})
+		}
+	}
+	if opts.Standings {
+		standings, err := ContestStandings(tx, contest.ID)
+		if err != nil {
+			return nil, err
+		}
+		a.Standings = standings
+	}
+
+	f, err := zw.Create("contest.json")
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(a); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return buf.Bytes(), nil
+}
+
+// archiveFile copies a stored file into the archive.
+func archiveFile(zw *zip.Writer, store storage.Storage, key, name string) error {
+	b, err := storage.ReadAll(store, key)
+	if err != nil {
+		return err
+	}
+	f, err := zw.Create(name)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	_, err = f.Write(b)
+	return errors.WithStack(err)
+}
+
+// ContestImport summarises an imported contest.
+type ContestImport struct {
+	Contest     *Contest
+	Questions   int
+	Submissions int
+	// MissingUsers are the submitters without an account of the same
+	// username here. Their submissions were skipped.
+	MissingUsers []string
+}
+
+// ImportContest recreates the contest in the archive file name under a
+// host. The archive is read from the file as needed rather than loaded
+// whole. Submissions are attributed to the users with the same usernames
+// and are pending: their verdicts are not imported, so the caller queues
+// them to be judged.
+func ImportContest(tx *pop.Connection, name string, hostID uuid.UUID) (*ContestImport, error) {
+	zr, err := zip.OpenReader(name)
+	if _, ok := err.(*os.PathError); ok {
+		return nil, errors.WithStack(err)
+	}
+	if err != nil {
+		return nil, ErrNotArchive
+	}
+	defer zr.Close()
+	return importContest(tx, &zr.Reader, hostID)
+}
+
+func importContest(tx *pop.Connection, zr *zip.Reader, hostID uuid.UUID) (*ContestImport, error) {
+	files := map[string]*zip.File{}
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+	f, ok := files["contest.json"]
+	if !ok {
+		return nil, ErrNotArchive
+	}
+	b, err := readArchiveFile(f)
+	if err != nil {
+		return nil, err
+	}
+	a := contestArchive{}
+	if err := json.Unmarshal(b, &a); err != nil || a.Format != ArchiveFormat {
+		return nil, ErrNotArchive
+	}
+	if a.Version > ArchiveVersion {
+		return nil, ErrArchiveVersion
+	}
+
+	// Everything is checked before anything is stored: files stored before
+	// a later error would be left behind when the transaction rolls back.
+	testData, err := checkArchive(zr, files, a)
+	if err != nil {
+		return nil, err
+	}
+
+	// Imported contests are never rated: their standings were made
+	// elsewhere. Hosts can rate them once the submissions are judged here.
+	contest := &Contest{
+		Title:           a.Contest.Title,
+		Description:     a.Contest.Description,
+		HostID:          hostID,
+		StartTime:       a.Contest.StartTime,
+		EndTime:         a.Contest.EndTime,
+		PublicSolutions: a.Contest.PublicSolutions,
+	}
+	if err := createArchived(tx, contest); err != nil {
+		return nil, err
+	}
+	res := &ContestImport{Contest: contest}
+
+	questionIDs := map[uuid.UUID]uuid.UUID{}
+	for _, aq := range a.Questions {
+		q := &Question{
+			Title:           aq.Title,
+			Description:     aq.Description,
+			InputFormat:     aq.InputFormat,
+			OutputFormat:    aq.OutputFormat,
+			Notes:           aq.Notes,
+			ContestID:       contest.ID,
+			Difficulty:      aq.Difficulty,
+			Tags:            aq.Tags,
+			TimeLimit:       aq.TimeLimit,
+			MemoryLimit:     aq.MemoryLimit,
+			Samples:         aq.Samples,
+			Checker:         aq.Checker,
+			GeneratorScript: aq.GeneratorScript,
+		}
+		if err := createArchived(tx, q); err != nil {
+			return nil, err
+		}
+		questionIDs[aq.ID] = q.ID
+		res.Questions++
+		if aq.Validator != "" {
+			source, err := readArchiveFile(files[aq.Validator])
+			if err != nil {
+				return nil, err
+			}
+			if err := q.StoreValidator(tx, aq.Validator, source); err != nil {
+				return nil, err
+			}
+		}
+		for _, as := range aq.Solutions {
+			source, err := readArchiveFile(files[as.Source])
+			if err != nil {
+				return nil, err
+			}
+			rs := &ReferenceSolution{QuestionID: q.ID, Filename: as.Filename, Expected: as.Expected, Main: as.Main}
+			verrs, err := AddReferenceSolution(tx, rs, source)
+			if err != nil {
+				return nil, err
+			}
+			if verrs.HasAny() {
+				return nil, archiveErrorf("The archive is not valid: %s", verrs.Error())
+			}
+		}
+		for _, ag := range aq.Generators {
+			source, err := readArchiveFile(files[ag.Source])
+			if err != nil {
+				return nil, err
+			}
+			_, verrs, err := AddGenerator(tx, q.ID, ag.Filename, source)
+			if err != nil {
+				return nil, err
+			}
+			if verrs.HasAny() {
+				return nil, archiveErrorf("The archive is not valid: %s", verrs.Error())
+			}
+		}
+		for _, at := range aq.Attachments {
+			data, err := readArchiveFile(files[at.File])
+			if err != nil {
+				return nil, err
+			}
+			_, verrs, err := AddAttachment(tx, q.ID, at.Filename, data)
+			if err != nil {
+				return nil, err
+			}
+			if verrs.HasAny() {
+				return nil, archiveErrorf("The archive is not valid: %s", verrs.Error())
+			}
+		}
+		if aq.Editorial != nil {
+			_, verrs, err := SaveEditorial(tx, q.ID, aq.Editorial.Content, aq.Editorial.PublishAt)
+			if err != nil {
+				return nil, err
+			}
+			if verrs.HasAny() {
+				return nil, archiveErrorf("The archive is not valid: %s", verrs.Error())
+			}
+		}
+		if aq.TestData == "" {
+			continue
+		}
+		if _, err := q.StoreTestData(tx, testData[aq.TestData]); err != nil {
+			return nil, err
+		}
+	}
+
+	users := map[string]*User{}
+	for _, as := range a.Submissions {
+		user, ok := users[as.Username]
+		if !ok {
+			user = &User{}
+			if err := tx.Where("username = ?", as.Username).First(user); err != nil {
+				if errors.Cause(err) != sql.ErrNoRows {
+					return nil, errors.WithStack(err)
+				}
+				user = nil
+				res.MissingUsers = append(res.MissingUsers, as.Username)
+			}
+			users[as.Username] = user
+		}
+		questionID, ok := questionIDs[as.QuestionID]
+		if user == nil || !ok {
+			continue
+		}
+		source, err := readArchiveFile(files[as.Source])
+		if err != nil {
+			return nil, err
+		}
+		s := &Submission{
+			ID:         uuid.Must(uuid.NewV4()),
+			CreatedAt:  as.CreatedAt,
+			UserID:     user.ID,
+			QuestionID: questionID,
+			ContestID:  contest.ID,
+			// Verdicts in an archive cannot be trusted, so submissions are
+			// judged again.
+			Status:     StatusPending,
+			Language:   as.Language,
+			Practice:   as.Practice,
+			Elapsed:    as.Elapsed,
+			SourceCode: string(source),
+		}
+		s.SubmissionPath = s.StorageKey()
+		if err := tx.Create(s); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		res.Submissions++
+	}
+	return res, nil
+}
+
+// checkArchive checks that the files an archive refers to are in it, and
+// that its test data matches the checksums it is stored under. It returns
+// the test data of the archive as zips of their own, by checksum.
+func checkArchive(zr *zip.Reader, files map[string]*zip.File, a contestArchive) (map[string][]byte, error) {
+	testData := map[string][]byte{}
+	for _, aq := range a.Questions {
+		if aq.Validator != "" && files[aq.Validator] == nil {
+			return nil, archiveErrorf("The validator of %q is missing.", aq.Title)
+		}
+		for _, as := range aq.Solutions {
+			if files[as.Source] == nil {
+				return nil, archiveErrorf("The solution %s of %q is missing.", as.Filename, aq.Title)
+			}
+		}
+		for _, ag := range aq.Generators {
+			if files[ag.Source] == nil {
+				return nil, archiveErrorf("The generator %s of %q is missing.", ag.Filename, aq.Title)
+			}
+		}
+		for _, at := range aq.Attachments {
+			if files[at.File] == nil {
+				return nil, archiveErrorf("The attachment %s of %q is missing.", at.Filename, aq.Title)
+			}
+		}
+		if aq.TestData == "" || testData[aq.TestData] != nil {
+			continue
+		}
+		data, err := subArchive(zr, "testdata/"+aq.TestData+"/")
+		if err != nil {
+			return nil, err
+		}
+		sum, err := TestDataChecksum(data)
+		if err != nil {
+			return nil, err
+		}
+		if sum != aq.TestData {
+			return nil, archiveErrorf("The test data of %q does not match its checksum.", aq.Title)
+		}
+		testData[aq.TestData] = data
+	}
+	for _, as := range a.Submissions {
+		if files[as.Source] == nil {
+			return nil, archiveErrorf("The source of submission %s is missing.", as.ID)
+		}
+	}
+	return testData, nil
+}
+
+// createArchived validates and creates an imported record.
+func createArchived(tx *pop.Connection, model interface{}) error {
+	verrs, err := tx.ValidateAndCreate(model)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if verrs.HasAny() {
+		return archiveErrorf("The archive is not valid: %s", verrs.Error())
+	}
+	return nil
+}
+
+func readArchiveFile(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, archiveReadError(f, err)
+	}
+	defer rc.Close()
+	b, err := ioutil.ReadAll(rc)
+	if err != nil {
+		return nil, archiveReadError(f, err)
+	}
+	return b, nil
+}
+
+// archiveReadError makes the errors of a damaged file in an archive an
+// ArchiveError.
+func archiveReadError(f *zip.File, err error) error {
+	switch err {
+	case zip.ErrFormat, zip.ErrAlgorithm, zip.ErrChecksum:
+		return archiveErrorf("The file %s of the archive is damaged.", f.Name)
+	}
+	return errors.WithStack(err)
+}
+
+// subArchive returns the files under prefix as a zip of their own.
+func subArchive(zr *zip.Reader, prefix string) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	for _, f := range zr.File {
+		if !strings.HasPrefix(f.Name, prefix) || f.FileInfo().IsDir() {
+			continue
+		}
+		b, err := readArchiveFile(f)
+		if err != nil {
+			return nil, err
+		}
+		w, err := zw.Create(strings.TrimPrefix(f.Name, prefix))
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if _, err := w.Write(b); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,204 @@
+package models_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cpjudge/cpjudge/models"
+	"github.com/cpjudge/cpjudge/storage"
+	"github.com/gobuffalo/pop/nulls"
+	"github.com/gobuffalo/uuid"
+)
+
+// importContest imports an archive from a temporary file, as uploads are.
+func (ms *ModelSuite) importContest(archive []byte, hostID uuid.UUID) (*models.ContestImport, error) {
+	f, err := ioutil.TempFile("", "archive")
+	ms.NoError(err)
+	defer os.Remove(f.Name())
+	_, err = f.Write(archive)
+	ms.NoError(err)
+	ms.NoError(f.Close())
+	return models.ImportContest(ms.DB, f.Name(), hostID)
+}
+
+func (ms *ModelSuite) Test_ExportImportContest() {
+	dir, err := ioutil.TempDir("", "storage")
+	ms.NoError(err)
+	defer os.RemoveAll(dir)
+	storage.Set(storage.NewLocal(dir))
+	defer storage.Set(nil)
+
+	contest := ms.pastContest(time.Hour)
+	contest.Rated = true
+	ms.NoError(ms.DB.Update(contest))
+	q := &models.Question{Title: "Sum", Description: "Add", InputFormat: "Two integers.", Notes: "![](sum.png)",
+		ContestID: contest.ID, Checker: models.CheckerTokens, Samples: "1.txt"}
+	ms.NoError(ms.DB.Create(q))
+	_, verrs, err := models.AddAttachment(ms.DB, q.ID, "sum.png", []byte("png"))
+	ms.NoError(err)
+	ms.False(verrs.HasAny())
+	v, err := q.StoreTestData(ms.DB, testDataZip(map[string]string{"inputs/1.txt": "1 2", "answers/1.txt": "3"}))
+	ms.NoError(err)
+	ms.NoError(q.StoreValidator(ms.DB, "check.cpp", []byte("int main() {}")))
+	verrs, err = models.AddReferenceSolution(ms.DB, &models.ReferenceSolution{QuestionID: q.ID, Filename: "sol.c", Expected: models.ExpectCorrect, Main: true}, []byte("// main"))
+	ms.NoError(err)
+	ms.False(verrs.HasAny())
+	_, verrs, err = models.AddGenerator(ms.DB, q.ID, "random.cpp", []byte("// random"))
+	ms.NoError(err)
+	ms.False(verrs.HasAny())
+	ms.NoError(q.StoreGeneratorScript(ms.DB, "random 10 1"))
+	_, verrs, err = models.SaveEditorial(ms.DB, q.ID, "Add them.", nulls.Time{})
+	ms.NoError(err)
+	ms.False(verrs.HasAny())
+	ada, bob := ms.createUser("ada"), ms.createUser("bob")
+	for _, u := range []*models.User{ada, bob} {
+		s := &models.Submission{ID: uuid.Must(uuid.NewV4()), UserID: u.ID, ContestID: contest.ID, QuestionID: q.ID,
+			Status: models.StatusCorrect, Language: "C", SourceCode: "int main(){}"}
+		s.SubmissionPath = s.StorageKey()
+		ms.NoError(ms.DB.Create(s))
+	}
+
+	archive, err := models.ExportContest(ms.DB, contest, models.ExportOptions{Submissions: true, Standings: true})
+	ms.NoError(err)
+	zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	ms.NoError(err)
+	names := map[string]bool{}
+	for _, f := range zr.File {
+		names[f.Name] = true
+	}
+	ms.True(names["contest.json"])
+	ms.True(names["testdata/"+v.Checksum+"/inputs/1.txt"])
+
+	// Only ada has an account of the same name on the importing side.
+	bob.Username = "robert"
+	ms.NoError(ms.DB.Update(bob))
+	hostID := uuid.Must(uuid.NewV4())
+	res, err := ms.importContest(archive, hostID)
+	ms.NoError(err)
+	ms.Equal(1, res.Questions)
+	ms.Equal(1, res.Submissions)
+	ms.Equal([]string{"bob"}, res.MissingUsers)
+	ms.Equal(hostID, res.Contest.HostID)
+	ms.Equal(contest.Title, res.Contest.Title)
+	ms.False(res.Contest.Rated)
+
+	imported := &models.Question{}
+	ms.NoError(ms.DB.Where("contest_id = ?", res.Contest.ID).First(imported))
+	ms.NotEqual(q.ID, imported.ID)
+	ms.Equal(v.Checksum, imported.TestCasesSum)
+	ms.Equal(models.CheckerTokens, imported.Checker)
+	ms.Equal("1.txt", imported.Samples)
+	ms.True(imported.HasValidator())
+	ms.NotEqual(q.ValidatorPath, imported.ValidatorPath)
+	main, err := models.MainSolution(ms.DB, imported.ID)
+	ms.NoError(err)
+	ms.NotNil(main)
+	ms.Equal("sol.c", main.Filename)
+	b, err := storage.ReadAll(storage.Default(), main.SourcePath)
+	ms.NoError(err)
+	ms.Equal("// main", string(b))
+	ms.Equal("random 10 1", imported.GeneratorScript)
+	generators, err := models.QuestionGenerators(ms.DB, imported.ID)
+	ms.NoError(err)
+	ms.Len(generators, 1)
+	ms.Equal("random", generators[0].Name)
+	ms.Equal("Two integers.", imported.InputFormat)
+	ms.Equal("![](sum.png)", imported.Notes)
+	attachment, err := models.FindAttachment(ms.DB, imported.ID, "sum.png")
+	ms.NoError(err)
+	b, err = storage.ReadAll(storage.Default(), attachment.StoragePath)
+	ms.NoError(err)
+	ms.Equal("png", string(b))
+	editorial, err := models.QuestionEditorial(ms.DB, imported.ID)
+	ms.NoError(err)
+	ms.NotNil(editorial)
+	ms.Equal("Add them.", editorial.Content)
+	s := &models.Submission{}
+	ms.NoError(ms.DB.Where("contest_id = ?", res.Contest.ID).First(s))
+	ms.Equal(ada.ID, s.UserID)
+	ms.Equal(imported.ID, s.QuestionID)
+	ms.Equal(models.StatusPending, s.Status)
+	source, err := s.Source()
+	ms.NoError(err)
+	ms.Equal("int main(){}", source)
+}
+
+func (ms *ModelSuite) Test_ImportContest_Invalid() {
+	_, err := ms.importContest([]byte("not a zip"), uuid.Nil)
+	ms.Equal(models.ErrNotArchive, err)
+
+	manifest, _ := json.Marshal(map[string]interface{}{"format": models.ArchiveFormat, "version": models.ArchiveVersion + 1})
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	f, _ := zw.Create("contest.json")
+	f.Write(manifest)
+	zw.Close()
+	_, err = ms.importContest(buf.Bytes(), uuid.Nil)
+	ms.Equal(models.ErrArchiveVersion, err)
+
+	manifest, _ = json.Marshal(map[string]interface{}{"format": models.ArchiveFormat, "version": models.ArchiveVersion,
+		"questions": []map[string]string{{"title": "Sum", "validator": "validators/check.cpp"}}})
+	buf.Reset()
+	zw = zip.NewWriter(buf)
+	f, _ = zw.Create("contest.json")
+	f.Write(manifest)
+	zw.Close()
+	_, err = ms.importContest(buf.Bytes(), uuid.Nil)
+	ms.IsType(&models.ArchiveError{}, err)
+	ms.Equal(`The validator of "Sum" is missing.`, err.Error())
+}
+
+func (ms *ModelSuite) Test_ImportContest_BadTestData() {
+	dir, err := ioutil.TempDir("", "storage")
+	ms.NoError(err)
+	defer os.RemoveAll(dir)
+	store := storage.NewLocal(dir)
+	storage.Set(store)
+	defer storage.Set(nil)
+
+	contest := ms.pastContest(time.Hour)
+	for _, title := range []string{"Sum", "Product"} {
+		q := &models.Question{Title: title, Description: "Compute", ContestID: contest.ID}
+		ms.NoError(ms.DB.Create(q))
+		_, err := q.StoreTestData(ms.DB, testDataZip(map[string]string{"inputs/1.txt": title, "answers/1.txt": title}))
+		ms.NoError(err)
+	}
+	archive, err := models.ExportContest(ms.DB, contest, models.ExportOptions{})
+	ms.NoError(err)
+	ms.NoError(storage.DeletePrefix(store, "testdata/"))
+
+	// The test data of the second question is tampered with.
+	zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	ms.NoError(err)
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	tampered := false
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		ms.NoError(err)
+		b, err := ioutil.ReadAll(rc)
+		ms.NoError(err)
+		rc.Close()
+		if !tampered && strings.HasSuffix(f.Name, "/answers/1.txt") && string(b) == "Product" {
+			b, tampered = []byte("Quotient"), true
+		}
+		w, err := zw.Create(f.Name)
+		ms.NoError(err)
+		w.Write(b)
+	}
+	ms.NoError(zw.Close())
+	ms.True(tampered)
+
+	_, err = ms.importContest(buf.Bytes(), uuid.Must(uuid.NewV4()))
+	ms.IsType(&models.ArchiveError{}, err)
+	ms.Contains(err.Error(), "does not match its checksum")
+	// Nothing was stored for the first question either.
+	keys, err := store.List("testdata/")
+	ms.NoError(err)
+	ms.Empty(keys)
+}
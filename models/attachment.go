@@ -0,0 +1,130 @@
+package models
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/cpjudge/cpjudge/storage"
+	"github.com/gobuffalo/pop"
+	"github.com/gobuffalo/uuid"
+	"github.com/gobuffalo/validate"
+	"github.com/pkg/errors"
+)
+
+// MaxAttachmentSize is the largest file that can be attached to a
+// question.
+const MaxAttachmentSize = 5 << 20
+
+// Attachment is a file of a question's statement, such as a picture. The
+// statement refers to it by file name, as in ![The graph](graph.png).
+type Attachment struct {
+	ID          uuid.UUID `json:"id" db:"id"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+	QuestionID  uuid.UUID `json:"question_id" db:"question_id"`
+	Filename    string    `json:"filename" db:"filename"`
+	Size        int       `json:"size" db:"size"`
+	StoragePath string    `json:"storage_path" db:"storage_path"`
+}
+
+type Attachments []Attachment
+
+var attachmentName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// imageExtensions are the attachments shown as pictures.
+var imageExtensions = map[string]bool{".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".svg": true}
+
+// Validate gets run every time you call a "pop.Validate*" (pop.ValidateAndSave, pop.ValidateAndCreate, pop.ValidateAndUpdate) method.
+func (a *Attachment) Validate(tx *pop.Connection) (*validate.Errors, error) {
+	verrs := validate.NewErrors()
+	if !attachmentName.MatchString(a.Filename) || strings.Contains(a.Filename, "..") {
+		verrs.Add("attachment", "Attachment file names may only contain letters, digits, dots, dashes and underscores.")
+	}
+	if a.Size > MaxAttachmentSize {
+		verrs.Add("attachment", fmt.Sprintf("Attachments can be at most %d MB.", MaxAttachmentSize>>20))
+	}
+	return verrs, nil
+}
+
+// StorageKey is the storage key the attachment is kept under.
+func (a Attachment) StorageKey() string {
+	return "attachments/attachment_" + a.ID.String() + strings.ToLower(path.Ext(a.Filename))
+}
+
+// IsImage reports whether the attachment is a picture.
+func (a Attachment) IsImage() bool {
+	return imageExtensions[strings.ToLower(path.Ext(a.Filename))]
+}
+
+// AddAttachment stores a file of a question's statement, replacing the
+// attachment of the same name.
+func AddAttachment(tx *pop.Connection, questionID uuid.UUID, filename string, data []byte) (*Attachment, *validate.Errors, error) {
+	a := &Attachment{ID: uuid.Must(uuid.NewV4()), QuestionID: questionID, Filename: path.Base(filename), Size: len(data)}
+	a.StoragePath = a.StorageKey()
+	verrs, err := a.Validate(tx)
+	if err != nil || verrs.HasAny() {
+		return a, verrs, err
+	}
+	old := Attachments{}
+	if err := tx.Where("question_id = ? and filename = ?", questionID, a.Filename).All(&old); err != nil {
+		return a, verrs, errors.WithStack(err)
+	}
+	for i := range old {
+		if err := old[i].Delete(tx); err != nil {
+			return a, verrs, err
+		}
+	}
+	if err := tx.Create(a); err != nil {
+		return a, verrs, errors.WithStack(err)
+	}
+	return a, verrs, storage.PutBytes(storage.Default(), a.StoragePath, data)
+}
+
+// QuestionAttachments returns the attachments of a question by name.
+func QuestionAttachments(tx *pop.Connection, questionID uuid.UUID) (Attachments, error) {
+	attachments := Attachments{}
+	if err := tx.Where("question_id = ?", questionID).Order("filename asc").All(&attachments); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return attachments, nil
+}
+
+// FindAttachment finds the attachment of a question by name.
+func FindAttachment(tx *pop.Connection, questionID uuid.UUID, filename string) (*Attachment, error) {
+	a := &Attachment{}
+	if err := tx.Where("question_id = ? and filename = ?", questionID, filename).First(a); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return a, nil
+}
+
+// Download copies the attachment into dir under its file name.
+func (a Attachment) Download(dir string) error {
+	return storage.DownloadFile(storage.Default(), a.StoragePath, filepath.Join(dir, a.Filename))
+}
+
+// Delete removes the attachment and its file.
+func (a *Attachment) Delete(tx *pop.Connection) error {
+	if err := tx.Destroy(a); err != nil {
+		return errors.WithStack(err)
+	}
+	return storage.Default().Delete(a.StoragePath)
+}
+
+// DeleteAttachments removes the attachments of the question.
+func (q Question) DeleteAttachments(tx *pop.Connection) error {
+	attachments, err := QuestionAttachments(tx, q.ID)
+	if err != nil {
+		return err
+	}
+	for i := range attachments {
+		if err := attachments[i].Delete(tx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package models_test
+
+import (
+	"io/ioutil"
+	"os"
+
+	"github.com/cpjudge/cpjudge/models"
+	"github.com/cpjudge/cpjudge/storage"
+)
+
+func (ms *ModelSuite) Test_AddAttachment() {
+	dir, err := ioutil.TempDir("", "storage")
+	ms.NoError(err)
+	defer os.RemoveAll(dir)
+	store := storage.NewLocal(dir)
+	storage.Set(store)
+	defer storage.Set(nil)
+
+	contest := ms.pastContest(0)
+	q := &models.Question{Title: "Sum", Description: "Add", ContestID: contest.ID}
+	ms.NoError(ms.DB.Create(q))
+
+	_, verrs, err := models.AddAttachment(ms.DB, q.ID, "my graph.png", []byte("png"))
+	ms.NoError(err)
+	ms.True(verrs.HasAny())
+	_, verrs, err = models.AddAttachment(ms.DB, q.ID, "huge.png", make([]byte, models.MaxAttachmentSize+1))
+	ms.NoError(err)
+	ms.True(verrs.HasAny())
+
+	first, verrs, err := models.AddAttachment(ms.DB, q.ID, "graph.png", []byte("v1"))
+	ms.NoError(err)
+	ms.False(verrs.HasAny())
+	second, verrs, err := models.AddAttachment(ms.DB, q.ID, "graph.png", []byte("v2"))
+	ms.NoError(err)
+	ms.False(verrs.HasAny())
+	ms.True(second.IsImage())
+	attachments, err := models.QuestionAttachments(ms.DB, q.ID)
+	ms.NoError(err)
+	ms.Len(attachments, 1)
+	ms.Equal(second.ID, attachments[0].ID)
+	_, err = store.Get(first.StoragePath)
+	ms.Equal(storage.ErrNotFound, err)
+
+	found, err := models.FindAttachment(ms.DB, q.ID, "graph.png")
+	ms.NoError(err)
+	ms.Equal(second.ID, found.ID)
+	b, err := storage.ReadAll(store, found.StoragePath)
+	ms.NoError(err)
+	ms.Equal("v2", string(b))
+
+	ms.NoError(q.DeleteAttachments(ms.DB))
+	_, err = store.Get(second.StoragePath)
+	ms.Equal(storage.ErrNotFound, err)
+}
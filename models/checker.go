@@ -0,0 +1,57 @@
+package models
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Checkers decide whether the output of a program matches the answer. The
+// exact checker compares whole outputs, ignoring leading and trailing
+// newlines; the token checker ignores how tokens are separated; the line
+// checker compares the tokens of each line; float checkers also accept real
+// numbers within an absolute or relative error.
+const (
+	CheckerExact  = ""
+	CheckerTokens = "tokens"
+	CheckerLines  = "lines"
+	CheckerFloat  = "float"
+)
+
+// CheckerOption is a checker hosts can pick for a question.
+type CheckerOption struct {
+	Value string
+	Label string
+}
+
+// Checkers are the checkers offered in the question form.
+var Checkers = []CheckerOption{
+	{CheckerExact, "Exact output"},
+	{CheckerTokens, "Ignore whitespace"},
+	{CheckerLines, "Ignore spacing within lines"},
+	{FloatChecker(1e-4), "Real numbers, error 1e-4"},
+	{FloatChecker(1e-6), "Real numbers, error 1e-6"},
+	{FloatChecker(1e-9), "Real numbers, error 1e-9"},
+}
+
+// FloatChecker returns the checker accepting real numbers within tolerance.
+func FloatChecker(tolerance float64) string {
+	return CheckerFloat + ":" + strconv.FormatFloat(tolerance, 'g', -1, 64)
+}
+
+// ParseChecker returns the kind of a checker and, for float checkers, the
+// tolerance.
+func ParseChecker(checker string) (string, float64, error) {
+	switch checker {
+	case CheckerExact, CheckerTokens, CheckerLines:
+		return checker, 0, nil
+	}
+	if strings.HasPrefix(checker, CheckerFloat+":") {
+		tolerance, err := strconv.ParseFloat(strings.TrimPrefix(checker, CheckerFloat+":"), 64)
+		if err == nil && tolerance > 0 && tolerance < 1 {
+			return CheckerFloat, tolerance, nil
+		}
+	}
+	return "", 0, errors.Errorf("unknown checker %q", checker)
+}
@@ -0,0 +1,196 @@
+package models
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/pop"
+	"github.com/gobuffalo/pop/nulls"
+	"github.com/gobuffalo/uuid"
+	"github.com/gobuffalo/validate"
+	"github.com/gobuffalo/validate/validators"
+	"github.com/pkg/errors"
+)
+
+// Clarification is a contestant's question about a contest, or an
+// announcement made by its host. Contestants see a clarification once it is
+// published: announcements immediately, questions when they are answered.
+type Clarification struct {
+	ID           uuid.UUID  `json:"id" db:"id"`
+	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at" db:"updated_at"`
+	ContestID    uuid.UUID  `json:"contest_id" db:"contest_id"`
+	QuestionID   nulls.UUID `json:"question_id" db:"question_id"`
+	UserID       nulls.UUID `json:"user_id" db:"user_id"`
+	Body         string     `json:"body" db:"body"`
+	Answer       string     `json:"answer" db:"answer"`
+	Public       bool       `json:"public" db:"public"`
+	Announcement bool       `json:"announcement" db:"announcement"`
+	PublishedAt  nulls.Time `json:"published_at" db:"published_at"`
+	// Filled in by Load for display.
+	ContestTitle  string `json:"contest_title" db:"-"`
+	QuestionTitle string `json:"question_title" db:"-"`
+	Asker         string `json:"asker" db:"-"`
+}
+
+type Clarifications []Clarification
+
+// Validate gets run every time you call a "pop.Validate*" (pop.ValidateAndSave, pop.ValidateAndCreate, pop.ValidateAndUpdate) method.
+func (cl *Clarification) Validate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.Validate(
+		&validators.StringIsPresent{Field: cl.Body, Name: "Body"},
+	), nil
+}
+
+// Answered reports whether a contestant's question has been answered.
+func (cl Clarification) Answered() bool {
+	return !cl.Announcement && cl.PublishedAt.Valid
+}
+
+// Respond answers a clarification, either privately to the asker or
+// publicly to every contestant, and publishes it.
+func (cl *Clarification) Respond(tx *pop.Connection, answer string, public bool, now time.Time) (*validate.Errors, error) {
+	verrs := validate.NewErrors()
+	if strings.TrimSpace(answer) == "" {
+		verrs.Add("answer", "Answer can not be blank.")
+		return verrs, nil
+	}
+	cl.Answer = answer
+	cl.Public = public
+	cl.PublishedAt = nulls.NewTime(now)
+	return tx.ValidateAndUpdate(cl)
+}
+
+// Announce publishes an announcement to every contestant of a contest.
+func Announce(tx *pop.Connection, contestID uuid.UUID, body string, now time.Time) (*Clarification, *validate.Errors, error) {
+	cl := &Clarification{
+		ContestID:    contestID,
+		Body:         body,
+		Public:       true,
+		Announcement: true,
+		PublishedAt:  nulls.NewTime(now),
+	}
+	verrs, err := tx.ValidateAndCreate(cl)
+	return cl, verrs, err
+}
+
+// ContestClarifications returns the clarifications of a contest a viewer may
+// see, newest first. Hosts see everything; users see published public
+// clarifications and their own questions. Pass uuid.Nil for guests.
+func ContestClarifications(tx *pop.Connection, contestID uuid.UUID, userID uuid.UUID, host bool) (Clarifications, error) {
+	q := tx.Where("contest_id = ?", contestID)
+	if !host {
+		q = q.Where("((public = ? and published_at is not null) or user_id = ?)", true, userID)
+	}
+	cls := Clarifications{}
+	if err := q.Order("created_at desc").All(&cls); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return cls, cls.Load(tx)
+}
+
+// UserClarifications returns the published clarifications of the contests
+// a user took part in, newest first: their own questions, and the public
+// clarifications of contests they submitted to or replayed.
+func UserClarifications(tx *pop.Connection, userID uuid.UUID) (Clarifications, error) {
+	cls := Clarifications{}
+	if err := userClarifications(tx, userID).Order("published_at desc").All(&cls); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return cls, cls.Load(tx)
+}
+
+func userClarifications(tx *pop.Connection, userID uuid.UUID) *pop.Query {
+	return tx.Where("published_at is not null and (user_id = ? or (public = ? and "+
+		"(contest_id in (select contest_id from submissions where user_id = ?) or "+
+		"contest_id in (select contest_id from virtual_participations where user_id = ?))))",
+		userID, true, userID, userID)
+}
+
+// PendingClarifications returns the unanswered questions in the contests
+// of a host, oldest first.
+func PendingClarifications(tx *pop.Connection, hostID uuid.UUID) (Clarifications, error) {
+	cls := Clarifications{}
+	if err := pendingClarifications(tx, hostID).Order("created_at asc").All(&cls); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return cls, cls.Load(tx)
+}
+
+// CountPendingClarifications counts the unanswered questions in the
+// contests of a host.
+func CountPendingClarifications(tx *pop.Connection, hostID uuid.UUID) (int, error) {
+	n, err := pendingClarifications(tx, hostID).Count(&Clarification{})
+	return n, errors.WithStack(err)
+}
+
+func pendingClarifications(tx *pop.Connection, hostID uuid.UUID) *pop.Query {
+	return tx.Where("published_at is null and contest_id in (select id from contests where host_id = ?)", hostID)
+}
+
+// Load fills in the contest, question and asker names.
+func (cls Clarifications) Load(tx *pop.Connection) error {
+	for i := range cls {
+		contest := Contest{}
+		if err := tx.Find(&contest, cls[i].ContestID); err != nil {
+			return errors.WithStack(err)
+		}
+		cls[i].ContestTitle = contest.Title
+		if cls[i].QuestionID.Valid {
+			question := Question{}
+			if err := tx.Find(&question, cls[i].QuestionID.UUID); err == nil {
+				cls[i].QuestionTitle = question.Title
+			}
+		}
+		if cls[i].UserID.Valid {
+			user := User{}
+			if err := tx.Find(&user, cls[i].UserID.UUID); err == nil {
+				cls[i].Asker = user.Username
+			}
+		}
+	}
+	return nil
+}
+
+// ClarificationRead remembers when a reader last looked at their
+// clarifications.
+type ClarificationRead struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	ReaderID  uuid.UUID `json:"reader_id" db:"reader_id"`
+	SeenAt    time.Time `json:"seen_at" db:"seen_at"`
+}
+
+// UnreadClarifications counts the clarifications listed by
+// UserClarifications that were published since the user last read them.
+// Before their first visit, everything published after the user signed up
+// counts.
+func UnreadClarifications(tx *pop.Connection, user *User) (int, error) {
+	since := user.CreatedAt
+	read := &ClarificationRead{}
+	err := tx.Where("reader_id = ?", user.ID).First(read)
+	if err == nil {
+		since = read.SeenAt
+	} else if errors.Cause(err) != sql.ErrNoRows {
+		return 0, errors.WithStack(err)
+	}
+	n, err := userClarifications(tx, user.ID).Where("published_at > ?", since).Count(&Clarification{})
+	return n, errors.WithStack(err)
+}
+
+// MarkClarificationsRead records that a user has seen their clarifications.
+func MarkClarificationsRead(tx *pop.Connection, userID uuid.UUID, now time.Time) error {
+	read := &ClarificationRead{}
+	err := tx.Where("reader_id = ?", userID).First(read)
+	if err != nil && errors.Cause(err) != sql.ErrNoRows {
+		return errors.WithStack(err)
+	}
+	read.ReaderID = userID
+	read.SeenAt = now
+	if read.ID == uuid.Nil {
+		return errors.WithStack(tx.Create(read))
+	}
+	return errors.WithStack(tx.Update(read))
+}
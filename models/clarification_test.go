@@ -0,0 +1,76 @@
+package models_test
+
+import (
+	"time"
+
+	"github.com/cpjudge/cpjudge/models"
+	"github.com/gobuffalo/pop/nulls"
+	"github.com/gobuffalo/uuid"
+)
+
+func (ms *ModelSuite) Test_Clarifications_Visibility() {
+	contest := &models.Contest{Title: "Weekly", Description: "Weekly contest"}
+	ms.NoError(ms.DB.Create(contest))
+	ada, bob := ms.createUser("ada"), ms.createUser("bob")
+
+	private := &models.Clarification{ContestID: contest.ID, UserID: nulls.NewUUID(ada.ID), Body: "Is n positive?"}
+	ms.NoError(ms.DB.Create(private))
+	public := &models.Clarification{ContestID: contest.ID, UserID: nulls.NewUUID(ada.ID), Body: "Can the input be empty?"}
+	ms.NoError(ms.DB.Create(public))
+
+	verrs, err := public.Respond(ms.DB, "No.", true, time.Now())
+	ms.NoError(err)
+	ms.False(verrs.HasAny())
+	verrs, err = private.Respond(ms.DB, "", false, time.Now())
+	ms.NoError(err)
+	ms.True(verrs.HasAny())
+	_, verrs, err = models.Announce(ms.DB, contest.ID, "Problem B has been fixed.", time.Now())
+	ms.NoError(err)
+	ms.False(verrs.HasAny())
+
+	// The asker also sees the pending question, others only published public items.
+	cls, err := models.ContestClarifications(ms.DB, contest.ID, ada.ID, false)
+	ms.NoError(err)
+	ms.Len(cls, 3)
+	cls, err = models.ContestClarifications(ms.DB, contest.ID, bob.ID, false)
+	ms.NoError(err)
+	ms.Len(cls, 2)
+	cls, err = models.ContestClarifications(ms.DB, contest.ID, uuid.Nil, true)
+	ms.NoError(err)
+	ms.Len(cls, 3)
+
+	pending, err := models.PendingClarifications(ms.DB, contest.HostID)
+	ms.NoError(err)
+	ms.Len(pending, 1)
+	ms.Equal("ada", pending[0].Asker)
+}
+
+func (ms *ModelSuite) Test_UnreadClarifications() {
+	contest := &models.Contest{Title: "Weekly", Description: "Weekly contest"}
+	ms.NoError(ms.DB.Create(contest))
+	ada := ms.createUser("ada")
+
+	_, _, err := models.Announce(ms.DB, contest.ID, "Welcome!", time.Now().Add(time.Minute))
+	ms.NoError(err)
+	// Announcements of contests ada has not taken part in do not count.
+	n, err := models.UnreadClarifications(ms.DB, ada)
+	ms.NoError(err)
+	ms.Equal(0, n)
+
+	ms.createSubmission(ada, contest.ID, uuid.Must(uuid.NewV4()), models.StatusWrong, "C")
+	n, err = models.UnreadClarifications(ms.DB, ada)
+	ms.NoError(err)
+	ms.Equal(1, n)
+
+	ms.NoError(models.MarkClarificationsRead(ms.DB, ada.ID, time.Now().Add(2*time.Minute)))
+	n, err = models.UnreadClarifications(ms.DB, ada)
+	ms.NoError(err)
+	ms.Equal(0, n)
+
+	_, _, err = models.Announce(ms.DB, contest.ID, "Ten minutes left.", time.Now().Add(3*time.Minute))
+	ms.NoError(err)
+	ms.NoError(models.MarkClarificationsRead(ms.DB, ada.ID, time.Now()))
+	n, err = models.UnreadClarifications(ms.DB, ada)
+	ms.NoError(err)
+	ms.Equal(1, n)
+}
@@ -4,26 +4,76 @@ import (
 	"time"
 
 	"github.com/gobuffalo/pop"
+	"github.com/gobuffalo/pop/nulls"
 	"github.com/gobuffalo/uuid"
 	"github.com/gobuffalo/validate"
 	"github.com/gobuffalo/validate/validators"
 )
 
 type Contest struct {
-	ID          uuid.UUID `json:"id" db:"id"`
-	CreatedAt   time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
-	Title       string    `json:"title" db:"title"`
-	Description string    `json:"description" db:"description"`
-	HostID      uuid.UUID `json:"host_id" db:"host_id"`
+	ID          uuid.UUID  `json:"id" db:"id"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`
+	Title       string     `json:"title" db:"title"`
+	Description string     `json:"description" db:"description"`
+	HostID      uuid.UUID  `json:"host_id" db:"host_id"`
+	StartTime   nulls.Time `json:"start_time" db:"start_time"`
+	EndTime     nulls.Time `json:"end_time" db:"end_time"`
+	Rated       bool       `json:"rated" db:"rated"`
+	// PublicSolutions makes the sources of accepted submissions readable
+	// by everyone once the contest has ended.
+	PublicSolutions bool `json:"public_solutions" db:"public_solutions"`
 }
 
 type Contests []Contest
 
 // Validate gets run every time you call a "pop.Validate*" (pop.ValidateAndSave, pop.ValidateAndCreate, pop.ValidateAndUpdate) method.
 func (c *Contest) Validate(tx *pop.Connection) (*validate.Errors, error) {
-	return validate.Validate(
+	// Form binding turns empty time fields into valid zero times.
+	if c.StartTime.Time.IsZero() {
+		c.StartTime = nulls.Time{}
+	}
+	if c.EndTime.Time.IsZero() {
+		c.EndTime = nulls.Time{}
+	}
+	verrs := validate.Validate(
 		&validators.StringIsPresent{Field: c.Title, Name: "Title"},
 		&validators.StringIsPresent{Field: c.Description, Name: "Description"},
-	), nil
+	)
+	if c.StartTime.Valid && c.EndTime.Valid && !c.EndTime.Time.After(c.StartTime.Time) {
+		verrs.Add("end_time", "End time must be after the start time.")
+	}
+	if c.Rated && !c.EndTime.Valid {
+		verrs.Add("rated", "Rated contests need an end time.")
+	}
+	return verrs, nil
+}
+
+// Started reports whether the contest has started at t. Contests without a
+// start time are open from the moment they are created.
+func (c Contest) Started(t time.Time) bool {
+	return !c.StartTime.Valid || !t.Before(c.StartTime.Time)
+}
+
+// Ended reports whether the contest is over at t. Contests without an end
+// time never end.
+func (c Contest) Ended(t time.Time) bool {
+	return c.EndTime.Valid && !t.Before(c.EndTime.Time)
+}
+
+// SharesSolutions reports whether the sources of accepted submissions are
+// public at t.
+func (c Contest) SharesSolutions(t time.Time) bool {
+	return c.PublicSolutions && c.Ended(t)
+}
+
+// SharesSource reports whether anyone may read the source of a submission
+// to the contest at t.
+func (c Contest) SharesSource(s Submission, t time.Time) bool {
+	return c.SharesSolutions(t) && s.Status == StatusCorrect
+}
+
+// Running reports whether the contest accepts contest submissions at t.
+func (c Contest) Running(t time.Time) bool {
+	return c.Started(t) && !c.Ended(t)
 }
@@ -0,0 +1,133 @@
+package models
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// DiffLine is a line of a line-by-line diff. Op is " " for unchanged lines,
+// "-" for lines only in the old text and "+" for lines only in the new one.
+type DiffLine struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// MaxDiffLines bounds the number of changed lines Diff compares, as its time
+// grows with the product of the changed lines of both texts.
+const MaxDiffLines = 10000
+
+// ErrDiffTooLarge is returned by Diff for texts with too many changed lines.
+var ErrDiffTooLarge = errors.New("the texts differ in too many lines to compare")
+
+// Diff computes a line diff between two texts from their longest common
+// subsequence of lines. It uses Hirschberg's algorithm, so it needs memory
+// linear in the number of lines, not their product.
+func Diff(oldText, newText string) ([]DiffLine, error) {
+	a := splitLines(oldText)
+	b := splitLines(newText)
+	lines := []DiffLine{}
+	// Submissions are mostly edits of each other; set the common start and
+	// end aside.
+	start := 0
+	for start < len(a) && start < len(b) && a[start] == b[start] {
+		start++
+	}
+	end := 0
+	for end < len(a)-start && end < len(b)-start && a[len(a)-1-end] == b[len(b)-1-end] {
+		end++
+	}
+	if len(a)+len(b)-2*(start+end) > MaxDiffLines {
+		return nil, ErrDiffTooLarge
+	}
+	lines = appendLines(lines, " ", a[:start])
+	lines = diffLines(lines, a[start:len(a)-end], b[start:len(b)-end])
+	return appendLines(lines, " ", a[len(a)-end:]), nil
+}
+
+// diffLines appends the diff of a and b to lines: it splits a in half,
+// finds where the longest common subsequence splits b and diffs both
+// halves.
+func diffLines(lines []DiffLine, a, b []string) []DiffLine {
+	switch {
+	case len(a) == 0:
+		return appendLines(lines, "+", b)
+	case len(b) == 0:
+		return appendLines(lines, "-", a)
+	case len(a) == 1:
+		for j := range b {
+			if b[j] == a[0] {
+				lines = appendLines(lines, "+", b[:j])
+				lines = append(lines, DiffLine{Op: " ", Text: a[0]})
+				return appendLines(lines, "+", b[j+1:])
+			}
+		}
+		lines = append(lines, DiffLine{Op: "-", Text: a[0]})
+		return appendLines(lines, "+", b)
+	}
+	mid := len(a) / 2
+	prefix := prefixLCS(a[:mid], b)
+	suffix := suffixLCS(a[mid:], b)
+	split := 0
+	for j := range prefix {
+		if prefix[j]+suffix[j] > prefix[split]+suffix[split] {
+			split = j
+		}
+	}
+	lines = diffLines(lines, a[:mid], b[:split])
+	return diffLines(lines, a[mid:], b[split:])
+}
+
+// prefixLCS returns, for each j, the LCS length of a and b[:j].
+func prefixLCS(a, b []string) []int {
+	row := make([]int, len(b)+1)
+	prev := make([]int, len(b)+1)
+	for i := range a {
+		row, prev = prev, row
+		for j := range b {
+			if a[i] == b[j] {
+				row[j+1] = prev[j] + 1
+			} else if prev[j+1] >= row[j] {
+				row[j+1] = prev[j+1]
+			} else {
+				row[j+1] = row[j]
+			}
+		}
+	}
+	return row
+}
+
+// suffixLCS returns, for each j, the LCS length of a and b[j:].
+func suffixLCS(a, b []string) []int {
+	row := make([]int, len(b)+1)
+	prev := make([]int, len(b)+1)
+	for i := len(a) - 1; i >= 0; i-- {
+		row, prev = prev, row
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				row[j] = prev[j+1] + 1
+			} else if prev[j] >= row[j+1] {
+				row[j] = prev[j]
+			} else {
+				row[j] = row[j+1]
+			}
+		}
+	}
+	return row
+}
+
+func appendLines(lines []DiffLine, op string, text []string) []DiffLine {
+	for _, t := range text {
+		lines = append(lines, DiffLine{Op: op, Text: t})
+	}
+	return lines
+}
+
+func splitLines(s string) []string {
+	s = strings.Replace(s, "\r\n", "\n", -1)
+	s = strings.TrimSuffix(s, "\n")
+	if s == "" {
+		return []string{}
+	}
+	return strings.Split(s, "\n")
+}
@@ -0,0 +1,52 @@
+package models_test
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/cpjudge/cpjudge/models"
+)
+
+func (ms *ModelSuite) Test_Diff() {
+	lines, err := models.Diff("a\nb\nc\n", "a\nc\nd\n")
+	ms.NoError(err)
+	ms.Equal([]models.DiffLine{
+		{Op: " ", Text: "a"},
+		{Op: "-", Text: "b"},
+		{Op: " ", Text: "c"},
+		{Op: "+", Text: "d"},
+	}, lines)
+
+	lines, err = models.Diff("", "")
+	ms.NoError(err)
+	ms.Empty(lines)
+	lines, err = models.Diff("", "x")
+	ms.NoError(err)
+	ms.Equal([]models.DiffLine{{Op: "+", Text: "x"}}, lines)
+
+	lines, err = models.Diff("x\na\nb\nc\nd\ny", "x\nb\na\nd\nc\ny")
+	ms.NoError(err)
+	ms.Equal([]models.DiffLine{
+		{Op: " ", Text: "x"},
+		{Op: "-", Text: "a"},
+		{Op: " ", Text: "b"},
+		{Op: "-", Text: "c"},
+		{Op: "+", Text: "a"},
+		{Op: " ", Text: "d"},
+		{Op: "+", Text: "c"},
+		{Op: " ", Text: "y"},
+	}, lines)
+
+	many := make([]string, models.MaxDiffLines)
+	for i := range many {
+		many[i] = strconv.Itoa(i)
+	}
+	_, err = models.Diff("", strings.Join(many, "\n")+"\nlast")
+	ms.Equal(models.ErrDiffTooLarge, err)
+}
+
+func (ms *ModelSuite) Test_Submission_SourceFilename() {
+	s := models.Submission{Language: "C++"}
+	ms.Equal("submission_"+s.ID.String()+".cpp", s.SourceFilename())
+	ms.Equal("C", models.FindLanguage("Brainfuck").Name)
+}
@@ -0,0 +1,189 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/gobuffalo/pop"
+	"github.com/gobuffalo/pop/nulls"
+	"github.com/gobuffalo/uuid"
+	"github.com/gobuffalo/validate"
+	"github.com/gobuffalo/validate/validators"
+	"github.com/pkg/errors"
+)
+
+// MaxCommentLength is the longest an editorial comment can be.
+const MaxCommentLength = 5000
+
+// Editorial explains the solution of a question in markdown. Contestants see
+// it once the contest has ended and its publish time, if any, has passed.
+type Editorial struct {
+	ID         uuid.UUID  `json:"id" db:"id"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at" db:"updated_at"`
+	QuestionID uuid.UUID  `json:"question_id" db:"question_id"`
+	Content    string     `json:"content" db:"content"`
+	PublishAt  nulls.Time `json:"publish_at" db:"publish_at"`
+}
+
+type Editorials []Editorial
+
+// Validate gets run every time you call a "pop.Validate*" (pop.ValidateAndSave, pop.ValidateAndCreate, pop.ValidateAndUpdate) method.
+func (e *Editorial) Validate(tx *pop.Connection) (*validate.Errors, error) {
+	// Form binding turns an empty publish time into a valid zero time.
+	if e.PublishAt.Time.IsZero() {
+		e.PublishAt = nulls.Time{}
+	}
+	return validate.Validate(
+		&validators.StringIsPresent{Field: e.Content, Name: "Content"},
+	), nil
+}
+
+// Published reports whether contestants of contest can read the editorial
+// at t.
+func (e Editorial) Published(contest Contest, t time.Time) bool {
+	return contest.Ended(t) && (!e.PublishAt.Valid || !t.Before(e.PublishAt.Time))
+}
+
+// PublishedAt is when contestants of contest can first read the editorial,
+// or the zero time if the contest never ends.
+func (e Editorial) PublishedAt(contest Contest) time.Time {
+	if !contest.EndTime.Valid {
+		return time.Time{}
+	}
+	if e.PublishAt.Valid && e.PublishAt.Time.After(contest.EndTime.Time) {
+		return e.PublishAt.Time
+	}
+	return contest.EndTime.Time
+}
+
+// QuestionEditorial returns the editorial of a question, or nil if it has
+// none.
+func QuestionEditorial(tx *pop.Connection, questionID uuid.UUID) (*Editorial, error) {
+	e := &Editorial{}
+	err := tx.Where("question_id = ?", questionID).First(e)
+	if errors.Cause(err) == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return e, nil
+}
+
+// SaveEditorial writes the editorial of a question, creating it if the
+// question has none.
+func SaveEditorial(tx *pop.Connection, questionID uuid.UUID, content string, publishAt nulls.Time) (*Editorial, *validate.Errors, error) {
+	e, err := QuestionEditorial(tx, questionID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if e == nil {
+		e = &Editorial{QuestionID: questionID}
+	}
+	e.Content = content
+	e.PublishAt = publishAt
+	verrs, err := tx.ValidateAndSave(e)
+	return e, verrs, errors.WithStack(err)
+}
+
+// LoadEditorials fills in whether contestants of contest can read the
+// editorial of each of its questions at t.
+func (qs Questions) LoadEditorials(tx *pop.Connection, contest Contest, t time.Time) error {
+	if !contest.Ended(t) {
+		return nil
+	}
+	for i := range qs {
+		e, err := QuestionEditorial(tx, qs[i].ID)
+		if err != nil {
+			return err
+		}
+		qs[i].HasEditorial = e != nil && e.Published(contest, t)
+	}
+	return nil
+}
+
+// SharedSolution is an accepted submission whose contest shares its source.
+type SharedSolution struct {
+	SubmissionID uuid.UUID `db:"id"`
+	Username     string    `db:"username"`
+	Language     string    `db:"language"`
+	CreatedAt    time.Time `db:"created_at"`
+}
+
+// SharedSolutions returns the accepted submissions to a question anyone can
+// read at t, oldest first, or none if its contest does not share them.
+func SharedSolutions(tx *pop.Connection, contest Contest, questionID uuid.UUID, t time.Time) ([]SharedSolution, error) {
+	solutions := []SharedSolution{}
+	if !contest.SharesSolutions(t) {
+		return solutions, nil
+	}
+	err := tx.RawQuery("SELECT submissions.id, users.username, submissions.language, submissions.created_at FROM submissions "+
+		"JOIN users ON users.id = submissions.user_id WHERE submissions.question_id = ? AND submissions.status = ? "+
+		"ORDER BY submissions.created_at ASC", questionID, StatusCorrect).All(&solutions)
+	return solutions, errors.WithStack(err)
+}
+
+// Delete removes the editorial and its comments.
+func (e *Editorial) Delete(tx *pop.Connection) error {
+	if err := tx.RawQuery("DELETE FROM editorial_comments WHERE editorial_id = ?", e.ID).Exec(); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(tx.Destroy(e))
+}
+
+// DeleteEditorial removes the editorial of the question, if any.
+func (q Question) DeleteEditorial(tx *pop.Connection) error {
+	e, err := QuestionEditorial(tx, q.ID)
+	if err != nil || e == nil {
+		return err
+	}
+	return e.Delete(tx)
+}
+
+// EditorialComment is a user's comment on an editorial.
+type EditorialComment struct {
+	ID          uuid.UUID `json:"id" db:"id"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+	EditorialID uuid.UUID `json:"editorial_id" db:"editorial_id"`
+	UserID      uuid.UUID `json:"user_id" db:"user_id"`
+	Body        string    `json:"body" db:"body"`
+	// Filled in by ListEditorialComments for display.
+	Username string `json:"username" db:"-"`
+}
+
+type EditorialComments []EditorialComment
+
+// Validate gets run every time you call a "pop.Validate*" (pop.ValidateAndSave, pop.ValidateAndCreate, pop.ValidateAndUpdate) method.
+func (ec *EditorialComment) Validate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.Validate(
+		&validators.StringIsPresent{Field: ec.Body, Name: "Body"},
+		&validators.StringLengthInRange{Field: ec.Body, Name: "Body", Max: MaxCommentLength},
+	), nil
+}
+
+// commentWithAuthor is an editorial comment joined with the username of its
+// author.
+type commentWithAuthor struct {
+	EditorialComment
+	Author string `db:"author"`
+}
+
+// ListEditorialComments returns the comments of an editorial, oldest first,
+// with their authors' usernames.
+func ListEditorialComments(tx *pop.Connection, editorialID uuid.UUID) (EditorialComments, error) {
+	rows := []commentWithAuthor{}
+	err := tx.RawQuery("SELECT editorial_comments.*, users.username AS author FROM editorial_comments "+
+		"JOIN users ON users.id = editorial_comments.user_id WHERE editorial_comments.editorial_id = ? "+
+		"ORDER BY editorial_comments.created_at ASC", editorialID).All(&rows)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	comments := make(EditorialComments, len(rows))
+	for i, row := range rows {
+		comments[i] = row.EditorialComment
+		comments[i].Username = row.Author
+	}
+	return comments, nil
+}
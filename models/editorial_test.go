@@ -0,0 +1,79 @@
+package models_test
+
+import (
+	"strings"
+	"time"
+
+	"github.com/cpjudge/cpjudge/models"
+	"github.com/gobuffalo/pop/nulls"
+)
+
+func (ms *ModelSuite) Test_Editorial_Published() {
+	now := time.Now()
+	running := models.Contest{EndTime: nulls.NewTime(now.Add(time.Hour))}
+	ended := models.Contest{EndTime: nulls.NewTime(now.Add(-time.Hour))}
+
+	e := models.Editorial{}
+	ms.False(e.Published(running, now))
+	ms.False(e.Published(models.Contest{}, now))
+	ms.True(e.Published(ended, now))
+	ms.Equal(ended.EndTime.Time, e.PublishedAt(ended))
+
+	e.PublishAt = nulls.NewTime(now.Add(time.Hour))
+	ms.False(e.Published(ended, now))
+	ms.True(e.Published(ended, now.Add(2*time.Hour)))
+	ms.Equal(e.PublishAt.Time, e.PublishedAt(ended))
+}
+
+func (ms *ModelSuite) Test_SaveEditorial() {
+	contest := ms.pastContest(time.Hour)
+	q := &models.Question{Title: "Sum", Description: "Add", ContestID: contest.ID}
+	ms.NoError(ms.DB.Create(q))
+
+	_, verrs, err := models.SaveEditorial(ms.DB, q.ID, "", nulls.Time{})
+	ms.NoError(err)
+	ms.True(verrs.HasAny())
+
+	first, verrs, err := models.SaveEditorial(ms.DB, q.ID, "Add them.", nulls.Time{})
+	ms.NoError(err)
+	ms.False(verrs.HasAny())
+	second, verrs, err := models.SaveEditorial(ms.DB, q.ID, "Add them up.", nulls.Time{})
+	ms.NoError(err)
+	ms.False(verrs.HasAny())
+	ms.Equal(first.ID, second.ID)
+
+	questions := models.Questions{*q}
+	ms.NoError(questions.LoadEditorials(ms.DB, *contest, time.Now()))
+	ms.True(questions[0].HasEditorial)
+
+	ada := ms.createUser("ada")
+	verrs, err = ms.DB.ValidateAndCreate(&models.EditorialComment{EditorialID: second.ID, UserID: ada.ID, Body: "Thanks!"})
+	ms.NoError(err)
+	ms.False(verrs.HasAny())
+	verrs, err = ms.DB.ValidateAndCreate(&models.EditorialComment{EditorialID: second.ID, UserID: ada.ID, Body: strings.Repeat("a", models.MaxCommentLength+1)})
+	ms.NoError(err)
+	ms.True(verrs.HasAny())
+	comments, err := models.ListEditorialComments(ms.DB, second.ID)
+	ms.NoError(err)
+	ms.Len(comments, 1)
+	ms.Equal("ada", comments[0].Username)
+
+	ms.NoError(q.DeleteEditorial(ms.DB))
+	e, err := models.QuestionEditorial(ms.DB, q.ID)
+	ms.NoError(err)
+	ms.Nil(e)
+	n, err := ms.DB.Where("editorial_id = ?", second.ID).Count(&models.EditorialComment{})
+	ms.NoError(err)
+	ms.Equal(0, n)
+}
+
+func (ms *ModelSuite) Test_Contest_SharesSource() {
+	now := time.Now()
+	contest := models.Contest{EndTime: nulls.NewTime(now.Add(-time.Hour)), PublicSolutions: true}
+	accepted := models.Submission{Status: models.StatusCorrect}
+	ms.True(contest.SharesSource(accepted, now))
+	ms.False(contest.SharesSource(models.Submission{Status: models.StatusWrong}, now))
+	ms.False(contest.SharesSource(accepted, now.Add(-2*time.Hour)))
+	contest.PublicSolutions = false
+	ms.False(contest.SharesSource(accepted, now))
+}
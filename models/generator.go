@@ -0,0 +1,169 @@
+package models
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cpjudge/cpjudge/storage"
+	"github.com/gobuffalo/pop"
+	"github.com/gobuffalo/uuid"
+	"github.com/gobuffalo/validate"
+	"github.com/pkg/errors"
+)
+
+// MaxGeneratedTests is the most tests a generator script can make.
+const MaxGeneratedTests = 1000
+
+// Generator is a host's program printing a test input, which it builds
+// from its arguments. Generators are named after their source file and
+// called by name in the question's generator script.
+type Generator struct {
+	ID         uuid.UUID `json:"id" db:"id"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at" db:"updated_at"`
+	QuestionID uuid.UUID `json:"question_id" db:"question_id"`
+	Name       string    `json:"name" db:"name"`
+	Filename   string    `json:"filename" db:"filename"`
+	SourcePath string    `json:"source_path" db:"source_path"`
+}
+
+type Generators []Generator
+
+var generatorName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// GeneratorName returns the name a generator with the given source file is
+// called by.
+func GeneratorName(filename string) string {
+	return strings.TrimSuffix(path.Base(filename), path.Ext(filename))
+}
+
+// Validate gets run every time you call a "pop.Validate*" (pop.ValidateAndSave, pop.ValidateAndCreate, pop.ValidateAndUpdate) method.
+func (g *Generator) Validate(tx *pop.Connection) (*validate.Errors, error) {
+	verrs := validate.NewErrors()
+	if ProgramExtension(g.Filename) == "" {
+		verrs.Add("generator", "Generators must be C or C++ sources.")
+	} else if !generatorName.MatchString(g.Name) {
+		verrs.Add("generator", "Generator file names may only contain letters, digits, dashes and underscores.")
+	}
+	return verrs, nil
+}
+
+// StorageKey is the storage key the source of the generator is kept under.
+func (g Generator) StorageKey() string {
+	return "generators/generator_" + g.ID.String() + ProgramExtension(g.Filename)
+}
+
+// AddGenerator stores the source of a generator, replacing the question's
+// generator of the same name.
+func AddGenerator(tx *pop.Connection, questionID uuid.UUID, filename string, source []byte) (*Generator, *validate.Errors, error) {
+	g := &Generator{ID: uuid.Must(uuid.NewV4()), QuestionID: questionID, Name: GeneratorName(filename), Filename: path.Base(filename)}
+	g.SourcePath = g.StorageKey()
+	verrs, err := g.Validate(tx)
+	if err != nil || verrs.HasAny() {
+		return g, verrs, err
+	}
+	old := Generators{}
+	if err := tx.Where("question_id = ? and name = ?", questionID, g.Name).All(&old); err != nil {
+		return g, verrs, errors.WithStack(err)
+	}
+	for i := range old {
+		if err := old[i].Delete(tx); err != nil {
+			return g, verrs, err
+		}
+	}
+	if err := tx.Create(g); err != nil {
+		return g, verrs, errors.WithStack(err)
+	}
+	return g, verrs, storage.PutBytes(storage.Default(), g.SourcePath, source)
+}
+
+// QuestionGenerators returns the generators of a question by name.
+func QuestionGenerators(tx *pop.Connection, questionID uuid.UUID) (Generators, error) {
+	generators := Generators{}
+	if err := tx.Where("question_id = ?", questionID).Order("name asc").All(&generators); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return generators, nil
+}
+
+// Download copies the generator source into dir and returns its path.
+func (g Generator) Download(dir string) (string, error) {
+	dst := filepath.Join(dir, path.Base(g.SourcePath))
+	return dst, storage.DownloadFile(storage.Default(), g.SourcePath, dst)
+}
+
+// Delete removes the generator and its source.
+func (g *Generator) Delete(tx *pop.Connection) error {
+	if err := tx.Destroy(g); err != nil {
+		return errors.WithStack(err)
+	}
+	return storage.Default().Delete(g.SourcePath)
+}
+
+// DeleteGenerators removes the generators of the question.
+func (q Question) DeleteGenerators(tx *pop.Connection) error {
+	generators, err := QuestionGenerators(tx, q.ID)
+	if err != nil {
+		return err
+	}
+	for i := range generators {
+		if err := generators[i].Delete(tx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GeneratorCall is a line of a generator script: the generator making a
+// test and its arguments. Test is the name of the input it makes.
+type GeneratorCall struct {
+	Line      int
+	Test      string
+	Generator string
+	Args      []string
+}
+
+// String returns the call as written in the script.
+func (c GeneratorCall) String() string {
+	return strings.Join(append([]string{c.Generator}, c.Args...), " ")
+}
+
+// ParseGeneratorScript reads a generator script. Each line calls a
+// generator with arguments separated by spaces, such as "random 1000 42",
+// and makes one test; blank lines and lines starting with # are skipped.
+// Tests are named 01.txt, 02.txt and so on, in the order of the script.
+func ParseGeneratorScript(script string) ([]GeneratorCall, error) {
+	calls := []GeneratorCall{}
+	for i, line := range strings.Split(script, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if !generatorName.MatchString(fields[0]) {
+			return nil, errors.Errorf("Line %d: %q is not a generator name.", i+1, fields[0])
+		}
+		calls = append(calls, GeneratorCall{Line: i + 1, Generator: fields[0], Args: fields[1:]})
+	}
+	if len(calls) > MaxGeneratedTests {
+		return nil, errors.Errorf("Generator scripts can make at most %d tests.", MaxGeneratedTests)
+	}
+	width := len(strconv.Itoa(len(calls)))
+	if width < 2 {
+		width = 2
+	}
+	for i := range calls {
+		calls[i].Test = fmt.Sprintf("%0*d.txt", width, i+1)
+	}
+	return calls, nil
+}
+
+// StoreGeneratorScript saves the question's generator script.
+func (q *Question) StoreGeneratorScript(tx *pop.Connection, script string) error {
+	q.GeneratorScript = script
+	return updateQuestionColumns(tx, q.ID, map[string]interface{}{"generator_script": script})
+}
@@ -0,0 +1,60 @@
+package models_test
+
+import (
+	"io/ioutil"
+	"os"
+
+	"github.com/cpjudge/cpjudge/models"
+	"github.com/cpjudge/cpjudge/storage"
+)
+
+func (ms *ModelSuite) Test_AddGenerator() {
+	dir, err := ioutil.TempDir("", "storage")
+	ms.NoError(err)
+	defer os.RemoveAll(dir)
+	store := storage.NewLocal(dir)
+	storage.Set(store)
+	defer storage.Set(nil)
+
+	contest := ms.pastContest(0)
+	q := &models.Question{Title: "Sum", Description: "Add", ContestID: contest.ID}
+	ms.NoError(ms.DB.Create(q))
+
+	_, verrs, err := models.AddGenerator(ms.DB, q.ID, "gen.py", nil)
+	ms.NoError(err)
+	ms.True(verrs.HasAny())
+	_, verrs, err = models.AddGenerator(ms.DB, q.ID, "my gen.c", nil)
+	ms.NoError(err)
+	ms.True(verrs.HasAny())
+
+	first, verrs, err := models.AddGenerator(ms.DB, q.ID, "random.c", []byte("// v1"))
+	ms.NoError(err)
+	ms.False(verrs.HasAny())
+	second, verrs, err := models.AddGenerator(ms.DB, q.ID, "random.cpp", []byte("// v2"))
+	ms.NoError(err)
+	ms.False(verrs.HasAny())
+	generators, err := models.QuestionGenerators(ms.DB, q.ID)
+	ms.NoError(err)
+	ms.Len(generators, 1)
+	ms.Equal(second.ID, generators[0].ID)
+	ms.Equal("random", generators[0].Name)
+	_, err = store.Get(first.SourcePath)
+	ms.Equal(storage.ErrNotFound, err)
+
+	ms.NoError(q.DeleteGenerators(ms.DB))
+	_, err = store.Get(second.SourcePath)
+	ms.Equal(storage.ErrNotFound, err)
+}
+
+func (ms *ModelSuite) Test_ParseGeneratorScript() {
+	calls, err := models.ParseGeneratorScript("# samples\nsmall 1\n\n  random 100 42  \n")
+	ms.NoError(err)
+	ms.Equal([]models.GeneratorCall{
+		{Line: 2, Test: "01.txt", Generator: "small", Args: []string{"1"}},
+		{Line: 4, Test: "02.txt", Generator: "random", Args: []string{"100", "42"}},
+	}, calls)
+	ms.Equal("random 100 42", calls[1].String())
+
+	_, err = models.ParseGeneratorScript("small 1\n./gen 2\n")
+	ms.EqualError(err, `Line 2: "./gen" is not a generator name.`)
+}
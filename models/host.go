@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/gobuffalo/pop"
+	"github.com/gobuffalo/pop/nulls"
 	"github.com/gobuffalo/uuid"
 	"github.com/gobuffalo/validate"
 	"github.com/gobuffalo/validate/validators"
@@ -25,6 +26,12 @@ type Host struct {
 	PasswordHash    string    `json:"-" db:"password_hash"`
 	Password        string    `json:"-" db:"-"`
 	PasswordConfirm string    `json:"-" db:"-"`
+
+	Verified              bool       `json:"-" db:"verified"`
+	VerificationTokenHash string     `json:"-" db:"verification_token_hash"`
+	VerificationToken     string     `json:"-" db:"-"`
+	ResetTokenHash        string     `json:"-" db:"reset_token_hash"`
+	ResetTokenExpiresAt   nulls.Time `json:"-" db:"reset_token_expires_at"`
 }
 
 // String is not required by pop and may be deleted
@@ -111,6 +118,13 @@ func (h *Host) Create(tx *pop.Connection) (*validate.Errors, error) {
 		return validate.NewErrors(), errors.WithStack(err)
 	}
 	h.PasswordHash = string(pwdHash)
+	h.Verified = false
+	token, hash, err := NewToken()
+	if err != nil {
+		return validate.NewErrors(), errors.WithStack(err)
+	}
+	h.VerificationToken = token
+	h.VerificationTokenHash = hash
 	return tx.ValidateAndCreate(h)
 }
 
@@ -129,5 +143,98 @@ func (u *Host) Authorize(tx *pop.Connection) error {
 	if err != nil {
 		return errors.New("Invalid password.")
 	}
+	if !u.Verified {
+		return ErrNotVerified
+	}
 	return nil
 }
+
+// RefreshVerificationToken replaces the host's verification token with a new
+// one. The plain token is left in VerificationToken so it can be mailed.
+func (h *Host) RefreshVerificationToken(tx *pop.Connection) error {
+	token, hash, err := NewToken()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	h.VerificationToken = token
+	h.VerificationTokenHash = hash
+	return errors.WithStack(tx.Update(h))
+}
+
+// VerifyHost marks the host owning the given verification token as verified.
+func VerifyHost(tx *pop.Connection, token string) (*Host, error) {
+	h := &Host{}
+	if token == "" {
+		return h, ErrInvalidToken
+	}
+	err := tx.Where("verification_token_hash = ?", HashToken(token)).First(h)
+	if err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return h, ErrInvalidToken
+		}
+		return h, errors.WithStack(err)
+	}
+	h.Verified = true
+	h.VerificationTokenHash = ""
+	return h, errors.WithStack(tx.Update(h))
+}
+
+// StartPasswordReset looks up the host by email and stores a new reset token
+// valid for PasswordResetTTL. The plain token is returned so it can be mailed.
+func (h *Host) StartPasswordReset(tx *pop.Connection) (string, error) {
+	err := tx.Where("email = ?", strings.ToLower(h.Email)).First(h)
+	if err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return "", ErrNoAccount
+		}
+		return "", errors.WithStack(err)
+	}
+	token, hash, err := NewToken()
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	h.ResetTokenHash = hash
+	h.ResetTokenExpiresAt = nulls.NewTime(time.Now().Add(PasswordResetTTL))
+	return token, errors.WithStack(tx.Update(h))
+}
+
+// FindHostByResetToken returns the host owning an unexpired reset token.
+func FindHostByResetToken(tx *pop.Connection, token string) (*Host, error) {
+	h := &Host{}
+	if token == "" {
+		return h, ErrInvalidToken
+	}
+	err := tx.Where("reset_token_hash = ?", HashToken(token)).First(h)
+	if err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return h, ErrInvalidToken
+		}
+		return h, errors.WithStack(err)
+	}
+	if !h.ResetTokenExpiresAt.Valid || time.Now().After(h.ResetTokenExpiresAt.Time) {
+		return h, ErrInvalidToken
+	}
+	return h, nil
+}
+
+// ResetPassword sets a new password and invalidates the reset token. Following
+// a reset link also proves ownership of the email address.
+func (h *Host) ResetPassword(tx *pop.Connection) (*validate.Errors, error) {
+	verrs := validate.Validate(
+		&validators.StringIsPresent{Field: h.Password, Name: "Password"},
+		&validators.StringsMatch{Name: "Password", Field: h.Password, Field2: h.PasswordConfirm, Message: "Passwords do not match."},
+	)
+	if verrs.HasAny() {
+		return verrs, nil
+	}
+	pwdHash, err := bcrypt.GenerateFromPassword([]byte(h.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return verrs, errors.WithStack(err)
+	}
+	h.PasswordHash = string(pwdHash)
+	h.ResetTokenHash = ""
+	h.ResetTokenExpiresAt = nulls.Time{}
+	h.Verified = true
+	h.VerificationTokenHash = ""
+	return verrs, errors.WithStack(tx.Update(h))
+}
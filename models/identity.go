@@ -0,0 +1,175 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/pop"
+	"github.com/gobuffalo/uuid"
+	"github.com/gobuffalo/validate"
+	"github.com/gobuffalo/validate/validators"
+	"github.com/pkg/errors"
+)
+
+// Identity links a user to an account at an external login provider.
+type Identity struct {
+	ID             uuid.UUID `json:"id" db:"id"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at" db:"updated_at"`
+	UserID         uuid.UUID `json:"user_id" db:"user_id"`
+	Provider       string    `json:"provider" db:"provider"`
+	ProviderUserID string    `json:"provider_user_id" db:"provider_user_id"`
+	Email          string    `json:"email" db:"email"`
+}
+
+type Identities []Identity
+
+// Validate gets run every time you call a "pop.Validate*" (pop.ValidateAndSave, pop.ValidateAndCreate, pop.ValidateAndUpdate) method.
+func (i *Identity) Validate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.Validate(
+		&validators.StringIsPresent{Field: i.Provider, Name: "Provider"},
+		&validators.StringIsPresent{Field: i.ProviderUserID, Name: "ProviderUserID"},
+	), nil
+}
+
+// ExternalUser is what an external login provider reports about an account.
+type ExternalUser struct {
+	Provider       string
+	ProviderUserID string
+	Email          string
+	EmailVerified  bool
+	Nickname       string
+}
+
+// ErrExternalEmailNotVerified is returned when an external account would have
+// to be matched to a user by an email address the provider has not verified.
+var ErrExternalEmailNotVerified = errors.New("Your email address at the login provider is not verified. Log in with your password and link the account from there.")
+
+// UserFromExternal returns the user an external account belongs to. Known
+// identities are looked up directly. Otherwise the account is linked to the
+// user with the same verified email address, or a new user is created. An
+// unverified user with the address loses their password: whoever chose it
+// may not own the address.
+func UserFromExternal(tx *pop.Connection, eu ExternalUser) (*User, error) {
+	u := &User{}
+	identity := &Identity{}
+	err := tx.Where("provider = ? and provider_user_id = ?", eu.Provider, eu.ProviderUserID).First(identity)
+	if err == nil {
+		return u, errors.WithStack(tx.Find(u, identity.UserID))
+	}
+	if errors.Cause(err) != sql.ErrNoRows {
+		return u, errors.WithStack(err)
+	}
+
+	if eu.Email == "" || !eu.EmailVerified {
+		return u, ErrExternalEmailNotVerified
+	}
+	err = tx.Where("email = ?", strings.ToLower(eu.Email)).First(u)
+	switch {
+	case errors.Cause(err) == sql.ErrNoRows:
+		if err := u.createFromExternal(tx, eu); err != nil {
+			return u, err
+		}
+	case err != nil:
+		return u, errors.WithStack(err)
+	case !u.Verified:
+		// Anyone can sign up with an address before its owner does. The
+		// password they chose must stop working once the owner proves the
+		// address is theirs.
+		if err := u.resetUnverifiedPassword(tx); err != nil {
+			return u, err
+		}
+	}
+	return u, u.LinkIdentity(tx, eu)
+}
+
+// resetUnverifiedPassword replaces the password of an unverified user with
+// a random one and marks them verified. They can set a password later
+// through password reset.
+func (u *User) resetUnverifiedPassword(tx *pop.Connection) error {
+	password, _, err := NewToken()
+	if err != nil {
+		return err
+	}
+	u.Password = password
+	u.PasswordConfirm = password
+	verrs, err := u.ResetPassword(tx)
+	if err != nil {
+		return err
+	}
+	if verrs.HasAny() {
+		return errors.New(verrs.Error())
+	}
+	return nil
+}
+
+// LinkIdentity attaches an external account to the user. A verified email
+// from the provider that matches the user's also verifies the user.
+func (u *User) LinkIdentity(tx *pop.Connection, eu ExternalUser) error {
+	identity := &Identity{
+		UserID:         u.ID,
+		Provider:       eu.Provider,
+		ProviderUserID: eu.ProviderUserID,
+		Email:          strings.ToLower(eu.Email),
+	}
+	verrs, err := tx.ValidateAndCreate(identity)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if verrs.HasAny() {
+		return errors.New(verrs.Error())
+	}
+	if !u.Verified && eu.EmailVerified && strings.EqualFold(eu.Email, u.Email) {
+		u.Verified = true
+		u.VerificationTokenHash = ""
+		return errors.WithStack(tx.Update(u))
+	}
+	return nil
+}
+
+var usernameInvalidChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+// createFromExternal creates a verified user for an external account. The
+// user gets a random password and can set one later through password reset.
+func (u *User) createFromExternal(tx *pop.Connection, eu ExternalUser) error {
+	base := usernameInvalidChars.ReplaceAllString(eu.Nickname, "")
+	if base == "" {
+		base = usernameInvalidChars.ReplaceAllString(strings.Split(eu.Email, "@")[0], "")
+	}
+	if base == "" {
+		base = "user"
+	}
+	username := base
+	for i := 2; ; i++ {
+		exists, err := tx.Where("username = ?", username).Exists(&User{})
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if !exists {
+			break
+		}
+		username = fmt.Sprintf("%s%d", base, i)
+	}
+
+	password, _, err := NewToken()
+	if err != nil {
+		return err
+	}
+	u.Username = username
+	u.Email = eu.Email
+	u.Password = password
+	u.PasswordConfirm = password
+	verrs, err := u.Create(tx)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if verrs.HasAny() {
+		return errors.New(verrs.Error())
+	}
+	u.Verified = true
+	u.VerificationTokenHash = ""
+	return errors.WithStack(tx.Update(u))
+}
@@ -0,0 +1,36 @@
+package models_test
+
+import (
+	"github.com/cpjudge/cpjudge/models"
+)
+
+func (ms *ModelSuite) Test_UserFromExternal() {
+	u := &models.User{Username: "ada", Email: "ada@example.com", Password: "secret", PasswordConfirm: "secret"}
+	_, err := u.Create(ms.DB)
+	ms.NoError(err)
+
+	eu := models.ExternalUser{Provider: "github", ProviderUserID: "1", Email: "ADA@example.com", Nickname: "ada"}
+	_, err = models.UserFromExternal(ms.DB, eu)
+	ms.Equal(models.ErrExternalEmailNotVerified, err)
+
+	eu.EmailVerified = true
+	linked, err := models.UserFromExternal(ms.DB, eu)
+	ms.NoError(err)
+	ms.Equal(u.ID, linked.ID)
+	ms.True(linked.Verified)
+	// The password chosen before the address was verified no longer works.
+	ms.NoError(ms.DB.Reload(linked))
+	ms.NotEqual(u.PasswordHash, linked.PasswordHash)
+
+	again, err := models.UserFromExternal(ms.DB, eu)
+	ms.NoError(err)
+	ms.Equal(u.ID, again.ID)
+
+	created, err := models.UserFromExternal(ms.DB, models.ExternalUser{
+		Provider: "google", ProviderUserID: "2", Email: "grace@example.com", EmailVerified: true, Nickname: "ada",
+	})
+	ms.NoError(err)
+	ms.NotEqual(u.ID, created.ID)
+	ms.Equal("ada2", created.Username)
+	ms.True(created.Verified)
+}
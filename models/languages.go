@@ -0,0 +1,27 @@
+package models
+
+// Language is a programming language submissions can be written in.
+type Language struct {
+	Name string `json:"name"`
+	// Extension is the file extension of sources, without the dot.
+	Extension string `json:"extension"`
+	// Highlight is the highlight.js language class.
+	Highlight string `json:"highlight"`
+}
+
+// Languages lists the supported languages. The first one is the default.
+var Languages = []Language{
+	{Name: "C", Extension: "c", Highlight: "c"},
+	{Name: "C++", Extension: "cpp", Highlight: "cpp"},
+}
+
+// FindLanguage returns the language with the given name, or the default
+// language if there is none.
+func FindLanguage(name string) Language {
+	for _, l := range Languages {
+		if l.Name == name {
+			return l
+		}
+	}
+	return Languages[0]
+}
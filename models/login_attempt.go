@@ -0,0 +1,126 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/pop"
+	"github.com/gobuffalo/uuid"
+	"github.com/pkg/errors"
+)
+
+// LoginAttempt is the audit record of a password login or of a
+// second-factor code, whose account types end in "_two_factor".
+type LoginAttempt struct {
+	ID          uuid.UUID `json:"id" db:"id"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+	AccountType string    `json:"account_type" db:"account_type"`
+	Email       string    `json:"email" db:"email"`
+	IP          string    `json:"ip" db:"ip"`
+	Success     bool      `json:"success" db:"success"`
+}
+
+type LoginAttempts []LoginAttempt
+
+// LoginPolicy describes how failed logins are throttled. The first
+// FreeFailures failures are not delayed, after that every failure doubles
+// the wait before the next attempt up to MaxDelay. LockoutFailures failures
+// within Window lock the key out for LockoutDuration.
+type LoginPolicy struct {
+	FreeFailures    int
+	MaxDelay        time.Duration
+	LockoutFailures int
+	LockoutDuration time.Duration
+	Window          time.Duration
+}
+
+// AccountLoginPolicy throttles attempts against a single account.
+var AccountLoginPolicy = LoginPolicy{
+	FreeFailures:    3,
+	MaxDelay:        time.Minute,
+	LockoutFailures: 10,
+	LockoutDuration: 15 * time.Minute,
+	Window:          15 * time.Minute,
+}
+
+// IPLoginPolicy throttles attempts from a single address across accounts.
+var IPLoginPolicy = LoginPolicy{
+	FreeFailures:    10,
+	MaxDelay:        time.Minute,
+	LockoutFailures: 50,
+	LockoutDuration: time.Hour,
+	Window:          time.Hour,
+}
+
+// Wait returns how long to wait after the given number of recent failures,
+// the last of which happened at last.
+func (p LoginPolicy) Wait(failures int, last, now time.Time) time.Duration {
+	var delay time.Duration
+	switch {
+	case failures >= p.LockoutFailures:
+		delay = p.LockoutDuration
+	case failures > p.FreeFailures:
+		delay = time.Second << uint(failures-p.FreeFailures-1)
+		if delay > p.MaxDelay || delay <= 0 {
+			delay = p.MaxDelay
+		}
+	default:
+		return 0
+	}
+	if wait := last.Add(delay).Sub(now); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// RecordLoginAttempt stores the outcome of a login attempt.
+func RecordLoginAttempt(tx *pop.Connection, accountType, email, ip string, success bool) error {
+	return errors.WithStack(tx.Create(&LoginAttempt{
+		AccountType: accountType,
+		Email:       strings.ToLower(email),
+		IP:          ip,
+		Success:     success,
+	}))
+}
+
+// LoginWait returns how long the next login attempt for an account from an
+// address has to wait. Zero means the attempt may proceed.
+func LoginWait(tx *pop.Connection, accountType, email, ip string) (time.Duration, error) {
+	now := time.Now()
+	accountWait, err := loginWait(tx, AccountLoginPolicy, now,
+		"account_type = ? and email = ?", accountType, strings.ToLower(email))
+	if err != nil {
+		return 0, err
+	}
+	ipWait, err := loginWait(tx, IPLoginPolicy, now, "ip = ?", ip)
+	if err != nil {
+		return 0, err
+	}
+	if ipWait > accountWait {
+		return ipWait, nil
+	}
+	return accountWait, nil
+}
+
+// loginWait counts the failures matching where since the last success
+// within the policy window.
+func loginWait(tx *pop.Connection, p LoginPolicy, now time.Time, where string, args ...interface{}) (time.Duration, error) {
+	attempts := LoginAttempts{}
+	args = append(args, now.Add(-p.Window))
+	err := tx.Where(where+" and created_at > ?", args...).Order("created_at desc").All(&attempts)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	failures := 0
+	for _, a := range attempts {
+		if a.Success {
+			break
+		}
+		failures++
+	}
+	if failures == 0 {
+		return 0, nil
+	}
+	return p.Wait(failures, attempts[0].CreatedAt, now), nil
+}
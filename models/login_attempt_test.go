@@ -0,0 +1,36 @@
+package models_test
+
+import (
+	"time"
+
+	"github.com/cpjudge/cpjudge/models"
+)
+
+func (ms *ModelSuite) Test_LoginPolicy_Wait() {
+	p := models.AccountLoginPolicy
+	now := time.Now()
+	ms.Equal(time.Duration(0), p.Wait(p.FreeFailures, now, now))
+	ms.Equal(time.Second, p.Wait(p.FreeFailures+1, now, now))
+	ms.Equal(4*time.Second, p.Wait(p.FreeFailures+3, now, now))
+	ms.Equal(p.MaxDelay, p.Wait(p.LockoutFailures-1, now, now))
+	ms.Equal(p.LockoutDuration, p.Wait(p.LockoutFailures, now, now))
+	ms.Equal(time.Duration(0), p.Wait(p.LockoutFailures, now.Add(-p.LockoutDuration), now))
+}
+
+func (ms *ModelSuite) Test_LoginWait() {
+	for i := 0; i < models.AccountLoginPolicy.LockoutFailures; i++ {
+		ms.NoError(models.RecordLoginAttempt(ms.DB, "user", "Ada@example.com", "10.0.0.1", false))
+	}
+	wait, err := models.LoginWait(ms.DB, "user", "ada@example.com", "10.0.0.2")
+	ms.NoError(err)
+	ms.True(wait > models.AccountLoginPolicy.MaxDelay)
+
+	wait, err = models.LoginWait(ms.DB, "host", "ada@example.com", "10.0.0.2")
+	ms.NoError(err)
+	ms.Equal(time.Duration(0), wait)
+
+	ms.NoError(models.RecordLoginAttempt(ms.DB, "user", "ada@example.com", "10.0.0.1", true))
+	wait, err = models.LoginWait(ms.DB, "user", "ada@example.com", "10.0.0.2")
+	ms.NoError(err)
+	ms.Equal(time.Duration(0), wait)
+}
@@ -0,0 +1,151 @@
+package models
+
+import (
+	"database/sql"
+	"sort"
+	"time"
+
+	"github.com/gobuffalo/pop"
+	"github.com/gobuffalo/uuid"
+	"github.com/pkg/errors"
+)
+
+// HeatmapWeeks is how many weeks of activity a profile shows.
+const HeatmapWeeks = 53
+
+// Count is a labelled counter, used for verdict and language breakdowns.
+type Count struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// ActivityDay is one cell of the activity heatmap.
+type ActivityDay struct {
+	Date  time.Time `json:"date"`
+	Count int       `json:"count"`
+	Level int       `json:"level"`
+}
+
+// ContestRank is the final place of a user in a contest.
+type ContestRank struct {
+	Contest      Contest `json:"contest"`
+	Rank         int     `json:"rank"`
+	Participants int     `json:"participants"`
+}
+
+// Profile gathers the statistics shown on a user's public profile.
+type Profile struct {
+	User        User            `json:"user"`
+	Submissions int             `json:"submissions"`
+	Solved      int             `json:"solved"`
+	Verdicts    []Count         `json:"verdicts"`
+	Languages   []Count         `json:"languages"`
+	Activity    [][]ActivityDay `json:"activity"`
+	Ranks       []ContestRank   `json:"ranks"`
+	// RatingHistory lists the rated contests of the user, oldest first.
+	RatingHistory []RatingHistoryEntry `json:"rating_history"`
+}
+
+// UserProfile computes the profile statistics of a user from their
+// submissions.
+func UserProfile(tx *pop.Connection, user User) (*Profile, error) {
+	submissions := Submissions{}
+	if err := tx.Where("user_id = ?", user.ID).Order("created_at asc").All(&submissions); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	p := &Profile{User: user, Submissions: len(submissions)}
+	verdicts := map[string]int{}
+	languages := map[string]int{}
+	solved := map[uuid.UUID]bool{}
+	daily := map[string]int{}
+	contestIDs := []uuid.UUID{}
+	seenContests := map[uuid.UUID]bool{}
+	for _, s := range submissions {
+		verdicts[s.Status]++
+		languages[s.Language]++
+		if s.Status == StatusCorrect {
+			solved[s.QuestionID] = true
+		}
+		daily[s.CreatedAt.UTC().Format("2006-01-02")]++
+		if !seenContests[s.ContestID] {
+			seenContests[s.ContestID] = true
+			contestIDs = append(contestIDs, s.ContestID)
+		}
+	}
+	p.Solved = len(solved)
+	p.Verdicts = sortedCounts(verdicts)
+	p.Languages = sortedCounts(languages)
+	p.Activity = activityWeeks(daily, time.Now().UTC())
+
+	for _, cid := range contestIDs {
+		contest := Contest{}
+		if err := tx.Find(&contest, cid); err != nil {
+			if errors.Cause(err) == sql.ErrNoRows {
+				continue
+			}
+			return nil, errors.WithStack(err)
+		}
+		standings, err := ContestStandings(tx, cid)
+		if err != nil {
+			return nil, err
+		}
+		if row, ok := standings.Find(user.ID); ok {
+			p.Ranks = append(p.Ranks, ContestRank{Contest: contest, Rank: row.Rank, Participants: len(standings)})
+		}
+	}
+
+	history, err := UserRatingHistory(tx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	p.RatingHistory = history
+	return p, nil
+}
+
+func sortedCounts(m map[string]int) []Count {
+	counts := []Count{}
+	for name, n := range m {
+		counts = append(counts, Count{Name: name, Count: n})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return counts[i].Name < counts[j].Name
+	})
+	return counts
+}
+
+// activityWeeks lays out daily submission counts as columns of weeks,
+// Sunday first, ending with the week containing today.
+func activityWeeks(daily map[string]int, today time.Time) [][]ActivityDay {
+	today = time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.UTC)
+	start := today.AddDate(0, 0, -int(today.Weekday())-7*(HeatmapWeeks-1))
+	weeks := make([][]ActivityDay, HeatmapWeeks)
+	for w := range weeks {
+		for d := 0; d < 7; d++ {
+			date := start.AddDate(0, 0, 7*w+d)
+			if date.After(today) {
+				break
+			}
+			n := daily[date.Format("2006-01-02")]
+			weeks[w] = append(weeks[w], ActivityDay{Date: date, Count: n, Level: activityLevel(n)})
+		}
+	}
+	return weeks
+}
+
+func activityLevel(n int) int {
+	switch {
+	case n == 0:
+		return 0
+	case n < 3:
+		return 1
+	case n < 6:
+		return 2
+	case n < 10:
+		return 3
+	}
+	return 4
+}
@@ -0,0 +1,66 @@
+package models_test
+
+import (
+	"github.com/cpjudge/cpjudge/models"
+	"github.com/gobuffalo/uuid"
+)
+
+func (ms *ModelSuite) createUser(username string) *models.User {
+	u := &models.User{Username: username, Email: username + "@example.com", Password: "secret", PasswordConfirm: "secret"}
+	verrs, err := u.Create(ms.DB)
+	ms.NoError(err)
+	ms.False(verrs.HasAny())
+	return u
+}
+
+func (ms *ModelSuite) createSubmission(user *models.User, contestID, questionID uuid.UUID, status, language string) {
+	ms.NoError(ms.DB.Create(&models.Submission{
+		UserID:     user.ID,
+		ContestID:  contestID,
+		QuestionID: questionID,
+		Status:     status,
+		Language:   language,
+	}))
+}
+
+func (ms *ModelSuite) Test_ContestStandings() {
+	contestID := uuid.Must(uuid.NewV4())
+	q1, q2 := uuid.Must(uuid.NewV4()), uuid.Must(uuid.NewV4())
+	ada, bob, cid := ms.createUser("ada"), ms.createUser("bob"), ms.createUser("cid")
+	ms.createSubmission(ada, contestID, q1, models.StatusCorrect, "C")
+	ms.createSubmission(ada, contestID, q2, models.StatusCorrect, "C")
+	ms.createSubmission(bob, contestID, q1, models.StatusWrong, "C")
+	ms.createSubmission(bob, contestID, q1, models.StatusCorrect, "C")
+	ms.createSubmission(cid, contestID, q2, models.StatusCorrect, "C")
+	ms.createSubmission(cid, contestID, q2, models.StatusTimeLimit, "C")
+
+	standings, err := models.ContestStandings(ms.DB, contestID)
+	ms.NoError(err)
+	ms.Len(standings, 3)
+	ms.Equal("ada", standings[0].Username)
+	ms.Equal(1, standings[0].Rank)
+	ms.Equal(2, standings[1].Rank)
+	ms.Equal(2, standings[2].Rank)
+}
+
+func (ms *ModelSuite) Test_UserProfile() {
+	contest := &models.Contest{Title: "Weekly", Description: "Weekly contest"}
+	ms.NoError(ms.DB.Create(contest))
+	q1, q2 := uuid.Must(uuid.NewV4()), uuid.Must(uuid.NewV4())
+	ada := ms.createUser("ada")
+	ms.createSubmission(ada, contest.ID, q1, models.StatusWrong, "C")
+	ms.createSubmission(ada, contest.ID, q1, models.StatusCorrect, "C")
+	ms.createSubmission(ada, contest.ID, q2, models.StatusCorrect, "C++")
+
+	p, err := models.UserProfile(ms.DB, *ada)
+	ms.NoError(err)
+	ms.Equal(3, p.Submissions)
+	ms.Equal(2, p.Solved)
+	ms.Equal(models.Count{Name: models.StatusCorrect, Count: 2}, p.Verdicts[0])
+	ms.Equal(models.Count{Name: "C", Count: 2}, p.Languages[0])
+	ms.Len(p.Activity, models.HeatmapWeeks)
+	last := p.Activity[len(p.Activity)-1]
+	ms.Equal(3, last[len(last)-1].Count)
+	ms.Len(p.Ranks, 1)
+	ms.Equal(1, p.Ranks[0].Rank)
+}
@@ -1,13 +1,12 @@
 package models
 
 import (
-	"archive/zip"
-	"fmt"
-	"io"
-	"os"
-	"path/filepath"
+	"io/ioutil"
+	"sort"
+	"strings"
 	"time"
 
+	"github.com/cpjudge/cpjudge/storage"
 	"github.com/gobuffalo/buffalo/binding"
 	"github.com/gobuffalo/pop"
 	"github.com/gobuffalo/uuid"
@@ -16,108 +15,191 @@ import (
 	"github.com/pkg/errors"
 )
 
+// Question is a problem of a contest. Its statement is in sections: the
+// Description is the legend, followed by the input and output formats, the
+// samples and the notes. Sections are markdown with LaTeX math.
 type Question struct {
 	ID               uuid.UUID    `json:"id" db:"id"`
 	CreatedAt        time.Time    `json:"created_at" db:"created_at"`
 	UpdatedAt        time.Time    `json:"updated_at" db:"updated_at"`
 	Title            string       `json:"title" db:"title"`
 	Description      string       `json:"description" db:"description"`
+	InputFormat      string       `json:"input_format" db:"input_format"`
+	OutputFormat     string       `json:"output_format" db:"output_format"`
+	Notes            string       `json:"notes" db:"notes"`
 	ContestID        uuid.UUID    `json:"contest_id" db:"contest_id"`
 	Contest          Contest      `json:"-" db:"-"`
 	TestCasesZipFile binding.File `json:"test_cases_zip_file" db:"-" form:"TestCasesZipFile"`
 	TestCasesPath    string       `json:"testcases_path" db:"testcases_path"`
+	TestCasesSum     string       `json:"testcases_checksum" db:"testcases_checksum"`
+	Difficulty       int          `json:"difficulty" db:"difficulty"`
+	Tags             string       `json:"tags" db:"tags"`
+	TimeLimit        int          `json:"time_limit" db:"time_limit"`
+	MemoryLimit      int          `json:"memory_limit" db:"memory_limit"`
+	Samples          string       `json:"samples" db:"samples"`
+	Checker          string       `json:"checker" db:"checker"`
+	ValidatorFile    binding.File `json:"-" db:"-" form:"ValidatorFile"`
+	ValidatorPath    string       `json:"validator_path" db:"validator_path"`
+	GeneratorScript  string       `json:"generator_script" db:"generator_script"`
+	SolvedCount      int          `json:"solved_count" db:"-"`
+	SolvedByMe       bool         `json:"-" db:"-"`
+	HasEditorial     bool         `json:"-" db:"-"`
 }
 
 type Questions []Question
 
-func (q *Question) AfterSave(tx *pop.Connection) error {
+// MaxDifficulty is the hardest difficulty a question can be given.
+const MaxDifficulty = 3500
 
-	if !q.TestCasesZipFile.Valid() {
-		fmt.Printf("\n\nZip file is not valid\n\n")
-		return nil
-	}
-	dir := filepath.Join("..", "testcases")
-
-	fmt.Println(dir)
-
-	fmt.Printf("\n\nFile path %q\n\n", dir)
+// Limits of a question. TimeLimit is in milliseconds and MemoryLimit in
+// megabytes, per test case. Questions without limits get the defaults.
+const (
+	DefaultTimeLimit   = 3000
+	DefaultMemoryLimit = 256
+	MaxTimeLimit       = 20000
+	MaxMemoryLimit     = 1024
+)
 
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return errors.WithStack(err)
+// EffectiveTimeLimit returns the time limit in milliseconds.
+func (q Question) EffectiveTimeLimit() int {
+	if q.TimeLimit > 0 {
+		return q.TimeLimit
 	}
+	return DefaultTimeLimit
+}
 
-	testCaseZipFileName := "testcase_" + q.ID.String() + ".zip"
-	f, err := os.Create(filepath.Join(dir, testCaseZipFileName))
-	if err != nil {
-		return errors.WithStack(err)
-	}
-	defer f.Close()
-	_, err = io.Copy(f, q.TestCasesZipFile)
-	if err != nil {
-		return errors.WithStack(err)
+// EffectiveMemoryLimit returns the memory limit in megabytes.
+func (q Question) EffectiveMemoryLimit() int {
+	if q.MemoryLimit > 0 {
+		return q.MemoryLimit
 	}
+	return DefaultMemoryLimit
+}
 
-	zipReader, err := zip.OpenReader(dir + "/" + testCaseZipFileName)
-	if err != nil {
-		fmt.Println("Zip file could not be opened")
-		return errors.WithStack(err)
+// TagList returns the question's comma separated tags.
+func (q Question) TagList() []string {
+	tags := []string{}
+	for _, tag := range strings.Split(q.Tags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
 	}
-	for _, file := range zipReader.Reader.File {
+	return tags
+}
 
-		zippedFile, err := file.Open()
+// LoadSolvedCounts fills in how many users solved each question and whether
+// userID is one of them. Pass uuid.Nil when nobody is logged in.
+func (qs Questions) LoadSolvedCounts(tx *pop.Connection, userID uuid.UUID) error {
+	for i := range qs {
+		row := struct {
+			Count int `db:"count"`
+		}{}
+		err := tx.RawQuery("SELECT COUNT(DISTINCT user_id) AS count FROM submissions WHERE question_id = ? AND status = ?",
+			qs[i].ID, StatusCorrect).First(&row)
 		if err != nil {
-			fmt.Println("File could not be opened")
 			return errors.WithStack(err)
 		}
-		defer zippedFile.Close()
-
-		extractedFilePath := filepath.Join(dir, file.Name)
-
-		if file.FileInfo().IsDir() {
-			os.MkdirAll(extractedFilePath, file.Mode())
-		} else {
-
-			outputFile, err := os.OpenFile(
-				extractedFilePath,
-				os.O_WRONLY|os.O_CREATE|os.O_TRUNC,
-				file.Mode(),
-			)
+		qs[i].SolvedCount = row.Count
+		if userID != uuid.Nil {
+			solved, err := tx.Where("question_id = ? and user_id = ? and status = ?", qs[i].ID, userID, StatusCorrect).Exists(&Submission{})
 			if err != nil {
-				fmt.Println("File could not be edited")
-				return errors.WithStack(err)
-			}
-			defer outputFile.Close()
-
-			_, err = io.Copy(outputFile, zippedFile)
-			if err != nil {
-				fmt.Println("File could not be copied")
 				return errors.WithStack(err)
 			}
+			qs[i].SolvedByMe = solved
 		}
 	}
+	return nil
+}
 
-	err = os.Remove("../testcases/" + testCaseZipFileName)
-	if err != nil {
-		return errors.WithStack(err)
+// TestCasesKey is where test cases were kept before they were versioned.
+func (q Question) TestCasesKey() string {
+	return "testcases/testcase_" + q.ID.String()
+}
+
+// updateQuestionColumns writes columns of a question directly. Saving the
+// question instead would run AfterSave again, and store its uploads twice.
+func updateQuestionColumns(tx *pop.Connection, id uuid.UUID, columns map[string]interface{}) error {
+	names := make([]string, 0, len(columns))
+	for name := range columns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	args := make([]interface{}, 0, len(columns)+1)
+	for i, name := range names {
+		args = append(args, columns[name])
+		names[i] = name + " = ?"
 	}
+	args = append(args, id)
+	err := tx.RawQuery("UPDATE questions SET "+strings.Join(names, ", ")+" WHERE id = ?", args...).Exec()
+	return errors.WithStack(err)
+}
+
+// BeforeSave normalizes the tags.
+func (q *Question) BeforeSave(tx *pop.Connection) error {
+	q.Tags = NormalizeTags(q.Tags)
+	return nil
+}
 
-	err = os.RemoveAll("../testcases/testcase_" + q.ID.String())
+// AfterSave adds new tags to the vocabulary, and stores an uploaded
+// validator and an uploaded zip of test cases as a new version.
+func (q *Question) AfterSave(tx *pop.Connection) error {
+	if err := RegisterTags(tx, q.TagList()); err != nil {
+		return err
+	}
+	if q.ValidatorFile.Valid() {
+		b, err := ioutil.ReadAll(q.ValidatorFile)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if err := q.StoreValidator(tx, q.ValidatorFile.Filename, b); err != nil {
+			return err
+		}
+	}
+	if !q.TestCasesZipFile.Valid() {
+		return nil
+	}
+	b, err := ioutil.ReadAll(q.TestCasesZipFile)
 	if err != nil {
 		return errors.WithStack(err)
 	}
+	_, err = q.StoreTestData(tx, b)
+	return err
+}
 
-	err = os.Rename("../testcases/testcases", "../testcases/testcase_"+q.ID.String())
+// UpdateChecksum computes the checksum of test cases uploaded before they
+// were versioned.
+func (q *Question) UpdateChecksum(tx *pop.Connection) error {
+	if q.TestCasesPath == "" {
+		return nil
+	}
+	sum, err := storage.Checksum(storage.Default(), q.TestCasesPath+"/")
 	if err != nil {
-		return errors.WithStack(err)
+		return err
 	}
-
-	return err
+	q.TestCasesSum = sum
+	return updateQuestionColumns(tx, q.ID, map[string]interface{}{"testcases_checksum": sum})
 }
 
 // Validate gets run every time you call a "pop.Validate*" (pop.ValidateAndSave, pop.ValidateAndCreate, pop.ValidateAndUpdate) method.
 func (q *Question) Validate(tx *pop.Connection) (*validate.Errors, error) {
-	return validate.Validate(
+	verrs := validate.Validate(
 		&validators.StringIsPresent{Field: q.Title, Name: "Title"},
 		&validators.StringIsPresent{Field: q.Description, Name: "Description"},
-	), nil
+		&validators.IntIsGreaterThan{Field: q.Difficulty, Name: "Difficulty", Compared: -1},
+		&validators.IntIsLessThan{Field: q.Difficulty, Name: "Difficulty", Compared: MaxDifficulty + 1},
+		&validators.IntIsGreaterThan{Field: q.TimeLimit, Name: "TimeLimit", Compared: -1},
+		&validators.IntIsLessThan{Field: q.TimeLimit, Name: "TimeLimit", Compared: MaxTimeLimit + 1},
+		&validators.IntIsGreaterThan{Field: q.MemoryLimit, Name: "MemoryLimit", Compared: -1},
+		&validators.IntIsLessThan{Field: q.MemoryLimit, Name: "MemoryLimit", Compared: MaxMemoryLimit + 1},
+	)
+	for _, tag := range q.TagList() {
+		verrs.Append(ValidateTagName(NormalizeTag(tag)))
+	}
+	if _, _, err := ParseChecker(q.Checker); err != nil {
+		verrs.Add("checker", "Checker is not a known checker.")
+	}
+	if q.ValidatorFile.Valid() && ProgramExtension(q.ValidatorFile.Filename) == "" {
+		verrs.Add("validator", "Validator must be a C or C++ source.")
+	}
+	return verrs, nil
 }
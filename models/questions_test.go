@@ -0,0 +1,86 @@
+package models_test
+
+import (
+	"github.com/cpjudge/cpjudge/models"
+	"github.com/gobuffalo/uuid"
+)
+
+func (ms *ModelSuite) Test_Question_TagList() {
+	q := models.Question{Tags: " dp, graphs ,,greedy"}
+	ms.Equal([]string{"dp", "graphs", "greedy"}, q.TagList())
+	ms.Empty(models.Question{}.TagList())
+}
+
+func (ms *ModelSuite) Test_Question_Validate_Difficulty() {
+	q := &models.Question{Title: "Sum", Description: "Add two numbers", Difficulty: models.MaxDifficulty + 1}
+	verrs, err := q.Validate(ms.DB)
+	ms.NoError(err)
+	ms.True(verrs.HasAny())
+}
+
+func (ms *ModelSuite) Test_Questions_LoadSolvedCounts() {
+	contest := &models.Contest{Title: "Weekly", Description: "Weekly contest"}
+	ms.NoError(ms.DB.Create(contest))
+	q := &models.Question{Title: "Sum", Description: "Add two numbers", ContestID: contest.ID}
+	ms.NoError(ms.DB.Create(q))
+	ada, bob := ms.createUser("ada"), ms.createUser("bob")
+	ms.createSubmission(ada, contest.ID, q.ID, models.StatusCorrect, "C")
+	ms.createSubmission(ada, contest.ID, q.ID, models.StatusCorrect, "C")
+	ms.createSubmission(bob, contest.ID, q.ID, models.StatusWrong, "C")
+
+	qs := models.Questions{*q}
+	ms.NoError(qs.LoadSolvedCounts(ms.DB, bob.ID))
+	ms.Equal(1, qs[0].SolvedCount)
+	ms.False(qs[0].SolvedByMe)
+
+	ms.NoError(qs.LoadSolvedCounts(ms.DB, ada.ID))
+	ms.True(qs[0].SolvedByMe)
+}
+
+func (ms *ModelSuite) Test_ContestStandings_IgnoresPractice() {
+	contestID, qid := uuid.Must(uuid.NewV4()), uuid.Must(uuid.NewV4())
+	ada, bob := ms.createUser("ada"), ms.createUser("bob")
+	ms.createSubmission(ada, contestID, qid, models.StatusCorrect, "C")
+	ms.NoError(ms.DB.Create(&models.Submission{
+		UserID:     bob.ID,
+		ContestID:  contestID,
+		QuestionID: qid,
+		Status:     models.StatusCorrect,
+		Language:   "C",
+		Practice:   true,
+	}))
+
+	standings, err := models.ContestStandings(ms.DB, contestID)
+	ms.NoError(err)
+	ms.Len(standings, 1)
+	ms.Equal("ada", standings[0].Username)
+}
+
+func (ms *ModelSuite) Test_Question_Limits() {
+	q := models.Question{}
+	ms.Equal(models.DefaultTimeLimit, q.EffectiveTimeLimit())
+	ms.Equal(models.DefaultMemoryLimit, q.EffectiveMemoryLimit())
+	q.TimeLimit, q.MemoryLimit = 1000, 64
+	ms.Equal(1000, q.EffectiveTimeLimit())
+	ms.Equal(64, q.EffectiveMemoryLimit())
+
+	q = models.Question{Title: "Sum", Description: "Add", TimeLimit: models.MaxTimeLimit + 1}
+	verrs, err := q.Validate(ms.DB)
+	ms.NoError(err)
+	ms.True(verrs.HasAny())
+}
+
+func (ms *ModelSuite) Test_Question_Validate_Checker() {
+	for _, checker := range []string{models.CheckerExact, models.CheckerTokens, models.FloatChecker(1e-6)} {
+		q := &models.Question{Title: "Sum", Description: "Add", Checker: checker}
+		verrs, err := q.Validate(ms.DB)
+		ms.NoError(err)
+		ms.False(verrs.HasAny(), checker)
+	}
+	for _, checker := range []string{"diff", "float:", "float:2", "float:abc"} {
+		q := &models.Question{Title: "Sum", Description: "Add", Checker: checker}
+		verrs, err := q.Validate(ms.DB)
+		ms.NoError(err)
+		ms.NotEmpty(verrs.Get("checker"), checker)
+	}
+}
@@ -0,0 +1,236 @@
+package models
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/gobuffalo/pop"
+	"github.com/gobuffalo/uuid"
+	"github.com/pkg/errors"
+)
+
+// InitialRating is the rating of a user before their first rated contest.
+const InitialRating = 1500
+
+var (
+	// ErrContestNotRated is returned when rating an unrated contest.
+	ErrContestNotRated = errors.New("The contest is not rated.")
+	// ErrContestNotEnded is returned when rating a contest before its end.
+	ErrContestNotEnded = errors.New("The contest has not ended yet.")
+	// ErrRatingsApplied is returned when a contest has already been rated.
+	ErrRatingsApplied = errors.New("Ratings have already been applied for this contest.")
+)
+
+// RatingChange records how a rated contest changed a user's rating.
+type RatingChange struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	UserID    uuid.UUID `json:"user_id" db:"user_id"`
+	ContestID uuid.UUID `json:"contest_id" db:"contest_id"`
+	Rank      int       `json:"rank" db:"rank"`
+	OldRating int       `json:"old_rating" db:"old_rating"`
+	NewRating int       `json:"new_rating" db:"new_rating"`
+}
+
+type RatingChanges []RatingChange
+
+// Delta returns the rating gained or lost.
+func (rc RatingChange) Delta() int {
+	return rc.NewRating - rc.OldRating
+}
+
+// RatingParticipant is a contestant's rating and final rank in a contest.
+type RatingParticipant struct {
+	UserID uuid.UUID
+	Rating int
+	Rank   int
+}
+
+// winProbability is the Elo probability that a player rated a beats a
+// player rated b.
+func winProbability(a, b float64) float64 {
+	return 1 / (1 + math.Pow(10, (b-a)/400))
+}
+
+// expectedRank is the rank a player rated r is expected to get against the
+// participants other than skip.
+func expectedRank(ps []RatingParticipant, skip int, r float64) float64 {
+	seed := 1.0
+	for j, p := range ps {
+		if j != skip {
+			seed += winProbability(float64(p.Rating), r)
+		}
+	}
+	return seed
+}
+
+// ComputeRatingChanges returns the rating change of every participant,
+// following the Codeforces approach: each player's performance is the rating
+// at which their expected rank equals the geometric mean of their expected
+// and actual rank, and they move halfway towards it. The changes are then
+// shifted so they sum to about zero and top players do not inflate.
+func ComputeRatingChanges(ps []RatingParticipant) map[uuid.UUID]int {
+	n := len(ps)
+	deltas := make([]float64, n)
+	for i, p := range ps {
+		seed := expectedRank(ps, i, float64(p.Rating))
+		mid := math.Sqrt(seed * float64(p.Rank))
+		lo, hi := 1.0, 8000.0
+		for hi-lo > 0.5 {
+			m := (lo + hi) / 2
+			if expectedRank(ps, i, m) < mid {
+				hi = m
+			} else {
+				lo = m
+			}
+		}
+		deltas[i] = (lo - float64(p.Rating)) / 2
+	}
+
+	order := make([]int, n)
+	sum := 0.0
+	for i := range order {
+		order[i] = i
+		sum += deltas[i]
+	}
+	if n > 0 {
+		inc := -sum/float64(n) - 1
+		for i := range deltas {
+			deltas[i] += inc
+		}
+	}
+
+	sort.Slice(order, func(a, b int) bool {
+		return ps[order[a]].Rating > ps[order[b]].Rating
+	})
+	top := int(math.Min(float64(n), 4*math.Round(math.Sqrt(float64(n)))))
+	if top > 0 {
+		topSum := 0.0
+		for _, i := range order[:top] {
+			topSum += deltas[i]
+		}
+		inc := math.Min(math.Max(-topSum/float64(top), -10), 0)
+		for i := range deltas {
+			deltas[i] += inc
+		}
+	}
+
+	changes := map[uuid.UUID]int{}
+	for i, p := range ps {
+		changes[p.UserID] = int(math.Round(deltas[i]))
+	}
+	return changes
+}
+
+// ApplyContestRatings computes and stores the rating changes of an ended,
+// rated contest from its final standings.
+func ApplyContestRatings(tx *pop.Connection, contest *Contest) (RatingChanges, error) {
+	if !contest.Rated {
+		return nil, ErrContestNotRated
+	}
+	if !contest.Ended(time.Now()) {
+		return nil, ErrContestNotEnded
+	}
+	applied, err := tx.Where("contest_id = ?", contest.ID).Exists(&RatingChange{})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if applied {
+		return nil, ErrRatingsApplied
+	}
+
+	standings, err := ContestStandings(tx, contest.ID)
+	if err != nil {
+		return nil, err
+	}
+	participants := []RatingParticipant{}
+	for _, row := range standings {
+		user := &User{}
+		if err := tx.Find(user, row.UserID); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		participants = append(participants, RatingParticipant{UserID: user.ID, Rating: user.Rating, Rank: row.Rank})
+	}
+
+	deltas := ComputeRatingChanges(participants)
+	changes := RatingChanges{}
+	for _, p := range participants {
+		rc := RatingChange{
+			UserID:    p.UserID,
+			ContestID: contest.ID,
+			Rank:      p.Rank,
+			OldRating: p.Rating,
+			NewRating: p.Rating + deltas[p.UserID],
+		}
+		if err := tx.Create(&rc); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if err := tx.RawQuery("UPDATE users SET rating = ? WHERE id = ?", rc.NewRating, rc.UserID).Exec(); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		changes = append(changes, rc)
+	}
+	return changes, nil
+}
+
+// RecalculateRatings resets every rating and applies all ended rated
+// contests again in the order they ended.
+func RecalculateRatings(tx *pop.Connection) (int, error) {
+	if err := tx.RawQuery("DELETE FROM rating_changes").Exec(); err != nil {
+		return 0, errors.WithStack(err)
+	}
+	if err := tx.RawQuery("UPDATE users SET rating = ?", InitialRating).Exec(); err != nil {
+		return 0, errors.WithStack(err)
+	}
+	contests := Contests{}
+	err := tx.Where("rated = ? and end_time is not null and end_time <= ?", true, time.Now()).Order("end_time asc").All(&contests)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	for i := range contests {
+		if _, err := ApplyContestRatings(tx, &contests[i]); err != nil {
+			return i, err
+		}
+	}
+	return len(contests), nil
+}
+
+// RatingsOutdated reports whether a rejudge changed a verdict of the contest
+// after its rating changes were made: a verdict recorded in the history since
+// then differs from the current one. Contests without rating changes are
+// never outdated.
+func RatingsOutdated(tx *pop.Connection, contestID uuid.UUID) (bool, error) {
+	outdated, err := tx.Where(`created_at > (SELECT MAX(created_at) FROM rating_changes WHERE contest_id = ?)
+		AND EXISTS (SELECT 1 FROM submissions WHERE submissions.id = verdicts.submission_id
+			AND submissions.contest_id = ? AND submissions.status <> verdicts.status)`,
+		contestID, contestID).Exists(&Verdict{})
+	return outdated, errors.WithStack(err)
+}
+
+// RatingHistoryEntry is a rating change together with its contest.
+type RatingHistoryEntry struct {
+	Contest Contest      `json:"contest"`
+	Change  RatingChange `json:"change"`
+}
+
+// UserRatingHistory returns the rating changes of a user, oldest first.
+func UserRatingHistory(tx *pop.Connection, userID uuid.UUID) ([]RatingHistoryEntry, error) {
+	changes := RatingChanges{}
+	if err := tx.Where("user_id = ?", userID).Order("created_at asc").All(&changes); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	history := []RatingHistoryEntry{}
+	for _, rc := range changes {
+		contest := Contest{}
+		if err := tx.Find(&contest, rc.ContestID); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		history = append(history, RatingHistoryEntry{Contest: contest, Change: rc})
+	}
+	sort.SliceStable(history, func(i, j int) bool {
+		return history[i].Contest.EndTime.Time.Before(history[j].Contest.EndTime.Time)
+	})
+	return history, nil
+}
@@ -0,0 +1,103 @@
+package models_test
+
+import (
+	"time"
+
+	"github.com/cpjudge/cpjudge/models"
+	"github.com/gobuffalo/pop/nulls"
+	"github.com/gobuffalo/uuid"
+)
+
+func (ms *ModelSuite) Test_ComputeRatingChanges() {
+	ids := []uuid.UUID{uuid.Must(uuid.NewV4()), uuid.Must(uuid.NewV4()), uuid.Must(uuid.NewV4()), uuid.Must(uuid.NewV4())}
+	ps := []models.RatingParticipant{
+		{UserID: ids[0], Rating: 1500, Rank: 1},
+		{UserID: ids[1], Rating: 1500, Rank: 2},
+		{UserID: ids[2], Rating: 1500, Rank: 3},
+		{UserID: ids[3], Rating: 1500, Rank: 4},
+	}
+	changes := models.ComputeRatingChanges(ps)
+	ms.True(changes[ids[0]] > 0)
+	ms.True(changes[ids[0]] > changes[ids[1]])
+	ms.True(changes[ids[1]] > changes[ids[2]])
+	ms.True(changes[ids[3]] < 0)
+	sum := 0
+	for _, d := range changes {
+		sum += d
+	}
+	ms.True(sum <= 0 && sum > -20)
+
+	// An upset moves the favourite down and the underdog up.
+	changes = models.ComputeRatingChanges([]models.RatingParticipant{
+		{UserID: ids[0], Rating: 2000, Rank: 2},
+		{UserID: ids[1], Rating: 1200, Rank: 1},
+	})
+	ms.True(changes[ids[0]] < 0)
+	ms.True(changes[ids[1]] > 0)
+}
+
+func (ms *ModelSuite) Test_ApplyContestRatings() {
+	contest := &models.Contest{Title: "Weekly", Description: "Weekly contest", Rated: true,
+		EndTime: nulls.NewTime(time.Now().Add(-time.Hour))}
+	ms.NoError(ms.DB.Create(contest))
+	q := uuid.Must(uuid.NewV4())
+	ada, bob := ms.createUser("ada"), ms.createUser("bob")
+	ms.createSubmission(ada, contest.ID, q, models.StatusCorrect, "C")
+	ms.createSubmission(bob, contest.ID, q, models.StatusWrong, "C")
+
+	changes, err := models.ApplyContestRatings(ms.DB, contest)
+	ms.NoError(err)
+	ms.Len(changes, 2)
+	_, err = models.ApplyContestRatings(ms.DB, contest)
+	ms.Equal(models.ErrRatingsApplied, err)
+
+	ms.NoError(ms.DB.Reload(ada))
+	ms.True(ada.Rating > models.InitialRating)
+
+	n, err := models.RecalculateRatings(ms.DB)
+	ms.NoError(err)
+	ms.Equal(1, n)
+	history, err := models.UserRatingHistory(ms.DB, ada.ID)
+	ms.NoError(err)
+	ms.Len(history, 1)
+	ms.Equal(ada.Rating, history[0].Change.NewRating)
+
+	unrated := &models.Contest{Title: "Practice", Description: "Unrated"}
+	_, err = models.ApplyContestRatings(ms.DB, unrated)
+	ms.Equal(models.ErrContestNotRated, err)
+}
+
+func (ms *ModelSuite) Test_RatingsOutdated() {
+	contest := &models.Contest{Title: "Weekly", Description: "Weekly contest", Rated: true,
+		EndTime: nulls.NewTime(time.Now().Add(-time.Hour))}
+	ms.NoError(ms.DB.Create(contest))
+	q := uuid.Must(uuid.NewV4())
+	ada := ms.createUser("ada")
+	ms.createSubmission(ada, contest.ID, q, models.StatusWrong, "C")
+
+	outdated, err := models.RatingsOutdated(ms.DB, contest.ID)
+	ms.NoError(err)
+	ms.False(outdated)
+
+	_, err = models.ApplyContestRatings(ms.DB, contest)
+	ms.NoError(err)
+	ms.NoError(ms.DB.RawQuery("UPDATE rating_changes SET created_at = ?", time.Now().Add(-time.Minute)).Exec())
+	outdated, err = models.RatingsOutdated(ms.DB, contest.ID)
+	ms.NoError(err)
+	ms.False(outdated)
+
+	s := &models.Submission{}
+	ms.NoError(ms.DB.Where("user_id = ?", ada.ID).First(s))
+	ms.NoError(s.ResetForRejudge(ms.DB, "Rejudged by host"))
+	s.Status = models.StatusWrong
+	ms.NoError(ms.DB.Update(s))
+	outdated, err = models.RatingsOutdated(ms.DB, contest.ID)
+	ms.NoError(err)
+	ms.False(outdated, "the verdict did not change")
+
+	s.Status = models.StatusCorrect
+	ms.NoError(ms.DB.Update(s))
+	outdated, err = models.RatingsOutdated(ms.DB, contest.ID)
+	ms.NoError(err)
+	ms.True(outdated)
+}
@@ -0,0 +1,135 @@
+package models
+
+import (
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cpjudge/cpjudge/storage"
+)
+
+// MaxSampleSize caps how much of a sample is shown in a statement.
+const MaxSampleSize = 4 << 10
+
+// Sample is a test case shown in the problem statement.
+type Sample struct {
+	Name   string `json:"name"`
+	Input  string `json:"input"`
+	Answer string `json:"answer"`
+}
+
+// SampleNames returns the names of the test cases marked as samples, in the
+// order they are shown and judged.
+func (q Question) SampleNames() []string {
+	names := []string{}
+	for _, name := range strings.Split(q.Samples, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// IsSample reports whether the test case with the given name is a sample.
+func (q Question) IsSample(name string) bool {
+	for _, s := range q.SampleNames() {
+		if s == name {
+			return true
+		}
+	}
+	return false
+}
+
+// remapSamples returns the samples that are still test cases once the
+// tests are renamed to names. A sample that is gone is matched by number, so
+// that 1.txt becomes 01.txt when a generator script renumbers the tests;
+// samples without a match are dropped.
+func remapSamples(samples, names []string) []string {
+	exists := map[string]bool{}
+	numbered := map[string]string{}
+	for _, name := range names {
+		exists[name] = true
+		if key, ok := sampleNumber(name); ok {
+			numbered[key] = name
+		}
+	}
+	remapped := []string{}
+	for _, s := range samples {
+		if exists[s] {
+			remapped = append(remapped, s)
+			continue
+		}
+		if key, ok := sampleNumber(s); ok && numbered[key] != "" {
+			remapped = append(remapped, numbered[key])
+		}
+	}
+	return remapped
+}
+
+// sampleNumber returns the number of a test named like 01.txt together with
+// its extension, or false when the name is not a number.
+func sampleNumber(name string) (string, bool) {
+	ext := path.Ext(name)
+	n, err := strconv.Atoi(strings.TrimSuffix(name, ext))
+	if err != nil || n < 0 {
+		return "", false
+	}
+	return strconv.Itoa(n) + ext, true
+}
+
+// TestCaseNames lists the uploaded test cases by input file name. It
+// returns nothing when no test cases have been uploaded.
+func (q Question) TestCaseNames() ([]string, error) {
+	if q.TestCasesPath == "" {
+		return []string{}, nil
+	}
+	prefix := q.TestCasesPath + "/inputs/"
+	keys, err := storage.Default().List(prefix)
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, key := range keys {
+		if name := strings.TrimPrefix(key, prefix); !strings.Contains(name, "/") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// LoadSamples reads the sample test cases, truncated to MaxSampleSize.
+// Samples whose files are missing are skipped.
+func (q Question) LoadSamples() ([]Sample, error) {
+	samples := []Sample{}
+	for _, name := range q.SampleNames() {
+		input, err := readSampleFile(q.TestCasesPath + "/inputs/" + name)
+		if err == storage.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		answer, err := readSampleFile(q.TestCasesPath + "/answers/" + name)
+		if err == storage.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		samples = append(samples, Sample{Name: name, Input: input, Answer: answer})
+	}
+	return samples, nil
+}
+
+func readSampleFile(key string) (string, error) {
+	b, err := storage.ReadAll(storage.Default(), key)
+	if err != nil {
+		return "", err
+	}
+	if len(b) > MaxSampleSize {
+		b = append(b[:MaxSampleSize], "\n..."...)
+	}
+	return string(b), nil
+}
@@ -0,0 +1,38 @@
+package models_test
+
+import (
+	"io/ioutil"
+	"os"
+
+	"github.com/cpjudge/cpjudge/models"
+	"github.com/cpjudge/cpjudge/storage"
+)
+
+func (ms *ModelSuite) Test_Question_Samples() {
+	dir, err := ioutil.TempDir("", "testcases")
+	ms.NoError(err)
+	defer os.RemoveAll(dir)
+	store := storage.NewLocal(dir)
+	storage.Set(store)
+	defer storage.Set(nil)
+	for _, sub := range []string{"inputs", "answers"} {
+		for _, name := range []string{"1.txt", "2.txt"} {
+			ms.NoError(storage.PutBytes(store, "testcases/q/"+sub+"/"+name, []byte(sub+" "+name)))
+		}
+	}
+
+	q := models.Question{TestCasesPath: "testcases/q", Samples: "2.txt, missing.txt"}
+	names, err := q.TestCaseNames()
+	ms.NoError(err)
+	ms.Equal([]string{"1.txt", "2.txt"}, names)
+	ms.True(q.IsSample("2.txt"))
+	ms.False(q.IsSample("1.txt"))
+
+	samples, err := q.LoadSamples()
+	ms.NoError(err)
+	ms.Equal([]models.Sample{{Name: "2.txt", Input: "inputs 2.txt", Answer: "answers 2.txt"}}, samples)
+
+	names, err = models.Question{}.TestCaseNames()
+	ms.NoError(err)
+	ms.Empty(names)
+}
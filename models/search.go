@@ -0,0 +1,33 @@
+package models
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/gobuffalo/pop"
+)
+
+// FullTextQuery turns a search into a MySQL boolean mode full-text query
+// requiring every word as a prefix, such as "+short* +path*".
+func FullTextQuery(search string) string {
+	words := []string{}
+	for _, w := range strings.FieldsFunc(search, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) {
+		words = append(words, "+"+w+"*")
+	}
+	return strings.Join(words, " ")
+}
+
+// SearchQuestions narrows a query on questions to those whose title or
+// description match a search. Titles are also matched as they are, for
+// words the full-text index leaves out, such as short ones.
+func SearchQuestions(q *pop.Query, search string) *pop.Query {
+	return q.Where("(MATCH(questions.title, questions.description) AGAINST (? IN BOOLEAN MODE) OR questions.title LIKE ?)",
+		FullTextQuery(search), "%"+search+"%")
+}
+
+// SearchContests narrows a query on contests to those whose title or
+// description match a search.
+func SearchContests(q *pop.Query, search string) *pop.Query {
+	return q.Where("(MATCH(contests.title, contests.description) AGAINST (? IN BOOLEAN MODE) OR contests.title LIKE ?)",
+		FullTextQuery(search), "%"+search+"%")
+}
@@ -0,0 +1,201 @@
+package models
+
+import (
+	"database/sql"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/cpjudge/cpjudge/storage"
+	"github.com/gobuffalo/pop"
+	"github.com/gobuffalo/uuid"
+	"github.com/gobuffalo/validate"
+	"github.com/pkg/errors"
+)
+
+// Expected verdicts of reference solutions.
+const (
+	ExpectCorrect   = "AC"
+	ExpectTimeLimit = "TLE"
+	ExpectWrong     = "WA"
+)
+
+// ExpectedVerdict is an expected verdict a host can pick.
+type ExpectedVerdict struct {
+	Value string `json:"value"`
+	Label string `json:"label"`
+}
+
+// ExpectedVerdicts lists the verdicts a reference solution can be expected
+// to get.
+var ExpectedVerdicts = []ExpectedVerdict{
+	{ExpectCorrect, StatusCorrect},
+	{ExpectTimeLimit, StatusTimeLimit},
+	{ExpectWrong, StatusWrong},
+}
+
+// ReferenceSolution is a host's solution to a question with the verdict it
+// is expected to get. Reference solutions are judged again whenever the
+// test data changes, to catch tests that are wrong or too weak. The main
+// solution is a correct one, used to generate answers.
+type ReferenceSolution struct {
+	ID         uuid.UUID `json:"id" db:"id"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at" db:"updated_at"`
+	QuestionID uuid.UUID `json:"question_id" db:"question_id"`
+	Filename   string    `json:"filename" db:"filename"`
+	Expected   string    `json:"expected" db:"expected"`
+	Main       bool      `json:"main" db:"main"`
+	SourcePath string    `json:"source_path" db:"source_path"`
+	// Status is the verdict on the test data with checksum TestDataSum,
+	// StatusPending while it is judged, or empty when the solution has not
+	// been judged.
+	Status      string `json:"status" db:"status"`
+	TestDataSum string `json:"test_data_checksum" db:"test_data_checksum"`
+}
+
+type ReferenceSolutions []ReferenceSolution
+
+// Validate gets run every time you call a "pop.Validate*" (pop.ValidateAndSave, pop.ValidateAndCreate, pop.ValidateAndUpdate) method.
+func (s *ReferenceSolution) Validate(tx *pop.Connection) (*validate.Errors, error) {
+	verrs := validate.NewErrors()
+	if ProgramExtension(s.Filename) == "" {
+		verrs.Add("solution", "Reference solutions must be C or C++ sources.")
+	}
+	known := false
+	for _, v := range ExpectedVerdicts {
+		known = known || v.Value == s.Expected
+	}
+	if !known {
+		verrs.Add("expected", "Expected verdict is not a known verdict.")
+	}
+	if s.Main && s.Expected != ExpectCorrect {
+		verrs.Add("main", "The main solution must be expected to be accepted.")
+	}
+	return verrs, nil
+}
+
+// StorageKey is the storage key the source of the solution is kept under.
+func (s ReferenceSolution) StorageKey() string {
+	return "solutions/solution_" + s.ID.String() + ProgramExtension(s.Filename)
+}
+
+// ExpectedLabel describes the expected verdict.
+func (s ReferenceSolution) ExpectedLabel() string {
+	for _, v := range ExpectedVerdicts {
+		if v.Value == s.Expected {
+			return v.Label
+		}
+	}
+	return s.Expected
+}
+
+// Judged reports whether the solution was judged on the given test data.
+func (s ReferenceSolution) Judged(checksum string) bool {
+	return s.Status != "" && s.TestDataSum == checksum
+}
+
+// Matches reports whether the solution got its expected verdict. Failing
+// on a sample counts as failing on any other test.
+func (s ReferenceSolution) Matches() bool {
+	status := BaseStatus(s.Status)
+	switch s.Expected {
+	case ExpectCorrect:
+		return status == StatusCorrect
+	case ExpectTimeLimit:
+		return status == StatusTimeLimit
+	case ExpectWrong:
+		return status == StatusWrong
+	}
+	return false
+}
+
+// Flagged reports whether the solution was judged and did not get its
+// expected verdict, such as a solution that should time out passing.
+func (s ReferenceSolution) Flagged() bool {
+	return s.Status != "" && s.Status != StatusPending && !s.Matches()
+}
+
+// AddReferenceSolution stores the source of a new reference solution. A
+// new main solution replaces the previous one.
+func AddReferenceSolution(tx *pop.Connection, s *ReferenceSolution, source []byte) (*validate.Errors, error) {
+	s.ID = uuid.Must(uuid.NewV4())
+	s.SourcePath = s.StorageKey()
+	verrs, err := tx.ValidateAndCreate(s)
+	if err != nil || verrs.HasAny() {
+		return verrs, errors.WithStack(err)
+	}
+	if s.Main {
+		err := tx.RawQuery("UPDATE reference_solutions SET main = ? WHERE question_id = ? AND id <> ?", false, s.QuestionID, s.ID).Exec()
+		if err != nil {
+			return verrs, errors.WithStack(err)
+		}
+	}
+	return verrs, storage.PutBytes(storage.Default(), s.SourcePath, source)
+}
+
+// QuestionReferenceSolutions returns the reference solutions of a question,
+// the main one first.
+func QuestionReferenceSolutions(tx *pop.Connection, questionID uuid.UUID) (ReferenceSolutions, error) {
+	solutions := ReferenceSolutions{}
+	if err := tx.Where("question_id = ?", questionID).Order("main desc, created_at asc").All(&solutions); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return solutions, nil
+}
+
+// MainSolution returns the main solution of a question, or nil when it has
+// none.
+func MainSolution(tx *pop.Connection, questionID uuid.UUID) (*ReferenceSolution, error) {
+	s := &ReferenceSolution{}
+	if err := tx.Where("question_id = ? and main = ?", questionID, true).First(s); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, errors.WithStack(err)
+	}
+	return s, nil
+}
+
+// Source returns the source code of the solution.
+func (s ReferenceSolution) Source() (string, error) {
+	b, err := storage.ReadAll(storage.Default(), s.SourcePath)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// Download copies the solution source into dir and returns its path.
+func (s ReferenceSolution) Download(dir string) (string, error) {
+	dst := filepath.Join(dir, path.Base(s.SourcePath))
+	return dst, storage.DownloadFile(storage.Default(), s.SourcePath, dst)
+}
+
+// Delete removes the solution and its source, and drops its unfinished
+// judge jobs.
+func (s *ReferenceSolution) Delete(tx *pop.Connection) error {
+	err := tx.RawQuery("UPDATE judge_jobs SET status = ?, leased_until = NULL WHERE reference_solution_id = ? AND status != ?",
+		JobDone, s.ID, JobDone).Exec()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if err := tx.Destroy(s); err != nil {
+		return errors.WithStack(err)
+	}
+	return storage.Default().Delete(s.SourcePath)
+}
+
+// DeleteReferenceSolutions removes the reference solutions of the question.
+func (q Question) DeleteReferenceSolutions(tx *pop.Connection) error {
+	solutions, err := QuestionReferenceSolutions(tx, q.ID)
+	if err != nil {
+		return err
+	}
+	for i := range solutions {
+		if err := solutions[i].Delete(tx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
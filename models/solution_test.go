@@ -0,0 +1,77 @@
+package models_test
+
+import (
+	"io/ioutil"
+	"os"
+
+	"github.com/cpjudge/cpjudge/models"
+	"github.com/cpjudge/cpjudge/storage"
+)
+
+func (ms *ModelSuite) Test_AddReferenceSolution() {
+	dir, err := ioutil.TempDir("", "storage")
+	ms.NoError(err)
+	defer os.RemoveAll(dir)
+	store := storage.NewLocal(dir)
+	storage.Set(store)
+	defer storage.Set(nil)
+
+	contest := ms.pastContest(0)
+	q := &models.Question{Title: "Sum", Description: "Add", ContestID: contest.ID}
+	ms.NoError(ms.DB.Create(q))
+
+	verrs, err := models.AddReferenceSolution(ms.DB, &models.ReferenceSolution{QuestionID: q.ID, Filename: "slow.py", Expected: models.ExpectCorrect}, nil)
+	ms.NoError(err)
+	ms.True(verrs.HasAny())
+	verrs, err = models.AddReferenceSolution(ms.DB, &models.ReferenceSolution{QuestionID: q.ID, Filename: "slow.c", Expected: models.ExpectTimeLimit, Main: true}, nil)
+	ms.NoError(err)
+	ms.True(verrs.HasAny())
+
+	first := &models.ReferenceSolution{QuestionID: q.ID, Filename: "a.cpp", Expected: models.ExpectCorrect, Main: true}
+	verrs, err = models.AddReferenceSolution(ms.DB, first, []byte("// a"))
+	ms.NoError(err)
+	ms.False(verrs.HasAny())
+	b, err := storage.ReadAll(store, first.SourcePath)
+	ms.NoError(err)
+	ms.Equal("// a", string(b))
+
+	second := &models.ReferenceSolution{QuestionID: q.ID, Filename: "b.c", Expected: models.ExpectCorrect, Main: true}
+	verrs, err = models.AddReferenceSolution(ms.DB, second, []byte("// b"))
+	ms.NoError(err)
+	ms.False(verrs.HasAny())
+	main, err := models.MainSolution(ms.DB, q.ID)
+	ms.NoError(err)
+	ms.Equal(second.ID, main.ID)
+
+	solutions, err := models.QuestionReferenceSolutions(ms.DB, q.ID)
+	ms.NoError(err)
+	ms.Len(solutions, 2)
+	ms.Equal(second.ID, solutions[0].ID)
+
+	ms.NoError(q.DeleteReferenceSolutions(ms.DB))
+	main, err = models.MainSolution(ms.DB, q.ID)
+	ms.NoError(err)
+	ms.Nil(main)
+	_, err = store.Get(first.SourcePath)
+	ms.Equal(storage.ErrNotFound, err)
+}
+
+func (ms *ModelSuite) Test_ReferenceSolution_Flagged() {
+	for _, tt := range []struct {
+		expected, status string
+		flagged          bool
+	}{
+		{models.ExpectCorrect, "", false},
+		{models.ExpectCorrect, models.StatusCorrect, false},
+		{models.ExpectCorrect, models.StatusPending, false},
+		{models.ExpectCorrect, models.StatusWrong, true},
+		{models.ExpectTimeLimit, models.StatusTimeLimit, false},
+		{models.ExpectTimeLimit, models.StatusCorrect, true},
+		{models.ExpectWrong, models.StatusWrongOnSample, false},
+		{models.ExpectTimeLimit, models.SampleStatus(models.StatusTimeLimit), false},
+		{models.ExpectWrong, models.StatusRuntimeError, true},
+	} {
+		s := models.ReferenceSolution{Expected: tt.expected, Status: tt.status}
+		ms.Equal(tt.flagged, s.Flagged(), "%s got %q", tt.expected, tt.status)
+	}
+}
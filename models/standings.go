@@ -0,0 +1,103 @@
+package models
+
+import (
+	"sort"
+
+	"github.com/gobuffalo/pop"
+	"github.com/gobuffalo/uuid"
+	"github.com/pkg/errors"
+)
+
+// Standing is a user's row on a contest leaderboard.
+type Standing struct {
+	UserID   uuid.UUID `json:"user_id"`
+	Username string    `json:"username"`
+	Rank     int       `json:"rank"`
+	Correct  int       `json:"correct"`
+	Wrong    int       `json:"wrong"`
+	Rating   int       `json:"rating"`
+	// Delta is the rating change from this contest, once it has been rated.
+	Delta    int  `json:"delta"`
+	HasDelta bool `json:"has_delta"`
+	// Virtual marks the row of a virtual participant in ghost standings.
+	Virtual bool `json:"virtual"`
+}
+
+type Standings []Standing
+
+// ContestStandings ranks the participants of a contest by correct
+// submissions, breaking ties by fewer wrong submissions. Users with the same
+// counts share a rank. Practice submissions are not counted.
+func ContestStandings(tx *pop.Connection, contestID uuid.UUID) (Standings, error) {
+	submissions := Submissions{}
+	if err := tx.Where("contest_id = ? and practice = ?", contestID, false).All(&submissions); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return RankSubmissions(tx, submissions)
+}
+
+// RankSubmissions builds standings from a set of submissions.
+func RankSubmissions(tx *pop.Connection, submissions Submissions) (Standings, error) {
+	rows := map[uuid.UUID]*Standing{}
+	for _, submission := range submissions {
+		row, ok := rows[submission.UserID]
+		if !ok {
+			user := &User{}
+			if err := tx.Find(user, submission.UserID); err != nil {
+				return nil, errors.WithStack(err)
+			}
+			row = &Standing{UserID: user.ID, Username: user.Username, Rating: user.Rating}
+			rows[submission.UserID] = row
+		}
+		switch BaseStatus(submission.Status) {
+		case StatusCorrect:
+			row.Correct++
+		case StatusRuntimeError, StatusWrong, StatusTimeLimit:
+			row.Wrong++
+		}
+	}
+
+	standings := Standings{}
+	for _, row := range rows {
+		standings = append(standings, *row)
+	}
+	sort.Slice(standings, func(i, j int) bool {
+		if standings[i].Correct != standings[j].Correct {
+			return standings[i].Correct > standings[j].Correct
+		}
+		if standings[i].Wrong != standings[j].Wrong {
+			return standings[i].Wrong < standings[j].Wrong
+		}
+		return standings[i].Username < standings[j].Username
+	})
+	for i := range standings {
+		if i > 0 && standings[i].Correct == standings[i-1].Correct && standings[i].Wrong == standings[i-1].Wrong {
+			standings[i].Rank = standings[i-1].Rank
+		} else {
+			standings[i].Rank = i + 1
+		}
+	}
+	return standings, nil
+}
+
+// Find returns the standing of a user, if they took part.
+func (s Standings) Find(userID uuid.UUID) (Standing, bool) {
+	for _, row := range s {
+		if row.UserID == userID {
+			return row, true
+		}
+	}
+	return Standing{}, false
+}
+
+// WithRatingChanges fills in the rating changes of a rated contest.
+func (s Standings) WithRatingChanges(changes RatingChanges) Standings {
+	deltas := map[uuid.UUID]int{}
+	for _, rc := range changes {
+		deltas[rc.UserID] = rc.Delta()
+	}
+	for i := range s {
+		s[i].Delta, s[i].HasDelta = deltas[s[i].UserID]
+	}
+	return s
+}
@@ -3,14 +3,14 @@ package models
 import (
 	"fmt"
 	"io"
-	"os"
-	"path/filepath"
+	"strings"
 	"time"
 
+	"github.com/cpjudge/cpjudge/storage"
 	"github.com/gobuffalo/buffalo/binding"
 	"github.com/gobuffalo/pop"
+	"github.com/gobuffalo/pop/nulls"
 	"github.com/gobuffalo/uuid"
-	"github.com/pkg/errors"
 )
 
 type Submission struct {
@@ -23,36 +23,92 @@ type Submission struct {
 	SubmissionFile binding.File `json:"submission_file" db:"-" form:"SubmissionFile"`
 	SubmissionPath string       `json:"submission_path" db:"submission_path"`
 	Status         string       `json:"status" db:"status"`
+	Language       string       `json:"language" db:"language"`
+	// SourceCode is source typed in the editor, stored like an uploaded file.
+	SourceCode string `json:"-" db:"-" form:"SourceCode"`
+	// Practice submissions are made after the contest ended and do not
+	// count towards its leaderboard.
+	Practice bool `json:"practice" db:"practice"`
+	// VirtualParticipationID is set for submissions made during a virtual
+	// participation.
+	VirtualParticipationID nulls.UUID `json:"virtual_participation_id" db:"virtual_participation_id"`
+	// Elapsed is the number of seconds since the start of the submitter's
+	// (virtual) contest.
+	Elapsed int `json:"elapsed" db:"elapsed"`
+	// TestDataVersion is the checksum of the test data the submission was
+	// last judged against.
+	TestDataVersion string `json:"test_data_version" db:"test_data_version"`
 }
 
 type Submissions []Submission
 
-func (s *Submission) AfterSave(tx *pop.Connection) error {
+// Verdicts a submission can end up with.
+const (
+	StatusPending          = "Pending"
+	StatusCorrect          = "Correct Answer"
+	StatusWrong            = "Wrong answer"
+	StatusWrongOnSample    = StatusWrong + onSample
+	StatusTimeLimit        = "Time Limit Exceeded"
+	StatusRuntimeError     = "Runtime Error"
+	StatusCompilationError = "Compilation error"
+	// StatusSystemError is the verdict when the judge itself fails, for
+	// example because the test data is missing.
+	StatusSystemError = "System Error"
+)
 
-	if !s.SubmissionFile.Valid() {
-		fmt.Printf("\n\nFile is not valid\n\n")
-		return nil
-	}
-	dir := filepath.Join("..", "submissions")
+// onSample is appended to the verdict of a program failing a sample test.
+const onSample = " on sample"
 
-	fmt.Println(dir)
+// SampleStatus returns the verdict of a program that got status on a sample
+// test, such as "Time Limit Exceeded on sample".
+func SampleStatus(status string) string {
+	return status + onSample
+}
 
-	fmt.Printf("\n\nFile path %q\n\n", dir)
+// BaseStatus returns the verdict without the mention of a sample test.
+func BaseStatus(status string) string {
+	return strings.TrimSuffix(status, onSample)
+}
 
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return errors.WithStack(err)
-	}
+// ElapsedClock formats Elapsed as h:mm:ss.
+func (s Submission) ElapsedClock() string {
+	return fmt.Sprintf("%d:%02d:%02d", s.Elapsed/3600, s.Elapsed/60%60, s.Elapsed%60)
+}
 
-	submissionFileName := "submission_" + s.ID.String() + ".c"
-	f, err := os.Create(filepath.Join(dir, submissionFileName))
-	if err != nil {
-		return errors.WithStack(err)
-	}
-	defer f.Close()
-	_, err = io.Copy(f, s.SubmissionFile)
+// Source returns the submitted source code.
+func (s Submission) Source() (string, error) {
+	b, err := storage.ReadAll(storage.Default(), s.SubmissionPath)
 	if err != nil {
-		return errors.WithStack(err)
+		return "", err
 	}
+	return string(b), nil
+}
+
+// SourceFilename is the name the source is downloaded as.
+func (s Submission) SourceFilename() string {
+	return "submission_" + s.ID.String() + "." + FindLanguage(s.Language).Extension
+}
+
+// StorageKey is the storage key the source of the submission is kept under.
+func (s Submission) StorageKey() string {
+	return "submissions/" + s.SourceFilename()
+}
 
-	return err
+// HasSource reports whether a file was uploaded or source was typed in.
+func (s Submission) HasSource() bool {
+	return s.SubmissionFile.Valid() || strings.TrimSpace(s.SourceCode) != ""
+}
+
+// AfterSave stores the uploaded file or the typed source under StorageKey.
+func (s *Submission) AfterSave(tx *pop.Connection) error {
+	var src io.Reader
+	switch {
+	case s.SubmissionFile.Valid():
+		src = s.SubmissionFile
+	case s.SourceCode != "":
+		src = strings.NewReader(s.SourceCode)
+	default:
+		return nil
+	}
+	return storage.Default().Put(s.StorageKey(), src)
 }
@@ -0,0 +1,34 @@
+package models_test
+
+import (
+	"io/ioutil"
+	"os"
+
+	"github.com/cpjudge/cpjudge/models"
+	"github.com/cpjudge/cpjudge/storage"
+)
+
+func (ms *ModelSuite) Test_Submission_HasSource() {
+	s := models.Submission{Language: "C"}
+	ms.False(s.HasSource())
+	s.SourceCode = "  \n"
+	ms.False(s.HasSource())
+	s.SourceCode = "int main(){return 0;}"
+	ms.True(s.HasSource())
+	ms.Equal("submissions/"+s.SourceFilename(), s.StorageKey())
+}
+
+func (ms *ModelSuite) Test_Submission_Source() {
+	dir, err := ioutil.TempDir("", "storage")
+	ms.NoError(err)
+	defer os.RemoveAll(dir)
+	storage.Set(storage.NewLocal(dir))
+	defer storage.Set(nil)
+
+	s := &models.Submission{Language: "C", SourceCode: "int main(){return 0;}"}
+	ms.NoError(s.AfterSave(ms.DB))
+	s.SubmissionPath = s.StorageKey()
+	source, err := s.Source()
+	ms.NoError(err)
+	ms.Equal("int main(){return 0;}", source)
+}
@@ -0,0 +1,275 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/pop"
+	"github.com/gobuffalo/uuid"
+	"github.com/gobuffalo/validate"
+	"github.com/pkg/errors"
+)
+
+// MaxTagLength is the longest a tag name can be.
+const MaxTagLength = 30
+
+// Tag is a topic questions can be tagged with, such as "dp" or "graphs".
+// Questions keep their tags by name in Question.Tags; the tags table is the
+// vocabulary hosts pick from, which also holds tags no question has yet.
+type Tag struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	Name      string    `json:"name" db:"name"`
+}
+
+type Tags []Tag
+
+var tagName = regexp.MustCompile(`^[a-z0-9][a-z0-9 +#.-]*$`)
+
+// NormalizeTag lowercases a tag name and collapses its spaces.
+func NormalizeTag(name string) string {
+	return strings.Join(strings.Fields(strings.ToLower(name)), " ")
+}
+
+// NormalizeTags normalizes comma separated tags, dropping empty and
+// repeated ones.
+func NormalizeTags(tags string) string {
+	names := []string{}
+	seen := map[string]bool{}
+	for _, name := range strings.Split(tags, ",") {
+		name = NormalizeTag(name)
+		if name != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+// ValidateTagName checks a normalized tag name.
+func ValidateTagName(name string) *validate.Errors {
+	verrs := validate.NewErrors()
+	if len(name) > MaxTagLength {
+		verrs.Add("tags", fmt.Sprintf("Tag %q is longer than %d characters.", name, MaxTagLength))
+	} else if !tagName.MatchString(name) {
+		verrs.Add("tags", fmt.Sprintf("Tag %q may only contain letters, digits, spaces and the characters + # . -", name))
+	}
+	return verrs
+}
+
+// Validate gets run every time you call a "pop.Validate*" (pop.ValidateAndSave, pop.ValidateAndCreate, pop.ValidateAndUpdate) method.
+func (t *Tag) Validate(tx *pop.Connection) (*validate.Errors, error) {
+	verrs := ValidateTagName(t.Name)
+	if verrs.HasAny() {
+		return verrs, nil
+	}
+	exists, err := tx.Where("name = ? and id <> ?", t.Name, t.ID).Exists(&Tag{})
+	if err != nil {
+		return verrs, errors.WithStack(err)
+	}
+	if exists {
+		verrs.Add("tags", fmt.Sprintf("Tag %q already exists.", t.Name))
+	}
+	return verrs, nil
+}
+
+// CreateTag adds a tag to the vocabulary.
+func CreateTag(tx *pop.Connection, name string) (*Tag, *validate.Errors, error) {
+	t := &Tag{Name: NormalizeTag(name)}
+	verrs, err := tx.ValidateAndCreate(t)
+	return t, verrs, errors.WithStack(err)
+}
+
+// RegisterTags adds the tags that are not in the vocabulary yet.
+func RegisterTags(tx *pop.Connection, names []string) error {
+	for _, name := range names {
+		exists, err := tx.Where("name = ?", name).Exists(&Tag{})
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if !exists {
+			if err := tx.Create(&Tag{Name: name}); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+	}
+	return nil
+}
+
+// TagUse is a tag with the number of questions having it, in all contests
+// and in those of a host.
+type TagUse struct {
+	Name      string
+	Questions int
+	Hosted    int
+}
+
+// TagUses returns the vocabulary and every tag a question has, by name,
+// with the questions having them. Hosted counts those of hostID's contests.
+func TagUses(tx *pop.Connection, hostID uuid.UUID) ([]TagUse, error) {
+	tags := Tags{}
+	if err := tx.All(&tags); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	uses := map[string]*TagUse{}
+	for _, t := range tags {
+		uses[t.Name] = &TagUse{Name: t.Name}
+	}
+	contests := Contests{}
+	if err := tx.Where("host_id = ?", hostID).All(&contests); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	hosted := map[uuid.UUID]bool{}
+	for _, c := range contests {
+		hosted[c.ID] = true
+	}
+	questions := Questions{}
+	if err := tx.Where("tags <> ''").All(&questions); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	for _, q := range questions {
+		for _, name := range q.TagList() {
+			use, ok := uses[name]
+			if !ok {
+				use = &TagUse{Name: name}
+				uses[name] = use
+			}
+			use.Questions++
+			if hosted[q.ContestID] {
+				use.Hosted++
+			}
+		}
+	}
+	list := []TagUse{}
+	for _, use := range uses {
+		list = append(list, *use)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+// TagNames returns the names of the tags in the vocabulary, sorted. Tags
+// of questions are added to it when the questions are saved.
+func TagNames(tx *pop.Connection) ([]string, error) {
+	return tagNames(tx.RawQuery("SELECT * FROM tags ORDER BY name"))
+}
+
+// ArchivedTagNames returns the names of the tags of questions in contests
+// that ended by t, sorted. Listings offer only these, so that they do not
+// give away the topics of running contests.
+func ArchivedTagNames(tx *pop.Connection, t time.Time) ([]string, error) {
+	return tagNames(tx.RawQuery("SELECT * FROM tags WHERE EXISTS (SELECT 1 FROM questions "+
+		"JOIN contests ON contests.id = questions.contest_id "+
+		"WHERE contests.end_time IS NOT NULL AND contests.end_time <= ? "+
+		"AND CONCAT(',', questions.tags, ',') LIKE CONCAT('%,', tags.name, ',%')) ORDER BY name", t))
+}
+
+func tagNames(q *pop.Query) ([]string, error) {
+	tags := Tags{}
+	if err := q.All(&tags); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	names := make([]string, len(tags))
+	for i, t := range tags {
+		names[i] = t.Name
+	}
+	return names, nil
+}
+
+// NormalizeStoredTags normalizes the tags of questions and of the
+// vocabulary saved before tags were normalized, merging tags that only
+// differed in case or spacing. It returns the number of questions changed.
+func NormalizeStoredTags(tx *pop.Connection) (int, error) {
+	tags := Tags{}
+	if err := tx.Order("created_at asc").All(&tags); err != nil {
+		return 0, errors.WithStack(err)
+	}
+	seen := map[string]bool{}
+	for _, t := range tags {
+		name := NormalizeTag(t.Name)
+		if name == "" || seen[name] {
+			if err := tx.Destroy(&t); err != nil {
+				return 0, errors.WithStack(err)
+			}
+			continue
+		}
+		seen[name] = true
+		if name != t.Name {
+			if err := tx.RawQuery("UPDATE tags SET name = ? WHERE id = ?", name, t.ID).Exec(); err != nil {
+				return 0, errors.WithStack(err)
+			}
+		}
+	}
+
+	questions := Questions{}
+	if err := tx.Where("tags <> ''").All(&questions); err != nil {
+		return 0, errors.WithStack(err)
+	}
+	changed := 0
+	for _, q := range questions {
+		tags := NormalizeTags(q.Tags)
+		if err := RegisterTags(tx, strings.Split(tags, ",")); err != nil {
+			return 0, err
+		}
+		if tags == q.Tags {
+			continue
+		}
+		if err := updateQuestionColumns(tx, q.ID, map[string]interface{}{"tags": tags}); err != nil {
+			return 0, err
+		}
+		changed++
+	}
+	return changed, nil
+}
+
+// TaggedWith narrows a query on questions to those with a tag.
+func TaggedWith(q *pop.Query, tag string) *pop.Query {
+	return q.Where("CONCAT(',', questions.tags, ',') LIKE ?", "%,"+NormalizeTag(tag)+",%")
+}
+
+// RetagQuestions renames a tag on the questions of a host's contests, or
+// removes it when name is empty; a question having both tags keeps one.
+// The old tag leaves the vocabulary once no question has it. It returns the
+// number of questions changed.
+func RetagQuestions(tx *pop.Connection, hostID uuid.UUID, old, name string) (int, error) {
+	old, name = NormalizeTag(old), NormalizeTag(name)
+	if name != "" {
+		if err := RegisterTags(tx, []string{name}); err != nil {
+			return 0, err
+		}
+	}
+	questions := Questions{}
+	q := TaggedWith(tx.Q(), old).
+		Join("contests", "contests.id = questions.contest_id").
+		Where("contests.host_id = ?", hostID)
+	if err := q.All(&questions); err != nil {
+		return 0, errors.WithStack(err)
+	}
+	for _, question := range questions {
+		tags := []string{}
+		for _, t := range question.TagList() {
+			if t == old {
+				t = name
+			}
+			tags = append(tags, t)
+		}
+		err := updateQuestionColumns(tx, question.ID, map[string]interface{}{"tags": NormalizeTags(strings.Join(tags, ","))})
+		if err != nil {
+			return 0, err
+		}
+	}
+	used, err := TaggedWith(tx.Q(), old).Exists(&Question{})
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	if !used {
+		if err := tx.RawQuery("DELETE FROM tags WHERE name = ?", old).Exec(); err != nil {
+			return 0, errors.WithStack(err)
+		}
+	}
+	return len(questions), nil
+}
@@ -0,0 +1,104 @@
+package models_test
+
+import (
+	"time"
+
+	"github.com/cpjudge/cpjudge/models"
+	"github.com/gobuffalo/pop/nulls"
+	"github.com/gobuffalo/uuid"
+)
+
+func (ms *ModelSuite) Test_NormalizeTags() {
+	ms.Equal("dp,graphs,number theory", models.NormalizeTags(" DP, graphs ,,dp, Number   Theory"))
+	ms.Equal("", models.NormalizeTags(" , "))
+}
+
+func (ms *ModelSuite) Test_ValidateTagName() {
+	ms.False(models.ValidateTagName("c++").HasAny())
+	ms.True(models.ValidateTagName("<b>").HasAny())
+	ms.True(models.ValidateTagName("a very long tag name that goes on").HasAny())
+}
+
+func (ms *ModelSuite) Test_FullTextQuery() {
+	ms.Equal("+short* +path*", models.FullTextQuery("Short path"))
+	ms.Equal("+a* +b*", models.FullTextQuery(`"a" -(b)*`))
+	ms.Equal("", models.FullTextQuery("+-"))
+}
+
+func (ms *ModelSuite) Test_Question_Tags_Registered() {
+	contest := ms.pastContest(time.Hour)
+	q := &models.Question{Title: "Sum", Description: "Add", ContestID: contest.ID, Tags: "Math, Implementation"}
+	ms.NoError(ms.DB.Create(q))
+	ms.Equal("math,implementation", q.Tags)
+
+	names, err := models.TagNames(ms.DB)
+	ms.NoError(err)
+	ms.Equal([]string{"implementation", "math"}, names)
+}
+
+func (ms *ModelSuite) Test_RetagQuestions() {
+	mine := &models.Contest{Title: "Mine", Description: "Mine", HostID: uuid.Must(uuid.NewV4()), EndTime: nulls.NewTime(time.Now())}
+	ms.NoError(ms.DB.Create(mine))
+	theirs := &models.Contest{Title: "Theirs", Description: "Theirs", HostID: uuid.Must(uuid.NewV4())}
+	ms.NoError(ms.DB.Create(theirs))
+	a := &models.Question{Title: "A", Description: "A", ContestID: mine.ID, Tags: "dp,greedy"}
+	ms.NoError(ms.DB.Create(a))
+	b := &models.Question{Title: "B", Description: "B", ContestID: mine.ID, Tags: "dynamic programming,dp"}
+	ms.NoError(ms.DB.Create(b))
+	c := &models.Question{Title: "C", Description: "C", ContestID: theirs.ID, Tags: "dp"}
+	ms.NoError(ms.DB.Create(c))
+
+	n, err := models.RetagQuestions(ms.DB, mine.HostID, "DP", "Dynamic Programming")
+	ms.NoError(err)
+	ms.Equal(2, n)
+	ms.NoError(ms.DB.Reload(a))
+	ms.Equal("dynamic programming,greedy", a.Tags)
+	ms.NoError(ms.DB.Reload(b))
+	ms.Equal("dynamic programming", b.Tags)
+	ms.NoError(ms.DB.Reload(c))
+	ms.Equal("dp", c.Tags)
+
+	n, err = models.RetagQuestions(ms.DB, theirs.HostID, "dp", "")
+	ms.NoError(err)
+	ms.Equal(1, n)
+	ms.NoError(ms.DB.Reload(c))
+	ms.Equal("", c.Tags)
+	exists, err := ms.DB.Where("name = ?", "dp").Exists(&models.Tag{})
+	ms.NoError(err)
+	ms.False(exists)
+}
+
+func (ms *ModelSuite) Test_ArchivedTagNames() {
+	ended := ms.pastContest(time.Hour)
+	running := &models.Contest{Title: "Running", Description: "Running", EndTime: nulls.NewTime(time.Now().Add(time.Hour))}
+	ms.NoError(ms.DB.Create(running))
+	ms.NoError(ms.DB.Create(&models.Question{Title: "A", Description: "A", ContestID: ended.ID, Tags: "dp,greedy"}))
+	ms.NoError(ms.DB.Create(&models.Question{Title: "B", Description: "B", ContestID: running.ID, Tags: "flows,dp"}))
+
+	// Tags of the running contest stay hidden until it ends.
+	names, err := models.ArchivedTagNames(ms.DB, time.Now())
+	ms.NoError(err)
+	ms.Equal([]string{"dp", "greedy"}, names)
+	names, err = models.ArchivedTagNames(ms.DB, time.Now().Add(2*time.Hour))
+	ms.NoError(err)
+	ms.Equal([]string{"dp", "flows", "greedy"}, names)
+}
+
+func (ms *ModelSuite) Test_NormalizeStoredTags() {
+	contest := ms.pastContest(time.Hour)
+	q := &models.Question{Title: "Sum", Description: "Add", ContestID: contest.ID}
+	ms.NoError(ms.DB.Create(q))
+	// Saved before tags were normalized.
+	ms.NoError(ms.DB.RawQuery("UPDATE questions SET tags = ? WHERE id = ?", "DP, Number  Theory,dp", q.ID).Exec())
+	ms.NoError(ms.DB.Create(&models.Tag{Name: "Graphs"}))
+	ms.NoError(ms.DB.Create(&models.Tag{Name: "graphs"}))
+
+	n, err := models.NormalizeStoredTags(ms.DB)
+	ms.NoError(err)
+	ms.Equal(1, n)
+	ms.NoError(ms.DB.Reload(q))
+	ms.Equal("dp,number theory", q.Tags)
+	names, err := models.TagNames(ms.DB)
+	ms.NoError(err)
+	ms.Equal([]string{"dp", "graphs", "number theory"}, names)
+}
@@ -0,0 +1,263 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/cpjudge/cpjudge/storage"
+	"github.com/gobuffalo/pop"
+	"github.com/gobuffalo/pop/nulls"
+	"github.com/gobuffalo/uuid"
+	"github.com/pkg/errors"
+)
+
+// Kinds of tasks.
+const (
+	// TaskTestData checks uploaded test data with the validator of the
+	// question, generates missing answers and stores it.
+	TaskTestData = "test_data"
+	// TaskAnswers replaces the answers of the current test data with the
+	// outputs of the main solution.
+	TaskAnswers = "answers"
+	// TaskReferenceSolutions judges the pending reference solutions of the
+	// question when there are no judge workers.
+	TaskReferenceSolutions = "reference_solutions"
+	// TaskGenerate builds the test data of the question by running its
+	// generator script.
+	TaskGenerate = "generate"
+	// TaskSubmissions judges the pending submissions of the contest when
+	// there are no judge workers, such as those of an imported contest.
+	TaskSubmissions = "submissions"
+	// TaskProblemSet builds the printable statements of the contest as a PDF,
+	// kept as the output of the task.
+	TaskProblemSet = "problem_set"
+)
+
+// MaxTaskAttempts is how many times a task is started before it is given
+// up, for example because it keeps crashing the server running it.
+const MaxTaskAttempts = 3
+
+// Task is work that takes too long to do within a request, such as running
+// a validator on every test. The web server runs tasks in the background,
+// one at a time per question, and hosts see their outcome on the edit page.
+type Task struct {
+	ID         uuid.UUID  `json:"id" db:"id"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at" db:"updated_at"`
+	Kind       string     `json:"kind" db:"kind"`
+	QuestionID nulls.UUID `json:"question_id" db:"question_id"`
+	ContestID  nulls.UUID `json:"contest_id" db:"contest_id"`
+	Status     string     `json:"status" db:"status"`
+	// Input is the storage key of the data the task works on, such as
+	// uploaded test data. It is deleted once the task is done.
+	Input string `json:"input" db:"input"`
+	// Output is the storage key of what the task made, if it is kept.
+	Output      string     `json:"output" db:"output"`
+	Failed      bool       `json:"failed" db:"failed"`
+	Message     string     `json:"message" db:"message"`
+	Problems    string     `json:"problems" db:"problems"`
+	Attempts    int        `json:"attempts" db:"attempts"`
+	LeasedUntil nulls.Time `json:"leased_until" db:"leased_until"`
+}
+
+type Tasks []Task
+
+// TaskProblem is a test a task failed on, with the reason.
+type TaskProblem struct {
+	Name    string `json:"name"`
+	Message string `json:"message"`
+}
+
+// TaskInputKey is the storage key for the input of a new task.
+func TaskInputKey() string {
+	return "tasks/" + uuid.Must(uuid.NewV4()).String()
+}
+
+// Label describes the kind of the task.
+func (t Task) Label() string {
+	switch t.Kind {
+	case TaskTestData:
+		return "Test data upload"
+	case TaskAnswers:
+		return "Answer generation"
+	case TaskReferenceSolutions:
+		return "Reference solutions"
+	case TaskGenerate:
+		return "Test generation"
+	case TaskSubmissions:
+		return "Submissions"
+	case TaskProblemSet:
+		return "Problem set"
+	}
+	return t.Kind
+}
+
+// Done reports whether the task finished, successfully or not.
+func (t Task) Done() bool {
+	return t.Status == JobDone
+}
+
+// ProblemList returns the tests the task failed on.
+func (t Task) ProblemList() []TaskProblem {
+	problems := []TaskProblem{}
+	if t.Problems != "" {
+		json.Unmarshal([]byte(t.Problems), &problems)
+	}
+	return problems
+}
+
+// EnqueueTask queues a task for the background runner.
+func EnqueueTask(tx *pop.Connection, t *Task) error {
+	t.Status = JobQueued
+	return errors.WithStack(tx.Create(t))
+}
+
+// ClaimTask takes the oldest queued task, or a running task whose lease has
+// run out, skipping questions that already have a task running. Tasks that
+// were started MaxTaskAttempts times are failed instead. It returns nil when
+// there is nothing to do.
+func ClaimTask(tx *pop.Connection, now time.Time) (*Task, error) {
+	for {
+		t := &Task{}
+		err := tx.RawQuery(`SELECT * FROM tasks WHERE (status = ? OR (status = ? AND leased_until < ?))
+			AND (question_id IS NULL OR question_id NOT IN
+				(SELECT question_id FROM (SELECT question_id FROM tasks WHERE status = ? AND leased_until >= ? AND question_id IS NOT NULL) AS running))
+			ORDER BY created_at ASC LIMIT 1 FOR UPDATE`,
+			JobQueued, JobRunning, now, JobRunning, now).First(t)
+		if err != nil {
+			if errors.Cause(err) == sql.ErrNoRows {
+				return nil, nil
+			}
+			return nil, errors.WithStack(err)
+		}
+		if t.Attempts >= MaxTaskAttempts {
+			if err := t.Fail(tx, "The task was interrupted too many times.", nil); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		t.Status = JobRunning
+		t.Attempts++
+		t.LeasedUntil = nulls.NewTime(now.Add(JobLease))
+		if err := tx.Update(t); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return t, nil
+	}
+}
+
+// Renew extends the lease of a running task.
+func (t *Task) Renew(tx *pop.Connection, now time.Time) error {
+	err := tx.RawQuery("UPDATE tasks SET leased_until = ? WHERE id = ? AND status = ?",
+		now.Add(JobLease), t.ID, JobRunning).Exec()
+	return errors.WithStack(err)
+}
+
+// Succeed completes the task with a message for the host.
+func (t *Task) Succeed(tx *pop.Connection, message string) error {
+	t.Failed = false
+	return t.done(tx, message, nil)
+}
+
+// Fail completes the task as failed, with the reason and the tests at
+// fault.
+func (t *Task) Fail(tx *pop.Connection, message string, problems []TaskProblem) error {
+	t.Failed = true
+	return t.done(tx, message, problems)
+}
+
+func (t *Task) done(tx *pop.Connection, message string, problems []TaskProblem) error {
+	t.Status = JobDone
+	t.Message = message
+	t.Problems = ""
+	if len(problems) > 0 {
+		b, err := json.Marshal(problems)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		t.Problems = string(b)
+	}
+	t.LeasedUntil = nulls.Time{}
+	if t.Input != "" {
+		if err := storage.Default().Delete(t.Input); err != nil {
+			return err
+		}
+		t.Input = ""
+	}
+	return errors.WithStack(tx.Update(t))
+}
+
+// QuestionTasks returns the latest tasks of a question, newest first.
+func QuestionTasks(tx *pop.Connection, questionID uuid.UUID, limit int) (Tasks, error) {
+	tasks := Tasks{}
+	err := tx.Where("question_id = ?", questionID).Order("created_at desc").Limit(limit).All(&tasks)
+	return tasks, errors.WithStack(err)
+}
+
+// LatestContestTask returns the newest task of the kind for the contest, or
+// nil when there is none.
+func LatestContestTask(tx *pop.Connection, contestID uuid.UUID, kind string) (*Task, error) {
+	t := &Task{}
+	err := tx.Where("contest_id = ? AND kind = ?", contestID, kind).Order("created_at desc").First(t)
+	if errors.Cause(err) == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return t, nil
+}
+
+// DeleteContestTaskOutputs removes the stored outputs of the tasks of the kind for the
+// contest, except the task keep.
+func DeleteContestTaskOutputs(tx *pop.Connection, contestID uuid.UUID, kind string, keep uuid.UUID) error {
+	tasks := Tasks{}
+	if err := tx.Where("contest_id = ? AND kind = ? AND id <> ? AND output <> ''", contestID, kind, keep).All(&tasks); err != nil {
+		return errors.WithStack(err)
+	}
+	for _, t := range tasks {
+		if err := storage.Default().Delete(t.Output); err != nil {
+			return err
+		}
+		t.Output = ""
+		if err := tx.Update(&t); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+// DeleteTasks removes the tasks of the question with their stored input
+// and output.
+func (q Question) DeleteTasks(tx *pop.Connection) error {
+	return deleteTasks(tx, "question_id", q.ID)
+}
+
+// DeleteTasks removes the tasks of the contest with their stored input and
+// output.
+func (c Contest) DeleteTasks(tx *pop.Connection) error {
+	return deleteTasks(tx, "contest_id", c.ID)
+}
+
+func deleteTasks(tx *pop.Connection, column string, id uuid.UUID) error {
+	tasks := Tasks{}
+	if err := tx.Where(column+" = ?", id).All(&tasks); err != nil {
+		return errors.WithStack(err)
+	}
+	store := storage.Default()
+	for _, t := range tasks {
+		for _, key := range []string{t.Input, t.Output} {
+			if key == "" {
+				continue
+			}
+			if err := store.Delete(key); err != nil {
+				return err
+			}
+		}
+		if err := tx.Destroy(&t); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
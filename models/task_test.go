@@ -0,0 +1,116 @@
+package models_test
+
+import (
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/cpjudge/cpjudge/models"
+	"github.com/cpjudge/cpjudge/storage"
+	"github.com/gobuffalo/pop/nulls"
+)
+
+func (ms *ModelSuite) Test_Task_Lifecycle() {
+	dir, err := ioutil.TempDir("", "storage")
+	ms.NoError(err)
+	defer os.RemoveAll(dir)
+	store := storage.NewLocal(dir)
+	storage.Set(store)
+	defer storage.Set(nil)
+
+	contest := ms.pastContest(0)
+	q := &models.Question{Title: "Sum", Description: "Add", ContestID: contest.ID}
+	ms.NoError(ms.DB.Create(q))
+	first := &models.Task{Kind: models.TaskTestData, QuestionID: nulls.NewUUID(q.ID), Input: models.TaskInputKey()}
+	ms.NoError(storage.PutBytes(store, first.Input, []byte("zip")))
+	ms.NoError(models.EnqueueTask(ms.DB, first))
+	second := &models.Task{Kind: models.TaskTestData, QuestionID: nulls.NewUUID(q.ID)}
+	ms.NoError(models.EnqueueTask(ms.DB, second))
+	now := time.Now()
+
+	claimed, err := models.ClaimTask(ms.DB, now)
+	ms.NoError(err)
+	ms.Equal(first.ID, claimed.ID)
+	ms.Equal(1, claimed.Attempts)
+
+	// Tasks of a question run one at a time.
+	none, err := models.ClaimTask(ms.DB, now.Add(time.Second))
+	ms.NoError(err)
+	ms.Nil(none)
+
+	ms.NoError(claimed.Fail(ms.DB, "The validator rejected 1 inputs.", []models.TaskProblem{{Name: "1.txt", Message: "n is too large"}}))
+	ms.True(claimed.Done())
+	ms.Equal([]models.TaskProblem{{Name: "1.txt", Message: "n is too large"}}, claimed.ProblemList())
+	_, err = store.Get(first.Input)
+	ms.Equal(storage.ErrNotFound, err)
+
+	next, err := models.ClaimTask(ms.DB, now.Add(time.Second))
+	ms.NoError(err)
+	ms.Equal(second.ID, next.ID)
+	ms.NoError(next.Succeed(ms.DB, "Stored."))
+
+	tasks, err := models.QuestionTasks(ms.DB, q.ID, 5)
+	ms.NoError(err)
+	ms.Len(tasks, 2)
+}
+
+func (ms *ModelSuite) Test_ClaimTask_GivesUp() {
+	contest := ms.pastContest(0)
+	q := &models.Question{Title: "Sum", Description: "Add", ContestID: contest.ID}
+	ms.NoError(ms.DB.Create(q))
+	task := &models.Task{Kind: models.TaskTestData, QuestionID: nulls.NewUUID(q.ID)}
+	ms.NoError(models.EnqueueTask(ms.DB, task))
+
+	// The server running the task dies each time, so its lease runs out.
+	now := time.Now()
+	for i := 0; i < models.MaxTaskAttempts; i++ {
+		claimed, err := models.ClaimTask(ms.DB, now)
+		ms.NoError(err)
+		ms.Equal(task.ID, claimed.ID)
+		now = now.Add(models.JobLease + time.Second)
+	}
+	none, err := models.ClaimTask(ms.DB, now)
+	ms.NoError(err)
+	ms.Nil(none)
+	ms.NoError(ms.DB.Reload(task))
+	ms.True(task.Done())
+	ms.True(task.Failed)
+}
+
+func (ms *ModelSuite) Test_ContestTasks() {
+	dir, err := ioutil.TempDir("", "storage")
+	ms.NoError(err)
+	defer os.RemoveAll(dir)
+	store := storage.NewLocal(dir)
+	storage.Set(store)
+	defer storage.Set(nil)
+
+	contest := ms.pastContest(0)
+	latest, err := models.LatestContestTask(ms.DB, contest.ID, models.TaskProblemSet)
+	ms.NoError(err)
+	ms.Nil(latest)
+
+	old := &models.Task{Kind: models.TaskProblemSet, ContestID: nulls.NewUUID(contest.ID), Output: "problemsets/old.pdf"}
+	ms.NoError(storage.PutBytes(store, old.Output, []byte("pdf")))
+	ms.NoError(models.EnqueueTask(ms.DB, old))
+	ms.NoError(ms.DB.RawQuery("UPDATE tasks SET created_at = ? WHERE id = ?", time.Now().Add(-time.Hour), old.ID).Exec())
+	task := &models.Task{Kind: models.TaskProblemSet, ContestID: nulls.NewUUID(contest.ID), Output: "problemsets/new.pdf"}
+	ms.NoError(storage.PutBytes(store, task.Output, []byte("pdf")))
+	ms.NoError(models.EnqueueTask(ms.DB, task))
+
+	latest, err = models.LatestContestTask(ms.DB, contest.ID, models.TaskProblemSet)
+	ms.NoError(err)
+	ms.Equal(task.ID, latest.ID)
+
+	ms.NoError(models.DeleteContestTaskOutputs(ms.DB, contest.ID, models.TaskProblemSet, task.ID))
+	ms.NoError(ms.DB.Reload(old))
+	ms.Equal("", old.Output)
+	keys, err := store.List("problemsets/")
+	ms.NoError(err)
+	ms.Equal([]string{"problemsets/new.pdf"}, keys)
+
+	ms.NoError(contest.DeleteTasks(ms.DB))
+	count, err := ms.DB.Where("contest_id = ?", contest.ID).Count(&models.Task{})
+	ms.NoError(err)
+	ms.Equal(0, count)
+}
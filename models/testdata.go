@@ -0,0 +1,173 @@
+package models
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cpjudge/cpjudge/storage"
+	"github.com/gobuffalo/pop"
+	"github.com/gobuffalo/uuid"
+	"github.com/pkg/errors"
+)
+
+// TestDataVersion is an uploaded set of test cases of a question. Versions
+// are stored under TestDataKey(Checksum) and never change, so evaluations
+// running while new test data is uploaded keep using the old version.
+type TestDataVersion struct {
+	ID         uuid.UUID `json:"id" db:"id"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at" db:"updated_at"`
+	QuestionID uuid.UUID `json:"question_id" db:"question_id"`
+	Checksum   string    `json:"checksum" db:"checksum"`
+	Files      int       `json:"files" db:"files"`
+}
+
+type TestDataVersions []TestDataVersion
+
+// TestDataKey is the storage prefix of the test data with the given
+// checksum.
+func TestDataKey(checksum string) string {
+	return "testdata/" + checksum
+}
+
+// ShortChecksum abbreviates a checksum for display.
+func ShortChecksum(checksum string) string {
+	if len(checksum) > 12 {
+		return checksum[:12]
+	}
+	return checksum
+}
+
+// StoreTestData stores the test cases in a zip archive as a new version and
+// makes it the current test data of the question. Archives may wrap
+// inputs/ and answers/ in a testcases/ directory. Uploading data identical
+// to an existing version reuses the stored copy.
+func (q *Question) StoreTestData(tx *pop.Connection, archive []byte) (*TestDataVersion, error) {
+	dir, staged, err := stageTestData(archive)
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	files, err := staged.List("")
+	if err != nil {
+		return nil, err
+	}
+	sum, err := storage.Checksum(staged, "")
+	if err != nil {
+		return nil, err
+	}
+
+	store := storage.Default()
+	prefix := TestDataKey(sum) + "/"
+	stored, err := store.List(prefix)
+	if err != nil {
+		return nil, err
+	}
+	if len(stored) != len(files) {
+		if err := storage.Copy(staged, "", store, prefix); err != nil {
+			return nil, err
+		}
+	}
+
+	v := &TestDataVersion{QuestionID: q.ID, Checksum: sum, Files: len(files)}
+	if err := tx.Create(v); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	names := []string{}
+	for _, f := range files {
+		if name := strings.TrimPrefix(f, "inputs/"); name != f && !strings.Contains(name, "/") {
+			names = append(names, name)
+		}
+	}
+	q.TestCasesPath = TestDataKey(sum)
+	q.TestCasesSum = sum
+	// The new tests may be named differently, for example when they were
+	// generated.
+	q.Samples = strings.Join(remapSamples(q.SampleNames(), names), ",")
+	return v, updateQuestionColumns(tx, q.ID, map[string]interface{}{
+		"testcases_path":     q.TestCasesPath,
+		"testcases_checksum": q.TestCasesSum,
+		"samples":            q.Samples,
+	})
+}
+
+// TestDataChecksum returns the checksum test data in a zip archive would be
+// stored under.
+func TestDataChecksum(archive []byte) (string, error) {
+	dir, staged, err := stageTestData(archive)
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+	return storage.Checksum(staged, "")
+}
+
+// stageTestData unzips test data into a new temporary directory, which the
+// caller removes.
+func stageTestData(archive []byte) (string, *storage.Local, error) {
+	dir, err := ioutil.TempDir("", "testdata")
+	if err != nil {
+		return "", nil, errors.WithStack(err)
+	}
+	staged := storage.NewLocal(dir)
+	if err := storage.Unzip(archive, staged, "", "testcases/"); err != nil {
+		os.RemoveAll(dir)
+		return "", nil, err
+	}
+	return dir, staged, nil
+}
+
+// TestDataPath returns the storage prefix of a version of the question's
+// test data, or "" when the question has no such version. Test data
+// uploaded before versioning is only known by the question's checksum.
+func (q Question) TestDataPath(tx *pop.Connection, checksum string) (string, error) {
+	exists, err := tx.Where("question_id = ? and checksum = ?", q.ID, checksum).Exists(&TestDataVersion{})
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	switch {
+	case exists:
+		return TestDataKey(checksum), nil
+	case checksum == q.TestCasesSum:
+		return q.TestCasesPath, nil
+	}
+	return "", nil
+}
+
+// QuestionTestDataVersions returns the test data versions of a question,
+// newest first.
+func QuestionTestDataVersions(tx *pop.Connection, questionID uuid.UUID) (TestDataVersions, error) {
+	versions := TestDataVersions{}
+	if err := tx.Where("question_id = ?", questionID).Order("created_at desc").All(&versions); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return versions, nil
+}
+
+// DeleteTestData removes the versions of the question's test data, and the
+// stored files no other question uses.
+func (q Question) DeleteTestData(tx *pop.Connection) error {
+	versions, err := QuestionTestDataVersions(tx, q.ID)
+	if err != nil {
+		return err
+	}
+	store := storage.Default()
+	for _, v := range versions {
+		if err := tx.Destroy(&v); err != nil {
+			return errors.WithStack(err)
+		}
+		shared, err := tx.Where("checksum = ?", v.Checksum).Exists(&TestDataVersion{})
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if !shared {
+			if err := storage.DeletePrefix(store, TestDataKey(v.Checksum)+"/"); err != nil {
+				return err
+			}
+		}
+	}
+	// Test data uploaded before versioning.
+	return storage.DeletePrefix(store, q.TestCasesKey()+"/")
+}
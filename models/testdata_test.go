@@ -0,0 +1,99 @@
+package models_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"os"
+
+	"github.com/cpjudge/cpjudge/models"
+	"github.com/cpjudge/cpjudge/storage"
+)
+
+// testDataZip builds a test data archive the way hosts upload it.
+func testDataZip(files map[string]string) []byte {
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	for name, body := range files {
+		f, _ := zw.Create("testcases/" + name)
+		f.Write([]byte(body))
+	}
+	zw.Close()
+	return buf.Bytes()
+}
+
+func (ms *ModelSuite) Test_Question_StoreTestData() {
+	dir, err := ioutil.TempDir("", "storage")
+	ms.NoError(err)
+	defer os.RemoveAll(dir)
+	store := storage.NewLocal(dir)
+	storage.Set(store)
+	defer storage.Set(nil)
+
+	contest := ms.pastContest(0)
+	q := &models.Question{Title: "Sum", Description: "Add", ContestID: contest.ID}
+	ms.NoError(ms.DB.Create(q))
+	other := &models.Question{Title: "Sum again", Description: "Add", ContestID: contest.ID}
+	ms.NoError(ms.DB.Create(other))
+
+	first, err := q.StoreTestData(ms.DB, testDataZip(map[string]string{"inputs/1.txt": "1 2", "answers/1.txt": "3"}))
+	ms.NoError(err)
+	ms.Equal(2, first.Files)
+	ms.Equal(models.TestDataKey(first.Checksum), q.TestCasesPath)
+	names, err := q.TestCaseNames()
+	ms.NoError(err)
+	ms.Equal([]string{"1.txt"}, names)
+
+	// A new upload leaves the old version in place.
+	second, err := q.StoreTestData(ms.DB, testDataZip(map[string]string{"inputs/1.txt": "2 2", "answers/1.txt": "4"}))
+	ms.NoError(err)
+	ms.NotEqual(first.Checksum, second.Checksum)
+	b, err := storage.ReadAll(store, models.TestDataKey(first.Checksum)+"/answers/1.txt")
+	ms.NoError(err)
+	ms.Equal("3", string(b))
+	ms.NoError(ms.DB.Reload(q))
+	ms.Equal(second.Checksum, q.TestCasesSum)
+
+	p, err := q.TestDataPath(ms.DB, first.Checksum)
+	ms.NoError(err)
+	ms.Equal(models.TestDataKey(first.Checksum), p)
+	p, err = other.TestDataPath(ms.DB, first.Checksum)
+	ms.NoError(err)
+	ms.Equal("", p)
+	versions, err := models.QuestionTestDataVersions(ms.DB, q.ID)
+	ms.NoError(err)
+	ms.Len(versions, 2)
+
+	// Identical data shared with another question survives deletion.
+	_, err = other.StoreTestData(ms.DB, testDataZip(map[string]string{"inputs/1.txt": "1 2", "answers/1.txt": "3"}))
+	ms.NoError(err)
+	ms.Equal(first.Checksum, other.TestCasesSum)
+	ms.NoError(q.DeleteTestData(ms.DB))
+	keys, err := store.List("testdata/")
+	ms.NoError(err)
+	ms.Len(keys, 2)
+	ms.Equal(models.TestDataKey(first.Checksum)+"/answers/1.txt", keys[0])
+}
+
+func (ms *ModelSuite) Test_Question_StoreTestData_Samples() {
+	dir, err := ioutil.TempDir("", "storage")
+	ms.NoError(err)
+	defer os.RemoveAll(dir)
+	storage.Set(storage.NewLocal(dir))
+	defer storage.Set(nil)
+
+	contest := ms.pastContest(0)
+	q := &models.Question{Title: "Sum", Description: "Add", ContestID: contest.ID, Samples: "1.txt,3.txt,big.txt"}
+	ms.NoError(ms.DB.Create(q))
+
+	// Generated tests are renumbered: 1.txt is now 01.txt, and there is no
+	// third test or big.txt any more.
+	_, err = q.StoreTestData(ms.DB, testDataZip(map[string]string{
+		"inputs/01.txt": "1 2", "answers/01.txt": "3",
+		"inputs/02.txt": "2 2", "answers/02.txt": "4",
+	}))
+	ms.NoError(err)
+	ms.Equal("01.txt", q.Samples)
+	ms.NoError(ms.DB.Reload(q))
+	ms.Equal([]string{"01.txt"}, q.SampleNames())
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// PasswordResetTTL is how long a password reset link stays valid.
+const PasswordResetTTL = 2 * time.Hour
+
+// ErrNotVerified is returned by Authorize when the account exists and the
+// password matches but the email address has not been verified yet.
+var ErrNotVerified = errors.New("Email address has not been verified.")
+
+// ErrInvalidToken is returned when a verification or reset token does not
+// match any account or has expired.
+var ErrInvalidToken = errors.New("The link is invalid or has expired.")
+
+// ErrNoAccount is returned by StartPasswordReset when no account has the
+// email address.
+var ErrNoAccount = errors.New("No account has that email address.")
+
+// NewToken returns a random token to be sent to the account owner along
+// with its hash, which is the only thing stored in the database.
+func NewToken() (string, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", errors.WithStack(err)
+	}
+	token := hex.EncodeToString(b)
+	return token, HashToken(token), nil
+}
+
+// HashToken returns the hex encoded sha256 hash of a token.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
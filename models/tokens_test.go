@@ -0,0 +1,52 @@
+package models_test
+
+import (
+	"github.com/cpjudge/cpjudge/models"
+)
+
+func (ms *ModelSuite) Test_User_Verification() {
+	u := &models.User{Username: "ada", Email: "Ada@example.com", Password: "secret", PasswordConfirm: "secret"}
+	verrs, err := u.Create(ms.DB)
+	ms.NoError(err)
+	ms.False(verrs.HasAny())
+	ms.NotEmpty(u.VerificationToken)
+
+	login := &models.User{Email: "ada@example.com", Password: "secret"}
+	ms.Equal(models.ErrNotVerified, login.Authorize(ms.DB))
+
+	_, err = models.VerifyUser(ms.DB, "bogus")
+	ms.Equal(models.ErrInvalidToken, err)
+	_, err = models.VerifyUser(ms.DB, u.VerificationToken)
+	ms.NoError(err)
+	ms.NoError(login.Authorize(ms.DB))
+}
+
+func (ms *ModelSuite) Test_Host_PasswordReset() {
+	h := &models.Host{Hostname: "acm", Email: "acm@example.com", Password: "old", PasswordConfirm: "old"}
+	verrs, err := h.Create(ms.DB)
+	ms.NoError(err)
+	ms.False(verrs.HasAny())
+
+	_, err = (&models.Host{Email: "nobody@example.com"}).StartPasswordReset(ms.DB)
+	ms.Equal(models.ErrNoAccount, err)
+
+	token, err := (&models.Host{Email: "ACM@example.com"}).StartPasswordReset(ms.DB)
+	ms.NoError(err)
+
+	found, err := models.FindHostByResetToken(ms.DB, token)
+	ms.NoError(err)
+	found.Password = "new"
+	found.PasswordConfirm = "nope"
+	verrs, err = found.ResetPassword(ms.DB)
+	ms.NoError(err)
+	ms.True(verrs.HasAny())
+
+	found.PasswordConfirm = "new"
+	verrs, err = found.ResetPassword(ms.DB)
+	ms.NoError(err)
+	ms.False(verrs.HasAny())
+
+	_, err = models.FindHostByResetToken(ms.DB, token)
+	ms.Equal(models.ErrInvalidToken, err)
+	ms.NoError((&models.Host{Email: "acm@example.com", Password: "new"}).Authorize(ms.DB))
+}
@@ -0,0 +1,153 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/pop"
+	"github.com/gobuffalo/uuid"
+	"github.com/pkg/errors"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+)
+
+// RecoveryCodeCount is how many recovery codes are issued at enrollment.
+const RecoveryCodeCount = 10
+
+// totpPeriod is the length in seconds of a TOTP time step.
+const totpPeriod = 30
+
+// ErrInvalidCode is returned when a code does not match the TOTP secret.
+var ErrInvalidCode = errors.New("The code is not valid. Check the time on your device and try again.")
+
+// TwoFactor holds the TOTP secret of a user or host. OwnerID is the id of
+// the account; user and host ids never collide.
+type TwoFactor struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	OwnerID   uuid.UUID `json:"owner_id" db:"owner_id"`
+	Secret    string    `json:"-" db:"secret"`
+	Enabled   bool      `json:"enabled" db:"enabled"`
+	// LastStep is the time step of the last accepted code. A code is only
+	// accepted once, so codes at or before it are rejected.
+	LastStep int64 `json:"-" db:"last_step"`
+}
+
+// RecoveryCode is a single use code that replaces a TOTP code.
+type RecoveryCode struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	OwnerID   uuid.UUID `json:"owner_id" db:"owner_id"`
+	CodeHash  string    `json:"-" db:"code_hash"`
+	Used      bool      `json:"used" db:"used"`
+}
+
+type RecoveryCodes []RecoveryCode
+
+// FindTwoFactor returns the two-factor settings of an account. Accounts that
+// never enrolled get a disabled TwoFactor.
+func FindTwoFactor(tx *pop.Connection, ownerID uuid.UUID) (*TwoFactor, error) {
+	tf := &TwoFactor{}
+	err := tx.Where("owner_id = ?", ownerID).First(tf)
+	if err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return &TwoFactor{OwnerID: ownerID}, nil
+		}
+		return tf, errors.WithStack(err)
+	}
+	return tf, nil
+}
+
+// NewTOTPKey generates a new TOTP secret for the account. The key's URL is
+// what authenticator apps scan from the QR code.
+func NewTOTPKey(accountName string) (*otp.Key, error) {
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      "CP Judge",
+		AccountName: accountName,
+	})
+	return key, errors.WithStack(err)
+}
+
+// Enable turns on two-factor authentication with secret once code proves
+// the authenticator app is set up. It returns fresh recovery codes.
+func (tf *TwoFactor) Enable(tx *pop.Connection, secret, code string) ([]string, error) {
+	step, ok := totpStep(strings.TrimSpace(code), secret, time.Now())
+	if !ok {
+		return nil, ErrInvalidCode
+	}
+	tf.Secret = secret
+	tf.Enabled = true
+	tf.LastStep = step
+	if err := tx.Save(tf); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return GenerateRecoveryCodes(tx, tf.OwnerID)
+}
+
+// Check validates a TOTP code or, failing that, consumes a recovery code.
+// A TOTP code that was already accepted is rejected, so a code seen by
+// someone else cannot be replayed while it is still valid.
+func (tf *TwoFactor) Check(tx *pop.Connection, code string) (bool, error) {
+	code = strings.TrimSpace(code)
+	if !tf.Enabled {
+		return false, nil
+	}
+	if step, ok := totpStep(code, tf.Secret, time.Now()); ok {
+		if step <= tf.LastStep {
+			return false, nil
+		}
+		tf.LastStep = step
+		return true, errors.WithStack(tx.Update(tf))
+	}
+	rc := &RecoveryCode{}
+	err := tx.Where("owner_id = ? and code_hash = ? and used = ?", tf.OwnerID, HashToken(strings.ToLower(code)), false).First(rc)
+	if err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, errors.WithStack(err)
+	}
+	rc.Used = true
+	return true, errors.WithStack(tx.Update(rc))
+}
+
+// totpStep returns the time step whose code matches code. Like
+// totp.Validate it allows one step of clock skew either way.
+func totpStep(code, secret string, now time.Time) (int64, bool) {
+	for _, skew := range []int64{0, -1, 1} {
+		t := now.Add(time.Duration(skew*totpPeriod) * time.Second)
+		expected, err := totp.GenerateCode(secret, t)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(code), []byte(expected)) == 1 {
+			return t.Unix() / totpPeriod, true
+		}
+	}
+	return 0, false
+}
+
+// GenerateRecoveryCodes replaces the recovery codes of an account.
+func GenerateRecoveryCodes(tx *pop.Connection, ownerID uuid.UUID) ([]string, error) {
+	if err := tx.RawQuery("DELETE FROM recovery_codes WHERE owner_id = ?", ownerID).Exec(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	codes := make([]string, RecoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		codes[i] = fmt.Sprintf("%x-%x", b[:2], b[2:])
+		if err := tx.Create(&RecoveryCode{OwnerID: ownerID, CodeHash: HashToken(codes[i])}); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	return codes, nil
+}
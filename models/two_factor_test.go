@@ -0,0 +1,65 @@
+package models_test
+
+import (
+	"time"
+
+	"github.com/cpjudge/cpjudge/models"
+	"github.com/gobuffalo/uuid"
+	"github.com/pquerna/otp/totp"
+)
+
+func (ms *ModelSuite) Test_TwoFactor() {
+	ownerID := uuid.Must(uuid.NewV4())
+	tf, err := models.FindTwoFactor(ms.DB, ownerID)
+	ms.NoError(err)
+	ms.False(tf.Enabled)
+
+	key, err := models.NewTOTPKey("ada@example.com")
+	ms.NoError(err)
+	_, err = tf.Enable(ms.DB, key.Secret(), "000000x")
+	ms.Equal(models.ErrInvalidCode, err)
+
+	code, err := totp.GenerateCode(key.Secret(), time.Now())
+	ms.NoError(err)
+	codes, err := tf.Enable(ms.DB, key.Secret(), code)
+	ms.NoError(err)
+	ms.Len(codes, models.RecoveryCodeCount)
+
+	tf, err = models.FindTwoFactor(ms.DB, ownerID)
+	ms.NoError(err)
+	ms.True(tf.Enabled)
+
+	// The code that enabled two-factor authentication is used up.
+	ok, err := tf.Check(ms.DB, code)
+	ms.NoError(err)
+	ms.False(ok)
+
+	next, err := totp.GenerateCode(key.Secret(), time.Now().Add(30*time.Second))
+	ms.NoError(err)
+	ok, err = tf.Check(ms.DB, next)
+	ms.NoError(err)
+	ms.True(ok)
+	ok, err = tf.Check(ms.DB, next)
+	ms.NoError(err)
+	ms.False(ok)
+
+	// Codes of earlier steps cannot be used after a later one.
+	previous, err := totp.GenerateCode(key.Secret(), time.Now().Add(-30*time.Second))
+	ms.NoError(err)
+	ok, err = tf.Check(ms.DB, previous)
+	ms.NoError(err)
+	ms.False(ok)
+
+	tf, err = models.FindTwoFactor(ms.DB, ownerID)
+	ms.NoError(err)
+	ok, err = tf.Check(ms.DB, next)
+	ms.NoError(err)
+	ms.False(ok)
+
+	ok, err = tf.Check(ms.DB, codes[0])
+	ms.NoError(err)
+	ms.True(ok)
+	ok, err = tf.Check(ms.DB, codes[0])
+	ms.NoError(err)
+	ms.False(ok)
+}
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/gobuffalo/pop"
+	"github.com/gobuffalo/pop/nulls"
 	"github.com/gobuffalo/uuid"
 	"github.com/gobuffalo/validate"
 	"github.com/gobuffalo/validate/validators"
@@ -25,6 +26,17 @@ type User struct {
 	PasswordHash    string    `json:"-" db:"password_hash"`
 	Password        string    `json:"-" db:"-"`
 	PasswordConfirm string    `json:"-" db:"-"`
+
+	Verified              bool       `json:"-" db:"verified"`
+	VerificationTokenHash string     `json:"-" db:"verification_token_hash"`
+	VerificationToken     string     `json:"-" db:"-"`
+	ResetTokenHash        string     `json:"-" db:"reset_token_hash"`
+	ResetTokenExpiresAt   nulls.Time `json:"-" db:"reset_token_expires_at"`
+
+	DisplayName string `json:"display_name" db:"display_name"`
+	Institution string `json:"institution" db:"institution"`
+	Country     string `json:"country" db:"country"`
+	Rating      int    `json:"rating" db:"rating"`
 }
 
 // String is not required by pop and may be deleted
@@ -106,11 +118,19 @@ func (v *EmailNotTaken) IsValid(errors *validate.Errors) {
 func (u *User) Create(tx *pop.Connection) (*validate.Errors, error) {
 	u.Email = strings.ToLower(u.Email)
 	u.Admin = false
+	u.Rating = InitialRating
 	pwdHash, err := bcrypt.GenerateFromPassword([]byte(u.Password), bcrypt.DefaultCost)
 	if err != nil {
 		return validate.NewErrors(), errors.WithStack(err)
 	}
 	u.PasswordHash = string(pwdHash)
+	u.Verified = false
+	token, hash, err := NewToken()
+	if err != nil {
+		return validate.NewErrors(), errors.WithStack(err)
+	}
+	u.VerificationToken = token
+	u.VerificationTokenHash = hash
 	return tx.ValidateAndCreate(u)
 }
 
@@ -129,5 +149,122 @@ func (u *User) Authorize(tx *pop.Connection) error {
 	if err != nil {
 		return errors.New("Invalid password.")
 	}
+	if !u.Verified {
+		return ErrNotVerified
+	}
 	return nil
 }
+
+// RefreshVerificationToken replaces the user's verification token with a new
+// one. The plain token is left in VerificationToken so it can be mailed.
+func (u *User) RefreshVerificationToken(tx *pop.Connection) error {
+	token, hash, err := NewToken()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	u.VerificationToken = token
+	u.VerificationTokenHash = hash
+	return errors.WithStack(tx.Update(u))
+}
+
+// VerifyUser marks the user owning the given verification token as verified.
+func VerifyUser(tx *pop.Connection, token string) (*User, error) {
+	u := &User{}
+	if token == "" {
+		return u, ErrInvalidToken
+	}
+	err := tx.Where("verification_token_hash = ?", HashToken(token)).First(u)
+	if err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return u, ErrInvalidToken
+		}
+		return u, errors.WithStack(err)
+	}
+	u.Verified = true
+	u.VerificationTokenHash = ""
+	return u, errors.WithStack(tx.Update(u))
+}
+
+// StartPasswordReset looks up the user by email and stores a new reset token
+// valid for PasswordResetTTL. The plain token is returned so it can be mailed.
+func (u *User) StartPasswordReset(tx *pop.Connection) (string, error) {
+	err := tx.Where("email = ?", strings.ToLower(u.Email)).First(u)
+	if err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return "", ErrNoAccount
+		}
+		return "", errors.WithStack(err)
+	}
+	token, hash, err := NewToken()
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	u.ResetTokenHash = hash
+	u.ResetTokenExpiresAt = nulls.NewTime(time.Now().Add(PasswordResetTTL))
+	return token, errors.WithStack(tx.Update(u))
+}
+
+// FindUserByResetToken returns the user owning an unexpired reset token.
+func FindUserByResetToken(tx *pop.Connection, token string) (*User, error) {
+	u := &User{}
+	if token == "" {
+		return u, ErrInvalidToken
+	}
+	err := tx.Where("reset_token_hash = ?", HashToken(token)).First(u)
+	if err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return u, ErrInvalidToken
+		}
+		return u, errors.WithStack(err)
+	}
+	if !u.ResetTokenExpiresAt.Valid || time.Now().After(u.ResetTokenExpiresAt.Time) {
+		return u, ErrInvalidToken
+	}
+	return u, nil
+}
+
+// ResetPassword sets a new password and invalidates the reset token. Following
+// a reset link also proves ownership of the email address.
+func (u *User) ResetPassword(tx *pop.Connection) (*validate.Errors, error) {
+	verrs := validate.Validate(
+		&validators.StringIsPresent{Field: u.Password, Name: "Password"},
+		&validators.StringsMatch{Name: "Password", Field: u.Password, Field2: u.PasswordConfirm, Message: "Passwords do not match."},
+	)
+	if verrs.HasAny() {
+		return verrs, nil
+	}
+	pwdHash, err := bcrypt.GenerateFromPassword([]byte(u.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return verrs, errors.WithStack(err)
+	}
+	u.PasswordHash = string(pwdHash)
+	u.ResetTokenHash = ""
+	u.ResetTokenExpiresAt = nulls.Time{}
+	u.Verified = true
+	u.VerificationTokenHash = ""
+	return verrs, errors.WithStack(tx.Update(u))
+}
+
+// UpdateProfile validates and saves the editable profile fields.
+func (u *User) UpdateProfile(tx *pop.Connection) (*validate.Errors, error) {
+	u.DisplayName = strings.TrimSpace(u.DisplayName)
+	u.Institution = strings.TrimSpace(u.Institution)
+	u.Country = strings.TrimSpace(u.Country)
+	verrs := validate.Validate(
+		&validators.StringLengthInRange{Field: u.DisplayName, Name: "DisplayName", Max: 64},
+		&validators.StringLengthInRange{Field: u.Institution, Name: "Institution", Max: 128},
+		&validators.StringLengthInRange{Field: u.Country, Name: "Country", Max: 64},
+	)
+	if verrs.HasAny() {
+		return verrs, nil
+	}
+	return verrs, errors.WithStack(tx.Update(u))
+}
+
+// Name returns the display name of the user, falling back to the username.
+func (u User) Name() string {
+	if u.DisplayName != "" {
+		return u.DisplayName
+	}
+	return u.Username
+}
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/cpjudge/cpjudge/storage"
+	"github.com/gobuffalo/pop"
+	"github.com/pkg/errors"
+)
+
+// programExtensions are the languages the programs hosts upload, validators
+// and reference solutions, can be written in.
+var programExtensions = map[string]bool{".c": true, ".cpp": true, ".cc": true}
+
+// ProgramExtension returns the extension of a validator or reference
+// solution source file, or "" if it is not written in C or C++.
+func ProgramExtension(name string) string {
+	ext := strings.ToLower(path.Ext(name))
+	if !programExtensions[ext] {
+		return ""
+	}
+	return ext
+}
+
+// ValidatorKey is the storage key of the question's validator source.
+func (q Question) ValidatorKey(ext string) string {
+	return "validators/validator_" + q.ID.String() + ext
+}
+
+// HasValidator reports whether the question has an input validator.
+func (q Question) HasValidator() bool {
+	return q.ValidatorPath != ""
+}
+
+// StoreValidator stores the source of the question's input validator,
+// replacing the previous one.
+func (q *Question) StoreValidator(tx *pop.Connection, name string, source []byte) error {
+	ext := ProgramExtension(name)
+	if ext == "" {
+		return errors.Errorf("%s is not a C or C++ source.", name)
+	}
+	store := storage.Default()
+	key := q.ValidatorKey(ext)
+	if err := storage.PutBytes(store, key, source); err != nil {
+		return err
+	}
+	if q.ValidatorPath != "" && q.ValidatorPath != key {
+		if err := store.Delete(q.ValidatorPath); err != nil {
+			return err
+		}
+	}
+	q.ValidatorPath = key
+	return updateQuestionColumns(tx, q.ID, map[string]interface{}{"validator_path": q.ValidatorPath})
+}
+
+// DownloadValidator copies the validator source into dir and returns its
+// path.
+func (q Question) DownloadValidator(dir string) (string, error) {
+	dst := filepath.Join(dir, path.Base(q.ValidatorPath))
+	return dst, storage.DownloadFile(storage.Default(), q.ValidatorPath, dst)
+}
+
+// DeleteValidator removes the stored validator source.
+func (q Question) DeleteValidator() error {
+	if q.ValidatorPath == "" {
+		return nil
+	}
+	return storage.Default().Delete(q.ValidatorPath)
+}
@@ -0,0 +1,36 @@
+package models_test
+
+import (
+	"io/ioutil"
+	"os"
+
+	"github.com/cpjudge/cpjudge/models"
+	"github.com/cpjudge/cpjudge/storage"
+)
+
+func (ms *ModelSuite) Test_Question_StoreValidator() {
+	dir, err := ioutil.TempDir("", "storage")
+	ms.NoError(err)
+	defer os.RemoveAll(dir)
+	store := storage.NewLocal(dir)
+	storage.Set(store)
+	defer storage.Set(nil)
+
+	contest := ms.pastContest(0)
+	q := &models.Question{Title: "Sum", Description: "Add", ContestID: contest.ID}
+	ms.NoError(ms.DB.Create(q))
+	ms.False(q.HasValidator())
+	ms.Error(q.StoreValidator(ms.DB, "validate.py", []byte("exit(0)")))
+
+	ms.NoError(q.StoreValidator(ms.DB, "validate.c", []byte("int main() {}")))
+	first := q.ValidatorPath
+	ms.NoError(q.StoreValidator(ms.DB, "Validate.CPP", []byte("int main() {}")))
+	ms.NoError(ms.DB.Reload(q))
+	ms.Equal(q.ValidatorKey(".cpp"), q.ValidatorPath)
+	_, err = store.Get(first)
+	ms.Equal(storage.ErrNotFound, err)
+
+	ms.NoError(q.DeleteValidator())
+	_, err = store.Get(q.ValidatorPath)
+	ms.Equal(storage.ErrNotFound, err)
+}
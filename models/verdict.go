@@ -0,0 +1,81 @@
+package models
+
+import (
+	"time"
+
+	"github.com/gobuffalo/pop"
+	"github.com/gobuffalo/uuid"
+	"github.com/pkg/errors"
+)
+
+// Verdict is a previous verdict of a submission, kept when it is rejudged.
+type Verdict struct {
+	ID           uuid.UUID `json:"id" db:"id"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+	SubmissionID uuid.UUID `json:"submission_id" db:"submission_id"`
+	Status       string    `json:"status" db:"status"`
+	Reason       string    `json:"reason" db:"reason"`
+}
+
+type Verdicts []Verdict
+
+// SubmissionVerdicts returns the verdict history of a submission, oldest
+// first.
+func SubmissionVerdicts(tx *pop.Connection, submissionID uuid.UUID) (Verdicts, error) {
+	verdicts := Verdicts{}
+	if err := tx.Where("submission_id = ?", submissionID).Order("created_at asc").All(&verdicts); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return verdicts, nil
+}
+
+// RejudgeFilter selects the submissions to rejudge. Exactly one of
+// SubmissionID, QuestionID and ContestID should be set. When Statuses is not
+// empty only submissions with one of those verdicts, on a sample or not, are
+// selected.
+type RejudgeFilter struct {
+	SubmissionID uuid.UUID
+	QuestionID   uuid.UUID
+	ContestID    uuid.UUID
+	Statuses     []string
+}
+
+// Submissions returns the submissions matching the filter, oldest first.
+func (f RejudgeFilter) Submissions(tx *pop.Connection) (Submissions, error) {
+	q := tx.Q()
+	switch {
+	case f.SubmissionID != uuid.Nil:
+		q = q.Where("id = ?", f.SubmissionID)
+	case f.QuestionID != uuid.Nil:
+		q = q.Where("question_id = ?", f.QuestionID)
+	case f.ContestID != uuid.Nil:
+		q = q.Where("contest_id = ?", f.ContestID)
+	default:
+		return nil, errors.New("rejudge filter selects no submissions")
+	}
+	if len(f.Statuses) > 0 {
+		args := []interface{}{}
+		for _, s := range f.Statuses {
+			args = append(args, s, SampleStatus(s))
+		}
+		q = q.Where("status in (?)", args...)
+	}
+	submissions := Submissions{}
+	if err := q.Order("created_at asc").All(&submissions); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return submissions, nil
+}
+
+// ResetForRejudge records the current verdict of a submission in its
+// history and marks it pending again.
+func (s *Submission) ResetForRejudge(tx *pop.Connection, reason string) error {
+	if s.Status != StatusPending {
+		if err := tx.Create(&Verdict{SubmissionID: s.ID, Status: s.Status, Reason: reason}); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	s.Status = StatusPending
+	return errors.WithStack(tx.Update(s))
+}
@@ -0,0 +1,47 @@
+package models_test
+
+import (
+	"github.com/cpjudge/cpjudge/models"
+	"github.com/gobuffalo/uuid"
+)
+
+func (ms *ModelSuite) Test_RejudgeFilter() {
+	contestID := uuid.Must(uuid.NewV4())
+	q1, q2 := uuid.Must(uuid.NewV4()), uuid.Must(uuid.NewV4())
+	ada := ms.createUser("ada")
+	ms.createSubmission(ada, contestID, q1, models.StatusWrong, "C")
+	ms.createSubmission(ada, contestID, q1, models.StatusCorrect, "C")
+	ms.createSubmission(ada, contestID, q2, models.StatusTimeLimit, "C")
+	ms.createSubmission(ada, contestID, q2, models.SampleStatus(models.StatusRuntimeError), "C")
+
+	subs, err := models.RejudgeFilter{QuestionID: q1}.Submissions(ms.DB)
+	ms.NoError(err)
+	ms.Len(subs, 2)
+
+	subs, err = models.RejudgeFilter{ContestID: contestID, Statuses: []string{models.StatusWrong, models.StatusTimeLimit}}.Submissions(ms.DB)
+	ms.NoError(err)
+	ms.Len(subs, 2)
+
+	subs, err = models.RejudgeFilter{ContestID: contestID, Statuses: []string{models.StatusRuntimeError}}.Submissions(ms.DB)
+	ms.NoError(err)
+	ms.Len(subs, 1)
+
+	_, err = models.RejudgeFilter{}.Submissions(ms.DB)
+	ms.Error(err)
+}
+
+func (ms *ModelSuite) Test_Submission_ResetForRejudge() {
+	ada := ms.createUser("ada")
+	ms.createSubmission(ada, uuid.Must(uuid.NewV4()), uuid.Must(uuid.NewV4()), models.StatusWrong, "C")
+	s := &models.Submission{}
+	ms.NoError(ms.DB.First(s))
+
+	ms.NoError(s.ResetForRejudge(ms.DB, "Rejudged by host"))
+	ms.Equal(models.StatusPending, s.Status)
+
+	verdicts, err := models.SubmissionVerdicts(ms.DB, s.ID)
+	ms.NoError(err)
+	ms.Len(verdicts, 1)
+	ms.Equal(models.StatusWrong, verdicts[0].Status)
+	ms.Equal("Rejudged by host", verdicts[0].Reason)
+}
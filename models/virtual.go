@@ -0,0 +1,142 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/gobuffalo/pop"
+	"github.com/gobuffalo/uuid"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrContestNotReplayable is returned when a contest without a fixed
+	// duration is started virtually.
+	ErrContestNotReplayable = errors.New("Only contests with a start and end time can be replayed.")
+	// ErrAlreadyParticipated is returned when a user starts a virtual
+	// participation in a contest they took part in or already replayed.
+	ErrAlreadyParticipated = errors.New("You have already participated in this contest.")
+)
+
+// VirtualParticipation is a user's replay of a past contest on a personal
+// timer.
+type VirtualParticipation struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	UserID    uuid.UUID `json:"user_id" db:"user_id"`
+	ContestID uuid.UUID `json:"contest_id" db:"contest_id"`
+	StartedAt time.Time `json:"started_at" db:"started_at"`
+	EndsAt    time.Time `json:"ends_at" db:"ends_at"`
+}
+
+type VirtualParticipations []VirtualParticipation
+
+// Duration returns the length of a contest with a start and end time.
+func (c Contest) Duration() time.Duration {
+	if !c.StartTime.Valid || !c.EndTime.Valid {
+		return 0
+	}
+	return c.EndTime.Time.Sub(c.StartTime.Time)
+}
+
+// Running reports whether the virtual contest is still going on at t.
+func (vp VirtualParticipation) Running(t time.Time) bool {
+	return !t.Before(vp.StartedAt) && t.Before(vp.EndsAt)
+}
+
+// Elapsed returns the virtual contest time at t, capped at the contest
+// duration.
+func (vp VirtualParticipation) Elapsed(t time.Time) time.Duration {
+	if t.After(vp.EndsAt) {
+		t = vp.EndsAt
+	}
+	if t.Before(vp.StartedAt) {
+		return 0
+	}
+	return t.Sub(vp.StartedAt)
+}
+
+// StartVirtualParticipation starts the timer of a user for an ended contest.
+func StartVirtualParticipation(tx *pop.Connection, user *User, contest *Contest, now time.Time) (*VirtualParticipation, error) {
+	if contest.Duration() <= 0 {
+		return nil, ErrContestNotReplayable
+	}
+	if !contest.Ended(now) {
+		return nil, ErrContestNotEnded
+	}
+	participated, err := tx.Where("user_id = ? and contest_id = ? and practice = ?", user.ID, contest.ID, false).Exists(&Submission{})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if !participated {
+		participated, err = tx.Where("user_id = ? and contest_id = ?", user.ID, contest.ID).Exists(&VirtualParticipation{})
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	if participated {
+		return nil, ErrAlreadyParticipated
+	}
+	vp := &VirtualParticipation{
+		UserID:    user.ID,
+		ContestID: contest.ID,
+		StartedAt: now,
+		EndsAt:    now.Add(contest.Duration()),
+	}
+	if err := tx.Create(vp); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return vp, nil
+}
+
+// FindVirtualParticipation returns the virtual participation of a user in a
+// contest, or nil if they have not started one.
+func FindVirtualParticipation(tx *pop.Connection, userID, contestID uuid.UUID) (*VirtualParticipation, error) {
+	vp := &VirtualParticipation{}
+	err := tx.Where("user_id = ? and contest_id = ?", userID, contestID).First(vp)
+	if err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, errors.WithStack(err)
+	}
+	return vp, nil
+}
+
+// Replaying reports whether a user is in the middle of a virtual
+// participation in a contest at t. Editorials and shared solutions of the
+// contest are hidden from them until it ends.
+func Replaying(tx *pop.Connection, userID, contestID uuid.UUID, t time.Time) (bool, error) {
+	vp, err := FindVirtualParticipation(tx, userID, contestID)
+	if err != nil {
+		return false, err
+	}
+	return vp != nil && vp.Running(t), nil
+}
+
+// VirtualStandings ghost-ranks a virtual participant against the original
+// contestants: only submissions made up to the same elapsed contest time are
+// counted.
+func VirtualStandings(tx *pop.Connection, vp *VirtualParticipation, contest *Contest, now time.Time) (Standings, error) {
+	elapsed := vp.Elapsed(now)
+	original := Submissions{}
+	err := tx.Where("contest_id = ? and practice = ? and created_at <= ?",
+		contest.ID, false, contest.StartTime.Time.Add(elapsed)).All(&original)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	virtual := Submissions{}
+	err = tx.Where("virtual_participation_id = ? and elapsed <= ?", vp.ID, int(elapsed.Seconds())).All(&virtual)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	standings, err := RankSubmissions(tx, append(original, virtual...))
+	if err != nil {
+		return nil, err
+	}
+	for i := range standings {
+		standings[i].Virtual = standings[i].UserID == vp.UserID
+	}
+	return standings, nil
+}
@@ -0,0 +1,118 @@
+package models_test
+
+import (
+	"time"
+
+	"github.com/cpjudge/cpjudge/models"
+	"github.com/gobuffalo/pop/nulls"
+	"github.com/gobuffalo/uuid"
+)
+
+func (ms *ModelSuite) pastContest(duration time.Duration) *models.Contest {
+	end := time.Now().Add(-time.Hour)
+	contest := &models.Contest{
+		Title:       "Old Round",
+		Description: "An old round",
+		StartTime:   nulls.NewTime(end.Add(-duration)),
+		EndTime:     nulls.NewTime(end),
+	}
+	ms.NoError(ms.DB.Create(contest))
+	return contest
+}
+
+func (ms *ModelSuite) Test_StartVirtualParticipation() {
+	contest := ms.pastContest(2 * time.Hour)
+	ada := ms.createUser("ada")
+	now := time.Now()
+
+	vp, err := models.StartVirtualParticipation(ms.DB, ada, contest, now)
+	ms.NoError(err)
+	ms.True(vp.Running(now.Add(time.Hour)))
+	ms.False(vp.Running(now.Add(2 * time.Hour)))
+	ms.Equal(2*time.Hour, vp.Elapsed(now.Add(3*time.Hour)))
+
+	_, err = models.StartVirtualParticipation(ms.DB, ada, contest, now)
+	ms.Equal(models.ErrAlreadyParticipated, err)
+
+	found, err := models.FindVirtualParticipation(ms.DB, ada.ID, contest.ID)
+	ms.NoError(err)
+	ms.Equal(vp.ID, found.ID)
+}
+
+func (ms *ModelSuite) Test_StartVirtualParticipation_Rejected() {
+	open := &models.Contest{Title: "Open", Description: "No times"}
+	ms.NoError(ms.DB.Create(open))
+	ada, bob := ms.createUser("ada"), ms.createUser("bob")
+	_, err := models.StartVirtualParticipation(ms.DB, ada, open, time.Now())
+	ms.Equal(models.ErrContestNotReplayable, err)
+
+	contest := ms.pastContest(time.Hour)
+	ms.createSubmission(bob, contest.ID, uuid.Must(uuid.NewV4()), models.StatusCorrect, "C")
+	_, err = models.StartVirtualParticipation(ms.DB, bob, contest, time.Now())
+	ms.Equal(models.ErrAlreadyParticipated, err)
+}
+
+func (ms *ModelSuite) Test_VirtualStandings() {
+	contest := ms.pastContest(2 * time.Hour)
+	q1, q2 := uuid.Must(uuid.NewV4()), uuid.Must(uuid.NewV4())
+	ada, bob := ms.createUser("ada"), ms.createUser("bob")
+	// ada solved one problem early and one late in the original contest.
+	for i, offset := range []time.Duration{10 * time.Minute, 100 * time.Minute} {
+		s := &models.Submission{UserID: ada.ID, ContestID: contest.ID, QuestionID: []uuid.UUID{q1, q2}[i], Status: models.StatusCorrect, Language: "C"}
+		ms.NoError(ms.DB.Create(s))
+		ms.NoError(ms.DB.RawQuery("UPDATE submissions SET created_at = ? WHERE id = ?", contest.StartTime.Time.Add(offset), s.ID).Exec())
+	}
+
+	now := time.Now()
+	vp, err := models.StartVirtualParticipation(ms.DB, bob, contest, now.Add(-30*time.Minute))
+	ms.NoError(err)
+	ms.NoError(ms.DB.Create(&models.Submission{
+		UserID:                 bob.ID,
+		ContestID:              contest.ID,
+		QuestionID:             q1,
+		Status:                 models.StatusCorrect,
+		Language:               "C",
+		Practice:               true,
+		VirtualParticipationID: nulls.NewUUID(vp.ID),
+		Elapsed:                int((5 * time.Minute).Seconds()),
+	}))
+
+	// Thirty minutes in, ada has one problem, like bob.
+	standings, err := models.VirtualStandings(ms.DB, vp, contest, now)
+	ms.NoError(err)
+	ms.Len(standings, 2)
+	ms.Equal(1, standings[0].Rank)
+	ms.Equal(1, standings[1].Rank)
+	row, ok := standings.Find(bob.ID)
+	ms.True(ok)
+	ms.True(row.Virtual)
+
+	// By the end, ada is ahead.
+	standings, err = models.VirtualStandings(ms.DB, vp, contest, now.Add(3*time.Hour))
+	ms.NoError(err)
+	ms.Equal("ada", standings[0].Username)
+	ms.Equal(2, standings[0].Correct)
+
+	// The virtual submission does not count in the real standings.
+	standings, err = models.ContestStandings(ms.DB, contest.ID)
+	ms.NoError(err)
+	ms.Len(standings, 1)
+}
+
+func (ms *ModelSuite) Test_Replaying() {
+	contest := ms.pastContest(2 * time.Hour)
+	ada, bob := ms.createUser("ada"), ms.createUser("bob")
+	now := time.Now()
+	_, err := models.StartVirtualParticipation(ms.DB, ada, contest, now)
+	ms.NoError(err)
+
+	replaying, err := models.Replaying(ms.DB, ada.ID, contest.ID, now.Add(time.Hour))
+	ms.NoError(err)
+	ms.True(replaying)
+	replaying, err = models.Replaying(ms.DB, ada.ID, contest.ID, now.Add(3*time.Hour))
+	ms.NoError(err)
+	ms.False(replaying)
+	replaying, err = models.Replaying(ms.DB, bob.ID, contest.ID, now)
+	ms.NoError(err)
+	ms.False(replaying)
+}
@@ -0,0 +1,298 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/gobuffalo/pop"
+	"github.com/gobuffalo/pop/nulls"
+	"github.com/gobuffalo/uuid"
+	"github.com/pkg/errors"
+)
+
+// WorkerTimeout is how long a worker may go without a heartbeat before it
+// is shown as offline.
+const WorkerTimeout = 30 * time.Second
+
+// JobLease is how long a job stays assigned to a worker without progress
+// or heartbeats. Jobs whose lease runs out are handed to another worker.
+const JobLease = time.Minute
+
+// MaxJobAttempts is how many times a job is handed to a worker before it is
+// given up, for example because the submission keeps crashing workers.
+const MaxJobAttempts = 3
+
+// WorkerRetention is how long an offline worker is listed before it is
+// removed. Workers register anew each time they start.
+const WorkerRetention = 24 * time.Hour
+
+// States of a judge job.
+const (
+	JobQueued  = "queued"
+	JobRunning = "running"
+	JobDone    = "done"
+)
+
+// ErrJobNotAssigned is returned when a worker reports on a job that is no
+// longer assigned to it, usually because it was reassigned.
+var ErrJobNotAssigned = errors.New("Job is not assigned to this worker.")
+
+// Worker is a judge process registered with the server.
+type Worker struct {
+	ID         uuid.UUID `json:"id" db:"id"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at" db:"updated_at"`
+	Name       string    `json:"name" db:"name"`
+	LastSeenAt time.Time `json:"last_seen_at" db:"last_seen_at"`
+	Judged     int       `json:"judged" db:"judged"`
+	Job        *JudgeJob `json:"-" db:"-"`
+}
+
+type Workers []Worker
+
+// Online reports whether the worker sent a heartbeat recently.
+func (w Worker) Online(now time.Time) bool {
+	return now.Sub(w.LastSeenAt) < WorkerTimeout
+}
+
+// JudgeJob is a submission or a reference solution waiting for or being
+// judged by a worker. One of SubmissionID and ReferenceSolutionID is set.
+type JudgeJob struct {
+	ID                  uuid.UUID  `json:"id" db:"id"`
+	CreatedAt           time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at" db:"updated_at"`
+	SubmissionID        nulls.UUID `json:"submission_id" db:"submission_id"`
+	ReferenceSolutionID nulls.UUID `json:"reference_solution_id" db:"reference_solution_id"`
+	WorkerID            nulls.UUID `json:"worker_id" db:"worker_id"`
+	Status              string     `json:"status" db:"status"`
+	Test                int        `json:"test" db:"test"`
+	Total               int        `json:"total" db:"total"`
+	Attempts            int        `json:"attempts" db:"attempts"`
+	LeasedUntil         nulls.Time `json:"leased_until" db:"leased_until"`
+	// TestDataVersion is the checksum of the test data the worker was told
+	// to use.
+	TestDataVersion string `json:"test_data_version" db:"test_data_version"`
+}
+
+type JudgeJobs []JudgeJob
+
+// RegisterWorker records a new worker, and removes the workers that have
+// been offline for longer than WorkerRetention.
+func RegisterWorker(tx *pop.Connection, name string, now time.Time) (*Worker, error) {
+	if err := PruneWorkers(tx, now); err != nil {
+		return nil, err
+	}
+	w := &Worker{Name: name, LastSeenAt: now}
+	if err := tx.Create(w); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return w, nil
+}
+
+// PruneWorkers removes the workers that have been offline for longer than
+// WorkerRetention. Their jobs ran out of lease long ago and are handed to
+// other workers.
+func PruneWorkers(tx *pop.Connection, now time.Time) error {
+	err := tx.RawQuery("UPDATE judge_jobs SET worker_id = NULL WHERE worker_id IN (SELECT id FROM workers WHERE last_seen_at < ?)",
+		now.Add(-WorkerRetention)).Exec()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	err = tx.RawQuery("DELETE FROM workers WHERE last_seen_at < ?", now.Add(-WorkerRetention)).Exec()
+	return errors.WithStack(err)
+}
+
+// Heartbeat marks the worker as alive and extends the leases of its jobs.
+func (w *Worker) Heartbeat(tx *pop.Connection, now time.Time) error {
+	w.LastSeenAt = now
+	if err := tx.Update(w); err != nil {
+		return errors.WithStack(err)
+	}
+	err := tx.RawQuery("UPDATE judge_jobs SET leased_until = ? WHERE worker_id = ? AND status = ?",
+		now.Add(JobLease), w.ID, JobRunning).Exec()
+	return errors.WithStack(err)
+}
+
+// EnqueueJudgeJob queues a submission for the workers. Unfinished jobs of
+// the submission are dropped, so a worker still running one cannot report
+// a stale verdict.
+func EnqueueJudgeJob(tx *pop.Connection, submissionID uuid.UUID) (*JudgeJob, error) {
+	err := tx.RawQuery("UPDATE judge_jobs SET status = ?, leased_until = NULL WHERE submission_id = ? AND status != ?",
+		JobDone, submissionID, JobDone).Exec()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	job := &JudgeJob{SubmissionID: nulls.NewUUID(submissionID), Status: JobQueued}
+	if err := tx.Create(job); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return job, nil
+}
+
+// EnqueueReferenceJob queues a reference solution for the workers,
+// dropping its unfinished jobs like EnqueueJudgeJob.
+func EnqueueReferenceJob(tx *pop.Connection, solutionID uuid.UUID) (*JudgeJob, error) {
+	err := tx.RawQuery("UPDATE judge_jobs SET status = ?, leased_until = NULL WHERE reference_solution_id = ? AND status != ?",
+		JobDone, solutionID, JobDone).Exec()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	job := &JudgeJob{ReferenceSolutionID: nulls.NewUUID(solutionID), Status: JobQueued}
+	if err := tx.Create(job); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return job, nil
+}
+
+// ClaimJudgeJob assigns the oldest queued job, or a running job whose
+// lease has run out, to the worker. Jobs that were handed out
+// MaxJobAttempts times are given up instead. It returns nil when there is
+// no work.
+func ClaimJudgeJob(tx *pop.Connection, w *Worker, now time.Time) (*JudgeJob, error) {
+	for {
+		job := &JudgeJob{}
+		err := tx.RawQuery("SELECT * FROM judge_jobs WHERE status = ? OR (status = ? AND leased_until < ?) ORDER BY created_at ASC LIMIT 1 FOR UPDATE",
+			JobQueued, JobRunning, now).First(job)
+		if err != nil {
+			if errors.Cause(err) == sql.ErrNoRows {
+				return nil, nil
+			}
+			return nil, errors.WithStack(err)
+		}
+		if job.Attempts >= MaxJobAttempts {
+			if err := job.giveUp(tx); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		job.WorkerID = nulls.NewUUID(w.ID)
+		job.Status = JobRunning
+		job.Test = 0
+		job.Total = 0
+		job.Attempts++
+		job.LeasedUntil = nulls.NewTime(now.Add(JobLease))
+		if err := tx.Update(job); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return job, nil
+	}
+}
+
+// giveUp completes a job no worker could finish, judging its submission or
+// reference solution a system error.
+func (j *JudgeJob) giveUp(tx *pop.Connection) error {
+	if j.ReferenceSolutionID.Valid {
+		err := tx.RawQuery("UPDATE reference_solutions SET status = ? WHERE id = ?", StatusSystemError, j.ReferenceSolutionID.UUID).Exec()
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	} else {
+		err := tx.RawQuery("UPDATE submissions SET status = ? WHERE id = ?", StatusSystemError, j.SubmissionID.UUID).Exec()
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	j.Status = JobDone
+	j.LeasedUntil = nulls.Time{}
+	return errors.WithStack(tx.Update(j))
+}
+
+// FindWorkerJob returns the job with the given ID if it is running on the
+// worker.
+func FindWorkerJob(tx *pop.Connection, w *Worker, jobID string) (*JudgeJob, error) {
+	job := &JudgeJob{}
+	if err := tx.Find(job, jobID); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if job.Status != JobRunning || !job.WorkerID.Valid || job.WorkerID.UUID != w.ID {
+		return nil, ErrJobNotAssigned
+	}
+	return job, nil
+}
+
+// Progress records that the worker is running test out of total, and
+// extends the lease.
+func (j *JudgeJob) Progress(tx *pop.Connection, test, total int, now time.Time) error {
+	j.Test = test
+	j.Total = total
+	j.LeasedUntil = nulls.NewTime(now.Add(JobLease))
+	return errors.WithStack(tx.Update(j))
+}
+
+// Finish stores the verdict on the submission or the reference solution
+// and completes the job. It returns the submission, or nil for a reference
+// solution.
+func (j *JudgeJob) Finish(tx *pop.Connection, w *Worker, status string) (*Submission, error) {
+	var s *Submission
+	if j.ReferenceSolutionID.Valid {
+		rs := &ReferenceSolution{}
+		if err := tx.Find(rs, j.ReferenceSolutionID.UUID); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		rs.Status = status
+		rs.TestDataSum = j.TestDataVersion
+		if err := tx.Update(rs); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	} else {
+		s = &Submission{}
+		if err := tx.Find(s, j.SubmissionID.UUID); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		s.Status = status
+		s.TestDataVersion = j.TestDataVersion
+		if err := tx.Update(s); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	j.Status = JobDone
+	j.LeasedUntil = nulls.Time{}
+	if err := tx.Update(j); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	w.Judged++
+	return s, errors.WithStack(tx.Update(w))
+}
+
+// ContestJobsPending reports whether submissions of the contest have
+// unfinished jobs.
+func ContestJobsPending(tx *pop.Connection, contestID uuid.UUID) (bool, error) {
+	pending, err := tx.Where("status != ? AND submission_id IN (SELECT id FROM submissions WHERE contest_id = ?)",
+		JobDone, contestID).Exists(&JudgeJob{})
+	return pending, errors.WithStack(err)
+}
+
+// SubmissionJob returns the unfinished job of a submission, or nil.
+func SubmissionJob(tx *pop.Connection, submissionID uuid.UUID) (*JudgeJob, error) {
+	jobs := JudgeJobs{}
+	err := tx.Where("submission_id = ? and status != ?", submissionID, JobDone).Order("created_at desc").All(&jobs)
+	if err != nil || len(jobs) == 0 {
+		return nil, errors.WithStack(err)
+	}
+	return &jobs[0], nil
+}
+
+// LoadWorkers returns every worker with its current job, most recently
+// seen first.
+func LoadWorkers(tx *pop.Connection) (Workers, error) {
+	workers := Workers{}
+	if err := tx.Order("last_seen_at desc").All(&workers); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	for i := range workers {
+		jobs := JudgeJobs{}
+		if err := tx.Where("worker_id = ? and status = ?", workers[i].ID, JobRunning).All(&jobs); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if len(jobs) > 0 {
+			workers[i].Job = &jobs[0]
+		}
+	}
+	return workers, nil
+}
+
+// CountQueuedJobs returns how many jobs wait for a worker.
+func CountQueuedJobs(tx *pop.Connection) (int, error) {
+	n, err := tx.Where("status = ?", JobQueued).Count(&JudgeJob{})
+	return n, errors.WithStack(err)
+}
@@ -0,0 +1,155 @@
+package models_test
+
+import (
+	"time"
+
+	"github.com/cpjudge/cpjudge/models"
+)
+
+func (ms *ModelSuite) Test_JudgeJob_Lifecycle() {
+	user := ms.createUser("ada")
+	s := &models.Submission{UserID: user.ID, Status: models.StatusPending, Language: "C"}
+	ms.NoError(ms.DB.Create(s))
+	now := time.Now()
+
+	first, err := models.RegisterWorker(ms.DB, "first", now)
+	ms.NoError(err)
+	second, err := models.RegisterWorker(ms.DB, "second", now)
+	ms.NoError(err)
+
+	job, err := models.EnqueueJudgeJob(ms.DB, s.ID)
+	ms.NoError(err)
+	busy, err := models.ContestJobsPending(ms.DB, s.ContestID)
+	ms.NoError(err)
+	ms.True(busy)
+	claimed, err := models.ClaimJudgeJob(ms.DB, first, now)
+	ms.NoError(err)
+	ms.Equal(job.ID, claimed.ID)
+	ms.Equal(1, claimed.Attempts)
+	ms.NoError(claimed.Progress(ms.DB, 2, 5, now))
+
+	// Nothing is left while the lease holds.
+	none, err := models.ClaimJudgeJob(ms.DB, second, now.Add(time.Second))
+	ms.NoError(err)
+	ms.Nil(none)
+
+	// The first worker dies; its job goes to the second one.
+	later := now.Add(models.JobLease + time.Second)
+	reassigned, err := models.ClaimJudgeJob(ms.DB, second, later)
+	ms.NoError(err)
+	ms.Equal(job.ID, reassigned.ID)
+	ms.Equal(2, reassigned.Attempts)
+	ms.Equal(0, reassigned.Test)
+	ms.False(first.Online(later))
+
+	_, err = models.FindWorkerJob(ms.DB, first, job.ID.String())
+	ms.Equal(models.ErrJobNotAssigned, err)
+	mine, err := models.FindWorkerJob(ms.DB, second, job.ID.String())
+	ms.NoError(err)
+	_, err = mine.Finish(ms.DB, second, models.StatusCorrect)
+	ms.NoError(err)
+
+	ms.NoError(ms.DB.Reload(s))
+	ms.Equal(models.StatusCorrect, s.Status)
+	pending, err := models.SubmissionJob(ms.DB, s.ID)
+	ms.NoError(err)
+	ms.Nil(pending)
+	busy, err = models.ContestJobsPending(ms.DB, s.ContestID)
+	ms.NoError(err)
+	ms.False(busy)
+	workers, err := models.LoadWorkers(ms.DB)
+	ms.NoError(err)
+	ms.Len(workers, 2)
+}
+
+func (ms *ModelSuite) Test_EnqueueJudgeJob_DropsStaleJobs() {
+	user := ms.createUser("ada")
+	s := &models.Submission{UserID: user.ID, Status: models.StatusPending, Language: "C"}
+	ms.NoError(ms.DB.Create(s))
+	now := time.Now()
+	w, err := models.RegisterWorker(ms.DB, "worker", now)
+	ms.NoError(err)
+
+	old, err := models.EnqueueJudgeJob(ms.DB, s.ID)
+	ms.NoError(err)
+	_, err = models.ClaimJudgeJob(ms.DB, w, now)
+	ms.NoError(err)
+	// A rejudge queues the submission again while the worker runs it.
+	_, err = models.EnqueueJudgeJob(ms.DB, s.ID)
+	ms.NoError(err)
+	_, err = models.FindWorkerJob(ms.DB, w, old.ID.String())
+	ms.Equal(models.ErrJobNotAssigned, err)
+	queued, err := models.CountQueuedJobs(ms.DB)
+	ms.NoError(err)
+	ms.Equal(1, queued)
+}
+
+func (ms *ModelSuite) Test_JudgeJob_ReferenceSolution() {
+	contest := ms.pastContest(0)
+	q := &models.Question{Title: "Sum", Description: "Add", ContestID: contest.ID}
+	ms.NoError(ms.DB.Create(q))
+	rs := &models.ReferenceSolution{QuestionID: q.ID, Filename: "slow.c", Expected: models.ExpectTimeLimit, Status: models.StatusPending}
+	ms.NoError(ms.DB.Create(rs))
+	now := time.Now()
+	w, err := models.RegisterWorker(ms.DB, "worker", now)
+	ms.NoError(err)
+
+	_, err = models.EnqueueReferenceJob(ms.DB, rs.ID)
+	ms.NoError(err)
+	job, err := models.ClaimJudgeJob(ms.DB, w, now)
+	ms.NoError(err)
+	ms.Equal(rs.ID, job.ReferenceSolutionID.UUID)
+	job.TestDataVersion = "abc"
+	s, err := job.Finish(ms.DB, w, models.StatusTimeLimit)
+	ms.NoError(err)
+	ms.Nil(s)
+
+	ms.NoError(ms.DB.Reload(rs))
+	ms.Equal(models.StatusTimeLimit, rs.Status)
+	ms.Equal("abc", rs.TestDataSum)
+	ms.False(rs.Flagged())
+}
+
+func (ms *ModelSuite) Test_ClaimJudgeJob_GivesUp() {
+	user := ms.createUser("ada")
+	s := &models.Submission{UserID: user.ID, Status: models.StatusPending, Language: "C"}
+	ms.NoError(ms.DB.Create(s))
+	now := time.Now()
+	w, err := models.RegisterWorker(ms.DB, "crashing", now)
+	ms.NoError(err)
+	job, err := models.EnqueueJudgeJob(ms.DB, s.ID)
+	ms.NoError(err)
+
+	// The submission takes down every worker running it.
+	for i := 0; i < models.MaxJobAttempts; i++ {
+		claimed, err := models.ClaimJudgeJob(ms.DB, w, now)
+		ms.NoError(err)
+		ms.Equal(job.ID, claimed.ID)
+		now = now.Add(models.JobLease + time.Second)
+	}
+	none, err := models.ClaimJudgeJob(ms.DB, w, now)
+	ms.NoError(err)
+	ms.Nil(none)
+	ms.NoError(ms.DB.Reload(s))
+	ms.Equal(models.StatusSystemError, s.Status)
+	ms.NoError(ms.DB.Reload(job))
+	ms.Equal(models.JobDone, job.Status)
+}
+
+func (ms *ModelSuite) Test_RegisterWorker_PrunesOfflineWorkers() {
+	now := time.Now()
+	old, err := models.RegisterWorker(ms.DB, "old", now.Add(-models.WorkerRetention-time.Hour))
+	ms.NoError(err)
+	recent, err := models.RegisterWorker(ms.DB, "recent", now.Add(-time.Hour))
+	ms.NoError(err)
+	_, err = models.RegisterWorker(ms.DB, "new", now)
+	ms.NoError(err)
+
+	workers, err := models.LoadWorkers(ms.DB)
+	ms.NoError(err)
+	ms.Len(workers, 2)
+	for _, w := range workers {
+		ms.NotEqual(old.ID, w.ID)
+	}
+	ms.Equal(recent.ID, workers[1].ID)
+}
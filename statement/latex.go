@@ -0,0 +1,225 @@
+package statement
+
+import (
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	heading  = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*$`)
+	listItem = regexp.MustCompile(`^(\s*)([-*+]|\d+[.)])\s+(.*)$`)
+	image    = regexp.MustCompile(`^!\[([^\]]*)\]\(([^)\s]+)[^)]*\)`)
+	link     = regexp.MustCompile(`^\[([^\]]*)\]\(([^)\s]+)[^)]*\)`)
+)
+
+// printableImages are the image formats pdflatex includes.
+var printableImages = map[string]bool{".png": true, ".jpg": true, ".jpeg": true, ".pdf": true}
+
+var latexEscaper = strings.NewReplacer(
+	`\`, `\textbackslash{}`,
+	`{`, `\{`,
+	`}`, `\}`,
+	`$`, `\$`,
+	`&`, `\&`,
+	`#`, `\#`,
+	`%`, `\%`,
+	`_`, `\_`,
+	`~`, `\textasciitilde{}`,
+	`^`, `\textasciicircum{}`,
+	`<`, `\textless{}`,
+	`>`, `\textgreater{}`,
+)
+
+// escapeLaTeX escapes text for LaTeX.
+func escapeLaTeX(text string) string {
+	return latexEscaper.Replace(text)
+}
+
+// markdownEscapes are the characters a backslash escapes in markdown.
+const markdownEscapes = "\\`*_{}[]()#+-.!:|&<>~$"
+
+// LaTeX converts a statement to LaTeX for printing. Math is kept as it is,
+// and images must be attachments in the graphics path of the document.
+// Markdown it does not know, such as tables, is printed as text.
+func LaTeX(md string) string {
+	parts := SplitMath(strings.Replace(md, "\r\n", "\n", -1))
+	text := strings.Builder{}
+	for i, part := range parts {
+		if i%2 == 0 {
+			text.WriteString(part)
+		} else {
+			text.WriteString(latexPlaceholder(i / 2))
+		}
+	}
+	out := latexBlocks(text.String())
+	for i := 1; i < len(parts); i += 2 {
+		math := parts[i]
+		if strings.HasPrefix(math, "$$") {
+			math = `\[` + math[2:len(math)-2] + `\]`
+		}
+		out = strings.Replace(out, latexPlaceholder(i/2), math, 1)
+	}
+	return strings.TrimSpace(out)
+}
+
+func latexPlaceholder(n int) string {
+	return "\x00" + strconv.Itoa(n) + "\x00"
+}
+
+// latexBlocks converts paragraphs, headings, lists and code blocks.
+func latexBlocks(text string) string {
+	b := strings.Builder{}
+	paragraph := []string{}
+	flush := func() {
+		if len(paragraph) > 0 {
+			b.WriteString(latexInline(strings.Join(paragraph, "\n")) + "\n\n")
+			paragraph = nil
+		}
+	}
+	// indents are those of the open lists, innermost last.
+	indents, lists := []int{}, []string{}
+	closeList := func() {
+		n := len(lists) - 1
+		b.WriteString(`\end{` + lists[n] + "}\n")
+		indents, lists = indents[:n], lists[:n]
+		if n == 0 {
+			b.WriteString("\n")
+		}
+	}
+	closeLists := func() {
+		for len(lists) > 0 {
+			closeList()
+		}
+	}
+
+	lines := strings.Split(text, "\n")
+	blank := false
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, "```"):
+			flush()
+			closeLists()
+			code := []string{}
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), "```"); i++ {
+				code = append(code, lines[i])
+			}
+			b.WriteString("\\begin{verbatim}\n" + strings.Join(code, "\n") + "\n\\end{verbatim}\n\n")
+		case trimmed == "":
+			flush()
+		case heading.MatchString(trimmed):
+			flush()
+			closeLists()
+			m := heading.FindStringSubmatch(trimmed)
+			cmd := `\subsection*{`
+			if len(m[1]) > 2 {
+				cmd = `\subsubsection*{`
+			}
+			b.WriteString(cmd + latexInline(m[2]) + "}\n\n")
+		case listItem.MatchString(line):
+			flush()
+			m := listItem.FindStringSubmatch(line)
+			indent := len(strings.Replace(m[1], "\t", "    ", -1))
+			for len(indents) > 0 && indent < indents[len(indents)-1] {
+				closeList()
+			}
+			if len(indents) == 0 || indent > indents[len(indents)-1] {
+				env := "itemize"
+				if m[2][0] >= '0' && m[2][0] <= '9' {
+					env = "enumerate"
+				}
+				b.WriteString(`\begin{` + env + "}\n")
+				indents, lists = append(indents, indent), append(lists, env)
+			}
+			b.WriteString(`\item `)
+			paragraph = []string{m[3]}
+		default:
+			// A paragraph after a blank line ends the lists it is not
+			// indented into.
+			if blank && len(lists) > 0 && line[0] != ' ' && line[0] != '\t' {
+				closeLists()
+			}
+			paragraph = append(paragraph, strings.TrimPrefix(strings.TrimPrefix(trimmed, ">"), " "))
+		}
+		blank = trimmed == ""
+	}
+	flush()
+	closeLists()
+	return b.String()
+}
+
+// latexInline converts emphasis, code, links and images, and escapes the
+// rest of the text.
+func latexInline(s string) string {
+	b := strings.Builder{}
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && strings.IndexByte(markdownEscapes, s[i+1]) >= 0:
+			b.WriteString(escapeLaTeX(s[i+1 : i+2]))
+			i += 2
+			continue
+		case c == '`':
+			n := len(s[i:]) - len(strings.TrimLeft(s[i:], "`"))
+			if end := strings.Index(s[i+n:], s[i:i+n]); end >= 0 {
+				b.WriteString(`\texttt{` + escapeLaTeX(strings.TrimSpace(s[i+n:i+n+end])) + "}")
+				i += 2*n + end
+			} else {
+				b.WriteString(s[i : i+n])
+				i += n
+			}
+			continue
+		case c == '!' && image.MatchString(s[i:]):
+			m := image.FindStringSubmatch(s[i:])
+			if IsFileName(m[2]) && printableImages[strings.ToLower(path.Ext(m[2]))] {
+				b.WriteString(`\includegraphics[width=\maxwidth]{` + m[2] + "}")
+			} else {
+				b.WriteString(latexInline(m[1]))
+			}
+			i += len(m[0])
+			continue
+		case c == '[' && link.MatchString(s[i:]):
+			m := link.FindStringSubmatch(s[i:])
+			if IsFileName(m[2]) {
+				b.WriteString(latexInline(m[1]))
+			} else {
+				b.WriteString(`\href{` + urlEscaper.Replace(m[2]) + "}{" + latexInline(m[1]) + "}")
+			}
+			i += len(m[0])
+			continue
+		case c == '*' || c == '_':
+			delim := s[i : i+1]
+			if strings.HasPrefix(s[i:], delim+delim) {
+				delim += delim
+			}
+			n := len(delim)
+			end := strings.Index(s[i+n:], delim)
+			// Underscores inside words, as in snake_case, are text.
+			intraword := c == '_' && (i > 0 && isWordByte(s[i-1]) || end > 0 && i+2*n+end < len(s) && isWordByte(s[i+2*n+end]))
+			if end > 0 && !intraword {
+				cmd := `\emph{`
+				if n == 2 {
+					cmd = `\textbf{`
+				}
+				b.WriteString(cmd + latexInline(s[i+n:i+n+end]) + "}")
+				i += 2*n + end
+			} else {
+				b.WriteString(escapeLaTeX(delim))
+				i += n
+			}
+			continue
+		}
+		b.WriteString(escapeLaTeX(s[i : i+1]))
+		i++
+	}
+	return b.String()
+}
+
+var urlEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `#`, `\#`, `{`, `\{`, `}`, `\}`)
+
+func isWordByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c >= 0x80
+}
@@ -0,0 +1,153 @@
+package statement
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/envy"
+	"github.com/pkg/errors"
+)
+
+// PDFLaTeX is the command building problem sets.
+var PDFLaTeX = envy.Get("PDFLATEX", "pdflatex")
+
+// buildTimeout bounds a problem set build.
+const buildTimeout = 2 * time.Minute
+
+// ProblemSet is the printable statements of a contest.
+type ProblemSet struct {
+	Title    string
+	Subtitle string
+	Problems []Problem
+}
+
+// Problem is a statement in a problem set. Dir is the directory, relative
+// to the one the set is built in, holding the attachments of the problem.
+type Problem struct {
+	Label  string
+	Title  string
+	Dir    string
+	Legend string
+	Input  string
+	Output string
+	Notes  string
+	// TimeLimit is in milliseconds and MemoryLimit in megabytes.
+	TimeLimit   int
+	MemoryLimit int
+	Samples     []Sample
+}
+
+// Sample is a sample test shown in a statement.
+type Sample struct {
+	Input  string
+	Output string
+}
+
+const preamble = `\documentclass[11pt,a4paper]{article}
+\usepackage[utf8]{inputenc}
+\usepackage[T1]{fontenc}
+\usepackage{lmodern}
+\usepackage[margin=2cm]{geometry}
+\usepackage{amsmath,amssymb}
+\usepackage{graphicx}
+\usepackage[hidelinks]{hyperref}
+\makeatletter
+\def\maxwidth{\ifdim\Gin@nat@width>\linewidth\linewidth\else\Gin@nat@width\fi}
+\makeatother
+\setlength{\parindent}{0pt}
+\setlength{\parskip}{0.6em}
+`
+
+// LaTeX returns the problem set as a LaTeX document: a title page listing
+// the problems, then each problem on its own pages.
+func (s ProblemSet) LaTeX() string {
+	b := strings.Builder{}
+	b.WriteString(preamble)
+	b.WriteString("\\begin{document}\n\\begin{center}\n")
+	b.WriteString(`{\Huge ` + escapeLaTeX(s.Title) + "}\n")
+	if s.Subtitle != "" {
+		b.WriteString("\n\\vspace{1em}\n{\\large " + escapeLaTeX(s.Subtitle) + "}\n")
+	}
+	b.WriteString("\n\\vspace{3em}\n\\begin{tabular}{ll}\n")
+	for _, p := range s.Problems {
+		b.WriteString(escapeLaTeX(p.Label) + " & " + escapeLaTeX(p.Title) + " \\\\\n")
+	}
+	b.WriteString("\\end{tabular}\n\\end{center}\n")
+
+	for _, p := range s.Problems {
+		b.WriteString("\n\\clearpage\n")
+		if p.Dir != "" {
+			b.WriteString(`\graphicspath{{` + filepath.ToSlash(p.Dir) + "/}}\n")
+		}
+		b.WriteString(`\section*{` + escapeLaTeX(p.Label+". "+p.Title) + "}\n")
+		b.WriteString(`\textit{Time limit: ` + strconv.FormatFloat(float64(p.TimeLimit)/1000, 'f', -1, 64) + ` s. Memory limit: ` + strconv.Itoa(p.MemoryLimit) + " MB.}\n\n")
+		b.WriteString(LaTeX(p.Legend) + "\n")
+		for _, section := range []struct{ title, body string }{{"Input", p.Input}, {"Output", p.Output}} {
+			if strings.TrimSpace(section.body) != "" {
+				b.WriteString("\n\\subsection*{" + section.title + "}\n" + LaTeX(section.body) + "\n")
+			}
+		}
+		for i, sample := range p.Samples {
+			n := ""
+			if len(p.Samples) > 1 {
+				n = " " + strconv.Itoa(i+1)
+			}
+			b.WriteString("\n\\subsection*{Sample input" + n + "}\n" + verbatim(sample.Input))
+			b.WriteString("\\subsection*{Sample output" + n + "}\n" + verbatim(sample.Output))
+		}
+		if strings.TrimSpace(p.Notes) != "" {
+			b.WriteString("\n\\subsection*{Notes}\n" + LaTeX(p.Notes) + "\n")
+		}
+	}
+	b.WriteString("\\end{document}\n")
+	return b.String()
+}
+
+func verbatim(text string) string {
+	text = strings.TrimRight(strings.Replace(text, "\r\n", "\n", -1), "\n")
+	return "\\begin{verbatim}\n" + text + "\n\\end{verbatim}\n"
+}
+
+// BuildError is a problem set LaTeX failed to build, with the end of its
+// log.
+type BuildError struct {
+	Log string
+}
+
+func (e *BuildError) Error() string {
+	return "The problem set could not be built:\n" + e.Log
+}
+
+// PDF builds the problem set with pdflatex in dir, which holds the
+// attachments of the problems, and returns the document.
+func (s ProblemSet) PDF(dir string) ([]byte, error) {
+	if err := ioutil.WriteFile(filepath.Join(dir, "problemset.tex"), []byte(s.LaTeX()), 0644); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), buildTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, PDFLaTeX, "-interaction=nonstopmode", "-halt-on-error", "-no-shell-escape", "problemset.tex")
+	cmd.Dir = dir
+	// Math is written by hosts and passed as it is, so keep LaTeX from
+	// reading or writing files outside dir.
+	cmd.Env = append(os.Environ(), "openin_any=p", "openout_any=p")
+	out, err := cmd.CombinedOutput()
+	if _, ok := err.(*exec.Error); ok {
+		return nil, errors.Errorf("%s could not be run: %v", PDFLaTeX, err)
+	}
+	if err != nil {
+		lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+		if len(lines) > 20 {
+			lines = lines[len(lines)-20:]
+		}
+		return nil, &BuildError{Log: strings.Join(lines, "\n")}
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, "problemset.pdf"))
+	return b, errors.WithStack(err)
+}
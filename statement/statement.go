@@ -0,0 +1,119 @@
+// Package statement renders question statements, which are markdown with
+// LaTeX math between $ or $$ signs, as HTML for the site and as LaTeX for
+// printed problem sets. Statements refer to the attachments of their
+// question by file name, as in ![The graph](graph.png).
+package statement
+
+import (
+	"html/template"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/shurcooL/github_flavored_markdown"
+)
+
+var (
+	fileName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+	// relativeLink matches the start of a markdown link or image up to its
+	// target, which is the second group.
+	relativeLink = regexp.MustCompile(`(!?\[[^\]]*\]\()([^)\s]+)`)
+)
+
+// IsFileName reports whether a link target is the bare file name of an
+// attachment rather than a URL.
+func IsFileName(target string) bool {
+	return fileName.MatchString(target) && !strings.Contains(target, "..")
+}
+
+// HTML renders a statement for the site. Math is left for KaTeX to typeset
+// in the browser, and links to attachments are resolved with resolve,
+// which maps an attachment name to its URL.
+func HTML(md string, resolve func(name string) string) template.HTML {
+	parts := SplitMath(md)
+	text := strings.Builder{}
+	for i, part := range parts {
+		if i%2 == 0 {
+			text.WriteString(part)
+		} else {
+			text.WriteString(htmlPlaceholder(i / 2))
+		}
+	}
+	linked := relativeLink.ReplaceAllStringFunc(text.String(), func(link string) string {
+		m := relativeLink.FindStringSubmatch(link)
+		if resolve == nil || !IsFileName(m[2]) {
+			return link
+		}
+		return m[1] + resolve(m[2])
+	})
+	out := string(github_flavored_markdown.Markdown([]byte(linked)))
+	for i := 1; i < len(parts); i += 2 {
+		out = strings.Replace(out, htmlPlaceholder(i/2), mathHTML(parts[i]), 1)
+	}
+	return template.HTML(out)
+}
+
+// htmlPlaceholder stands in for math while the markdown is rendered. Its
+// private use characters are left out of heading anchors, so the
+// placeholder only appears in the text.
+func htmlPlaceholder(n int) string {
+	return "\ue000" + strconv.Itoa(n) + "\ue001"
+}
+
+// mathHTML wraps math in the delimiters KaTeX looks for.
+func mathHTML(math string) string {
+	if strings.HasPrefix(math, "$$") {
+		return `<span class="math math-display">\[` + template.HTMLEscapeString(math[2:len(math)-2]) + `\]</span>`
+	}
+	return `<span class="math">\(` + template.HTMLEscapeString(math[1:len(math)-1]) + `\)</span>`
+}
+
+// SplitMath splits a markdown statement into alternating text and math
+// parts, the math parts keeping their $ or $$ delimiters. Code is text, so
+// the $ in `echo $HOME` starts no math.
+func SplitMath(md string) []string {
+	return splitMath(md, true)
+}
+
+// SplitTeXMath is SplitMath for LaTeX, where backticks open quotes rather
+// than code.
+func SplitTeXMath(tex string) []string {
+	return splitMath(tex, false)
+}
+
+func splitMath(md string, code bool) []string {
+	parts := []string{}
+	start := 0
+	for i := 0; i < len(md); i++ {
+		switch md[i] {
+		case '\\':
+			i++
+		case '`':
+			if !code {
+				continue
+			}
+			n := len(md[i:]) - len(strings.TrimLeft(md[i:], "`"))
+			end := strings.Index(md[i+n:], md[i:i+n])
+			if end < 0 {
+				i += n - 1
+			} else {
+				i += 2*n + end - 1
+			}
+		case '$':
+			delim := "$"
+			if strings.HasPrefix(md[i:], "$$") {
+				delim = "$$"
+			}
+			end := strings.Index(md[i+len(delim):], delim)
+			if end <= 0 {
+				i += len(delim) - 1
+				continue
+			}
+			end += i + 2*len(delim)
+			parts = append(parts, md[start:i], md[i:end])
+			start = end
+			i = end - 1
+		}
+	}
+	return append(parts, md[start:])
+}
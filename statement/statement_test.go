@@ -0,0 +1,98 @@
+package statement
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitMath(t *testing.T) {
+	for _, c := range []struct {
+		md   string
+		want []string
+	}{
+		{"Print $a + b$.", []string{"Print ", "$a + b$", "."}},
+		{"$$\\sum a_i$$ and \\$5", []string{"", "$$\\sum a_i$$", " and \\$5"}},
+		{"Run `echo $HOME` for $n$", []string{"Run `echo $HOME` for ", "$n$", ""}},
+		{"It costs $5", []string{"It costs $5"}},
+	} {
+		if got := SplitMath(c.md); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("SplitMath(%q) = %q, want %q", c.md, got, c.want)
+		}
+	}
+
+	tex := "Print ``YES'' if $a < b$, ``NO'' otherwise."
+	want := []string{"Print ``YES'' if ", "$a < b$", ", ``NO'' otherwise."}
+	if got := SplitTeXMath(tex); !reflect.DeepEqual(got, want) {
+		t.Errorf("SplitTeXMath(%q) = %q, want %q", tex, got, want)
+	}
+}
+
+func TestHTML(t *testing.T) {
+	md := "Let $a_1 * b_2$ be.\n\n$$x < y$$\n\n![The graph](graph.png) and [a site](https://example.com). Run `echo $HOME`."
+	got := string(HTML(md, func(name string) string { return "/files/" + name }))
+	for _, want := range []string{
+		`<span class="math">\(a_1 * b_2\)</span>`,
+		`<span class="math math-display">\[x &lt; y\]</span>`,
+		`/files/graph.png`,
+		`https://example.com`,
+		`echo $HOME`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("HTML is missing %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestLaTeX(t *testing.T) {
+	md := "## Story\n\nGiven $n$ numbers_here, print **the sum** of *all* 100% of `a_i`.\n\n" +
+		"- one\n- two\n  1. nested\n\n$$\\sum_{i=1}^n a_i$$\n\n![Graph](graph.png) ![Other](data.txt) [Docs](https://example.com/a#b)\n\n```\nx_1 $y$\n```"
+	want := "\\subsection*{Story}\n\n" +
+		"Given $n$ numbers\\_here, print \\textbf{the sum} of \\emph{all} 100\\% of \\texttt{a\\_i}.\n\n" +
+		"\\begin{itemize}\n\\item one\n\n\\item two\n\n\\begin{enumerate}\n\\item nested\n\n\\end{enumerate}\n\\end{itemize}\n\n" +
+		"\\[\\sum_{i=1}^n a_i\\]\n\n" +
+		"\\includegraphics[width=\\maxwidth]{graph.png} Other \\href{https://example.com/a\\#b}{Docs}\n\n" +
+		"\\begin{verbatim}\nx_1 $y$\n\\end{verbatim}"
+	if got := LaTeX(md); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestProblemSetPDF(t *testing.T) {
+	if _, err := exec.LookPath(PDFLaTeX); err != nil {
+		t.Skip("pdflatex is not installed")
+	}
+	dir, err := ioutil.TempDir("", "statement")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	set := ProblemSet{Title: "Weekly #1", Problems: []Problem{{
+		Label:       "A",
+		Title:       "Sum & more",
+		Legend:      "Add $a$ and $b$.",
+		Input:       "Two integers.",
+		Output:      "Their sum.",
+		TimeLimit:   1500,
+		MemoryLimit: 256,
+		Samples:     []Sample{{Input: "1 2\n", Output: "3\n"}},
+	}}}
+	pdf, err := set.PDF(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(pdf, []byte("%PDF")) {
+		t.Errorf("the problem set is not a PDF")
+	}
+
+	set.Problems[0].Legend = "Broken $\\frac{a$."
+	if _, err := set.PDF(dir); err == nil {
+		t.Errorf("broken math was built")
+	} else if _, ok := err.(*BuildError); !ok {
+		t.Errorf("got %v, want a build error", err)
+	}
+}
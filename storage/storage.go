@@ -0,0 +1,260 @@
+// Package storage keeps submission sources and test data in a local
+// directory or an S3-compatible bucket. Objects are addressed by
+// slash-separated keys such as "submissions/submission_<id>.c".
+package storage
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/gobuffalo/envy"
+	"github.com/pkg/errors"
+)
+
+// ErrNotFound is returned when an object does not exist.
+var ErrNotFound = errors.New("storage: object not found")
+
+// Storage stores objects by key.
+type Storage interface {
+	// Put stores the contents of r under key, replacing any object there.
+	Put(key string, r io.Reader) error
+	// Get opens the object stored under key.
+	Get(key string) (io.ReadCloser, error)
+	// Delete removes the object under key. Deleting a missing object is
+	// not an error.
+	Delete(key string) error
+	// List returns the keys starting with prefix, sorted.
+	List(prefix string) ([]string, error)
+}
+
+var (
+	mu      sync.Mutex
+	current Storage
+)
+
+// Default returns the storage configured by the environment: the S3
+// backend when STORAGE is "s3", otherwise the local directory STORAGE_DIR.
+func Default() Storage {
+	mu.Lock()
+	defer mu.Unlock()
+	if current == nil {
+		s, err := fromEnv()
+		if err != nil {
+			log.Fatal(err)
+		}
+		current = s
+	}
+	return current
+}
+
+// Set replaces the default storage, for tests and tools.
+func Set(s Storage) {
+	mu.Lock()
+	defer mu.Unlock()
+	current = s
+}
+
+// Init sets up the default storage from the environment, and logs where
+// local objects are kept. Servers call it when they start, so that a
+// relative STORAGE_DIR is resolved once, against the directory they were
+// started in.
+func Init() error {
+	s, err := fromEnv()
+	if err != nil {
+		return err
+	}
+	if l, ok := s.(*Local); ok {
+		log.Printf("storage: keeping objects in %s", l.Root)
+	}
+	Set(s)
+	return nil
+}
+
+func fromEnv() (Storage, error) {
+	if envy.Get("STORAGE", "local") == "s3" {
+		return NewS3(S3Config{
+			Endpoint:  envy.Get("S3_ENDPOINT", "https://s3.amazonaws.com"),
+			Region:    envy.Get("S3_REGION", "us-east-1"),
+			Bucket:    envy.Get("S3_BUCKET", "cpjudge"),
+			AccessKey: envy.Get("S3_ACCESS_KEY", ""),
+			SecretKey: envy.Get("S3_SECRET_KEY", ""),
+		}), nil
+	}
+	// Keys used to be paths relative to the app directory's parent.
+	dir, err := filepath.Abs(envy.Get("STORAGE_DIR", ".."))
+	if err != nil {
+		return nil, errors.Wrap(err, "storage: resolving STORAGE_DIR")
+	}
+	return NewLocal(dir), nil
+}
+
+// ReadAll returns the contents of the object under key.
+func ReadAll(s Storage, key string) ([]byte, error) {
+	r, err := s.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	b, err := ioutil.ReadAll(r)
+	return b, errors.WithStack(err)
+}
+
+// PutBytes stores b under key.
+func PutBytes(s Storage, key string, b []byte) error {
+	return s.Put(key, bytes.NewReader(b))
+}
+
+// DeletePrefix removes every object whose key starts with prefix.
+func DeletePrefix(s Storage, prefix string) error {
+	keys, err := s.List(prefix)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := s.Delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Download copies the objects under prefix into dir, keeping the rest of
+// their keys as relative paths.
+func Download(s Storage, prefix, dir string) error {
+	keys, err := s.List(prefix)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		rel, err := CleanKey(strings.TrimPrefix(key, prefix))
+		if err != nil {
+			return err
+		}
+		if err := DownloadFile(s, key, filepath.Join(dir, filepath.FromSlash(rel))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Copy copies the objects under srcPrefix in src to dstPrefix in dst.
+func Copy(src Storage, srcPrefix string, dst Storage, dstPrefix string) error {
+	keys, err := src.List(srcPrefix)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		r, err := src.Get(key)
+		if err != nil {
+			return err
+		}
+		err = dst.Put(dstPrefix+strings.TrimPrefix(key, srcPrefix), r)
+		r.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Unzip stores the files of a zip archive in s, under prefix. A leading
+// strip directory is removed from the names in the archive.
+func Unzip(b []byte, s Storage, prefix, strip string) error {
+	r, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		name, err := CleanKey(strings.TrimPrefix(f.Name, strip))
+		if err != nil {
+			return err
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		err = s.Put(prefix+name, rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DownloadFile copies the object under key to the file dst.
+func DownloadFile(s Storage, key, dst string) error {
+	r, err := s.Get(key)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return errors.WithStack(err)
+	}
+	f, err := os.Create(dst)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer f.Close()
+	_, err = io.Copy(f, r)
+	return errors.WithStack(err)
+}
+
+// Checksum identifies the contents of the objects under prefix: it is the
+// SHA-256 of their names, relative to prefix, and the SHA-256 of each of
+// their contents. Copies of the same data have the same checksum wherever
+// they are stored.
+func Checksum(s Storage, prefix string) (string, error) {
+	keys, err := s.List(prefix)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.New()
+	for _, key := range keys {
+		r, err := s.Get(key)
+		if err != nil {
+			return "", err
+		}
+		h := sha256.New()
+		_, err = io.Copy(h, r)
+		r.Close()
+		if err != nil {
+			return "", errors.WithStack(err)
+		}
+		fmt.Fprintf(sum, "%s\x00%x\n", strings.TrimPrefix(key, prefix), h.Sum(nil))
+	}
+	return hex.EncodeToString(sum.Sum(nil)), nil
+}
+
+// CleanKey normalises a key and rejects keys that are empty or contain
+// ".." segments, which could escape a prefix or the local root.
+func CleanKey(key string) (string, error) {
+	parts := []string{}
+	for _, part := range strings.Split(strings.Replace(key, "\\", "/", -1), "/") {
+		switch part {
+		case "", ".":
+		case "..":
+			return "", errors.Errorf("storage: invalid key %q", key)
+		default:
+			parts = append(parts, part)
+		}
+	}
+	if len(parts) == 0 {
+		return "", errors.Errorf("storage: invalid key %q", key)
+	}
+	return strings.Join(parts, "/"), nil
+}
@@ -0,0 +1,73 @@
+package tasks
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/cpjudge/cpjudge/judge"
+	"github.com/cpjudge/cpjudge/models"
+	"github.com/gobuffalo/pop"
+	"github.com/pkg/errors"
+)
+
+// generateTests runs the generator script of the question and stores the
+// tests it makes, once they are validated and the main solution answered
+// them.
+func generateTests(db *pop.Connection, task *models.Task) (*outcome, error) {
+	question := &models.Question{}
+	if err := db.Find(question, task.QuestionID.UUID); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	// The script may have changed since the task was queued; the latest one
+	// is what the host wants.
+	calls, err := models.ParseGeneratorScript(question.GeneratorScript)
+	if err != nil {
+		return failure(err.Error(), nil), nil
+	}
+	if len(calls) == 0 {
+		return failure("The generator script makes no tests.", nil), nil
+	}
+
+	dir, err := ioutil.TempDir("", "cpjudge-generators")
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer os.RemoveAll(dir)
+	generators, err := models.QuestionGenerators(db, question.ID)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	sources := map[string]string{}
+	for _, g := range generators {
+		if sources[g.Name], err = g.Download(dir); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	inputs, failed, err := judge.GenerateInputs(sources, calls)
+	switch err.(type) {
+	case nil:
+	case *judge.ScriptError, *judge.CompileError:
+		return failure(err.Error(), nil), nil
+	default:
+		return nil, errors.WithStack(err)
+	}
+	if len(failed) > 0 {
+		return failure(fmt.Sprintf("The tests were not saved: %d generator runs failed.", len(failed)), failed), nil
+	}
+
+	validator := ""
+	if question.HasValidator() {
+		if validator, err = question.DownloadValidator(dir); err != nil {
+			return nil, err
+		}
+	}
+	data, rejection, err := PrepareTestData(db, question, validator, inputs)
+	if err != nil {
+		return nil, err
+	}
+	if rejection != nil {
+		return failure(rejection.Message, rejection.Inputs), nil
+	}
+	return storeTestData(question, data, fmt.Sprintf("Generated %d tests.", len(calls))), nil
+}
@@ -0,0 +1,97 @@
+package tasks
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/cpjudge/cpjudge/models"
+	"github.com/cpjudge/cpjudge/statement"
+	"github.com/cpjudge/cpjudge/storage"
+	"github.com/gobuffalo/pop"
+	"github.com/pkg/errors"
+)
+
+// buildProblemSet builds the statements of the contest as a PDF with
+// pdflatex, which can take minutes, and keeps it as the output of the task.
+func buildProblemSet(db *pop.Connection, task *models.Task) (*outcome, error) {
+	contest := &models.Contest{}
+	if err := db.Find(contest, task.ContestID.UUID); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	questions := models.Questions{}
+	if err := db.Where("contest_id = ?", contest.ID).Order("created_at asc").All(&questions); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if len(questions) == 0 {
+		return failure("The contest has no questions to print.", nil), nil
+	}
+
+	dir, err := ioutil.TempDir("", "cpjudge-problemset")
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer os.RemoveAll(dir)
+	set := statement.ProblemSet{Title: contest.Title}
+	if contest.StartTime.Valid {
+		set.Subtitle = contest.StartTime.Time.UTC().Format("January 2, 2006")
+	}
+	for i, q := range questions {
+		p := statement.Problem{
+			Label:       problemLabel(i),
+			Title:       q.Title,
+			Dir:         "problem_" + strconv.Itoa(i+1),
+			Legend:      q.Description,
+			Input:       q.InputFormat,
+			Output:      q.OutputFormat,
+			Notes:       q.Notes,
+			TimeLimit:   q.EffectiveTimeLimit(),
+			MemoryLimit: q.EffectiveMemoryLimit(),
+		}
+		samples, err := q.LoadSamples()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		for _, s := range samples {
+			p.Samples = append(p.Samples, statement.Sample{Input: s.Input, Output: s.Answer})
+		}
+		attachments, err := models.QuestionAttachments(db, q.ID)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		for _, a := range attachments {
+			if err := a.Download(filepath.Join(dir, p.Dir)); err != nil {
+				return nil, errors.WithStack(err)
+			}
+		}
+		set.Problems = append(set.Problems, p)
+	}
+	pdf, err := set.PDF(dir)
+	if berr, ok := err.(*statement.BuildError); ok {
+		return failure(berr.Error(), nil), nil
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	key := "problemsets/" + task.ID.String() + ".pdf"
+	if err := storage.PutBytes(storage.Default(), key, pdf); err != nil {
+		return nil, err
+	}
+	return &outcome{store: func(tx *pop.Connection) (string, error) {
+		task.Output = key
+		if err := models.DeleteContestTaskOutputs(tx, contest.ID, models.TaskProblemSet, task.ID); err != nil {
+			return "", err
+		}
+		return "The problem set is ready.", nil
+	}}, nil
+}
+
+// problemLabel labels the questions of a problem set A, B, C and so on.
+func problemLabel(i int) string {
+	if i < 26 {
+		return string(rune('A' + i))
+	}
+	return strconv.Itoa(i + 1)
+}
@@ -0,0 +1,117 @@
+package tasks
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/cpjudge/cpjudge/judge"
+	"github.com/cpjudge/cpjudge/models"
+	"github.com/cpjudge/cpjudge/storage"
+	"github.com/gobuffalo/pop"
+	"github.com/pkg/errors"
+)
+
+// generateAnswers replaces the answers of the current test data of the
+// question with the outputs of the main solution.
+func generateAnswers(db *pop.Connection, task *models.Task) (*outcome, error) {
+	question := &models.Question{}
+	if err := db.Find(question, task.QuestionID.UUID); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if question.TestCasesPath == "" {
+		return failure("The question has no test data.", nil), nil
+	}
+	inputs, err := zipStored(storage.Default(), question.TestCasesPath+"/", "inputs/")
+	if err != nil {
+		return nil, err
+	}
+	data, failed, err := MainAnswers(db, question, inputs)
+	if HostError(err) {
+		return failure(err.Error(), nil), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(failed) > 0 {
+		return failure(fmt.Sprintf("Answers were not generated: the main solution failed on %d inputs.", len(failed)), failed), nil
+	}
+	return storeTestData(question, data, "Answers were generated with the main solution."), nil
+}
+
+// judgeReferenceSolutions judges the pending reference solutions of the
+// question.
+func judgeReferenceSolutions(db *pop.Connection, task *models.Task) (*outcome, error) {
+	question := &models.Question{}
+	if err := db.Find(question, task.QuestionID.UUID); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	solutions, err := models.QuestionReferenceSolutions(db, question.ID)
+	if err != nil {
+		return nil, err
+	}
+	pending := models.ReferenceSolutions{}
+	for _, s := range solutions {
+		if s.Status == models.StatusPending {
+			pending = append(pending, s)
+		}
+	}
+	if err := judge.CheckReferenceSolutions(db, question, pending); err != nil {
+		return nil, err
+	}
+	return &outcome{message: fmt.Sprintf("Judged %d reference solutions.", len(pending))}, nil
+}
+
+// judgeSubmissions judges the pending submissions of the contest, such as
+// those of a rejudge, and then recalculates ratings once if verdicts changed
+// since they were applied.
+func judgeSubmissions(db *pop.Connection, task *models.Task) (*outcome, error) {
+	submissions := models.Submissions{}
+	if err := db.Where("contest_id = ? and status = ?", task.ContestID.UUID, models.StatusPending).All(&submissions); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	for i := range submissions {
+		s := &submissions[i]
+		s.Status, s.TestDataVersion = judge.Evaluate(db, s.SubmissionPath, s.QuestionID)
+		if err := db.Update(s); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	return &outcome{store: func(tx *pop.Connection) (string, error) {
+		message := fmt.Sprintf("Judged %d submissions.", len(submissions))
+		recalculated, err := judge.RecalculateOutdatedRatings(tx, task.ContestID.UUID)
+		if recalculated {
+			message += " Ratings have been recalculated."
+		}
+		return message, err
+	}}, nil
+}
+
+// zipStored zips the objects under prefix+dir, naming them by their keys
+// relative to prefix.
+func zipStored(s storage.Storage, prefix, dir string) ([]byte, error) {
+	keys, err := s.List(prefix + dir)
+	if err != nil {
+		return nil, err
+	}
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	for _, key := range keys {
+		b, err := storage.ReadAll(s, key)
+		if err != nil {
+			return nil, err
+		}
+		f, err := zw.Create(strings.TrimPrefix(key, prefix))
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if _, err := f.Write(b); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,139 @@
+// Package tasks runs the work of the web server that takes too long for a
+// request, such as checking uploaded test data, in the background.
+package tasks
+
+import (
+	"log"
+	"time"
+
+	"github.com/cpjudge/cpjudge/judge"
+	"github.com/cpjudge/cpjudge/models"
+	"github.com/gobuffalo/pop"
+	"github.com/pkg/errors"
+)
+
+// Poll is how often the runner looks for queued tasks.
+const Poll = 2 * time.Second
+
+// Run runs queued tasks one at a time until stop is closed.
+func Run(db *pop.Connection, stop <-chan struct{}) {
+	for {
+		ran, err := RunNext(db)
+		if err != nil {
+			log.Printf("tasks: %+v", err)
+		}
+		wait := Poll
+		if ran {
+			wait = 0
+		}
+		select {
+		case <-stop:
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// outcome is what a task did. Tasks that made something save it with store
+// in the transaction completing them, and return the message for the host.
+type outcome struct {
+	failed   bool
+	message  string
+	problems []judge.InvalidInput
+	store    func(tx *pop.Connection) (string, error)
+}
+
+// failure is the outcome of a task that failed for a reason the host can
+// fix.
+func failure(message string, problems []judge.InvalidInput) *outcome {
+	return &outcome{failed: true, message: message, problems: problems}
+}
+
+// RunNext claims the next queued task and runs it. It reports whether there
+// was one.
+func RunNext(db *pop.Connection) (bool, error) {
+	var task *models.Task
+	err := db.Transaction(func(tx *pop.Connection) error {
+		var err error
+		task, err = models.ClaimTask(tx, time.Now())
+		return err
+	})
+	if err != nil || task == nil {
+		return false, err
+	}
+
+	done := make(chan struct{})
+	go renew(db, task, done)
+	// The work happens outside of any transaction: it may take hours.
+	out, err := perform(db, task)
+	close(done)
+	if err == nil {
+		err = db.Transaction(func(tx *pop.Connection) error {
+			return complete(tx, task, out)
+		})
+	}
+	if err != nil {
+		fail := db.Transaction(func(tx *pop.Connection) error {
+			return task.Fail(tx, "The task failed because of an error of the server.", nil)
+		})
+		if fail != nil {
+			log.Printf("tasks: %+v", fail)
+		}
+		return true, errors.Wrapf(err, "task %s (%s)", task.ID, task.Kind)
+	}
+	return true, nil
+}
+
+// renew keeps the lease of a running task until done is closed.
+func renew(db *pop.Connection, task *models.Task, done <-chan struct{}) {
+	ticker := time.NewTicker(models.JobLease / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case now := <-ticker.C:
+			if err := task.Renew(db, now); err != nil {
+				log.Printf("tasks: %+v", err)
+			}
+		}
+	}
+}
+
+// perform does the work of a task.
+func perform(db *pop.Connection, task *models.Task) (*outcome, error) {
+	switch task.Kind {
+	case models.TaskTestData:
+		return checkTestData(db, task)
+	case models.TaskAnswers:
+		return generateAnswers(db, task)
+	case models.TaskReferenceSolutions:
+		return judgeReferenceSolutions(db, task)
+	case models.TaskGenerate:
+		return generateTests(db, task)
+	case models.TaskSubmissions:
+		return judgeSubmissions(db, task)
+	case models.TaskProblemSet:
+		return buildProblemSet(db, task)
+	}
+	return nil, errors.Errorf("unknown kind of task %q", task.Kind)
+}
+
+// complete stores what the task made and its outcome.
+func complete(tx *pop.Connection, task *models.Task, out *outcome) error {
+	if out.failed {
+		problems := make([]models.TaskProblem, len(out.problems))
+		for i, p := range out.problems {
+			problems[i] = models.TaskProblem{Name: p.Name, Message: p.Message}
+		}
+		return task.Fail(tx, out.message, problems)
+	}
+	message := out.message
+	if out.store != nil {
+		var err error
+		if message, err = out.store(tx); err != nil {
+			return err
+		}
+	}
+	return task.Succeed(tx, message)
+}
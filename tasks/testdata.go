@@ -0,0 +1,148 @@
+package tasks
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/cpjudge/cpjudge/judge"
+	"github.com/cpjudge/cpjudge/models"
+	"github.com/cpjudge/cpjudge/storage"
+	"github.com/gobuffalo/pop"
+	"github.com/gobuffalo/pop/nulls"
+	"github.com/pkg/errors"
+)
+
+// EnqueueTestData stores uploaded test data and queues it to be checked and
+// made the test data of the question.
+func EnqueueTestData(tx *pop.Connection, question *models.Question, archive []byte) error {
+	task := &models.Task{Kind: models.TaskTestData, QuestionID: nulls.NewUUID(question.ID), Input: models.TaskInputKey()}
+	if err := storage.PutBytes(storage.Default(), task.Input, archive); err != nil {
+		return err
+	}
+	return models.EnqueueTask(tx, task)
+}
+
+// checkTestData runs the validator of the question on uploaded test data
+// and generates missing answers with the main solution.
+func checkTestData(db *pop.Connection, task *models.Task) (*outcome, error) {
+	question := &models.Question{}
+	if err := db.Find(question, task.QuestionID.UUID); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	archive, err := storage.ReadAll(storage.Default(), task.Input)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := ioutil.TempDir("", "cpjudge-task")
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer os.RemoveAll(dir)
+	validator := ""
+	if question.HasValidator() {
+		if validator, err = question.DownloadValidator(dir); err != nil {
+			return nil, err
+		}
+	}
+	data, rejection, err := PrepareTestData(db, question, validator, archive)
+	if err != nil {
+		return nil, err
+	}
+	if rejection != nil {
+		return failure(rejection.Message, rejection.Inputs), nil
+	}
+	what := "The test cases were checked."
+	if judge.MissingAnswers(archive) {
+		what = "Answers were generated with the main solution."
+	}
+	return storeTestData(question, data, what), nil
+}
+
+// storeTestData is the outcome of a task that made test data: it becomes
+// the current version, and the reference solutions are queued to be judged
+// on it.
+func storeTestData(question *models.Question, data []byte, what string) *outcome {
+	return &outcome{store: func(tx *pop.Connection) (string, error) {
+		v, err := question.StoreTestData(tx, data)
+		if err != nil {
+			return "", err
+		}
+		if err := judge.QueueAllReferenceSolutions(tx, question); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s They are test data version %s. Rejudge the question to update existing verdicts.", what, models.ShortChecksum(v.Checksum)), nil
+	}}
+}
+
+// Rejection is why test data was not stored, with the inputs at fault.
+type Rejection struct {
+	Message string
+	Inputs  []judge.InvalidInput
+}
+
+// PrepareTestData runs the validator at validatorPath, if there is one, on
+// the inputs of test data, and generates missing answers with the main
+// solution. It returns the test data to store, or why it was rejected.
+func PrepareTestData(tx *pop.Connection, question *models.Question, validatorPath string, archive []byte) ([]byte, *Rejection, error) {
+	if validatorPath != "" {
+		invalid, err := judge.ValidateTestData(validatorPath, archive)
+		if err != nil {
+			return nil, &Rejection{Message: errors.Cause(err).Error()}, nil
+		}
+		if len(invalid) > 0 {
+			msg := fmt.Sprintf("The test cases were not saved: the validator rejected %d inputs.", len(invalid))
+			return nil, &Rejection{Message: msg, Inputs: invalid}, nil
+		}
+	}
+	if !judge.MissingAnswers(archive) {
+		return archive, nil, nil
+	}
+	generated, failed, err := MainAnswers(tx, question, archive)
+	if HostError(err) {
+		return nil, &Rejection{Message: err.Error()}, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(failed) > 0 {
+		msg := fmt.Sprintf("The test cases were not saved: the main solution failed on %d inputs.", len(failed))
+		return nil, &Rejection{Message: msg, Inputs: failed}, nil
+	}
+	return generated, nil, nil
+}
+
+// ErrNoMainSolution is returned when answers must be generated for a
+// question without a main solution.
+var ErrNoMainSolution = errors.New("The test cases have no answers. Add a main solution to generate them.")
+
+// MainAnswers runs the main solution of the question on the inputs of a
+// test data archive. It returns the archive with answers, or the inputs the
+// solution failed on. Errors the host can fix are ErrNoMainSolution and
+// *judge.CompileError; HostError tells them apart.
+func MainAnswers(tx *pop.Connection, question *models.Question, archive []byte) ([]byte, []judge.InvalidInput, error) {
+	main, err := models.MainSolution(tx, question.ID)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	if main == nil {
+		return nil, nil, ErrNoMainSolution
+	}
+	dir, err := ioutil.TempDir("", "cpjudge-main")
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	defer os.RemoveAll(dir)
+	source, err := main.Download(dir)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	return judge.GenerateAnswers(source, archive, judge.QuestionLimits(question))
+}
+
+// HostError reports whether an error from MainAnswers is one the host can
+// fix.
+func HostError(err error) bool {
+	_, ok := err.(*judge.CompileError)
+	return ok || err == ErrNoMainSolution
+}